@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/agent"
+	"github.com/okto/opensearch-backup-manager/pkg/backup"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/health"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"github.com/okto/opensearch-backup-manager/pkg/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// runAgentCommand agent subcommand: execute backup runs the scheduler
+// dispatches to agent.name, with clusters and storages of the local config,
+// until stopped; returns process exit code
+func runAgentCommand(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "usage: agent (configured by agent section of config)")
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Errorf("Failed to load config: %v", err)
+		return 1
+	}
+	worker, err := agent.NewWorker(cfg.Agent)
+	if err != nil {
+		log.Errorf("Failed to configure agent: %v", err)
+		return 1
+	}
+	logConfig(cfg)
+
+	var maxRetryWait time.Duration
+	if cfg.RetryBudget.MaxWait != "" {
+		if maxRetryWait, err = config.ParseDuration(cfg.RetryBudget.MaxWait); err != nil {
+			log.Errorf("Invalid retry_budget.max_wait: %v", err)
+			return 1
+		}
+	}
+	if cfg.Metrics.Enabled {
+		go metrics.Serve(cfg.Metrics.Listen)
+	}
+
+	osClient, err := opensearch.NewClient(cfg.OpenSearch)
+	if err != nil {
+		log.Errorf("Failed to create OpenSearch client: %v", err)
+		return 1
+	}
+	s3Client, profiles, err := openStorages(cfg)
+	if err != nil {
+		log.Errorf("Failed to create S3 client: %v", err)
+		return 1
+	}
+	checker, err := health.New(cfg.Health)
+	if err != nil {
+		log.Errorf("Failed to configure health checks: %v", err)
+		return 1
+	}
+	checker.Add("opensearch", health.OpenSearch(osClient))
+	checker.Add("s3", health.Storage(s3Client))
+	store, err := state.Open(context.Background(), cfg.State, osClient)
+	if err != nil {
+		log.Errorf("Failed to open state store: %v", err)
+		return 1
+	}
+	if store != nil {
+		defer store.Close()
+	}
+	backupService := backup.NewService(osClient, s3Client, cfg)
+	backupService.UseState(store)
+	for name, profile := range profiles {
+		backupService.AddStorage(name, profile)
+	}
+	for name, cluster := range cfg.Clusters {
+		clusterClient, err := opensearch.NewClient(cluster)
+		if err != nil {
+			log.Errorf("Failed to create OpenSearch client for cluster %s: %v", name, err)
+			return 1
+		}
+		backupService.AddCluster(name, clusterClient)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go backupService.DrainUploads(ctx)
+	go checker.Serve(ctx)
+
+	worker.Serve(ctx, func(ctx context.Context, work agent.Work) error {
+		if work.Type != "backup" {
+			return fmt.Errorf("%w: agents run backup jobs, not %s", errs.ErrInvalidConfig, work.Type)
+		}
+		job, ok := findBackupJob(cfg, work.Index)
+		if !ok {
+			return fmt.Errorf("%w: no backup job %s in agent config", errs.ErrInvalidConfig, work.Index)
+		}
+		overrides, err := work.Overrides()
+		if err != nil {
+			return err
+		}
+
+		ctx = run.WithBudget(run.WithID(ctx, work.RunID), run.NewBudget(cfg.RetryBudget.MaxRetries, maxRetryWait))
+		ctx = run.WithOverrides(ctx, overrides)
+		log.WithField("run_id", work.RunID).Infof("Running dispatched backup job for index: %s", job.Name())
+		err = run.Protect(ctx, "backup of "+job.Name(), func() error {
+			return backupService.Backup(ctx, job)
+		})
+		if err != nil {
+			logRunError(work.RunID, "Backup", job.Name(), err)
+		}
+		return err
+	})
+	return 0
+}
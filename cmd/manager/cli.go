@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// usage commands listed by help and on unknown commands
+const usage = `usage: opensearch-backup-manager [command] [flags]
+
+Commands:
+  serve         run scheduled jobs, admin API and health endpoints (default)
+  backup        run a configured backup job once: -index, -date, -from, -to, -dry-run
+  cleanup       run a configured cleanup job once: -index, -target-index, -dry-run
+  restore       restore a range of daily backups: -index, -from, -to, -target
+  list-backups  list backups of an index found by their manifests: -index, -json
+  run           run a configured job of any -type once
+  hold          list, place or release legal holds
+  agent         run backups dispatched to this agent
+  service       install or uninstall the Windows service
+  help          print this help
+
+"<command> -h" lists the flags of a command. One-shot commands exit 0 on
+success, 1 when the operation fails and 2 on invalid usage.
+`
+
+// runCommand run one-shot command name with args; returns process exit code
+func runCommand(name string, args []string) int {
+	switch name {
+	case "backup", "cleanup":
+		return runJob(name, name, args)
+	case "restore":
+		return runRestore(args)
+	case "list-backups":
+		return runListBackups(args)
+	case "run":
+		return runJobCommand(args)
+	case "hold":
+		return runHoldCommand(args)
+	case "service":
+		return runServiceCommand(args)
+	case "agent":
+		return runAgentCommand(args)
+	case "help":
+		fmt.Print(usage)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage)
+	return 2
+}
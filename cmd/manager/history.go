@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+	"github.com/okto/opensearch-backup-manager/pkg/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// Cross-instance job lock: held for lockTTL, renewed every lockRenew while
+// the run lasts, so a crashed instance blocks the job only briefly
+const (
+	lockTTL   = 2 * time.Minute
+	lockRenew = 30 * time.Second
+)
+
+// lockJob take lock of job in state store so no other instance runs it
+// meanwhile; false while another instance holds it. Without state store, or
+// when the store fails, the run goes ahead unlocked
+func (s *jobScheduler) lockJob(job *scheduledJob) (func(), bool) {
+	if s.store == nil {
+		return func() {}, true
+	}
+	name := state.JobKey(job.info.Type, job.info.Index)
+	ok, err := s.store.Lock(s.ctx, name, s.owner, lockTTL)
+	if err != nil {
+		log.Warnf("Run of %s job for %s is not locked across instances: %v", job.info.Type, job.info.Index, err)
+		return func() {}, true
+	}
+	if !ok {
+		return nil, false
+	}
+
+	ctx, stop := context.WithCancel(s.ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(lockRenew)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if ok, err := s.store.Lock(ctx, name, s.owner, lockTTL); err != nil || !ok {
+				log.Warnf("Failed to renew lock of %s job for %s (taken over: %t): %v", job.info.Type, job.info.Index, err == nil, err)
+			}
+		}
+	}()
+	return func() {
+		stop()
+		<-done
+		if err := s.store.Unlock(context.WithoutCancel(s.ctx), name, s.owner); err != nil {
+			log.Warnf("Failed to release lock of %s job for %s: %v", job.info.Type, job.info.Index, err)
+		}
+	}, true
+}
+
+// recordRun add finished run to history of job, with a watermark when it
+// succeeded, and prune history beyond state.history; failures are logged
+func (s *jobScheduler) recordRun(job *scheduledJob, runID string, started time.Time, err error) {
+	if s.store == nil {
+		return
+	}
+	ctx := context.WithoutCancel(s.ctx)
+	jobKey := state.JobKey(job.info.Type, job.info.Index)
+	record := state.Run{
+		RunID:    runID,
+		Type:     job.info.Type,
+		Index:    job.info.Index,
+		Status:   "success",
+		Started:  started,
+		Finished: s.clock.Now().UTC(),
+		Owner:    s.owner,
+	}
+	if err != nil {
+		record.Status, record.Error, record.ErrorKind = runStatus(err), err.Error(), errs.Kind(err)
+	}
+
+	data, _ := json.Marshal(record)
+	if err := s.store.Put(ctx, state.Runs, jobKey+"/"+runID, data); err != nil {
+		log.WithField("run_id", runID).Warnf("Failed to record run in state store: %v", err)
+		return
+	}
+	if record.Status == "success" {
+		data, _ := json.Marshal(state.Watermark{RunID: runID, Finished: record.Finished})
+		if err := s.store.Put(ctx, state.Watermarks, jobKey, data); err != nil {
+			log.WithField("run_id", runID).Warnf("Failed to record watermark in state store: %v", err)
+		}
+	}
+
+	// Run IDs start with their UTC start time, so keys sort oldest first
+	runs, err := s.store.List(ctx, state.Runs, jobKey+"/")
+	if err != nil || len(runs) <= s.history {
+		return
+	}
+	keys := make([]string, 0, len(runs))
+	for key := range runs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys[:len(keys)-s.history] {
+		if err := s.store.Delete(ctx, state.Runs, key); err != nil {
+			log.Warnf("Failed to prune run history of %s: %v", jobKey, err)
+			return
+		}
+	}
+}
+
+// runStatus status of run finished with err: "success", "skipped" (the job
+// had nothing to do, e.g. no such index) or "failure"
+func runStatus(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, errs.ErrSkipped):
+		return "skipped"
+	}
+	return "failure"
+}
+
+// restoreWatermarks set last success metric of jobs from watermarks of
+// earlier runs, also of other instances
+func (s *jobScheduler) restoreWatermarks(ctx context.Context) {
+	if s.store == nil {
+		return
+	}
+	watermarks, err := s.store.List(ctx, state.Watermarks, "")
+	if err != nil {
+		log.Warnf("Failed to read watermarks: %v", err)
+		return
+	}
+	for _, job := range s.jobs {
+		data, ok := watermarks[state.JobKey(job.info.Type, job.info.Index)]
+		if !ok {
+			continue
+		}
+		var watermark state.Watermark
+		if err := json.Unmarshal(data, &watermark); err != nil {
+			log.Warnf("Ignoring invalid watermark of %s job for %s: %v", job.info.Type, job.info.Index, err)
+			continue
+		}
+		metrics.JobLastSuccess.WithLabelValues(job.info.Type, job.info.Index, job.info.Cluster).Set(float64(watermark.Finished.Unix()))
+	}
+}
+
+// serveHistory finished runs from state store, newest first, filtered by
+// type and index query parameters, at most limit (default 100)
+func (s *jobScheduler) serveHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	prefix := ""
+	if jobType := query.Get("type"); jobType != "" {
+		prefix = jobType + "/"
+		if index := query.Get("index"); index != "" {
+			prefix = state.JobKey(jobType, index) + "/"
+		}
+	}
+	limit := state.DefaultHistory
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			writeJSONError(w, http.StatusBadRequest, errors.New("limit must be a positive number"))
+			return
+		}
+	}
+
+	values, err := s.store.List(r.Context(), state.Runs, prefix)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	runs := make([]state.Run, 0, len(values))
+	for _, data := range values {
+		var record state.Run
+		if json.Unmarshal(data, &record) != nil {
+			continue
+		}
+		if index := query.Get("index"); index != "" && record.Index != index {
+			continue
+		}
+		runs = append(runs, record)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Started.After(runs[j].Started) })
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// backupListing manifest summary printed by list-backups -json
+type backupListing struct {
+	Date      string    `json:"date"`
+	Manifest  string    `json:"manifest"`
+	RunID     string    `json:"run_id"`
+	Format    string    `json:"format"`
+	Documents int       `json:"documents"`
+	Bytes     int64     `json:"bytes"`
+	Objects   int       `json:"objects"`
+	Status    string    `json:"status"` // complete, partial or incomplete
+	Encrypted bool      `json:"encrypted"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// runListBackups list-backups subcommand: print backups of index found by
+// their manifests, oldest first; returns process exit code
+func runListBackups(args []string) int {
+	flags := flag.NewFlagSet("list-backups", flag.ContinueOnError)
+	index := flags.String("index", "", "backed up index name (required)")
+	s3Path := flags.String("s3-path", "", "backup path in bucket (default: s3_path of backup job of index)")
+	storageName := flags.String("storage", "", "storage profile (default: storage of backup job of index, else s3)")
+	cluster := flags.String("cluster", "", "named cluster of fan-out backup job whose s3_path and storage are the defaults")
+	from := flags.String("from", "", "first day listed, YYYY-MM-DD")
+	to := flags.String("to", "", "last day listed, YYYY-MM-DD")
+	asJSON := flags.Bool("json", false, "print one JSON object per backup instead of a table")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *index == "" {
+		flags.Usage()
+		return 2
+	}
+	var fromDate, toDate time.Time
+	for _, bound := range []struct {
+		flag  string
+		value string
+		dst   *time.Time
+	}{{"-from", *from, &fromDate}, {"-to", *to, &toDate}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(manifest.DateLayout, bound.value)
+		if err != nil {
+			log.Errorf("Invalid %s: %v", bound.flag, err)
+			return 2
+		}
+		*bound.dst = t
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Errorf("Failed to load config: %v", err)
+		return 1
+	}
+	backupDefaults(cfg, *index, *cluster, s3Path, storageName)
+	storageConfig, err := cfg.StorageConfig(*storageName)
+	if err != nil {
+		log.Errorf("Invalid -storage: %v", err)
+		return 2
+	}
+	s3Client, err := storage.NewS3Client(storageConfig)
+	if err != nil {
+		log.Errorf("Failed to create S3 client: %v", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	entries, err := manifest.List(ctx, s3Client, *s3Path, *index)
+	if err != nil {
+		log.Errorf("Failed to list backups of %s: %v", *index, err)
+		return 1
+	}
+
+	var listings []backupListing
+	for _, entry := range entries {
+		if (!fromDate.IsZero() && entry.Date.Before(fromDate)) || (!toDate.IsZero() && entry.Date.After(toDate)) {
+			continue
+		}
+		m := entry.Manifest
+		listings = append(listings, backupListing{
+			Date:      entry.Date.Format(manifest.DateLayout),
+			Manifest:  entry.Key,
+			RunID:     m.RunID,
+			Format:    m.Format,
+			Documents: m.Documents,
+			Bytes:     m.Bytes,
+			Objects:   len(m.Objects),
+			Status:    backupStatus(m),
+			Encrypted: m.Encrypted,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, listing := range listings {
+			encoder.Encode(listing)
+		}
+		return 0
+	}
+	if len(listings) == 0 {
+		fmt.Fprintf(os.Stderr, "no backups of %s under %q\n", *index, *s3Path)
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tDOCUMENTS\tSIZE\tSTATUS\tRUN\tMANIFEST")
+	for _, l := range listings {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", l.Date, l.Documents, humanize.Bytes(uint64(l.Bytes)), l.Status, l.RunID, l.Manifest)
+	}
+	w.Flush()
+	return 0
+}
+
+// backupStatus complete, partial or incomplete, as recorded in manifest
+func backupStatus(m manifest.Manifest) string {
+	switch {
+	case m.Partial:
+		return "partial"
+	case m.Incomplete:
+		return "incomplete"
+	}
+	return "complete"
+}
@@ -2,29 +2,68 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
-	"github.com/okto/opensearch-backup-manager/internal/backup"
-	"github.com/okto/opensearch-backup-manager/internal/cleanup"
-	"github.com/okto/opensearch-backup-manager/internal/config"
-	"github.com/okto/opensearch-backup-manager/internal/opensearch"
-	"github.com/okto/opensearch-backup-manager/internal/storage"
+	"github.com/okto/opensearch-backup-manager/pkg/admin"
+	"github.com/okto/opensearch-backup-manager/pkg/agent"
+	"github.com/okto/opensearch-backup-manager/pkg/backup"
+	"github.com/okto/opensearch-backup-manager/pkg/catalog"
+	"github.com/okto/opensearch-backup-manager/pkg/cleanup"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/events"
+	"github.com/okto/opensearch-backup-manager/pkg/firedrill"
+	"github.com/okto/opensearch-backup-manager/pkg/health"
+	"github.com/okto/opensearch-backup-manager/pkg/hold"
+	"github.com/okto/opensearch-backup-manager/pkg/journal"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+	"github.com/okto/opensearch-backup-manager/pkg/notify"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/rpc"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"github.com/okto/opensearch-backup-manager/pkg/state"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+	"github.com/okto/opensearch-backup-manager/pkg/systemd"
 	"github.com/robfig/cron/v3"
 	log "github.com/sirupsen/logrus"
 )
 
+// errorFields log fields classifying job error
+func errorFields(runID string, err error) log.Fields {
+	return log.Fields{
+		"run_id":     runID,
+		"error_kind": errs.Kind(err),
+		"retriable":  errs.Retriable(err),
+	}
+}
+
+// logRunError log error of run of job, as a warning when the run was skipped
+func logRunError(runID, what, job string, err error) {
+	if errors.Is(err, errs.ErrSkipped) {
+		log.WithFields(errorFields(runID, err)).Warnf("%s skipped for %s: %v", what, job, err)
+		return
+	}
+	log.WithFields(errorFields(runID, err)).Errorf("%s failed for %s: %v", what, job, err)
+}
+
 func logConfig(cfg *config.Config) {
 	log.Info("=== Configuration ===")
 
 	// OpenSearch configuration
 	log.WithFields(log.Fields{
-		"addresses": cfg.OpenSearch.Addresses,
-		"username":  cfg.OpenSearch.Username,
-		"password":  cfg.OpenSearch.Password,
-		"cert_path": cfg.OpenSearch.CertPath,
+		"addresses":          cfg.OpenSearch.Addresses,
+		"username":           cfg.OpenSearch.Username,
+		"password":           cfg.OpenSearch.Password,
+		"cert_path":          cfg.OpenSearch.CertPath,
+		"slow_log_threshold": cfg.OpenSearch.SlowLog.Threshold,
+		"rate_limit":         cfg.OpenSearch.RateLimit.RequestsPerSecond,
 	}).Info("OpenSearch configuration")
 
 	// S3/MinIO configuration
@@ -35,15 +74,173 @@ func logConfig(cfg *config.Config) {
 		"bucket":            cfg.S3.Bucket,
 		"region":            cfg.S3.Region,
 		"use_ssl":           cfg.S3.UseSSL,
+		"key_prefix":        cfg.S3.KeyPrefix,
+		"tenants":           cfg.S3.Tenants,
+		"accelerate":        cfg.S3.Accelerate,
+		"ca_cert":           cfg.S3.CACert,
+		"tls_min_version":   cfg.S3.TLSMinVersion,
+		"trace":             cfg.S3.Trace,
+		"upload_verify":     !cfg.S3.SkipUploadVerify,
+		"content_types":     cfg.S3.Objects.ContentTypes,
+		"content_encodings": cfg.S3.Objects.ContentEncodings,
+		"cache_control":     cfg.S3.Objects.CacheControl,
 	}).Info("S3/MinIO configuration")
 
+	// Admin API configuration
+	log.WithFields(log.Fields{
+		"enabled":     cfg.Admin.Enabled,
+		"listen":      cfg.Admin.Listen,
+		"grpc_listen": cfg.Admin.GRPCListen,
+		"tokens":      len(cfg.Admin.Tokens),
+		"agent_lease": cfg.Admin.Agents.Lease,
+		"queue_wait":  cfg.Admin.Agents.QueueWait,
+	}).Info("Admin API configuration")
+
+	// Agent mode configuration
+	log.WithFields(log.Fields{
+		"name":    cfg.Agent.Name,
+		"server":  cfg.Agent.Server,
+		"ca_cert": cfg.Agent.CACert,
+	}).Info("Agent configuration")
+
+	// Journal configuration
+	log.WithFields(log.Fields{
+		"dir":    cfg.Journal.Dir,
+		"resume": cfg.Journal.Resume,
+	}).Info("Journal configuration")
+
+	// State store configuration
+	log.WithFields(log.Fields{
+		"type":    cfg.State.Type,
+		"path":    cfg.State.Path,
+		"index":   cfg.State.Index,
+		"history": cfg.State.History,
+	}).Info("State store configuration")
+
+	// Blackout configuration
+	log.WithFields(log.Fields{
+		"dates":    len(cfg.Blackout.Dates),
+		"file":     cfg.Blackout.File,
+		"timezone": cfg.Blackout.Timezone,
+	}).Info("Blackout configuration")
+
+	// Upload window configuration
+	log.WithFields(log.Fields{
+		"start":     cfg.UploadWindow.Start,
+		"end":       cfg.UploadWindow.End,
+		"timezone":  cfg.UploadWindow.Timezone,
+		"dir":       cfg.UploadWindow.Dir,
+		"max_queue": cfg.UploadWindow.MaxQueue,
+	}).Info("Upload window configuration")
+
+	// Compression pool configuration
+	log.WithFields(log.Fields{
+		"threads": cfg.CompressionPool.Threads,
+		"nice":    cfg.CompressionPool.Nice,
+	}).Info("Compression pool configuration")
+
+	// Legal hold configuration
+	log.WithFields(log.Fields{
+		"dir": cfg.LegalHold.Dir,
+	}).Info("Legal hold configuration")
+
+	// Metrics configuration
+	log.WithFields(log.Fields{
+		"enabled": cfg.Metrics.Enabled,
+		"listen":  cfg.Metrics.Listen,
+	}).Info("Metrics configuration")
+
+	// Health configuration
+	log.WithFields(log.Fields{
+		"listen":   cfg.Health.Listen,
+		"interval": cfg.Health.Interval,
+		"timeout":  cfg.Health.Timeout,
+		"grace":    cfg.Health.Grace,
+	}).Info("Health configuration")
+
+	// Maintenance configuration
+	log.WithFields(log.Fields{
+		"schedule":          cfg.Maintenance.Schedule,
+		"multipart_max_age": cfg.Maintenance.MultipartMaxAge,
+	}).Info("Maintenance configuration")
+
+	// Retry budget configuration
+	log.WithFields(log.Fields{
+		"max_retries": cfg.RetryBudget.MaxRetries,
+		"max_wait":    cfg.RetryBudget.MaxWait,
+	}).Info("Retry budget configuration")
+
+	// Notifications configuration
+	log.WithFields(log.Fields{
+		"channels":   len(cfg.Notifications.Channels),
+		"routes":     len(cfg.Notifications.Routes),
+		"default":    cfg.Notifications.Default,
+		"on_success": cfg.Notifications.OnSuccess,
+	}).Info("Notifications configuration")
+
+	// Storage profiles
+	for name, profile := range cfg.Storages {
+		log.WithFields(log.Fields{
+			"endpoint":   profile.Endpoint,
+			"bucket":     profile.Bucket,
+			"region":     profile.Region,
+			"key_prefix": profile.KeyPrefix,
+			"tenants":    profile.Tenants,
+		}).Infof("Storage profile %s", name)
+	}
+
+	// Clusters of fan-out jobs
+	for name, cluster := range cfg.Clusters {
+		log.WithFields(log.Fields{
+			"addresses": cluster.Addresses,
+			"username":  cluster.Username,
+		}).Infof("Cluster %s", name)
+	}
+
+	// Catalog configuration
+	log.WithFields(log.Fields{
+		"enabled": cfg.Catalog.Enabled,
+		"index":   cfg.Catalog.Index,
+	}).Info("Catalog configuration")
+
+	// Restore configuration
+	log.WithFields(log.Fields{
+		"bulk_size":      cfg.Restore.BulkSize,
+		"identity_file":  cfg.Restore.IdentityFile,
+		"verify_keys":    len(cfg.Restore.VerifyKeys),
+		"allow_unsigned": cfg.Restore.AllowUnsigned,
+		"dead_letter":    cfg.Restore.DeadLetter,
+		"transform":      !cfg.Restore.Transform.Empty(),
+	}).Info("Restore configuration")
+
 	// Cleanup jobs
 	log.Infof("Cleanup jobs configured: %d", len(cfg.CleanupJobs))
 	for i, job := range cfg.CleanupJobs {
 		log.WithFields(log.Fields{
-			"index":          job.IndexName,
-			"retention_days": job.RetentionDays,
-			"schedule":       job.Schedule,
+			"index":               job.IndexName,
+			"mode":                job.Mode,
+			"retention_days":      job.RetentionDays,
+			"retention":           job.Retention,
+			"schedule":            job.Schedule,
+			"slices":              job.Slices,
+			"concurrency":         job.Concurrency,
+			"requests_per_second": job.RequestsPerSecond,
+			"conflicts":           job.Conflicts,
+			"max_retries":         job.MaxRetries,
+			"max_failures":        job.MaxFailures,
+			"max_docs":            job.MaxDocs,
+			"max_size":            job.MaxSize,
+			"max_delete":          job.MaxDeleteRatio,
+			"force":               job.Force,
+			"health_gate":         job.HealthGate.Enabled,
+			"load_gate":           job.LoadGate.Enabled,
+			"refresh":             job.Refresh,
+			"flush":               job.Flush,
+			"trash":               job.Trash.Enabled,
+			"exclude_indices":     job.ExcludeIndices,
+			"missing_index":       job.MissingIndex,
+			"cluster":             job.Cluster,
+			"labels":              job.Labels,
 		}).Infof("Cleanup job #%d", i+1)
 	}
 
@@ -51,13 +248,80 @@ func logConfig(cfg *config.Config) {
 	log.Infof("Backup jobs configured: %d", len(cfg.BackupJobs))
 	for i, job := range cfg.BackupJobs {
 		log.WithFields(log.Fields{
-			"index":            job.IndexName,
-			"schedule":         job.Schedule,
-			"interval_hours":   job.IntervalHours,
-			"s3_path":          job.S3Path,
-			"request_interval": job.RequestInterval,
+			"index":               job.IndexName,
+			"schedule":            job.Schedule,
+			"interval_hours":      job.IntervalHours,
+			"window":              job.Window,
+			"s3_path":             job.S3Path,
+			"storage":             job.Storage,
+			"tenant":              job.Tenant,
+			"request_interval":    job.RequestInterval,
+			"data_delay":          job.DataDelay,
+			"late_overlap":        job.LateOverlap,
+			"page_size":           job.PageSize,
+			"max_docs_per_file":   job.MaxDocsPerFile,
+			"max_period_docs":     job.MaxPeriodDocs,
+			"slices":              job.Slices,
+			"point_in_time":       job.PointInTime.Enabled,
+			"raw_source":          job.RawSource,
+			"format":              job.Format,
+			"extra_formats":       job.ExtraFormats,
+			"filename_template":   job.FilenameTemplate,
+			"dedup":               job.Dedup,
+			"on_error":            job.OnError,
+			"sample_rate":         job.SampleRate,
+			"preference":          job.Preference,
+			"routing":             job.Routing,
+			"query":               job.Query,
+			"query_file":          job.QueryFile,
+			"stored_query":        job.StoredQuery.ID,
+			"docvalue_fields":     job.DocValueFields,
+			"stored_fields":       job.StoredFields,
+			"compression_workers": job.CompressionWorkers,
+			"compression":         job.Compression.Codec,
+			"zstd_dictionary":     job.Compression.Dictionary.Enabled,
+			"chunked":             job.Chunked,
+			"upload_concurrency":  job.UploadConcurrency,
+			"max_disk_usage":      job.MaxDiskUsage,
+			"skip_existing":       job.SkipExisting,
+			"checkpoint":          job.Checkpoint,
+			"keep_on_failure":     job.KeepOnFailure,
+			"stream":              job.Stream,
+			"stream_part_size_mb": job.StreamPartSizeMB,
+			"autotune":            job.Autotune.Enabled,
+			"load_gate":           job.LoadGate.Enabled,
+			"encryption":          job.Encryption.Enabled(),
+			"signing":             job.Signing.Enabled(),
+			"archive_mapping":     job.ArchiveMapping,
+			"purge":               job.Purge.Enabled,
+			"profile":             job.Profile.Enabled,
+			"retention":           job.Retention,
+			"completeness":        job.Completeness.Enabled,
+			"spot_check":          job.SpotCheck.Enabled,
+			"exclude_indices":     job.ExcludeIndices,
+			"missing_index":       job.MissingIndex,
+			"cluster":             job.Cluster,
+			"agent":               job.Agent,
+			"labels":              job.Labels,
 		}).Infof("Backup job #%d", i+1)
 	}
+
+	// Fire drill jobs
+	log.Infof("Fire drill jobs configured: %d", len(cfg.FireDrills))
+	for i, job := range cfg.FireDrills {
+		log.WithFields(log.Fields{
+			"index":           job.IndexName,
+			"schedule":        job.Schedule,
+			"s3_path":         job.S3Path,
+			"storage":         job.Storage,
+			"source_cluster":  job.SourceCluster,
+			"max_age":         job.MaxAge,
+			"scratch_index":   job.ScratchIndex,
+			"scratch_cluster": job.Cluster != nil,
+			"keep_on_failure": job.KeepOnFailure,
+			"labels":          job.Labels,
+		}).Infof("Fire drill job #%d", i+1)
+	}
 }
 
 func main() {
@@ -66,7 +330,41 @@ func main() {
 	})
 	log.SetOutput(os.Stdout)
 	log.SetLevel(log.InfoLevel)
+	if level, err := log.ParseLevel(os.Getenv("LOG_LEVEL")); err == nil {
+		log.SetLevel(level)
+	}
+	if path := os.Getenv("LOG_FILE"); path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("Failed to open log file: %v", err)
+		}
+		log.SetOutput(file)
+	}
+
+	// One-shot commands exit instead of starting scheduler; serve, no command
+	// or flags only (arguments of the Windows service) run the daemon
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if os.Args[1] != "serve" {
+			os.Exit(runCommand(os.Args[1], os.Args[2:]))
+		}
+		if len(os.Args) > 2 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+	}
 
+	// Started by Windows service manager
+	if runAsService(daemon) {
+		return
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	daemon(sigChan)
+}
+
+// daemon run scheduler until stop receives a signal
+func daemon(stop <-chan os.Signal) {
 	log.Info("Starting OpenSearch Backup Manager")
 
 	cfg, err := config.LoadConfig()
@@ -76,6 +374,16 @@ func main() {
 
 	logConfig(cfg)
 
+	var maxRetryWait time.Duration
+	if cfg.RetryBudget.MaxWait != "" {
+		if maxRetryWait, err = config.ParseDuration(cfg.RetryBudget.MaxWait); err != nil {
+			log.Fatalf("Invalid retry_budget.max_wait: %v", err)
+		}
+	}
+	if cfg.Metrics.Enabled {
+		go metrics.Serve(cfg.Metrics.Listen)
+	}
+
 	// Initialize OpenSearch client
 	osClient, err := opensearch.NewClient(cfg.OpenSearch)
 	if err != nil {
@@ -88,76 +396,243 @@ func main() {
 		log.Fatalf("Failed to create S3 client: %v", err)
 	}
 
-	cleanupService := cleanup.NewService(osClient, cfg)
+	// Initialize health checks (nil when disabled)
+	checker, err := health.New(cfg.Health)
+	if err != nil {
+		log.Fatalf("Failed to configure health checks: %v", err)
+	}
+	checker.Add("opensearch", health.OpenSearch(osClient))
+	checker.Add("s3", health.Storage(s3Client))
+
+	// Initialize state store (nil when disabled)
+	store, err := state.Open(context.Background(), cfg.State, osClient)
+	if err != nil {
+		log.Fatalf("Failed to open state store: %v", err)
+	}
+	if store != nil {
+		defer store.Close()
+	}
+
+	// Initialize catalog (nil when disabled)
+	jobCatalog := catalog.New(osClient, cfg.Catalog)
+	if err := jobCatalog.EnsureIndex(context.Background()); err != nil {
+		log.Warnf("Failed to prepare catalog index: %v", err)
+	}
+
+	// Initialize notifier (nil when no channel is configured)
+	notifier, err := notify.New(cfg.Notifications)
+	if err != nil {
+		log.Fatalf("Failed to configure notifications: %v", err)
+	}
+
+	cleanupService := cleanup.NewService(osClient, jobCatalog, cfg)
 	backupService := backup.NewService(osClient, s3Client, cfg)
+	fireDrillService := firedrill.NewService(osClient, s3Client, cfg)
+	backupService.UseState(store)
+	for name, profile := range cfg.Storages {
+		profileClient, err := storage.NewS3Client(profile)
+		if err != nil {
+			log.Fatalf("Failed to create S3 client for storage %s: %v", name, err)
+		}
+		backupService.AddStorage(name, profileClient)
+		fireDrillService.AddStorage(name, profileClient)
+	}
+	for name, cluster := range cfg.Clusters {
+		clusterClient, err := opensearch.NewClient(cluster)
+		if err != nil {
+			log.Fatalf("Failed to create OpenSearch client for cluster %s: %v", name, err)
+		}
+		cleanupService.AddCluster(name, clusterClient)
+		backupService.AddCluster(name, clusterClient)
+	}
+
+	// Runs of jobs with agent wait here until a remote agent claims them
+	dispatcher, err := agent.NewDispatcher(cfg.Admin.Agents)
+	if err != nil {
+		log.Fatalf("Failed to configure agents: %v", err)
+	}
 
 	// Setup cron scheduler
 	c := cron.New()
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// Mutex to prevent concurrent execution of jobs
-	cleanupMutexes := make(map[string]*sync.Mutex)
-	backupMutexes := make(map[string]*sync.Mutex)
+	// runContext context of one job run with its own retry budget
+	runContext := func(parent context.Context, runID string) context.Context {
+		return run.WithBudget(run.WithID(parent, runID), run.NewBudget(cfg.RetryBudget.MaxRetries, maxRetryWait))
+	}
+
+	// Registered jobs, locked per index to prevent concurrent execution
+	hub := events.NewHub()
+	runJournal, err := journal.Open(cfg.Journal.Dir)
+	if err != nil {
+		log.Fatalf("Failed to open run journal: %v", err)
+	}
+	scheduler := newJobScheduler(ctx, c, cfg, hub, runJournal, store)
 
 	// Register cleanup jobs
 	for _, job := range cfg.CleanupJobs {
 		job := job
-		cleanupMutexes[job.IndexName] = &sync.Mutex{}
-		mutex := cleanupMutexes[job.IndexName]
-
-		_, err := c.AddFunc(job.Schedule, func() {
-			// Try to lock mutex
-			if !mutex.TryLock() {
-				log.Warnf("Cleanup job for %s is already running, skipping", job.IndexName)
-				return
+		info := admin.Job{Type: "cleanup", Index: job.Name(), Cluster: job.Cluster, Schedule: job.Schedule, Labels: job.Labels}
+		err := scheduler.schedule(info, scheduler.add(info, func(runCtx context.Context, runID string) error {
+			log.WithField("run_id", runID).Infof("Running cleanup job for index: %s", job.Name())
+			runCtx = runContext(runCtx, runID)
+			err := run.Protect(runCtx, "cleanup of "+job.Name(), func() error {
+				return cleanupService.Cleanup(runCtx, job)
+			})
+			if err != nil {
+				logRunError(runID, "Cleanup", job.Name(), err)
 			}
-			defer mutex.Unlock()
+			notifier.Notify(ctx, notify.NewEvent("cleanup", job.Name(), runID, job.Labels, err))
+			return err
+		}, nil))
+		if err != nil {
+			log.Fatalf("Failed to add cleanup job for %s: %v", job.Name(), err)
+		}
+		log.Infof("Registered cleanup job for %s (schedule: %s, retention: %s)",
+			job.Name(), job.Schedule, job.RetentionPeriod())
+	}
 
-			log.Infof("Running cleanup job for index: %s", job.IndexName)
-			if err := cleanupService.Cleanup(ctx, job); err != nil {
-				log.Errorf("Cleanup failed for %s: %v", job.IndexName, err)
+	for _, job := range cfg.BackupJobs {
+		job := job
+		if job.Agent != "" && !cfg.Admin.Enabled {
+			log.Fatalf("Backup job for %s runs on agent %s, which needs the admin API", job.Name(), job.Agent)
+		}
+		info := admin.Job{Type: "backup", Index: job.Name(), Cluster: job.Cluster, Schedule: job.Schedule, Labels: job.Labels}
+		err := scheduler.schedule(info, scheduler.add(info, func(runCtx context.Context, runID string) error {
+			log.WithField("run_id", runID).Infof("Running backup job for index: %s", job.Name())
+			runCtx = runContext(runCtx, runID)
+			err := run.Protect(runCtx, "backup of "+job.Name(), func() error {
+				if job.Agent != "" {
+					return dispatcher.Run(runCtx, job.Agent, agent.NewWork(runID, "backup", job.Name(), run.OverridesOf(runCtx)))
+				}
+				return backupService.Backup(runCtx, job)
+			})
+			if err != nil {
+				logRunError(runID, "Backup", job.Name(), err)
 			}
-		})
+			notifier.Notify(ctx, notify.NewEvent("backup", job.Name(), runID, job.Labels, err))
+			return err
+		}, func(started time.Time) bool {
+			// A re-run exports the same window only while it is still the target one
+			was, err := backup.TargetWindow(job, started)
+			if err != nil {
+				return false
+			}
+			now, err := backup.TargetWindow(job, time.Now())
+			return err == nil && was.Equal(now)
+		}))
 		if err != nil {
-			log.Fatalf("Failed to add cleanup job for %s: %v", job.IndexName, err)
+			log.Fatalf("Failed to add backup job for %s: %v", job.Name(), err)
 		}
-		log.Infof("Registered cleanup job for %s (schedule: %s, retention: %d days)",
-			job.IndexName, job.Schedule, job.RetentionDays)
+		log.Infof("Registered backup job for %s (schedule: %s, interval: %d hours)",
+			job.Name(), job.Schedule, job.IntervalHours)
 	}
 
-	for _, job := range cfg.BackupJobs {
+	for _, job := range cfg.FireDrills {
 		job := job
-		backupMutexes[job.IndexName] = &sync.Mutex{}
-		mutex := backupMutexes[job.IndexName]
+		info := admin.Job{Type: "fire_drill", Index: job.IndexName, Schedule: job.Schedule, Labels: job.Labels}
+		err := scheduler.schedule(info, scheduler.add(info, func(runCtx context.Context, runID string) error {
+			log.WithField("run_id", runID).Infof("Running fire drill for index: %s", job.IndexName)
+			runCtx = runContext(runCtx, runID)
+			err := run.Protect(runCtx, "fire drill of "+job.IndexName, func() error {
+				_, err := fireDrillService.Drill(runCtx, job)
+				return err
+			})
+			if err != nil {
+				log.WithFields(errorFields(runID, err)).Errorf("Fire drill failed for %s: %v", job.IndexName, err)
+			}
+			notifier.Notify(ctx, notify.NewEvent("fire_drill", job.IndexName, runID, job.Labels, err))
+			return err
+		}, nil))
+		if err != nil {
+			log.Fatalf("Failed to add fire drill job for %s: %v", job.IndexName, err)
+		}
+		log.Infof("Registered fire drill job for %s (schedule: %s)", job.IndexName, job.Schedule)
+	}
 
-		_, err := c.AddFunc(job.Schedule, func() {
-			// Try to lock mutex
-			if !mutex.TryLock() {
-				log.Warnf("Backup job for %s is already running, skipping", job.IndexName)
+	// Register storage maintenance
+	if cfg.Maintenance.Schedule != "" {
+		var maintenanceMutex sync.Mutex
+		info := admin.Job{Type: "maintenance", Index: "maintenance", Schedule: cfg.Maintenance.Schedule}
+		err := scheduler.schedule(info, func() {
+			if !maintenanceMutex.TryLock() {
+				log.Warn("Maintenance is already running, skipping")
 				return
 			}
-			defer mutex.Unlock()
+			defer maintenanceMutex.Unlock()
 
-			log.Infof("Running backup job for index: %s", job.IndexName)
-			if err := backupService.Backup(ctx, job); err != nil {
-				log.Errorf("Backup failed for %s: %v", job.IndexName, err)
+			runID := run.NewID()
+			log.WithField("run_id", runID).Info("Running storage maintenance")
+			runCtx := runContext(ctx, runID)
+			err := run.Protect(runCtx, "maintenance", func() error {
+				return backupService.CollectIncompleteUploads(runCtx, cfg.BackupJobs, cfg.Maintenance)
+			})
+			if err != nil {
+				log.WithFields(errorFields(runID, err)).Errorf("Maintenance failed: %v", err)
 			}
+			notifier.Notify(ctx, notify.NewEvent("maintenance", "maintenance", runID, nil, err))
 		})
 		if err != nil {
-			log.Fatalf("Failed to add backup job for %s: %v", job.IndexName, err)
+			log.Fatalf("Failed to add maintenance job: %v", err)
 		}
-		log.Infof("Registered backup job for %s (schedule: %s, interval: %d hours)",
-			job.IndexName, job.Schedule, job.IntervalHours)
+		log.Infof("Registered maintenance job (schedule: %s)", cfg.Maintenance.Schedule)
 	}
 
+	recoverInterrupted(ctx, runJournal, scheduler, jobCatalog, notifier, cfg.Journal.Resume)
+	scheduler.restoreWatermarks(ctx)
+
 	c.Start()
 	log.Info("Scheduler started")
+	go scheduler.watchDrift(ctx)
+	go scheduler.watchSchedule(ctx)
+	go backupService.DrainUploads(ctx)
+	go checker.Serve(ctx)
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	adminServer, err := admin.New(cfg.Admin, scheduler, hub, hold.New(cfg.LegalHold))
+	if err != nil {
+		log.Fatalf("Failed to configure admin API: %v", err)
+	}
+	if adminServer != nil {
+		dispatcher.Register(adminServer)
+		if store != nil {
+			adminServer.Handle("GET /api/runs", admin.RoleRead, scheduler.serveHistory)
+		}
+		go adminServer.Serve(ctx)
+		if cfg.Admin.GRPCListen != "" {
+			go rpc.New(cfg.Admin.Tokens, scheduler, hub).Serve(ctx, cfg.Admin.GRPCListen)
+		}
+	}
 
-	<-sigChan
+	// Type=notify units: ready once jobs are scheduled
+	if ok, err := systemd.Notify(systemd.Ready); err != nil {
+		log.Warnf("Failed to notify systemd: %v", err)
+	} else if ok {
+		log.Info("Notified systemd readiness")
+	}
+
+	// Watchdog pings only while cron loop answers, so a hung scheduler gets restarted
+	var watchdog <-chan time.Time
+	if interval := systemd.WatchdogInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watchdog = ticker.C
+		log.Infof("Systemd watchdog enabled (ping every %v)", interval)
+	}
+
+loop:
+	for {
+		select {
+		case <-stop:
+			break loop
+		case <-watchdog:
+			c.Entries()
+			if _, err := systemd.Notify(systemd.Watchdog); err != nil {
+				log.Warnf("Failed to ping systemd watchdog: %v", err)
+			}
+		}
+	}
 	log.Info("Shutting down...")
+	systemd.Notify(systemd.Stopping)
 
 	cancel()
 	c.Stop()
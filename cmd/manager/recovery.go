@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/catalog"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/journal"
+	"github.com/okto/opensearch-backup-manager/pkg/notify"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+// recoverInterrupted flag runs left in journal by a crash as interrupted in
+// logs, catalog and notifications; with resume, re-run those whose job would
+// do the same work now
+func recoverInterrupted(ctx context.Context, j *journal.Journal, scheduler *jobScheduler, jobCatalog *catalog.Catalog, notifier *notify.Notifier, resume bool) {
+	entries, err := j.Interrupted()
+	if err != nil {
+		log.Warnf("Failed to read run journal: %v", err)
+		return
+	}
+
+	for _, entry := range entries {
+		err := fmt.Errorf("%w: %s run of %s started at %s (pid %d) did not finish",
+			errs.ErrInterrupted, entry.Type, entry.Index, entry.Started.Format(time.RFC3339), entry.PID)
+		log.WithFields(errorFields(entry.RunID, err)).Warnf("Found interrupted run: %v", err)
+
+		job := scheduler.find(entry.Type, entry.Index, entry.Schedule)
+		var labels map[string]string
+		if job != nil {
+			labels = job.info.Labels
+		}
+
+		if recordErr := jobCatalog.RecordRun(ctx, catalog.RunRecord{
+			Timestamp: scheduler.clock.Now().UTC(),
+			RunID:     entry.RunID,
+			Job:       entry.Index,
+			JobType:   entry.Type,
+			Status:    "interrupted",
+			Error:     err.Error(),
+			Started:   entry.Started,
+		}); recordErr != nil {
+			log.Warnf("Failed to record interrupted run %s in catalog: %v", entry.RunID, recordErr)
+		}
+		notifier.Notify(ctx, notify.NewEvent(entry.Type, entry.Index, entry.RunID, labels, err))
+
+		if err := j.Complete(entry.RunID); err != nil {
+			log.Warnf("Failed to clear journal entry of %s: %v", entry.RunID, err)
+		}
+
+		if !resume || job == nil || job.resumable == nil {
+			continue
+		}
+		if entry.Overridden {
+			log.Infof("Not resuming %s run of %s: it was a manual run with overrides", entry.Type, entry.Index)
+			continue
+		}
+		if !job.resumable(entry.Started) {
+			log.Infof("Not resuming %s run of %s: its window is no longer current", entry.Type, entry.Index)
+			continue
+		}
+		runID, err := scheduler.start(job, run.Overrides{})
+		if err != nil {
+			log.Warnf("Failed to resume %s run of %s: %v", entry.Type, entry.Index, err)
+			continue
+		}
+		log.WithField("run_id", runID).Infof("Resuming interrupted %s run %s of %s", entry.Type, entry.RunID, entry.Index)
+	}
+}
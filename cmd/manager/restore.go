@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/restore"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// runRestore restore subcommand: restore range of daily backups and exit,
+// returns process exit code
+func runRestore(args []string) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	index := flags.String("index", "", "backed up index name (required)")
+	s3Path := flags.String("s3-path", "", "backup path in bucket (default: s3_path of backup job of index)")
+	storageName := flags.String("storage", "", "storage profile (default: storage of backup job of index, else s3)")
+	cluster := flags.String("cluster", "", "named cluster of fan-out backup job whose s3_path and storage are the defaults")
+	from := flags.String("from", "", "first day to restore, YYYY-MM-DD (required)")
+	to := flags.String("to", "", "last day to restore, YYYY-MM-DD (default: from)")
+	target := flags.String("target", "", "target index (default: original index of documents)")
+	partitionPattern := flags.String("partition", "", "daily target indices by @timestamp, Go layout in braces (e.g. logs-{2006.01.02}); instead of -target")
+	concurrency := flags.Int("concurrency", 1, "days restored in parallel")
+	deadLetterPath := flags.String("dead-letter", "", "NDJSON file rejected documents are appended to (default: restore.dead_letter)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *index == "" || *from == "" {
+		flags.Usage()
+		return 2
+	}
+	if *to == "" {
+		*to = *from
+	}
+	var partition *restore.Partition
+	if *partitionPattern != "" {
+		if *target != "" {
+			log.Errorf("-partition and -target exclude each other")
+			return 2
+		}
+		var err error
+		if partition, err = restore.NewPartition(*partitionPattern); err != nil {
+			log.Errorf("Invalid -partition: %v", err)
+			return 2
+		}
+	}
+	fromDate, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		log.Errorf("Invalid -from: %v", err)
+		return 2
+	}
+	toDate, err := time.Parse("2006-01-02", *to)
+	if err != nil {
+		log.Errorf("Invalid -to: %v", err)
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Errorf("Failed to load config: %v", err)
+		return 1
+	}
+	backupDefaults(cfg, *index, *cluster, s3Path, storageName)
+	storageConfig, err := cfg.StorageConfig(*storageName)
+	if err != nil {
+		log.Errorf("Invalid -storage: %v", err)
+		return 2
+	}
+
+	osClient, err := opensearch.NewClient(cfg.OpenSearch)
+	if err != nil {
+		log.Errorf("Failed to create OpenSearch client: %v", err)
+		return 1
+	}
+	s3Client, err := storage.NewS3Client(storageConfig)
+	if err != nil {
+		log.Errorf("Failed to create S3 client: %v", err)
+		return 1
+	}
+
+	if *deadLetterPath == "" {
+		*deadLetterPath = cfg.Restore.DeadLetter
+	}
+	deadLetter, err := restore.OpenDeadLetter(*deadLetterPath)
+	if err != nil {
+		log.Errorf("Invalid -dead-letter: %v", err)
+		return 1
+	}
+	defer deadLetter.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runID := run.NewID()
+	result, err := restore.NewService(osClient, s3Client, cfg).RestoreRange(run.WithID(ctx, runID), restore.RangeRequest{
+		Index:       *index,
+		S3Path:      *s3Path,
+		From:        fromDate,
+		To:          toDate,
+		TargetIndex: *target,
+		Concurrency: *concurrency,
+		DeadLetter:  deadLetter,
+		Partition:   partition,
+	})
+	fmt.Fprintf(os.Stderr, "restored %d documents from %d days (%d documents and %d days failed)\n",
+		result.Documents, result.Days, result.Failed, result.FailedDays)
+	if result.DeadLettered > 0 {
+		fmt.Fprintf(os.Stderr, "%d rejected documents written to %s\n", result.DeadLettered, deadLetter.Path())
+	}
+	if err != nil {
+		log.WithFields(errorFields(runID, err)).Errorf("Restore failed for %s: %v", *index, err)
+		return 1
+	}
+	return 0
+}
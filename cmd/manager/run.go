@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/backup"
+	"github.com/okto/opensearch-backup-manager/pkg/catalog"
+	"github.com/okto/opensearch-backup-manager/pkg/cleanup"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/firedrill"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// runJobCommand run subcommand: run one configured job once, optionally with
+// overrides, and exit; returns process exit code
+func runJobCommand(args []string) int {
+	return runJob("run", "", args)
+}
+
+// runJob run one configured job of jobType once under subcommand name, job
+// type taken from -type when empty; returns process exit code
+func runJob(name, jobType string, args []string) int {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	if jobType == "" {
+		flags.StringVar(&jobType, "type", "", "job type, backup, cleanup or fire_drill (required)")
+	}
+	index := flags.String("index", "", "index_name of configured job, <cluster>:<index_name> for fan-out jobs (required)")
+	date := flags.String("date", "", "backup the window containing this day, or fire drill the backup of this day, YYYY-MM-DD")
+	from := flags.String("from", "", "backup only documents from this time, RFC 3339 or YYYY-MM-DD")
+	to := flags.String("to", "", "backup only documents until this time (exclusive), RFC 3339 or YYYY-MM-DD")
+	targetIndex := flags.String("target-index", "", "index or pattern to run on instead of the configured one")
+	dryRun := flags.Bool("dry-run", false, "log what would be exported or deleted without changing anything")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if jobType == "" || *index == "" {
+		flags.Usage()
+		return 2
+	}
+	overrides, err := run.ParseOverrides(*date, *from, *to, *targetIndex, *dryRun)
+	if err == nil {
+		err = checkOverrides(jobType, overrides)
+	}
+	if err != nil {
+		log.Errorf("Invalid overrides: %v", err)
+		return 2
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Errorf("Failed to load config: %v", err)
+		return 1
+	}
+
+	var maxRetryWait time.Duration
+	if cfg.RetryBudget.MaxWait != "" {
+		if maxRetryWait, err = config.ParseDuration(cfg.RetryBudget.MaxWait); err != nil {
+			log.Errorf("Invalid retry_budget.max_wait: %v", err)
+			return 1
+		}
+	}
+
+	osClient, err := opensearch.NewClient(cfg.OpenSearch)
+	if err != nil {
+		log.Errorf("Failed to create OpenSearch client: %v", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runID := run.NewID()
+	ctx = run.WithBudget(run.WithID(ctx, runID), run.NewBudget(cfg.RetryBudget.MaxRetries, maxRetryWait))
+	ctx = run.WithOverrides(ctx, overrides)
+
+	var execute func() error
+	switch jobType {
+	case "cleanup":
+		job, ok := findCleanupJob(cfg, *index)
+		if !ok {
+			log.Errorf("No cleanup job for index %s in config", *index)
+			return 2
+		}
+		cleanupService := cleanup.NewService(osClient, catalog.New(osClient, cfg.Catalog), cfg)
+		if job.Cluster != "" {
+			clusterClient, err := opensearch.NewClient(cfg.Clusters[job.Cluster])
+			if err != nil {
+				log.Errorf("Failed to create OpenSearch client for cluster %s: %v", job.Cluster, err)
+				return 1
+			}
+			cleanupService.AddCluster(job.Cluster, clusterClient)
+		}
+		execute = func() error { return cleanupService.Cleanup(ctx, job) }
+	case "backup":
+		job, ok := findBackupJob(cfg, *index)
+		if !ok {
+			log.Errorf("No backup job for index %s in config", *index)
+			return 2
+		}
+		s3Client, profiles, err := openStorages(cfg)
+		if err != nil {
+			log.Errorf("Failed to create S3 client: %v", err)
+			return 1
+		}
+		backupService := backup.NewService(osClient, s3Client, cfg)
+		for name, profile := range profiles {
+			backupService.AddStorage(name, profile)
+		}
+		if job.Cluster != "" {
+			clusterClient, err := opensearch.NewClient(cfg.Clusters[job.Cluster])
+			if err != nil {
+				log.Errorf("Failed to create OpenSearch client for cluster %s: %v", job.Cluster, err)
+				return 1
+			}
+			backupService.AddCluster(job.Cluster, clusterClient)
+		}
+		execute = func() error { return backupService.Backup(ctx, job) }
+	case "fire_drill":
+		job, ok := findFireDrillJob(cfg, *index)
+		if !ok {
+			log.Errorf("No fire drill job for index %s in config", *index)
+			return 2
+		}
+		s3Client, profiles, err := openStorages(cfg)
+		if err != nil {
+			log.Errorf("Failed to create S3 client: %v", err)
+			return 1
+		}
+		fireDrillService := firedrill.NewService(osClient, s3Client, cfg)
+		for name, profile := range profiles {
+			fireDrillService.AddStorage(name, profile)
+		}
+		execute = func() error {
+			_, err := fireDrillService.Drill(ctx, job)
+			return err
+		}
+	default:
+		log.Errorf("Invalid -type %q: must be backup, cleanup or fire_drill", jobType)
+		return 2
+	}
+
+	log.WithField("run_id", runID).Infof("Running %s job for index %s once (overrides: %+v)", jobType, *index, overrides)
+	err = run.Protect(ctx, jobType+" of "+*index, execute)
+	if errors.Is(err, errs.ErrSkipped) {
+		log.WithFields(errorFields(runID, err)).Warnf("Manual %s skipped for %s: %v", jobType, *index, err)
+		fmt.Fprintf(os.Stderr, "%s of %s skipped (run %s)\n", jobType, *index, runID)
+		return 0
+	}
+	if err != nil {
+		log.WithFields(errorFields(runID, err)).Errorf("Manual %s failed for %s: %v", jobType, *index, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%s of %s finished (run %s)\n", jobType, *index, runID)
+	return 0
+}
+
+// openStorages S3 client of s3 section and of every storage profile
+func openStorages(cfg *config.Config) (storage.Backend, map[string]storage.Backend, error) {
+	s3Client, err := storage.NewS3Client(cfg.S3)
+	if err != nil {
+		return nil, nil, err
+	}
+	profiles := make(map[string]storage.Backend, len(cfg.Storages))
+	for name, profile := range cfg.Storages {
+		profileClient, err := storage.NewS3Client(profile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("storage %s: %w", name, err)
+		}
+		profiles[name] = profileClient
+	}
+	return s3Client, profiles, nil
+}
+
+// findCleanupJob first cleanup job named index
+func findCleanupJob(cfg *config.Config, index string) (config.CleanupJob, bool) {
+	for _, job := range cfg.CleanupJobs {
+		if job.Name() == index {
+			return job, true
+		}
+	}
+	return config.CleanupJob{}, false
+}
+
+// findBackupJob first backup job named index
+func findBackupJob(cfg *config.Config, index string) (config.BackupJob, bool) {
+	for _, job := range cfg.BackupJobs {
+		if job.Name() == index {
+			return job, true
+		}
+	}
+	return config.BackupJob{}, false
+}
+
+// backupDefaults fill empty s3Path and storageName from the backup job of index
+// on cluster, the job that wrote its backups
+func backupDefaults(cfg *config.Config, index, cluster string, s3Path, storageName *string) {
+	for _, job := range cfg.BackupJobs {
+		if job.IndexName == index && job.Cluster == cluster {
+			if *s3Path == "" {
+				*s3Path = job.S3Path
+			}
+			if *storageName == "" {
+				*storageName = job.Storage
+			}
+			return
+		}
+	}
+}
+
+// findFireDrillJob first fire drill job of index
+func findFireDrillJob(cfg *config.Config, index string) (config.FireDrillJob, bool) {
+	for _, job := range cfg.FireDrills {
+		if job.IndexName == index {
+			return job, true
+		}
+	}
+	return config.FireDrillJob{}, false
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/admin"
+	"github.com/okto/opensearch-backup-manager/pkg/clock"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/events"
+	"github.com/okto/opensearch-backup-manager/pkg/journal"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"github.com/okto/opensearch-backup-manager/pkg/state"
+	"github.com/robfig/cron/v3"
+	log "github.com/sirupsen/logrus"
+)
+
+// scheduledJob job registered in cron, also runnable through admin API
+type scheduledJob struct {
+	info  admin.Job
+	mutex *sync.Mutex // shared by jobs of the same type and index
+	run   func(ctx context.Context, runID string) error
+
+	// resumable check if run of job interrupted after starting at started
+	// would do the same work now, nil when interrupted runs are never resumed
+	resumable func(started time.Time) bool
+}
+
+// jobScheduler registered jobs, prevents concurrent runs of the same index
+// (across instances sharing a state store) and publishes their lifecycle events
+type jobScheduler struct {
+	ctx      context.Context
+	running  *config.Config
+	loadedAt time.Time
+
+	cron    *cron.Cron
+	entries []cronEntry
+	jobs    []*scheduledJob
+	mutexes map[string]*sync.Mutex
+	hub     *events.Hub
+	journal *journal.Journal
+	clock   clock.Clock // start and finish times of runs
+
+	store   state.Store // nil without state store
+	owner   string      // lock owner of this instance
+	history int         // finished runs kept per job
+}
+
+// cronEntry job (or maintenance) registered in cron
+type cronEntry struct {
+	info admin.Job
+	id   cron.EntryID
+}
+
+func newJobScheduler(ctx context.Context, c *cron.Cron, running *config.Config, hub *events.Hub, j *journal.Journal, store state.Store) *jobScheduler {
+	history := running.State.History
+	if history <= 0 {
+		history = state.DefaultHistory
+	}
+	return &jobScheduler{
+		ctx:      ctx,
+		running:  running,
+		loadedAt: time.Now().UTC(),
+		cron:     c,
+		mutexes:  make(map[string]*sync.Mutex),
+		hub:      hub,
+		journal:  j,
+		clock:    clock.Real{},
+		store:    store,
+		owner:    state.Owner(),
+		history:  history,
+	}
+}
+
+// schedule register fn in cron under schedule of info
+func (s *jobScheduler) schedule(info admin.Job, fn func()) error {
+	id, err := s.cron.AddFunc(info.Schedule, fn)
+	if err != nil {
+		return err
+	}
+	s.entries = append(s.entries, cronEntry{info: info, id: id})
+	return nil
+}
+
+// add register job, returned function is its cron entry
+func (s *jobScheduler) add(info admin.Job, runJob func(ctx context.Context, runID string) error, resumable func(started time.Time) bool) func() {
+	key := info.Type + "/" + info.Index
+	if s.mutexes[key] == nil {
+		s.mutexes[key] = &sync.Mutex{}
+	}
+	job := &scheduledJob{info: info, mutex: s.mutexes[key], run: runJob, resumable: resumable}
+	s.jobs = append(s.jobs, job)
+
+	return func() {
+		// Try to lock mutex
+		if !job.mutex.TryLock() {
+			log.Warnf("The %s job for %s is already running, skipping", info.Type, info.Index)
+			return
+		}
+		defer job.mutex.Unlock()
+		release, ok := s.lockJob(job)
+		if !ok {
+			log.Warnf("The %s job for %s is running on another instance, skipping", info.Type, info.Index)
+			return
+		}
+		defer release()
+		runID := run.NewID()
+		s.hub.Publish(s.event(events.Queued, job, runID))
+		s.execute(job, runID, run.Overrides{})
+	}
+}
+
+// event lifecycle event of job run
+func (s *jobScheduler) event(kind string, job *scheduledJob, runID string) events.Event {
+	return events.Event{Kind: kind, Type: job.info.Type, Index: job.info.Index, RunID: runID}
+}
+
+// execute run job, publishing its start, progress and outcome; the journal
+// entry lives exactly as long as the run
+func (s *jobScheduler) execute(job *scheduledJob, runID string, overrides run.Overrides) {
+	started := s.clock.Now().UTC()
+	entry := journal.Entry{RunID: runID, Type: job.info.Type, Index: job.info.Index, Schedule: job.info.Schedule, Started: started, Overridden: !overrides.Empty()}
+	if err := s.journal.Begin(entry); err != nil {
+		log.WithField("run_id", runID).Warnf("Run of %s is not journaled: %v", job.info.Index, err)
+	}
+	defer func() {
+		if err := s.journal.Complete(runID); err != nil {
+			log.WithField("run_id", runID).Warnf("Failed to complete journal entry: %v", err)
+		}
+	}()
+
+	s.hub.Publish(s.event(events.Started, job, runID))
+	ctx := events.WithRun(s.ctx, s.hub, s.event("", job, runID))
+	if !overrides.Empty() {
+		ctx = run.WithOverrides(ctx, overrides)
+		log.WithField("run_id", runID).Infof("Manual %s run of %s with overrides: %+v", job.info.Type, job.info.Index, overrides)
+	}
+	ev := s.event(events.Finished, job, runID)
+	// Backstop for panics outside job services, e.g. in notifications
+	err := run.Protect(ctx, job.info.Type+" of "+job.info.Index, func() error { return job.run(ctx, runID) })
+	status := runStatus(err)
+	switch status {
+	case "skipped":
+		ev.Kind, ev.Error, ev.ErrorKind = events.Skipped, err.Error(), errs.Kind(err)
+	case "failure":
+		ev.Kind, ev.Error, ev.ErrorKind = events.Failed, err.Error(), errs.Kind(err)
+	default:
+		metrics.JobLastSuccess.WithLabelValues(job.info.Type, job.info.Index, job.info.Cluster).SetToCurrentTime()
+	}
+	metrics.JobRuns.WithLabelValues(job.info.Type, job.info.Index, job.info.Cluster, status).Inc()
+	s.recordRun(job, runID, started, err)
+	s.hub.Publish(ev)
+}
+
+// Jobs configured jobs
+func (s *jobScheduler) Jobs() []admin.Job {
+	jobs := make([]admin.Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job.info)
+	}
+	return jobs
+}
+
+// Trigger start first job of type and index in background
+func (s *jobScheduler) Trigger(jobType, index string, overrides run.Overrides) (string, error) {
+	job := s.find(jobType, index, "")
+	if job == nil {
+		return "", fmt.Errorf("%w: %s %s", admin.ErrUnknownJob, jobType, index)
+	}
+	if err := checkOverrides(jobType, overrides); err != nil {
+		return "", err
+	}
+	return s.start(job, overrides)
+}
+
+// checkOverrides reject overrides the job type cannot apply
+func checkOverrides(jobType string, overrides run.Overrides) error {
+	switch {
+	case jobType == "cleanup" && (!overrides.Date.IsZero() || overrides.Ranged()):
+		return fmt.Errorf("%w: cleanup takes only index and dry_run overrides", errs.ErrInvalidConfig)
+	case jobType == "fire_drill" && (overrides.Ranged() || overrides.Index != "" || overrides.DryRun):
+		return fmt.Errorf("%w: fire drill takes only a date override", errs.ErrInvalidConfig)
+	}
+	return nil
+}
+
+// find first job of type and index, with schedule unless empty
+func (s *jobScheduler) find(jobType, index, schedule string) *scheduledJob {
+	for _, job := range s.jobs {
+		if job.info.Type == jobType && job.info.Index == index && (schedule == "" || job.info.Schedule == schedule) {
+			return job
+		}
+	}
+	return nil
+}
+
+// start run job in background unless it is already running
+func (s *jobScheduler) start(job *scheduledJob, overrides run.Overrides) (string, error) {
+	if !job.mutex.TryLock() {
+		return "", fmt.Errorf("%w: %s %s", admin.ErrBusy, job.info.Type, job.info.Index)
+	}
+	release, ok := s.lockJob(job)
+	if !ok {
+		job.mutex.Unlock()
+		return "", fmt.Errorf("%w: %s %s on another instance", admin.ErrBusy, job.info.Type, job.info.Index)
+	}
+	runID := run.NewID()
+	s.hub.Publish(s.event(events.Queued, job, runID))
+	go func() {
+		defer job.mutex.Unlock()
+		defer release()
+		s.execute(job, runID, overrides)
+	}()
+	return runID, nil
+}
+
+// Schedule registered cron entries with fire times, soonest next run first
+func (s *jobScheduler) Schedule() []admin.Entry {
+	fired := make(map[cron.EntryID]cron.Entry, len(s.entries))
+	for _, e := range s.cron.Entries() {
+		fired[e.ID] = e
+	}
+
+	entries := make([]admin.Entry, 0, len(s.entries))
+	for _, registered := range s.entries {
+		entry := admin.Entry{Job: registered.info}
+		if e, ok := fired[registered.id]; ok {
+			if !e.Next.IsZero() {
+				next := e.Next.UTC()
+				entry.Next = &next
+			}
+			if !e.Prev.IsZero() {
+				prev := e.Prev.UTC()
+				entry.Prev = &prev
+			}
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].Next, entries[j].Next
+		return a != nil && (b == nil || a.Before(*b))
+	})
+	return entries
+}
+
+// Schedule reporting intervals: metrics follow every fire, the log line is a
+// periodic reminder of what runs next
+const (
+	scheduleMetricsInterval = time.Minute
+	scheduleLogInterval     = time.Hour
+)
+
+// watchSchedule export fire times of cron entries and periodically log the
+// upcoming runs until ctx is done
+func (s *jobScheduler) watchSchedule(ctx context.Context) {
+	metricsTicker := time.NewTicker(scheduleMetricsInterval)
+	defer metricsTicker.Stop()
+	logTicker := time.NewTicker(scheduleLogInterval)
+	defer logTicker.Stop()
+
+	entries := s.exportSchedule()
+	logSchedule(entries)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-metricsTicker.C:
+			s.exportSchedule()
+		case <-logTicker.C:
+			logSchedule(s.exportSchedule())
+		}
+	}
+}
+
+// exportSchedule set schedule metrics, returns the exported entries
+func (s *jobScheduler) exportSchedule() []admin.Entry {
+	entries := s.Schedule()
+	for _, e := range entries {
+		var next, prev float64
+		if e.Next != nil {
+			next = float64(e.Next.Unix())
+		}
+		if e.Prev != nil {
+			prev = float64(e.Prev.Unix())
+		}
+		metrics.ScheduleNextRun.WithLabelValues(e.Type, e.Index, e.Schedule).Set(next)
+		metrics.SchedulePreviousRun.WithLabelValues(e.Type, e.Index, e.Schedule).Set(prev)
+	}
+	return entries
+}
+
+// logSchedule one line listing next run of every entry
+func logSchedule(entries []admin.Entry) {
+	if len(entries) == 0 {
+		return
+	}
+	now := time.Now()
+	parts := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.Next == nil {
+			parts = append(parts, fmt.Sprintf("%s/%s never", e.Type, e.Index))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s/%s at %s (in %v)", e.Type, e.Index,
+			e.Next.Format(time.RFC3339), e.Next.Sub(now).Round(time.Minute)))
+	}
+	log.Infof("Next scheduled runs: %s", strings.Join(parts, ", "))
+}
+
+// driftCheckInterval how often configuration file is compared with running jobs
+const driftCheckInterval = 5 * time.Minute
+
+// Drift compare configuration file with configuration jobs were registered from
+func (s *jobScheduler) Drift() (admin.DriftReport, error) {
+	report := admin.DriftReport{Path: config.ConfigPath(), LoadedAt: s.loadedAt}
+	if info, err := os.Stat(report.Path); err == nil {
+		report.ModifiedAt = info.ModTime().UTC()
+	}
+
+	current, err := config.LoadConfig()
+	if err != nil {
+		return report, err
+	}
+	report.Drift = config.Diff(s.running, current)
+	report.Drifted = !report.Drift.Empty()
+	return report, nil
+}
+
+// watchDrift periodically log and export drift of configuration file until ctx is done
+func (s *jobScheduler) watchDrift(ctx context.Context) {
+	ticker := time.NewTicker(driftCheckInterval)
+	defer ticker.Stop()
+
+	var reported string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := s.Drift()
+		if err != nil {
+			log.Warnf("Failed to check configuration drift: %v", err)
+			continue
+		}
+		if !report.Drifted {
+			metrics.ConfigDrift.Set(0)
+			reported = ""
+			continue
+		}
+		metrics.ConfigDrift.Set(1)
+		if summary := report.Drift.String(); summary != reported {
+			log.Warnf("Configuration file %s changed since it was loaded at %s and is not applied until restart (%s)",
+				report.Path, report.LoadedAt.Format(time.RFC3339), summary)
+			reported = summary
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runAsService Windows service mode is never used outside of Windows
+func runAsService(daemon func(stop <-chan os.Signal)) bool {
+	return false
+}
+
+// runServiceCommand service subcommand is Windows only
+func runServiceCommand(args []string) int {
+	log.Error("The service subcommand registers a Windows service and is only available on Windows")
+	return 2
+}
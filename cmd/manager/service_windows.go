@@ -0,0 +1,149 @@
+//go:build windows
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceName name of Windows service
+const serviceName = "OpenSearchBackupManager"
+
+// runAsService run daemon under Windows service manager, false when
+// started from console
+func runAsService(daemon func(stop <-chan os.Signal)) bool {
+	inService, err := svc.IsWindowsService()
+	if err != nil || !inService {
+		return false
+	}
+
+	// Service arguments stored by "service install"
+	flags := flag.NewFlagSet(serviceName, flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to config.yaml")
+	logFile := flags.String("log-file", "", "append logs to file")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		log.Fatalf("Invalid service arguments: %v", err)
+	}
+	if *configPath != "" {
+		os.Setenv("CONFIG_PATH", *configPath)
+	}
+	if *logFile != "" {
+		file, err := os.OpenFile(*logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			log.Fatalf("Failed to open log file: %v", err)
+		}
+		log.SetOutput(file)
+	}
+
+	if err := svc.Run(serviceName, &windowsService{daemon: daemon}); err != nil {
+		log.Fatalf("Windows service failed: %v", err)
+	}
+	return true
+}
+
+// windowsService svc.Handler stopping daemon on Stop and Shutdown requests
+type windowsService struct {
+	daemon func(stop <-chan os.Signal)
+}
+
+func (s *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	stop := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		s.daemon(stop)
+		close(done)
+	}()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case <-done:
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				status <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				stop <- os.Interrupt
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
+
+// runServiceCommand service subcommand: register or remove Windows service,
+// returns process exit code
+func runServiceCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "install" && args[0] != "uninstall") {
+		fmt.Fprintln(os.Stderr, "usage: service install [-config path] [-log-file path] | service uninstall")
+		return 2
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		log.Errorf("Failed to connect to service manager: %v", err)
+		return 1
+	}
+	defer m.Disconnect()
+
+	if args[0] == "uninstall" {
+		s, err := m.OpenService(serviceName)
+		if err != nil {
+			log.Errorf("Service %s is not installed: %v", serviceName, err)
+			return 1
+		}
+		defer s.Close()
+		if err := s.Delete(); err != nil {
+			log.Errorf("Failed to remove service %s: %v", serviceName, err)
+			return 1
+		}
+		log.Infof("Removed service %s", serviceName)
+		return 0
+	}
+
+	flags := flag.NewFlagSet("service install", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to config.yaml (default: CONFIG_PATH of the service account)")
+	logFile := flags.String("log-file", "", "append logs to file (services have no console)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		log.Errorf("Failed to locate executable: %v", err)
+		return 1
+	}
+	var serviceArgs []string
+	for _, arg := range [][2]string{{"-config", *configPath}, {"-log-file", *logFile}} {
+		if value := arg[1]; value != "" {
+			if abs, err := filepath.Abs(value); err == nil {
+				value = abs
+			}
+			serviceArgs = append(serviceArgs, arg[0], value)
+		}
+	}
+
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "OpenSearch Backup Manager",
+		Description: "Scheduled cleanup and S3 backups of OpenSearch indices",
+		StartType:   mgr.StartAutomatic,
+	}, serviceArgs...)
+	if err != nil {
+		log.Errorf("Failed to create service %s: %v", serviceName, err)
+		return 1
+	}
+	defer s.Close()
+	log.Infof("Installed service %s (%s), start it with: sc start %s", serviceName, exe, serviceName)
+	return 0
+}
@@ -0,0 +1,331 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/events"
+	"github.com/okto/opensearch-backup-manager/pkg/hold"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultListen default address of admin API
+const DefaultListen = ":8080"
+
+// heartbeatInterval comment sent on idle event streams, so proxies keep them open
+const heartbeatInterval = 15 * time.Second
+
+const (
+	RoleRead  = "read"  // status and listings
+	RoleAdmin = "admin" // read plus mutating calls (trigger, legal holds)
+	RoleAgent = "agent" // remote agents claiming dispatched runs, nothing else
+)
+
+// ErrUnknownJob trigger of job that is not configured
+var ErrUnknownJob = errors.New("unknown job")
+
+// ErrBusy trigger of job that is already running
+var ErrBusy = errors.New("job is already running")
+
+// Job configured job as listed by the API
+type Job struct {
+	Type     string            `json:"type"`  // "cleanup", "backup" or "fire_drill"
+	Index    string            `json:"index"` // job name, "<cluster>:<index>" for fan-out jobs
+	Cluster  string            `json:"cluster,omitempty"`
+	Schedule string            `json:"schedule"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// Entry cron entry with its fire times
+type Entry struct {
+	Job
+	Next *time.Time `json:"next"` // nil when schedule never fires again
+	Prev *time.Time `json:"prev"` // nil until first scheduled run since start
+}
+
+// DriftReport configuration file compared with running jobs
+type DriftReport struct {
+	Drifted    bool      `json:"drift"`
+	Path       string    `json:"path"`
+	LoadedAt   time.Time `json:"loaded_at"`
+	ModifiedAt time.Time `json:"modified_at"`
+	config.Drift
+}
+
+// Scheduler jobs managed by the API
+type Scheduler interface {
+	Jobs() []Job
+	// Trigger start job run in background with one-off overrides, returns
+	// its run identifier
+	Trigger(jobType, index string, overrides run.Overrides) (string, error)
+	// Drift compare configuration file on disk with running jobs
+	Drift() (DriftReport, error)
+	// Schedule registered cron entries, soonest next run first
+	Schedule() []Entry
+}
+
+// Tokens static bearer tokens of API clients
+type Tokens []config.AdminToken
+
+// Lookup name and role of token, empty when unknown
+func (t Tokens) Lookup(token string) (string, string) {
+	known := t.find(token)
+	return known.Name, known.Role
+}
+
+// find configured token, zero when unknown
+func (t Tokens) find(token string) config.AdminToken {
+	if token == "" {
+		return config.AdminToken{}
+	}
+	for _, known := range t {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(known.Token)) == 1 {
+			return known
+		}
+	}
+	return config.AdminToken{}
+}
+
+// Allows check if granted role includes required one
+func Allows(granted, required string) bool {
+	return granted == RoleAdmin || granted == required
+}
+
+// Server HTTP admin API; every request needs a bearer token from config,
+// mutating endpoints need a token with admin role
+type Server struct {
+	listen    string
+	tokens    Tokens
+	scheduler Scheduler
+	hub       *events.Hub
+	holds     *hold.Registry
+	mux       *http.ServeMux
+}
+
+// New create admin server, returns nil when API is disabled
+func New(cfg config.AdminConfig, scheduler Scheduler, hub *events.Hub, holds *hold.Registry) (*Server, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if len(cfg.Tokens) == 0 {
+		return nil, fmt.Errorf("%w: admin API needs at least one token", errs.ErrInvalidConfig)
+	}
+	for _, t := range cfg.Tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("%w: admin token %s is empty", errs.ErrInvalidConfig, t.Name)
+		}
+		if t.Role != RoleRead && t.Role != RoleAdmin && t.Role != RoleAgent {
+			return nil, fmt.Errorf("%w: admin token %s: unknown role %q", errs.ErrInvalidConfig, t.Name, t.Role)
+		}
+		if (t.Role == RoleAgent) != (t.Agent != "") {
+			return nil, fmt.Errorf("%w: admin token %s: agent name is required with role agent and only allowed there", errs.ErrInvalidConfig, t.Name)
+		}
+	}
+
+	s := &Server{
+		listen:    cfg.Listen,
+		tokens:    cfg.Tokens,
+		scheduler: scheduler,
+		hub:       hub,
+		holds:     holds,
+		mux:       http.NewServeMux(),
+	}
+	if s.listen == "" {
+		s.listen = DefaultListen
+	}
+
+	s.handle("GET /api/jobs", RoleRead, s.listJobs)
+	s.handle("POST /api/jobs/{type}/{index}/trigger", RoleAdmin, s.triggerJob)
+	s.handle("GET /api/events", RoleRead, s.streamEvents)
+	s.handle("GET /api/config/drift", RoleRead, s.configDrift)
+	s.handle("GET /api/schedule", RoleRead, s.schedule)
+	s.handle("GET /api/holds", RoleRead, s.listHolds)
+	s.handle("POST /api/holds", RoleAdmin, s.placeHold)
+	s.handle("DELETE /api/holds/{id}", RoleAdmin, s.releaseHold)
+	s.handle("GET /api/holds/audit", RoleRead, s.holdAudit)
+	return s, nil
+}
+
+// Handle register endpoint requiring role, for API extensions
+func (s *Server) Handle(pattern, role string, handler http.HandlerFunc) {
+	s.handle(pattern, role, handler)
+}
+
+// ServeHTTP serve request with registered endpoints
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve run API until ctx is cancelled
+func (s *Server) Serve(ctx context.Context) {
+	server := &http.Server{Addr: s.listen, Handler: s.mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Infof("Serving admin API on %s", s.listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Errorf("Admin API failed: %v", err)
+	}
+}
+
+func (s *Server) handle(pattern, role string, handler http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		token := s.authorize(r)
+		name, granted := token.Name, token.Role
+		if name == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		if !Allows(granted, role) {
+			log.Warnf("Admin API: token %s (%s) denied %s %s", name, granted, r.Method, r.URL.Path)
+			writeError(w, http.StatusForbidden, fmt.Sprintf("%s role required", role))
+			return
+		}
+		log.Debugf("Admin API: %s %s by %s", r.Method, r.URL.Path, name)
+		handler(w, r.WithContext(context.WithValue(r.Context(), tokenKey{}, token)))
+	})
+}
+
+// tokenKey context key of authorized token
+type tokenKey struct{}
+
+// tokenName name of token that authorized request
+func tokenName(r *http.Request) string {
+	token, _ := r.Context().Value(tokenKey{}).(config.AdminToken)
+	return token.Name
+}
+
+// TokenAgent agent name the token that authorized request is bound to,
+// empty for tokens of other roles
+func TokenAgent(r *http.Request) string {
+	token, _ := r.Context().Value(tokenKey{}).(config.AdminToken)
+	return token.Agent
+}
+
+// authorize configured token of request bearer token, zero when unknown
+func (s *Server) authorize(r *http.Request) config.AdminToken {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return config.AdminToken{}
+	}
+	return s.tokens.find(token)
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.scheduler.Jobs())
+}
+
+// triggerRequest optional body of trigger, overriding job configuration for that run
+type triggerRequest struct {
+	Date   string `json:"date"` // YYYY-MM-DD
+	From   string `json:"from"` // RFC 3339 time or YYYY-MM-DD
+	To     string `json:"to"`
+	Index  string `json:"index"`
+	DryRun bool   `json:"dry_run"`
+}
+
+func (s *Server) triggerJob(w http.ResponseWriter, r *http.Request) {
+	var req triggerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	overrides, err := run.ParseOverrides(req.Date, req.From, req.To, req.Index, req.DryRun)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	runID, err := s.scheduler.Trigger(r.PathValue("type"), r.PathValue("index"), overrides)
+	switch {
+	case errors.Is(err, errs.ErrInvalidConfig):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrUnknownJob):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrBusy):
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusAccepted, map[string]string{"run_id": runID})
+	}
+}
+
+func (s *Server) configDrift(w http.ResponseWriter, r *http.Request) {
+	report, err := s.scheduler.Drift()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) schedule(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.scheduler.Schedule())
+}
+
+// streamEvents Server-Sent Events of job runs, optionally filtered by
+// type and index query parameters, until client disconnects
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	jobType, index := r.URL.Query().Get("type"), r.URL.Query().Get("index")
+
+	ch, cancel := s.hub.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case ev := <-ch:
+			if (jobType != "" && jobType != ev.Type) || (index != "" && index != ev.Index) {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Kind, data)
+		}
+		flusher.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debugf("Admin API: failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
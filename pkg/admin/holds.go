@@ -0,0 +1,77 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/hold"
+)
+
+// releaseRequest optional body of hold release
+type releaseRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (s *Server) listHolds(w http.ResponseWriter, r *http.Request) {
+	holds, err := s.holds.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if holds == nil {
+		holds = hold.Set{}
+	}
+	writeJSON(w, http.StatusOK, holds)
+}
+
+// placeHold place hold of body on behalf of token name, id and placement
+// fields of body are ignored
+func (s *Server) placeHold(w http.ResponseWriter, r *http.Request) {
+	var req hold.Hold
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	placed, err := s.holds.Place(req, tokenName(r))
+	switch {
+	case errors.Is(err, errs.ErrInvalidConfig):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusCreated, placed)
+	}
+}
+
+func (s *Server) releaseHold(w http.ResponseWriter, r *http.Request) {
+	var req releaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	released, err := s.holds.Release(r.PathValue("id"), tokenName(r), req.Reason)
+	switch {
+	case errors.Is(err, hold.ErrUnknownHold):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, released)
+	}
+}
+
+func (s *Server) holdAudit(w http.ResponseWriter, r *http.Request) {
+	records, err := s.holds.Audit()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if records == nil {
+		records = []hold.AuditRecord{}
+	}
+	writeJSON(w, http.StatusOK, records)
+}
@@ -0,0 +1,114 @@
+// Package agent runs of jobs on remote agents: the scheduler queues them in a
+// Dispatcher served on its admin API, agents claim and execute them with a Worker
+package agent
+
+import (
+	"errors"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+)
+
+const (
+	// DefaultLease claimed runs fail when their agent is silent this long
+	DefaultLease = time.Minute
+	// DefaultQueueWait queued runs fail when no agent claims them this long
+	DefaultQueueWait = time.Hour
+
+	// claimWait longest claim request is held open while nothing is queued
+	claimWait = 30 * time.Second
+	// heartbeatInterval agents report running work this often, well within lease
+	heartbeatInterval = 15 * time.Second
+)
+
+// Work run dispatched to an agent, overrides as accepted by run.ParseOverrides
+type Work struct {
+	RunID       string `json:"run_id"`
+	Type        string `json:"type"`  // "backup"
+	Index       string `json:"index"` // job name, "<cluster>:<index>" for fan-out jobs
+	Date        string `json:"date,omitempty"`
+	From        string `json:"from,omitempty"`
+	To          string `json:"to,omitempty"`
+	TargetIndex string `json:"target_index,omitempty"`
+	DryRun      bool   `json:"dry_run,omitempty"`
+}
+
+// NewWork work of run with its overrides
+func NewWork(runID, jobType, index string, overrides run.Overrides) Work {
+	w := Work{RunID: runID, Type: jobType, Index: index, TargetIndex: overrides.Index, DryRun: overrides.DryRun}
+	if !overrides.Date.IsZero() {
+		w.Date = overrides.Date.Format("2006-01-02")
+	}
+	if overrides.Ranged() {
+		w.From, w.To = overrides.From.Format(time.RFC3339Nano), overrides.To.Format(time.RFC3339Nano)
+	}
+	return w
+}
+
+// Overrides run overrides of work
+func (w Work) Overrides() (run.Overrides, error) {
+	return run.ParseOverrides(w.Date, w.From, w.To, w.TargetIndex, w.DryRun)
+}
+
+// Result outcome of work reported by agent
+type Result struct {
+	Error     string `json:"error,omitempty"`
+	ErrorKind string `json:"error_kind,omitempty"` // errs.Kind of error
+	Retriable bool   `json:"retriable,omitempty"`
+	Skipped   bool   `json:"skipped,omitempty"` // errs.ErrSkipped, the run did nothing on purpose
+}
+
+// resultOf result of run error
+func resultOf(err error) Result {
+	if err == nil {
+		return Result{}
+	}
+	return Result{Error: err.Error(), ErrorKind: errs.Kind(err), Retriable: errs.Retriable(err), Skipped: errors.Is(err, errs.ErrSkipped)}
+}
+
+// kinds sentinel of every errs.Kind
+var kinds = map[string]error{
+	"panic":                  errs.ErrPanic,
+	"retry_budget_exhausted": errs.ErrRetryBudgetExhausted,
+	"invalid_config":         errs.ErrInvalidConfig,
+	"safety_guard":           errs.ErrSafetyGuard,
+	"index_not_found":        errs.ErrIndexNotFound,
+	"cluster_unavailable":    errs.ErrClusterUnavailable,
+	"partial_failure":        errs.ErrPartialFailure,
+	"incomplete":             errs.ErrIncomplete,
+	"interrupted":            errs.ErrInterrupted,
+	"agent_unavailable":      errs.ErrAgentUnavailable,
+}
+
+// remoteError error of agent run, classified as it was on the agent
+type remoteError struct {
+	message string
+	causes  []error
+}
+
+func (e *remoteError) Error() string {
+	return e.message
+}
+
+func (e *remoteError) Unwrap() []error {
+	return e.causes
+}
+
+// err error of result, nil for success
+func (r Result) err() error {
+	if r.Error == "" && r.ErrorKind == "" {
+		return nil
+	}
+	e := &remoteError{message: r.Error}
+	if cause, ok := kinds[r.ErrorKind]; ok {
+		e.causes = append(e.causes, cause)
+	}
+	if r.ErrorKind == "upload_failed" {
+		e.causes = append(e.causes, &errs.UploadError{Retriable: r.Retriable, Err: errors.New(r.Error)})
+	}
+	if r.Skipped {
+		e.causes = append(e.causes, errs.ErrSkipped)
+	}
+	return e
+}
@@ -0,0 +1,271 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/admin"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// Registrar API endpoints are registered on, e.g. *admin.Server
+type Registrar interface {
+	Handle(pattern, role string, handler http.HandlerFunc)
+}
+
+// Dispatcher runs queued by the scheduler until an agent claims them; a
+// claimed run lasts while its agent heartbeats and ends with its result
+type Dispatcher struct {
+	lease     time.Duration
+	queueWait time.Duration
+
+	mu      sync.Mutex
+	queued  map[string][]*assignment // by agent name, oldest first
+	wake    map[string]chan struct{} // closed once work is queued for agent
+	claimed map[string]*assignment   // by run ID
+}
+
+// assignment work queued for or claimed by agent
+type assignment struct {
+	agent  string
+	work   Work
+	worker string    // claiming worker, empty while queued
+	seen   time.Time // last claim or heartbeat
+	done   chan Result
+}
+
+// claimRequest body of claim
+type claimRequest struct {
+	Worker string `json:"worker"` // agent instance, for logs
+}
+
+// NewDispatcher dispatcher with lease and queue_wait of cfg
+func NewDispatcher(cfg config.AgentsConfig) (*Dispatcher, error) {
+	d := &Dispatcher{
+		lease:     DefaultLease,
+		queueWait: DefaultQueueWait,
+		queued:    make(map[string][]*assignment),
+		wake:      make(map[string]chan struct{}),
+		claimed:   make(map[string]*assignment),
+	}
+	var err error
+	if cfg.Lease != "" {
+		if d.lease, err = config.ParseDuration(cfg.Lease); err != nil || d.lease < 2*heartbeatInterval {
+			return nil, fmt.Errorf("%w: admin.agents.lease %q must be at least %v", errs.ErrInvalidConfig, cfg.Lease, 2*heartbeatInterval)
+		}
+	}
+	if cfg.QueueWait != "" {
+		if d.queueWait, err = config.ParseDuration(cfg.QueueWait); err != nil || d.queueWait <= 0 {
+			return nil, fmt.Errorf("%w: admin.agents.queue_wait %q", errs.ErrInvalidConfig, cfg.QueueWait)
+		}
+	}
+	return d, nil
+}
+
+// Register serve agent endpoints on server, for tokens with agent role; agent
+// tokens only reach the endpoints of their own agent name
+func (d *Dispatcher) Register(server Registrar) {
+	server.Handle("POST /api/agents/{agent}/claim", admin.RoleAgent, d.claim)
+	server.Handle("POST /api/agents/{agent}/runs/{run_id}/heartbeat", admin.RoleAgent, d.heartbeat)
+	server.Handle("POST /api/agents/{agent}/runs/{run_id}/complete", admin.RoleAgent, d.complete)
+}
+
+// Run queue work for agent and wait for its result; fails when no agent
+// claims it within queue_wait, when the claiming agent is silent for lease,
+// or when ctx is cancelled, after which the agent stops on its next heartbeat
+func (d *Dispatcher) Run(ctx context.Context, agent string, work Work) error {
+	a := &assignment{agent: agent, work: work, done: make(chan Result, 1)}
+	queuedAt := time.Now()
+	d.enqueue(a)
+	defer d.remove(a)
+	log.WithField("run_id", work.RunID).Infof("Queued %s of %s for agent %s", work.Type, work.Index, agent)
+
+	ticker := time.NewTicker(heartbeatInterval / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case result := <-a.done:
+			return result.err()
+		case now := <-ticker.C:
+			d.mu.Lock()
+			worker, seen := a.worker, a.seen
+			d.mu.Unlock()
+			switch {
+			case worker == "" && now.Sub(queuedAt) > d.queueWait:
+				return fmt.Errorf("%w: no agent %s claimed run within %v", errs.ErrAgentUnavailable, agent, d.queueWait)
+			case worker != "" && now.Sub(seen) > d.lease:
+				return fmt.Errorf("%w: agent %s (%s) stopped reporting for %v", errs.ErrInterrupted, agent, worker, d.lease)
+			}
+		}
+	}
+}
+
+// enqueue queue assignment and wake claims waiting for its agent
+func (d *Dispatcher) enqueue(a *assignment) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queued[a.agent] = append(d.queued[a.agent], a)
+	metrics.AgentQueuedRuns.WithLabelValues(a.agent).Set(float64(len(d.queued[a.agent])))
+	if wake, ok := d.wake[a.agent]; ok {
+		close(wake)
+		delete(d.wake, a.agent)
+	}
+}
+
+// next claim oldest work queued for agent, or channel closed once there is some
+func (d *Dispatcher) next(agent, worker string) (*assignment, <-chan struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if queue := d.queued[agent]; len(queue) > 0 {
+		a := queue[0]
+		d.queued[agent] = queue[1:]
+		metrics.AgentQueuedRuns.WithLabelValues(agent).Set(float64(len(d.queued[agent])))
+		a.worker, a.seen = worker, time.Now()
+		d.claimed[a.work.RunID] = a
+		return a, nil
+	}
+	wake, ok := d.wake[agent]
+	if !ok {
+		wake = make(chan struct{})
+		d.wake[agent] = wake
+	}
+	return nil, wake
+}
+
+// remove forget assignment once its run ended
+func (d *Dispatcher) remove(a *assignment) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.claimed[a.work.RunID] == a {
+		delete(d.claimed, a.work.RunID)
+	}
+	queue := d.queued[a.agent]
+	for i, queued := range queue {
+		if queued == a {
+			d.queued[a.agent] = append(queue[:i:i], queue[i+1:]...)
+			metrics.AgentQueuedRuns.WithLabelValues(a.agent).Set(float64(len(d.queued[a.agent])))
+			break
+		}
+	}
+}
+
+// allowed check that token of request may act as agent of its path, 403 otherwise;
+// admin tokens act as any agent
+func allowed(w http.ResponseWriter, r *http.Request) bool {
+	if bound := admin.TokenAgent(r); bound != "" && bound != r.PathValue("agent") {
+		log.Warnf("Agent API: token of agent %s denied %s %s", bound, r.Method, r.URL.Path)
+		writeError(w, http.StatusForbidden, fmt.Sprintf("token is bound to agent %s", bound))
+		return false
+	}
+	return true
+}
+
+// lookup claimed assignment of request, nil when its run already ended
+func (d *Dispatcher) lookup(r *http.Request) *assignment {
+	a := d.claimed[r.PathValue("run_id")]
+	if a == nil || a.agent != r.PathValue("agent") {
+		return nil
+	}
+	return a
+}
+
+// claim hand oldest queued work of agent to caller, waiting up to
+// claimWait for some; 204 when there is none
+func (d *Dispatcher) claim(w http.ResponseWriter, r *http.Request) {
+	if !allowed(w, r) {
+		return
+	}
+	var req claimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if req.Worker == "" {
+		req.Worker = r.RemoteAddr
+	}
+	agent := r.PathValue("agent")
+
+	timeout := time.NewTimer(claimWait)
+	defer timeout.Stop()
+	for {
+		a, wake := d.next(agent, req.Worker)
+		if a != nil {
+			log.WithField("run_id", a.work.RunID).Infof("Agent %s (%s) claimed %s of %s", agent, req.Worker, a.work.Type, a.work.Index)
+			writeJSON(w, http.StatusOK, a.work)
+			return
+		}
+		select {
+		case <-wake:
+		case <-timeout.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// heartbeat extend lease of claimed run; 404 tells agent to stop it
+func (d *Dispatcher) heartbeat(w http.ResponseWriter, r *http.Request) {
+	if !allowed(w, r) {
+		return
+	}
+	d.mu.Lock()
+	a := d.lookup(r)
+	if a != nil {
+		a.seen = time.Now()
+	}
+	d.mu.Unlock()
+	if a == nil {
+		writeError(w, http.StatusNotFound, "run is not claimed by agent")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// complete end claimed run with result of body
+func (d *Dispatcher) complete(w http.ResponseWriter, r *http.Request) {
+	if !allowed(w, r) {
+		return
+	}
+	var result Result
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	d.mu.Lock()
+	a := d.lookup(r)
+	if a != nil {
+		delete(d.claimed, a.work.RunID)
+	}
+	d.mu.Unlock()
+	if a == nil {
+		writeError(w, http.StatusNotFound, "run is not claimed by agent")
+		return
+	}
+	a.done <- result
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Debugf("Agent API: failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
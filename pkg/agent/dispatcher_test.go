@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/okto/opensearch-backup-manager/pkg/admin"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+)
+
+func TestDispatcherAgentTokens(t *testing.T) {
+	server, err := admin.New(config.AdminConfig{Enabled: true, Tokens: []config.AdminToken{
+		{Name: "zone-b", Role: admin.RoleAgent, Agent: "zone-b", Token: "zone-b-token"},
+		{Name: "zone-c", Role: admin.RoleAgent, Agent: "zone-c", Token: "zone-c-token"},
+		{Name: "oncall", Role: admin.RoleAdmin, Token: "oncall-token"},
+	}}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDispatcher(config.AgentsConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Register(server)
+	srv := httptest.NewServer(server)
+	defer srv.Close()
+
+	post := func(token, path, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	result := make(chan error, 1)
+	go func() { result <- d.Run(ctx, "zone-b", Work{RunID: "run-1", Type: "backup", Index: "audit"}) }()
+
+	// Token of another agent can neither claim nor report runs of zone-b
+	for _, path := range []string{"/api/agents/zone-b/claim", "/api/agents/zone-b/runs/run-1/heartbeat", "/api/agents/zone-b/runs/run-1/complete"} {
+		if resp := post("zone-c-token", path, "{}"); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("zone-c token %s: status %d, want 403", path, resp.StatusCode)
+		}
+	}
+
+	resp := post("zone-b-token", "/api/agents/zone-b/claim", `{"worker":"b-1"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("claim status %d", resp.StatusCode)
+	}
+	var work Work
+	if err := json.NewDecoder(resp.Body).Decode(&work); err != nil || work.RunID != "run-1" {
+		t.Fatalf("claimed %+v, %v", work, err)
+	}
+	// Nor does zone-c reach the run through its own path
+	if resp := post("zone-c-token", "/api/agents/zone-c/runs/run-1/heartbeat", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("zone-c heartbeat of zone-b run: status %d, want 404", resp.StatusCode)
+	}
+	// Admin tokens act as any agent
+	if resp := post("oncall-token", "/api/agents/zone-b/runs/run-1/heartbeat", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("admin heartbeat: status %d, want 204", resp.StatusCode)
+	}
+	if resp := post("zone-b-token", "/api/agents/zone-b/runs/run-1/complete", "{}"); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("complete status %d", resp.StatusCode)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("run error %v", err)
+	}
+}
+
+func TestAgentTokenNeedsAgent(t *testing.T) {
+	tests := []struct {
+		name  string
+		token config.AdminToken
+		valid bool
+	}{
+		{"bound agent", config.AdminToken{Name: "zone-b", Role: admin.RoleAgent, Agent: "zone-b", Token: "t"}, true},
+		{"unbound agent", config.AdminToken{Name: "zone-b", Role: admin.RoleAgent, Token: "t"}, false},
+		{"admin with agent", config.AdminToken{Name: "oncall", Role: admin.RoleAdmin, Agent: "zone-b", Token: "t"}, false},
+	}
+	for _, tt := range tests {
+		_, err := admin.New(config.AdminConfig{Enabled: true, Tokens: []config.AdminToken{tt.token}}, nil, nil, nil)
+		if tt.valid != (err == nil) {
+			t.Errorf("%s: error %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
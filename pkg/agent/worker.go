@@ -0,0 +1,205 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// retryDelay pause after failed claim, e.g. while the scheduler restarts
+	retryDelay = 10 * time.Second
+	// reportTimeout time given to report result, also when agent is stopping
+	reportTimeout = 30 * time.Second
+	// reportAttempts attempts to report result before the lease decides
+	reportAttempts = 3
+)
+
+// Worker agent claiming runs dispatched to its name and executing them one at a time
+type Worker struct {
+	name   string
+	id     string // instance reported on claims, host and pid
+	base   string // agent endpoints of server
+	token  string
+	client *http.Client
+}
+
+// NewWorker worker of agent configuration
+func NewWorker(cfg config.AgentConfig) (*Worker, error) {
+	if cfg.Name == "" || cfg.Server == "" {
+		return nil, fmt.Errorf("%w: agent needs name and server", errs.ErrInvalidConfig)
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("%w: agent needs a token with agent role (agent.token or AGENT_TOKEN)", errs.ErrInvalidConfig)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read agent.ca_cert: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: agent.ca_cert %s has no certificates", errs.ErrInvalidConfig, cfg.CACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	host, _ := os.Hostname()
+	return &Worker{
+		name:   cfg.Name,
+		id:     fmt.Sprintf("%s/%d", host, os.Getpid()),
+		base:   strings.TrimSuffix(cfg.Server, "/") + "/api/agents/" + url.PathEscape(cfg.Name),
+		token:  cfg.Token,
+		client: &http.Client{Transport: transport, Timeout: claimWait + 30*time.Second},
+	}, nil
+}
+
+// Serve claim work and execute it until ctx is cancelled; the context of
+// execute is cancelled when the scheduler gives up on the run
+func (w *Worker) Serve(ctx context.Context, execute func(ctx context.Context, work Work) error) {
+	log.Infof("Agent %s (%s) claiming runs from %s", w.name, w.id, w.base)
+	for ctx.Err() == nil {
+		work, err := w.claim(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Warnf("Failed to claim runs: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+		if work != nil {
+			w.execute(ctx, *work, execute)
+		}
+	}
+	log.Infof("Agent %s stopped", w.name)
+}
+
+// claim next work, nil when none was queued within claimWait
+func (w *Worker) claim(ctx context.Context) (*Work, error) {
+	var work Work
+	ok, err := w.post(ctx, "/claim", claimRequest{Worker: w.id}, &work)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &work, nil
+}
+
+// execute run work, heartbeating meanwhile, and report its result
+func (w *Worker) execute(ctx context.Context, work Work, execute func(ctx context.Context, work Work) error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go w.heartbeat(runCtx, work, cancel)
+
+	err := execute(runCtx, work)
+	cancel()
+
+	// Reported also when agent is stopping, so the run fails now rather than after lease
+	reportCtx, done := context.WithTimeout(context.WithoutCancel(ctx), reportTimeout)
+	defer done()
+	for attempt := 1; ; attempt++ {
+		_, reportErr := w.post(reportCtx, "/runs/"+url.PathEscape(work.RunID)+"/complete", resultOf(err), nil)
+		var status *statusError
+		if reportErr == nil || attempt == reportAttempts || reportCtx.Err() != nil || (errors.As(reportErr, &status) && status.code < 500) {
+			if reportErr != nil {
+				log.WithField("run_id", work.RunID).Warnf("Failed to report result of %s: %v", work.Index, reportErr)
+			}
+			return
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// heartbeat report work running until ctx is done, cancel it when the
+// scheduler no longer waits for it
+func (w *Worker) heartbeat(ctx context.Context, work Work, cancel context.CancelFunc) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		_, err := w.post(ctx, "/runs/"+url.PathEscape(work.RunID)+"/heartbeat", nil, nil)
+		if ctx.Err() != nil {
+			return
+		}
+		var status *statusError
+		if errors.As(err, &status) && status.code == http.StatusNotFound {
+			log.WithField("run_id", work.RunID).Warnf("Scheduler gave up on %s of %s, stopping", work.Type, work.Index)
+			cancel()
+			return
+		}
+		if err != nil {
+			log.WithField("run_id", work.RunID).Warnf("Failed to send heartbeat: %v", err)
+		}
+	}
+}
+
+// statusError error response of scheduler
+type statusError struct {
+	path    string
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s: %d %s", e.path, e.code, e.message)
+}
+
+// post call agent endpoint with JSON body, decoding response into result;
+// false for 204 No Content
+func (w *Worker) post(ctx context.Context, path string, body, result interface{}) (bool, error) {
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return false, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.base+path, reader)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", "Bearer "+w.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return false, nil
+	case resp.StatusCode >= 300:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return false, &statusError{path: path, code: resp.StatusCode, message: string(bytes.TrimSpace(message))}
+	case result != nil:
+		return true, json.NewDecoder(resp.Body).Decode(result)
+	}
+	return true, nil
+}
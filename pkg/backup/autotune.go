@@ -0,0 +1,191 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMinPageSize   = 100
+	defaultMaxPageSize   = 10000
+	defaultTargetLatency = time.Second
+	// maxThrottleDelay upper bound of pause between pages while cluster pushes back
+	maxThrottleDelay = 30 * time.Second
+	// maxThrottleRetries consecutive rejected pages before export gives up
+	maxThrottleRetries = 10
+)
+
+// tuner adapts page size and pacing between pages to observed search latency
+// and rejections; with autotune disabled it keeps static page size and never
+// retries. Sliced searches of a period share it, so it is safe for concurrent use
+type tuner struct {
+	enabled  bool
+	min, max int
+	target   time.Duration
+
+	mu    sync.Mutex
+	size  int
+	delay time.Duration
+}
+
+// newTuner create tuner for job, starting from configured page size
+func newTuner(job config.BackupJob) (*tuner, error) {
+	t := &tuner{
+		enabled: job.Autotune.Enabled,
+		size:    job.PageSize,
+		min:     job.Autotune.MinPageSize,
+		max:     job.Autotune.MaxPageSize,
+		target:  defaultTargetLatency,
+	}
+	if t.size <= 0 {
+		t.size = defaultPageSize
+	}
+	if !t.enabled {
+		return t, nil
+	}
+
+	if t.min <= 0 {
+		t.min = defaultMinPageSize
+	}
+	if t.max <= 0 {
+		t.max = defaultMaxPageSize
+	}
+	if t.min > t.max {
+		return nil, fmt.Errorf("%w: autotune min_page_size %d exceeds max_page_size %d", errs.ErrInvalidConfig, t.min, t.max)
+	}
+	if job.Autotune.TargetLatency != "" {
+		target, err := config.ParseDuration(job.Autotune.TargetLatency)
+		if err != nil {
+			return nil, fmt.Errorf("%w: autotune target_latency: %w", errs.ErrInvalidConfig, err)
+		}
+		t.target = target
+	}
+	t.size = clamp(t.size, t.min, t.max)
+
+	return t, nil
+}
+
+// pageSize current page size
+func (t *tuner) pageSize() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.size
+}
+
+// observe adjust to successful page latency: grow when fast, shrink when slow
+func (t *tuner) observe(latency time.Duration) {
+	if !t.enabled {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case latency > t.target:
+		t.size = clamp(t.size*3/4, t.min, t.max)
+	case latency < t.target/2:
+		t.size = clamp(t.size+t.size/4+1, t.min, t.max)
+		t.delay /= 2
+	}
+}
+
+// throttled back off after rejected page: halve page size and double pause,
+// returns the new pause
+func (t *tuner) throttled() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.size = clamp(t.size/2, t.min, t.max)
+	if t.delay == 0 {
+		t.delay = 500 * time.Millisecond
+	} else if t.delay *= 2; t.delay > maxThrottleDelay {
+		t.delay = maxThrottleDelay
+	}
+	return t.delay
+}
+
+// wait pause before next page while recovering from rejections
+func (t *tuner) wait(ctx context.Context) error {
+	t.mu.Lock()
+	delay := t.delay
+	t.mu.Unlock()
+	if delay < time.Millisecond {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetchPage search next page through tuner, retrying rejected (429) or
+// unavailable pages with smaller size when autotune is enabled;
+// limit caps page size (0 = no cap), size used is returned with hits
+func (s *Service) fetchPage(ctx context.Context, session *exportSession, pit *pointInTime, startTime, endTime time.Time, limit int, searchAfter []interface{}, page *bytes.Buffer) ([]pageHit, int, error) {
+	t := session.tuner
+	for attempt := 1; ; attempt++ {
+		if err := t.wait(ctx); err != nil {
+			return nil, 0, err
+		}
+
+		size := t.pageSize()
+		if limit > 0 && limit < size {
+			size = limit
+		}
+
+		page.Reset()
+		started := time.Now()
+		hits, err := s.searchPage(ctx, session, pit, startTime, endTime, size, searchAfter, page)
+		if err == nil {
+			t.observe(time.Since(started))
+			return hits, size, nil
+		}
+
+		if !t.enabled || !throttling(err) || attempt > maxThrottleRetries {
+			return nil, size, err
+		}
+
+		delay := t.throttled()
+		if budgetErr := run.Retry(ctx, delay); budgetErr != nil {
+			return nil, size, fmt.Errorf("%w: %w", budgetErr, err)
+		}
+		log.WithFields(log.Fields{
+			"index":     session.index,
+			"attempt":   attempt,
+			"page_size": t.pageSize(),
+			"delay":     delay.String(),
+		}).Warnf("Search rejected, slowing down: %v", err)
+	}
+}
+
+// throttling check if error means cluster asks to slow down
+func throttling(err error) bool {
+	switch opensearch.ResponseStatus(err) {
+	case 429, 502, 503, 504:
+		return true
+	}
+	return false
+}
+
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
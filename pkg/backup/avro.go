@@ -0,0 +1,647 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	log "github.com/sirupsen/logrus"
+)
+
+// FormatAvro Avro object container file, gzip-compressed
+const FormatAvro = "avro"
+
+// avroMagic object container file magic
+var avroMagic = []byte{'O', 'b', 'j', 1}
+
+// avroSchema parsed subset of Avro schema: primitives, record, array, map,
+// enum and union; named types may be referenced by name
+type avroSchema struct {
+	Type        string
+	Name        string
+	LogicalType string
+	Fields      []avroField
+	Items       *avroSchema // array
+	Values      *avroSchema // map
+	Symbols     []string    // enum
+	Branches    []*avroSchema
+}
+
+// avroField record field; source is the _source key when it differs from
+// name (field attribute "source", set for sanitized names)
+type avroField struct {
+	Name    string
+	Source  string
+	Schema  *avroSchema
+	Default interface{}
+	HasDef  bool
+}
+
+// parseAvroSchema parse schema JSON into encoder tree
+func parseAvroSchema(data []byte) (*avroSchema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return parseAvroNode(raw, map[string]*avroSchema{})
+}
+
+func parseAvroNode(raw interface{}, named map[string]*avroSchema) (*avroSchema, error) {
+	switch node := raw.(type) {
+	case string:
+		switch node {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &avroSchema{Type: node}, nil
+		}
+		if schema, ok := named[node]; ok {
+			return schema, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", node)
+
+	case []interface{}:
+		union := &avroSchema{Type: "union"}
+		for _, branch := range node {
+			schema, err := parseAvroNode(branch, named)
+			if err != nil {
+				return nil, err
+			}
+			union.Branches = append(union.Branches, schema)
+		}
+		return union, nil
+
+	case map[string]interface{}:
+		typeName, _ := node["type"].(string)
+		schema := &avroSchema{Type: typeName}
+		schema.LogicalType, _ = node["logicalType"].(string)
+		schema.Name, _ = node["name"].(string)
+
+		switch typeName {
+		case "record", "error":
+			schema.Type = "record"
+			// Register before fields, so recursive references resolve
+			named[schema.Name] = schema
+			fields, _ := node["fields"].([]interface{})
+			for _, rawField := range fields {
+				fieldNode, ok := rawField.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("invalid field of record %s", schema.Name)
+				}
+				fieldSchema, err := parseAvroNode(fieldNode["type"], named)
+				if err != nil {
+					return nil, err
+				}
+				field := avroField{Schema: fieldSchema}
+				field.Name, _ = fieldNode["name"].(string)
+				field.Source, _ = fieldNode["source"].(string)
+				field.Default, field.HasDef = fieldNode["default"]
+				schema.Fields = append(schema.Fields, field)
+			}
+		case "enum":
+			named[schema.Name] = schema
+			symbols, _ := node["symbols"].([]interface{})
+			for _, symbol := range symbols {
+				name, _ := symbol.(string)
+				schema.Symbols = append(schema.Symbols, name)
+			}
+		case "array":
+			items, err := parseAvroNode(node["items"], named)
+			if err != nil {
+				return nil, err
+			}
+			schema.Items = items
+		case "map":
+			values, err := parseAvroNode(node["values"], named)
+			if err != nil {
+				return nil, err
+			}
+			schema.Values = values
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+		default:
+			if typeName == "" {
+				return parseAvroNode(node["type"], named)
+			}
+			return nil, fmt.Errorf("unsupported type %q", typeName)
+		}
+		return schema, nil
+	}
+
+	return nil, fmt.Errorf("invalid schema node %v", raw)
+}
+
+// avroEncoder pages as blocks of single object container file; header and
+// sync marker are shared by all parts, so concatenated parts stay valid
+type avroEncoder struct {
+	schema     *avroSchema
+	schemaJSON []byte
+	sync       [16]byte
+}
+
+func newAvroEncoder(schemaJSON []byte) (*avroEncoder, error) {
+	schema, err := parseAvroSchema(schemaJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%w: avro schema: %w", errs.ErrInvalidConfig, err)
+	}
+	if schema.Type != "record" {
+		return nil, fmt.Errorf("%w: avro schema must be a record", errs.ErrInvalidConfig)
+	}
+
+	e := &avroEncoder{schema: schema, schemaJSON: schemaJSON}
+	rand.Read(e.sync[:])
+	return e, nil
+}
+
+func (e *avroEncoder) header() []byte {
+	var buf bytes.Buffer
+	buf.Write(avroMagic)
+
+	// File metadata map<bytes>, single block
+	writeLong(&buf, 2)
+	writeString(&buf, "avro.schema")
+	writeBytes(&buf, e.schemaJSON)
+	writeString(&buf, "avro.codec")
+	writeBytes(&buf, []byte("null"))
+	writeLong(&buf, 0)
+
+	buf.Write(e.sync[:])
+	return buf.Bytes()
+}
+
+func (e *avroEncoder) encode(buf *bytes.Buffer, page []byte, hits []pageHit) error {
+	if len(hits) == 0 {
+		return nil
+	}
+
+	block := getBuffer()
+	defer putBuffer(block)
+
+	for _, hit := range hits {
+		decoder := json.NewDecoder(bytes.NewReader(hit.Source))
+		decoder.UseNumber()
+
+		var source map[string]interface{}
+		if err := decoder.Decode(&source); err != nil {
+			return fmt.Errorf("failed to decode document %s: %w", hit.ID, err)
+		}
+		if _, ok := source["_id"]; !ok {
+			source["_id"] = hit.ID
+		}
+
+		if err := encodeAvro(block, e.schema, source); err != nil {
+			return fmt.Errorf("document %s: %w", hit.ID, err)
+		}
+	}
+
+	writeLong(buf, int64(len(hits)))
+	writeLong(buf, int64(block.Len()))
+	buf.Write(block.Bytes())
+	buf.Write(e.sync[:])
+	return nil
+}
+
+// encodeAvro write value in Avro binary encoding of schema
+func encodeAvro(buf *bytes.Buffer, schema *avroSchema, value interface{}) error {
+	switch schema.Type {
+	case "null":
+		if value != nil {
+			return fmt.Errorf("expected null, got %v", value)
+		}
+		return nil
+
+	case "boolean":
+		v, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("expected boolean, got %v", value)
+		}
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		return nil
+
+	case "int", "long":
+		n, err := avroLong(schema, value)
+		if err != nil {
+			return err
+		}
+		writeLong(buf, n)
+		return nil
+
+	case "float", "double":
+		f, err := avroDouble(value)
+		if err != nil {
+			return err
+		}
+		if schema.Type == "float" {
+			binary.Write(buf, binary.LittleEndian, math.Float32bits(float32(f)))
+		} else {
+			binary.Write(buf, binary.LittleEndian, math.Float64bits(f))
+		}
+		return nil
+
+	case "string", "bytes":
+		writeString(buf, avroString(value))
+		return nil
+
+	case "enum":
+		symbol := avroString(value)
+		for i, s := range schema.Symbols {
+			if s == symbol {
+				writeLong(buf, int64(i))
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a symbol of enum %s", symbol, schema.Name)
+
+	case "record":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object for record %s, got %v", schema.Name, value)
+		}
+		for _, field := range schema.Fields {
+			key := field.Name
+			if field.Source != "" {
+				key = field.Source
+			}
+			fieldValue, present := object[key]
+			if !present && field.HasDef {
+				fieldValue = field.Default
+			}
+			if err := encodeAvro(buf, field.Schema, fieldValue); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return nil
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected array, got %v", value)
+		}
+		if len(items) > 0 {
+			writeLong(buf, int64(len(items)))
+			for _, item := range items {
+				if err := encodeAvro(buf, schema.Items, item); err != nil {
+					return err
+				}
+			}
+		}
+		writeLong(buf, 0)
+		return nil
+
+	case "map":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object for map, got %v", value)
+		}
+		if len(object) > 0 {
+			keys := make([]string, 0, len(object))
+			for key := range object {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			writeLong(buf, int64(len(keys)))
+			for _, key := range keys {
+				writeString(buf, key)
+				if err := encodeAvro(buf, schema.Values, object[key]); err != nil {
+					return err
+				}
+			}
+		}
+		writeLong(buf, 0)
+		return nil
+
+	case "union":
+		index := unionBranch(schema, value)
+		if index < 0 {
+			return fmt.Errorf("value %v matches no branch of union", value)
+		}
+		writeLong(buf, int64(index))
+		branch := schema.Branches[index]
+		if branch.Type == "string" && !matchesAvro(branch, value) {
+			// Fallback for values of unexpected type (dynamic mapping drift)
+			value = avroString(value)
+		}
+		return encodeAvro(buf, branch, value)
+	}
+
+	return fmt.Errorf("unsupported type %q", schema.Type)
+}
+
+// unionBranch index of first branch matching value, falling back to string branch
+func unionBranch(schema *avroSchema, value interface{}) int {
+	for i, branch := range schema.Branches {
+		if matchesAvro(branch, value) {
+			return i
+		}
+	}
+	if value != nil {
+		for i, branch := range schema.Branches {
+			if branch.Type == "string" {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func matchesAvro(schema *avroSchema, value interface{}) bool {
+	switch schema.Type {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "int", "long":
+		_, err := avroLong(schema, value)
+		return err == nil
+	case "float", "double":
+		_, ok := value.(json.Number)
+		return ok
+	case "string", "bytes":
+		_, ok := value.(string)
+		return ok
+	case "enum":
+		symbol, ok := value.(string)
+		if ok {
+			for _, s := range schema.Symbols {
+				if s == symbol {
+					return true
+				}
+			}
+		}
+		return false
+	case "record", "map":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	}
+	return false
+}
+
+// avroLong integer value, dates are converted for timestamp-millis/micros logical types
+func avroLong(schema *avroSchema, value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Int64()
+	case string:
+		if schema.LogicalType == "timestamp-millis" || schema.LogicalType == "timestamp-micros" {
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return 0, err
+			}
+			if schema.LogicalType == "timestamp-micros" {
+				return t.UnixMicro(), nil
+			}
+			return t.UnixMilli(), nil
+		}
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("expected integer, got %v", value)
+}
+
+func avroDouble(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("expected number, got %v", value)
+}
+
+func avroString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return ""
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+func writeLong(buf *bytes.Buffer, n int64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buf.Write(scratch[:binary.PutVarint(scratch[:], n)])
+}
+
+func writeBytes(buf *bytes.Buffer, data []byte) {
+	writeLong(buf, int64(len(data)))
+	buf.Write(data)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	writeLong(buf, int64(len(s)))
+	buf.WriteString(s)
+}
+
+// mappingProperty field of index mapping
+type mappingProperty struct {
+	Type       string                     `json:"type"`
+	Properties map[string]mappingProperty `json:"properties"`
+}
+
+// avroSchemaJSON user-supplied schema of job or one derived from index mapping
+func (s *Service) avroSchemaJSON(ctx context.Context, job config.BackupJob) ([]byte, error) {
+	switch {
+	case job.Avro.Schema != "":
+		return []byte(job.Avro.Schema), nil
+	case job.Avro.SchemaFile != "":
+		data, err := os.ReadFile(job.Avro.SchemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: avro.schema_file: %w", errs.ErrInvalidConfig, err)
+		}
+		return data, nil
+	}
+
+	// Merge mappings of all indices behind pattern, first definition wins
+	var mappings map[string]struct {
+		Mappings struct {
+			Properties map[string]mappingProperty `json:"properties"`
+		} `json:"mappings"`
+	}
+	if err := s.client.Do(ctx, "GET", "/"+job.IndexName+"/_mapping", nil, &mappings); err != nil {
+		return nil, fmt.Errorf("failed to get mapping of %s: %w", job.IndexName, err)
+	}
+
+	names := make([]string, 0, len(mappings))
+	for name := range mappings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	properties := map[string]mappingProperty{}
+	for _, name := range names {
+		for field, property := range mappings[name].Mappings.Properties {
+			if _, ok := properties[field]; !ok {
+				properties[field] = property
+			}
+		}
+	}
+
+	record := avroRecord("Document", properties)
+	// Document id first, taken from hit metadata
+	fields := append([]interface{}{map[string]interface{}{"name": "_id", "type": "string"}}, record["fields"].([]interface{})...)
+	record["fields"] = fields
+
+	return json.Marshal(record)
+}
+
+// avroRecord derive record schema from mapping properties; every field is
+// nullable and may also hold an array, as any OpenSearch field can
+func avroRecord(name string, properties map[string]mappingProperty) map[string]interface{} {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	used := map[string]bool{}
+	fields := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		fieldName := avroName(key)
+		for base, i := fieldName, 2; used[fieldName]; i++ {
+			fieldName = fmt.Sprintf("%s_%d", base, i)
+		}
+		used[fieldName] = true
+
+		property := properties[key]
+		var fieldType interface{}
+		switch property.Type {
+		case "nested":
+			fieldType = []interface{}{"null", map[string]interface{}{
+				"type":  "array",
+				"items": avroRecord(name+"_"+fieldName, property.Properties),
+			}}
+		default:
+			var item interface{}
+			switch property.Type {
+			case "", "object":
+				item = avroRecord(name+"_"+fieldName, property.Properties)
+			case "long", "integer", "short", "byte", "unsigned_long":
+				item = "long"
+			case "float", "double", "half_float", "scaled_float":
+				item = "double"
+			case "boolean":
+				item = "boolean"
+			default:
+				// text, keyword, date, ip and everything else
+				item = "string"
+			}
+			if record, ok := item.(map[string]interface{}); ok {
+				// Named record is defined once, array refers to it by name
+				fieldType = []interface{}{"null", record, map[string]interface{}{"type": "array", "items": record["name"]}}
+			} else {
+				fieldType = []interface{}{"null", item, map[string]interface{}{"type": "array", "items": item}}
+			}
+		}
+
+		field := map[string]interface{}{"name": fieldName, "type": fieldType, "default": nil}
+		if fieldName != key {
+			field["source"] = key
+		}
+		fields = append(fields, field)
+	}
+
+	return map[string]interface{}{"type": "record", "name": name, "fields": fields}
+}
+
+// avroName sanitize field name to [A-Za-z_][A-Za-z0-9_]*
+func avroName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		switch {
+		case r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			b.WriteRune(r)
+		case r >= '0' && r <= '9' && i > 0:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// registerSchema register schema under subject in Confluent-compatible registry, returns schema id
+func registerSchema(ctx context.Context, cfg config.AvroConfig, subject string, schemaJSON []byte) (int, error) {
+	body, err := json.Marshal(map[string]string{"schema": string(schemaJSON)})
+	if err != nil {
+		return 0, err
+	}
+
+	url := strings.TrimSuffix(cfg.RegistryURL, "/") + "/subjects/" + subject + "/versions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	if cfg.RegistryUsername != "" {
+		req.SetBasicAuth(cfg.RegistryUsername, cfg.RegistryPassword)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ID      int    `json:"id"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("registry returned %s: %s", resp.Status, result.Message)
+	}
+	return result.ID, nil
+}
+
+// newAvroFormat build avro encoder for job, registering schema when registry is configured
+func (s *Service) newAvroFormat(ctx context.Context, job config.BackupJob) (*avroEncoder, error) {
+	schemaJSON, err := s.avroSchemaJSON(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder, err := newAvroEncoder(schemaJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Avro.RegistryURL != "" {
+		subject := job.Avro.Subject
+		if subject == "" {
+			subject = job.IndexName + "-value"
+		}
+		id, err := registerSchema(ctx, job.Avro, subject, schemaJSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to register avro schema: %w", err)
+		}
+		log.Infof("Registered avro schema for %s as subject %s (id %d)", job.IndexName, subject, id)
+	}
+
+	return encoder, nil
+}
@@ -0,0 +1,1264 @@
+// Package backup exports daily index data into compressed artifacts on S3
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"github.com/okto/opensearch-backup-manager/pkg/blackout"
+	"github.com/okto/opensearch-backup-manager/pkg/clock"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/events"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+	"github.com/okto/opensearch-backup-manager/pkg/naming"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"github.com/okto/opensearch-backup-manager/pkg/state"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultPageSize documents fetched per search request
+	defaultPageSize = 1000
+	// compressionBlockSize input block compressed by each gzip worker
+	compressionBlockSize = 1 << 20
+)
+
+// bufferPool reusable buffers for search requests and response pages
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	bufferPool.Put(buf)
+}
+
+type Service struct {
+	client   opensearch.API
+	s3Client storage.Backend
+	clock    clock.Clock
+	config   *config.Config
+	workDir  string
+	// named storage profiles referenced by job storage
+	storages map[string]storage.Backend
+	// named clusters of fan-out jobs
+	clusters map[string]opensearch.API
+	// progress checkpoints, nil keeps them as objects next to the manifest
+	state state.Store
+}
+
+// DefaultWorkDir local directory for temporary export files, under the system
+// temporary directory ($TMPDIR, %TMP%)
+var DefaultWorkDir = filepath.Join(os.TempDir(), "opensearch-backups")
+
+// Options backup service dependencies for embedding into other programs
+type Options struct {
+	Client  opensearch.API
+	Storage storage.Backend
+	Clock   clock.Clock    // default wall clock
+	Config  *config.Config // optional
+	WorkDir string         // default DefaultWorkDir
+	// named storage profiles referenced by job storage, Storage is the default
+	Storages map[string]storage.Backend
+	// named clusters of fan-out jobs, Client is the default
+	Clusters map[string]opensearch.API
+	// store of progress checkpoints, default objects in storage
+	State state.Store
+}
+
+// New create backup service from options
+func New(opts Options) (*Service, error) {
+	if opts.Client == nil || opts.Storage == nil {
+		return nil, fmt.Errorf("%w: backup requires OpenSearch client and storage", errs.ErrInvalidConfig)
+	}
+
+	workDir := opts.WorkDir
+	if workDir == "" {
+		workDir = DefaultWorkDir
+	}
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create work dir: %w", err)
+	}
+
+	jobClock := opts.Clock
+	if jobClock == nil {
+		jobClock = clock.Real{}
+	}
+
+	return &Service{
+		client:   opts.Client,
+		s3Client: opts.Storage,
+		clock:    jobClock,
+		config:   opts.Config,
+		workDir:  workDir,
+		storages: opts.Storages,
+		clusters: opts.Clusters,
+		state:    opts.State,
+	}, nil
+}
+
+// NewService create backup service with default work dir; a work dir that cannot
+// be created is tolerated, since stream mode never writes to it
+func NewService(client opensearch.API, s3Client storage.Backend, cfg *config.Config) *Service {
+	os.MkdirAll(DefaultWorkDir, 0755)
+
+	return &Service{
+		client:   client,
+		s3Client: s3Client,
+		clock:    clock.Real{},
+		config:   cfg,
+		workDir:  DefaultWorkDir,
+	}
+}
+
+// AddStorage register named storage profile for jobs with storage: name
+func (s *Service) AddStorage(name string, backend storage.Backend) {
+	if s.storages == nil {
+		s.storages = make(map[string]storage.Backend)
+	}
+	s.storages[name] = backend
+}
+
+// AddCluster register named cluster for fan-out jobs with cluster: name
+func (s *Service) AddCluster(name string, client opensearch.API) {
+	if s.clusters == nil {
+		s.clusters = make(map[string]opensearch.API)
+	}
+	s.clusters[name] = client
+}
+
+// UseState keep progress checkpoints in store instead of storage
+func (s *Service) UseState(store state.Store) {
+	s.state = store
+}
+
+// forJob service reading from cluster and writing to storage profile of job
+func (s *Service) forJob(job config.BackupJob) (*Service, error) {
+	if job.Storage == "" && job.Cluster == "" {
+		return s, nil
+	}
+	svc := *s
+	if job.Storage != "" {
+		backend, ok := s.storages[job.Storage]
+		if !ok {
+			return nil, fmt.Errorf("%w: backup job %s: unknown storage %q", errs.ErrInvalidConfig, job.Name(), job.Storage)
+		}
+		svc.s3Client = backend
+	}
+	if job.Cluster != "" {
+		client, ok := s.clusters[job.Cluster]
+		if !ok {
+			return nil, fmt.Errorf("%w: backup job %s: unknown cluster %q", errs.ErrInvalidConfig, job.Name(), job.Cluster)
+		}
+		svc.client = client
+	}
+	return &svc, nil
+}
+
+// Backup export previous day of job index to S3, then rotate old backups
+// when retention policy is set
+func (s *Service) Backup(ctx context.Context, job config.BackupJob) error {
+	svc, err := s.forJob(job)
+	if err != nil {
+		return err
+	}
+	return svc.runBackup(ctx, job)
+}
+
+func (s *Service) runBackup(ctx context.Context, job config.BackupJob) error {
+	ctx, runID := run.Ensure(ctx)
+
+	overrides := run.OverridesOf(ctx)
+	if overrides.Index != "" {
+		job.IndexName = overrides.Index
+	}
+	if overrides.Ranged() {
+		// Partial windows must not replace regular artifacts or take part in
+		// retention, so they get a prefix of their own
+		job.S3Path = path.Join(job.S3Path, "adhoc", runID)
+		job.SkipExisting = false
+		job.Retention = config.RetentionPolicy{}
+	}
+
+	retryDelay := defaultCompletenessRetryDelay
+	if job.Completeness.RetryDelay != "" {
+		delay, err := config.ParseDuration(job.Completeness.RetryDelay)
+		if err != nil {
+			return fmt.Errorf("%w: completeness.retry_delay: %w", errs.ErrInvalidConfig, err)
+		}
+		retryDelay = delay
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := s.backup(ctx, job)
+		if errors.Is(err, errs.ErrIncomplete) && attempt <= job.Completeness.Retries {
+			if budgetErr := run.Retry(ctx, retryDelay); budgetErr != nil {
+				return fmt.Errorf("%w: %w", budgetErr, err)
+			}
+			log.Warnf("Backup of %s incomplete, re-running in %v (retry %d/%d): %v", job.IndexName, retryDelay, attempt, job.Completeness.Retries, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-s.clock.After(retryDelay):
+			}
+			// Re-run replaces the incomplete artifact instead of skipping it
+			job.SkipExisting = false
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		break
+	}
+
+	if overrides.DryRun {
+		return nil
+	}
+	if job.Retention.Enabled() {
+		frozen, reason := false, ""
+		if s.config != nil {
+			var err error
+			if frozen, reason, err = blackout.Active(s.config.Blackout, s.clock.Now()); err != nil {
+				return err
+			}
+		}
+		if frozen {
+			log.Warnf("Skipping retention for %s: blackout day (%s)", job.IndexName, reason)
+		} else if err := s.rotate(ctx, job); err != nil {
+			return fmt.Errorf("failed to apply retention: %w", err)
+		}
+	}
+	if job.Retention.Lifecycle.Enabled {
+		if err := s.syncLifecycle(ctx, job); err != nil {
+			return fmt.Errorf("failed to sync bucket lifecycle: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) backup(ctx context.Context, job config.BackupJob) (err error) {
+	// By default backup for yesterday, or previous week/month with window
+	targetDate, err := targetWindow(&job, s.clock.Now())
+	if err != nil {
+		return err
+	}
+	overrides := run.OverridesOf(ctx)
+	if targetDate, err = overriddenWindow(job, targetDate, overrides); err != nil {
+		return err
+	}
+
+	ctx, runID := run.Ensure(ctx)
+	log.Infof("Starting backup for index %s, date: %s (run %s)", job.Name(), targetDate.Format("2006-01-02"), runID)
+
+	if err := s.checkIndex(ctx, job); err != nil {
+		return err
+	}
+	session, err := s.newExportSession(ctx, job)
+	if err != nil {
+		return err
+	}
+	if err := s.waitDataDelay(ctx, job, targetDate); err != nil {
+		return err
+	}
+
+	// Final keys are deterministic per date, so a retried run overwrites
+	// artifact of the failed one, or skips the day with skip_existing
+	artifact, err := session.artifactName(ctx, job, targetDate)
+	if err != nil {
+		return err
+	}
+	s3Key := path.Join(job.S3Path, artifact)
+	if job.SkipExisting && !job.Chunked {
+		exists, err := s.s3Client.Exists(ctx, s3Key)
+		if err != nil {
+			return fmt.Errorf("failed to check existing artifact: %w", err)
+		}
+		if exists {
+			log.Infof("Backup for %s already exists at %s, skipping", job.IndexName, s3Key)
+			return nil
+		}
+	}
+
+	// Stream always fails fast, a failed period aborts the upload
+	policy, err := errorPolicy(job)
+	if err != nil {
+		return err
+	}
+	if overrides.DryRun {
+		return s.dryRun(ctx, job, session, targetDate, s3Key)
+	}
+	if err := s.client.WaitForLoad(ctx, job.LoadGate); err != nil {
+		return fmt.Errorf("load gate: %w", err)
+	}
+	if err := s.loadDictionary(ctx, job, session, session.window(job, targetDate)); err != nil {
+		return err
+	}
+	if job.Stream {
+		return s.backupStream(ctx, job, session, targetDate, s3Key)
+	}
+
+	// Every temporary file of the run lives in its own directory, removed on
+	// all exit paths
+	dir := s.runDir(ctx)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create work dir of run: %w", err)
+	}
+	defer func() { s.removeRunDir(job, dir, err) }()
+
+	// Compress (or upload, for chunked output) completed periods in background
+	// while next ones download
+	compressedFile := s.tempPath(ctx, artifact)
+
+	budget, err := newDiskBudget(job.MaxDiskUsage)
+	if err != nil {
+		return fmt.Errorf("%w: max_disk_usage: %w", errs.ErrInvalidConfig, err)
+	}
+
+	ranges := session.window(job, targetDate)
+	periodsCount := len(ranges)
+	cp, err := s.startCheckpoint(ctx, job, session, targetDate, ranges)
+	if err != nil {
+		return err
+	}
+
+	var sink periodSink
+	var extras map[string]*extraArtifact
+	if job.Chunked {
+		sink = s.startChunkUploader(ctx, job, budget, session.recipients, session.codec, cp.uploaded)
+	} else {
+		comp, err := s.startCompressor(compressedFile, budget, session.recipients, session.codec, session.encoder.header())
+		if err != nil {
+			return fmt.Errorf("failed to start compressor: %w", err)
+		}
+		sink = comp
+		if len(session.extras) > 0 {
+			if extras, err = s.startExtraArtifacts(ctx, job, session, targetDate, budget); err != nil {
+				comp.finish()
+				return err
+			}
+			sink = &formatSink{primary: comp, extras: extras}
+		}
+	}
+
+	var allFiles []exportFile
+	var failed []int
+
+	// download export period i into sink; files of a failed attempt are removed
+	download := func(i int) error {
+		// Pause while queued files exceed local disk cap
+		if err := budget.wait(ctx); err != nil {
+			return err
+		}
+
+		files, err := s.downloadPeriod(ctx, job, session, targetDate, ranges[i], i+1)
+		if err != nil {
+			s.cleanup(files)
+			return err
+		}
+
+		for j := range files {
+			files[j].Period = i + 1
+			session.hours.merge(files[j].Hours)
+		}
+		budget.track(files)
+		allFiles = append(allFiles, files...)
+		cp.exported(i, ranges[i], files)
+		sink.add(files)
+		events.ReportProgress(ctx, i+1, periodsCount, fmt.Sprintf("period %d/%d exported", i+1, periodsCount))
+		return nil
+	}
+	abort := func(err error) error {
+		sink.finish()
+		return err
+	}
+
+	// Download data by intervals
+	for i := 0; i < periodsCount; i++ {
+		if cp.done(i) {
+			continue
+		}
+		if err := download(i); err != nil {
+			if ctx.Err() != nil || policy == config.OnErrorFailFast {
+				return abort(fmt.Errorf("failed to download period %d: %w", i+1, err))
+			}
+			log.Errorf("Failed to download period %d: %v", i+1, err)
+			failed = append(failed, i)
+		}
+
+		// Pause between requests
+		if i < periodsCount-1 && job.RequestInterval > 0 {
+			log.Infof("Waiting %d seconds before next request...", job.RequestInterval)
+			select {
+			case <-ctx.Done():
+				return abort(ctx.Err())
+			case <-s.clock.After(time.Duration(job.RequestInterval) * time.Second):
+			}
+		}
+	}
+
+	if policy == config.OnErrorRetryFailedPeriods {
+		for attempt := 1; attempt <= periodRetries(job) && len(failed) > 0; attempt++ {
+			delay := periodRetryBaseDelay * time.Duration(attempt)
+			if err := run.Retry(ctx, delay); err != nil {
+				return abort(fmt.Errorf("%w: %d periods still failing", err, len(failed)))
+			}
+			log.Warnf("Retrying %d failed periods in %v (attempt %d/%d)", len(failed), delay, attempt, periodRetries(job))
+			select {
+			case <-ctx.Done():
+				return abort(fmt.Errorf("%w: %d periods still failing", ctx.Err(), len(failed)))
+			case <-s.clock.After(delay):
+			}
+
+			var still []int
+			for _, i := range failed {
+				if err := download(i); err != nil {
+					log.Errorf("Retry of period %d failed: %v", i+1, err)
+					still = append(still, i)
+				}
+			}
+			failed = still
+		}
+		if len(failed) > 0 {
+			return abort(fmt.Errorf("%w: %d periods still failing after %d retries", errs.ErrPartialFailure, len(failed), periodRetries(job)))
+		}
+	}
+
+	totalCount, err := sink.finish()
+	if err != nil {
+		if job.Chunked {
+			return fmt.Errorf("failed to upload chunks: %w", err)
+		}
+		return fmt.Errorf("failed to compress files: %w", err)
+	}
+	resumedCount, resumedObjects := cp.resumedTotals()
+	totalCount += resumedCount
+
+	// Artifact is still uploaded when partial or incomplete, the error is returned last
+	incomplete := s.checkCompleteness(ctx, job, session, targetDate, totalCount)
+	if incomplete != nil && !errors.Is(incomplete, errs.ErrIncomplete) {
+		return incomplete
+	}
+	if len(failed) > 0 {
+		for _, i := range failed {
+			session.failedPeriods = append(session.failedPeriods, i+1)
+		}
+		incomplete = fmt.Errorf("%w: %d of %d periods failed, backup is partial", errs.ErrPartialFailure, len(failed), periodsCount)
+	}
+
+	if len(allFiles) == 0 && len(resumedObjects) == 0 {
+		log.Warnf("No data downloaded for %s", job.IndexName)
+		cp.finish()
+		return incomplete
+	}
+
+	if job.Chunked {
+		objects := append(make([]string, 0, len(resumedObjects)+len(allFiles)), resumedObjects...)
+		for _, file := range allFiles {
+			objects = append(objects, chunkKey(job, file))
+		}
+		if err := s.spotCheck(ctx, job, objects[0]); err != nil {
+			return err
+		}
+		if err := s.writeManifest(ctx, job, session, targetDate, objects, totalCount); err != nil {
+			return err
+		}
+		cp.finish()
+		log.Infof("Backup completed for %s: %d documents in chunks under %s", job.IndexName, totalCount, job.S3Path)
+		return incomplete
+	}
+
+	// Outside the upload window the artifact waits in the local queue
+	queued, err := s.deferUpload(ctx, job, session, targetDate, compressedFile, s3Key, totalCount, extras)
+	if err != nil {
+		return err
+	}
+	if queued {
+		cp.finish()
+		log.Infof("Backup exported for %s: %d documents, upload of %s queued (run %s)", job.IndexName, totalCount, s3Key, runID)
+		return incomplete
+	}
+
+	// Upload to S3
+	if err := s.s3Client.Upload(ctx, compressedFile, s3Key, totalCount, session.codec.metadata(uploadMetadata(ctx, job, totalCount))); err != nil {
+		return fmt.Errorf("failed to upload to S3: %w", err)
+	}
+	if err := s.uploadExtras(ctx, session.codec, extras, totalCount); err != nil {
+		return err
+	}
+
+	if err := s.spotCheck(ctx, job, s3Key); err != nil {
+		return err
+	}
+	if err := s.writeManifest(ctx, job, session, targetDate, []string{s3Key}, totalCount); err != nil {
+		return err
+	}
+	cp.finish()
+
+	log.Infof("Backup completed for %s: %s (run %s)", job.IndexName, s3Key, runID)
+	return incomplete
+}
+
+// waitDataDelay hold export until target window ended data_delay ago, so logs
+// arriving late are already indexed
+func (s *Service) waitDataDelay(ctx context.Context, job config.BackupJob, date time.Time) error {
+	// Dry run reads nothing but counts, no need to hold it back
+	if job.DataDelay == "" || run.OverridesOf(ctx).DryRun {
+		return nil
+	}
+	delay, err := config.ParseDuration(job.DataDelay)
+	if err != nil || delay < 0 {
+		return fmt.Errorf("%w: data_delay %q", errs.ErrInvalidConfig, job.DataDelay)
+	}
+
+	ready := windowEnd(job, time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)).Add(delay)
+	wait := ready.Sub(s.clock.Now())
+	if wait <= 0 {
+		return nil
+	}
+
+	log.Infof("Waiting %v for late data of %s before export", wait.Round(time.Second), job.IndexName)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.clock.After(wait):
+		return nil
+	}
+}
+
+// periodRetryBaseDelay base delay between retries of failed periods
+const periodRetryBaseDelay = 30 * time.Second
+
+// errorPolicy validated on_error policy of job
+func errorPolicy(job config.BackupJob) (string, error) {
+	switch job.OnError {
+	case "":
+		return config.OnErrorAllowPartial, nil
+	case config.OnErrorFailFast, config.OnErrorRetryFailedPeriods, config.OnErrorAllowPartial:
+		return job.OnError, nil
+	}
+	return "", fmt.Errorf("%w: on_error %q must be %q, %q or %q", errs.ErrInvalidConfig, job.OnError,
+		config.OnErrorFailFast, config.OnErrorRetryFailedPeriods, config.OnErrorAllowPartial)
+}
+
+// periodRetries retries of failed periods under retry_failed_periods
+func periodRetries(job config.BackupJob) int {
+	if job.PeriodRetries > 0 {
+		return job.PeriodRetries
+	}
+	return 3
+}
+
+// artifactName object name of daily artifact, deterministic unless the
+// filename template uses run ID
+func (e *exportSession) artifactName(ctx context.Context, job config.BackupJob, date time.Time) (string, error) {
+	name, err := e.names.Artifact(job.IndexName, date, run.ID(ctx))
+	if err != nil {
+		return "", err
+	}
+	name += formatExtension(job) + e.codec.extension()
+	if job.Encryption.Enabled() {
+		name += encryptedSuffix
+	}
+	return name, nil
+}
+
+// runDir local directory of run temporary files
+func (s *Service) runDir(ctx context.Context) string {
+	return filepath.Join(s.workDir, run.ID(ctx))
+}
+
+// removeRunDir delete temporary files of finished run; with keep_on_failure
+// files of a failed run stay on disk for debugging
+func (s *Service) removeRunDir(job config.BackupJob, dir string, runErr error) {
+	if runErr != nil && job.KeepOnFailure {
+		log.Warnf("Keeping temporary files of failed backup of %s in %s", job.IndexName, dir)
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		log.Warnf("Failed to remove temporary files in %s: %v", dir, err)
+		return
+	}
+	log.Infof("Cleaned up temporary files")
+}
+
+// tempPath local path of object in run directory, so concurrent or leftover
+// runs never collide; template subdirectories are flattened
+func (s *Service) tempPath(ctx context.Context, object string) string {
+	return filepath.Join(s.runDir(ctx), strings.ReplaceAll(object, "/", "_"))
+}
+
+// uploadMetadata S3 user metadata attached to uploaded objects; format and
+// its version let restore pick the reader without the manifest
+func uploadMetadata(ctx context.Context, job config.BackupJob, documents int) map[string]string {
+	return map[string]string{
+		"run-id":                       run.ID(ctx),
+		"documents":                    strconv.Itoa(documents),
+		manifest.MetadataFormat:        jobFormat(job),
+		manifest.MetadataFormatVersion: strconv.Itoa(manifest.FormatVersion),
+	}
+}
+
+// downloadPeriod download data for period, returns part files
+func (s *Service) downloadPeriod(ctx context.Context, job config.BackupJob, session *exportSession, date time.Time, p period, fileNum int) ([]exportFile, error) {
+	startTime, endTime := p.start, p.end
+
+	log.Infof("Downloading period %d: %s - %s", fileNum, startTime.Format(time.RFC3339), endTime.Format(time.RFC3339))
+
+	// Get count of documents
+	count, err := s.getCount(ctx, session, session.index, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get count: %w", opensearch.Classify(err))
+	}
+
+	if count == 0 {
+		log.Infof("No documents found for period %d", fileNum)
+		return nil, nil
+	}
+
+	log.Infof("Found %d documents for period %d", count, fileNum)
+
+	slices := []period{{start: startTime, end: endTime}}
+	if session.maxPeriodDocs > 0 && count > session.maxPeriodDocs {
+		if slices, err = s.splitPeriod(ctx, session, session.index, slices[0], count); err != nil {
+			return nil, fmt.Errorf("failed to split period: %w", opensearch.Classify(err))
+		}
+		log.Infof("Period %d split into %d slices of at most %d documents", fileNum, len(slices), session.maxPeriodDocs)
+	}
+
+	// Pages of all slices come from one point in time of the period
+	pit, err := s.openPointInTime(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	defer s.closePointInTime(ctx, pit)
+
+	// Download documents, part numbers continue across slices
+	var files []exportFile
+	parts := &partNumbers{}
+	for _, slice := range slices {
+		sliceFiles, err := s.exportSliced(ctx, job, session, pit, date, slice, fileNum, parts)
+		files = append(files, sliceFiles...)
+		if err != nil {
+			return files, fmt.Errorf("failed to search and save: %w", err)
+		}
+	}
+
+	return files, nil
+}
+
+// getCount get count of documents for period, with the same query, sampling
+// and shard selection as export searches
+func (s *Service) getCount(ctx context.Context, session *exportSession, indexName string, startTime, endTime time.Time) (int, error) {
+	body, err := json.Marshal(map[string]interface{}{"query": session.periodQuery(startTime, endTime)})
+	if err != nil {
+		return 0, fmt.Errorf("failed to build count request: %w", err)
+	}
+
+	var resp struct {
+		Count int `json:"count"`
+	}
+	if err := s.client.Do(ctx, "POST", "/"+indexName+"/_count"+session.searchParams, bytes.NewReader(body), &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Count, nil
+}
+
+// searchAndSave page through slice of period with search_after and stream each page
+// straight to disk, starting a new part file every max_docs_per_file documents;
+// documents are counted while writing so files never need to be decoded again.
+// Part files are created only once there are documents for them, numbered by parts
+func (s *Service) searchAndSave(ctx context.Context, job config.BackupJob, session *exportSession, pit *pointInTime, date time.Time, slice period, periodNum int, parts *partNumbers) ([]exportFile, error) {
+	startTime, endTime := slice.start, slice.end
+	var files []exportFile
+	var part *partFile
+	var extras []*partFile // parts of session extra formats, written alongside
+	current := 0           // files index of part
+	var searchAfter []interface{}
+
+	closeParts := func() error {
+		err := part.Close()
+		for _, extra := range extras {
+			if extraErr := extra.Close(); err == nil {
+				err = extraErr
+			}
+		}
+		return err
+	}
+	defer func() {
+		if part != nil {
+			closeParts()
+		}
+	}()
+
+	nextPart := func() error {
+		if part != nil {
+			if err := closeParts(); err != nil {
+				return err
+			}
+		}
+
+		base, err := session.names.PartFile(job.IndexName, date, run.ID(ctx), periodNum, parts.take())
+		if err != nil {
+			return err
+		}
+		object := base + formatExtension(job) + session.codec.extension()
+		filename := s.tempPath(ctx, object)
+
+		// Chunks are standalone objects and carry own header, merged
+		// artifact gets single header from compressor
+		var header []byte
+		if job.Chunked {
+			header = session.encoder.header()
+		}
+
+		if part, err = createPartFile(filename, object, session.codec, compressionWorkers(job), header); err != nil {
+			return err
+		}
+		current = len(files)
+		files = append(files, exportFile{Name: filename, Object: object, Hours: hourCounts{}})
+
+		extras = extras[:0]
+		for _, extra := range session.extras {
+			extraObject := base + formatExtension(extra.job) + session.codec.extension()
+			extraPart, err := createPartFile(s.tempPath(ctx, extraObject), extraObject, session.codec, compressionWorkers(job), nil)
+			if err != nil {
+				return err
+			}
+			extras = append(extras, extraPart)
+			files = append(files, exportFile{Name: extraPart.name, Object: extraObject, Format: extra.job.Format})
+		}
+		return nil
+	}
+	// full no part yet, or current one holds max_docs_per_file documents
+	full := func() bool {
+		return part == nil || (job.MaxDocsPerFile > 0 && part.docs >= job.MaxDocsPerFile)
+	}
+
+	for {
+		limit := 0
+		if job.MaxDocsPerFile > 0 {
+			limit = job.MaxDocsPerFile
+			if !full() {
+				limit -= part.docs
+			}
+		}
+
+		page := getBuffer()
+		hits, size, err := s.fetchPage(ctx, session, pit, startTime, endTime, limit, searchAfter, page)
+		if err != nil {
+			putBuffer(page)
+			return files, err
+		}
+
+		if len(hits) == 0 {
+			putBuffer(page)
+			break
+		}
+
+		kept := session.unique(hits)
+		if len(kept) > 0 {
+			// Spill to next part file when current one is full
+			if full() {
+				if err := nextPart(); err != nil {
+					putBuffer(page)
+					return files, err
+				}
+			}
+			err = part.writePage(session.encoder, page.Bytes(), kept)
+			for j := 0; j < len(extras) && err == nil; j++ {
+				err = extras[j].writePage(session.extras[j].encoder, page.Bytes(), kept)
+			}
+		}
+		putBuffer(page)
+		if err != nil {
+			return files, fmt.Errorf("failed to write page: %w", err)
+		}
+		if len(kept) > 0 {
+			part.docs += len(kept)
+			for j := current; j < len(files); j++ {
+				files[j].Docs = part.docs
+			}
+			files[current].Hours.add(kept)
+		}
+
+		if len(hits) < size {
+			break
+		}
+		searchAfter = hits[len(hits)-1].Sort
+	}
+
+	if part == nil {
+		return files, nil
+	}
+	if err := closeParts(); err != nil {
+		return files, err
+	}
+	part = nil
+
+	if parts := session.parts(files); parts > 1 {
+		log.Infof("Period exported into %d part files", parts)
+	}
+
+	return files, nil
+}
+
+// pageHit part of search hit needed for paging, _source is kept raw
+type pageHit struct {
+	Index   string          `json:"_index"`
+	ID      string          `json:"_id"`
+	Routing string          `json:"_routing"`
+	Source  json.RawMessage `json:"_source"`
+	Fields  json.RawMessage `json:"fields"`
+	Sort    []interface{}   `json:"sort"`
+}
+
+// searchPage fetch single page of period sorted by timestamp, continuing after given sort values,
+// from point in time pit unless nil; raw response body is left in page, only hits are decoded
+func (s *Service) searchPage(ctx context.Context, session *exportSession, pit *pointInTime, startTime, endTime time.Time, size int, searchAfter []interface{}, page *bytes.Buffer) ([]pageHit, error) {
+	body := map[string]interface{}{
+		"query": session.periodQuery(startTime, endTime),
+		// _id as tiebreaker for documents sharing the same timestamp
+		"sort": []interface{}{
+			map[string]interface{}{"@timestamp": map[string]interface{}{"order": "asc"}},
+			map[string]interface{}{"_id": map[string]interface{}{"order": "asc"}},
+		},
+		"size":             size,
+		"track_total_hits": false,
+	}
+	if searchAfter != nil {
+		body["search_after"] = searchAfter
+	}
+	path := "/" + session.index + "/_search" + session.searchParams
+	if pit != nil {
+		body["pit"] = map[string]interface{}{"id": pit.id, "keep_alive": pit.keepAlive}
+		path = "/_search"
+		if pit.slices > 1 {
+			body["slice"] = map[string]interface{}{"id": pit.slice, "max": pit.slices}
+		}
+	}
+	profiled := session.profiled()
+	if profiled {
+		body["profile"] = true
+	}
+	if session.fieldsMode() {
+		body["_source"] = false
+		if len(session.docvalueFields) > 0 {
+			body["docvalue_fields"] = session.docvalueFields
+		}
+		if len(session.storedFields) > 0 {
+			body["stored_fields"] = session.storedFields
+		}
+	}
+
+	request := getBuffer()
+	defer putBuffer(request)
+	if err := json.NewEncoder(request).Encode(body); err != nil {
+		return nil, fmt.Errorf("failed to build search request: %w", err)
+	}
+
+	if err := s.client.DoRaw(ctx, "POST", path, request, page); err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		PitID string `json:"pit_id"`
+		Hits  struct {
+			Hits []pageHit `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(page.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %w", err)
+	}
+	if profiled {
+		if err := logProfile(session.index, startTime, endTime, len(resp.Hits.Hits), page); err != nil {
+			log.Warnf("Failed to read profile of export search of %s: %v", session.index, err)
+		}
+	}
+	if pit != nil && resp.PitID != "" {
+		pit.id = resp.PitID
+	}
+	if session.fieldsMode() {
+		// Encoders work on _source, so build it from returned fields
+		for i := range resp.Hits.Hits {
+			source, err := fieldsSource(resp.Hits.Hits[i].Fields)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode fields of document %s: %w", resp.Hits.Hits[i].ID, err)
+			}
+			resp.Hits.Hits[i].Source = source
+		}
+		// format: search writes the page as returned, without _source
+		if err := withSource(page, resp.Hits.Hits); err != nil {
+			return nil, fmt.Errorf("failed to add _source to search response: %w", err)
+		}
+	}
+
+	return resp.Hits.Hits, nil
+}
+
+// exportFile exported part file with number of documents written to it
+type exportFile struct {
+	Name   string // local path
+	Object string // deterministic object name, without run ID
+	Docs   int
+	Size   int64
+	Period int        // 1-based export period
+	Hours  hourCounts // documents per hour
+	Format string     // extra format of file, empty for the job format
+}
+
+// partFile output file of exported pages, compressed as it is written into
+// a standalone gzip member or zstd frame
+type partFile struct {
+	name   string
+	file   *os.File
+	buffer *bufio.Writer
+	writer io.WriteCloser
+	docs   int
+	closed bool
+}
+
+func createPartFile(name, object string, compression codec, workers int, header []byte) (*partFile, error) {
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bufio.NewWriter(file)
+	writer, err := compression.writer(buffer, strings.TrimSuffix(object, compression.extension()), workers)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := writer.Write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &partFile{
+		name:   name,
+		file:   file,
+		buffer: buffer,
+		writer: writer,
+	}, nil
+}
+
+// writePage write page to part file
+func (p *partFile) writePage(encoder pageEncoder, page []byte, hits []pageHit) error {
+	return writePage(p.writer, encoder, page, hits)
+}
+
+// Close finish compressed member, flush and close file, safe to call twice
+func (p *partFile) Close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	if err := p.writer.Close(); err != nil {
+		p.file.Close()
+		return err
+	}
+	if err := p.buffer.Flush(); err != nil {
+		p.file.Close()
+		return err
+	}
+	return p.file.Close()
+}
+
+// writePage encode page through pooled buffer and write it in one call
+func writePage(w io.Writer, encoder pageEncoder, page []byte, hits []pageHit) error {
+	lines := getBuffer()
+	defer putBuffer(lines)
+
+	if err := encoder.encode(lines, page, hits); err != nil {
+		return err
+	}
+
+	_, err := w.Write(lines.Bytes())
+	return err
+}
+
+// exportSession per-run export state shared by all periods
+type exportSession struct {
+	index      string // searched indices: index_name without excluded indices
+	tuner      *tuner // page size tuning carries over between periods
+	encoder    pageEncoder
+	recipients []age.Recipient
+	codec      codec
+	// query string of search requests (preference, routing)
+	searchParams string
+	// keep_alive of point in time opened per period, empty without point_in_time
+	pitKeepAlive string
+	slices       int // concurrent sliced searches per period, 1 unsliced
+	names        *naming.Template
+	// export doc values / stored fields instead of _source
+	docvalueFields []string
+	storedFields   []string
+	sampleRate     float64       // fraction of documents exported, 0 for all
+	profileRate    float64       // fraction of search pages profiled, 0 for none
+	maxPeriodDocs  int           // split periods above this count, 0 disables
+	expected       int           // day count of completeness check
+	incomplete     bool          // exported documents did not match expected
+	failedPeriods  []int         // periods skipped under allow_partial
+	lateOverlap    time.Duration // previous day tail re-exported by first period
+	// time range [from, to) of manual run, zero for the whole window
+	from, to time.Time
+	// export filter AND-ed with period range, nil for all documents
+	filter map[string]interface{}
+	// _id hashes of exported documents, nil unless dedup is enabled; seenMu
+	// guards them against concurrent slices
+	seen       dedupSet
+	seenMu     sync.Mutex
+	duplicates int
+	hours      hourCounts // exported documents per hour, of completed periods only
+	// formats written alongside encoder and S3 keys of their artifacts by format
+	extras       []extraFormat
+	extraObjects map[string]string
+	mapping      string // S3 key of archived index mapping, empty unless archive_mapping
+}
+
+// checkIndex skip the run when index_name matches no index (404 for names
+// and aliases, nothing matched for patterns), or fail it with
+// missing_index: fail; an existing index without documents is backed up
+func (s *Service) checkIndex(ctx context.Context, job config.BackupJob) error {
+	switch job.MissingIndex {
+	case "", config.MissingIndexSkip, config.MissingIndexFail:
+	default:
+		return fmt.Errorf("%w: missing_index %q must be \"skip\" or \"fail\"", errs.ErrInvalidConfig, job.MissingIndex)
+	}
+	indices, err := s.client.ResolveIndices(ctx, job.IndexName)
+	if err != nil && !errors.Is(err, errs.ErrIndexNotFound) {
+		return err
+	}
+	if len(indices) > 0 {
+		return nil
+	}
+	missing := fmt.Errorf("no such index %s: %w", job.IndexName, errs.ErrIndexNotFound)
+	if job.MissingIndex == config.MissingIndexFail {
+		return missing
+	}
+	return fmt.Errorf("%w: %w", errs.ErrSkipped, missing)
+}
+
+// searchTarget index expression searched for job: index_name itself unless
+// some of its indices match exclude_indices. Wildcards keep matching new
+// indices with the excluded ones subtracted ("logs-*,-logs-old"), other
+// expressions are replaced by the remaining indices
+func (s *Service) searchTarget(ctx context.Context, job config.BackupJob) (string, error) {
+	if len(job.ExcludeIndices) == 0 {
+		return job.IndexName, nil
+	}
+	indices, err := s.client.ResolveIndices(ctx, job.IndexName)
+	if err != nil {
+		return "", err
+	}
+	kept, excluded := config.ExcludeIndices(indices, job.ExcludeIndices)
+	if len(excluded) == 0 {
+		return job.IndexName, nil
+	}
+	if len(kept) == 0 {
+		return "", fmt.Errorf("%w: every index of %s matches exclude_indices", errs.ErrInvalidConfig, job.IndexName)
+	}
+	log.Infof("Excluded %d indices of %s from backup: %s", len(excluded), job.IndexName, strings.Join(excluded, ", "))
+	if !strings.Contains(job.IndexName, "*") {
+		return strings.Join(kept, ","), nil
+	}
+	return job.IndexName + ",-" + strings.Join(excluded, ",-"), nil
+}
+
+// periodQuery query selecting documents of period; with sampling, a seeded
+// random score per document filtered by min_score keeps about sample_rate of
+// them, repeatably for the same day (seeded by day number)
+func (e *exportSession) periodQuery(startTime, endTime time.Time) map[string]interface{} {
+	query := map[string]interface{}{
+		"range": map[string]interface{}{
+			"@timestamp": map[string]interface{}{
+				"gte": startTime.Format(time.RFC3339Nano),
+				"lte": endTime.Format(time.RFC3339Nano),
+			},
+		},
+	}
+	if e.filter != nil {
+		query = map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{query, e.filter},
+			},
+		}
+	}
+	if e.sampleRate <= 0 {
+		return query
+	}
+
+	return map[string]interface{}{
+		"function_score": map[string]interface{}{
+			"query": query,
+			"functions": []interface{}{
+				map[string]interface{}{
+					"random_score": map[string]interface{}{
+						"seed":  startTime.Unix() / 86400,
+						"field": "_seq_no",
+					},
+				},
+			},
+			"boost_mode": "replace",
+			"min_score":  1 - e.sampleRate,
+		},
+	}
+}
+
+// parts number of part files among files, each written once per format
+func (e *exportSession) parts(files []exportFile) int {
+	return len(files) / (len(e.extras) + 1)
+}
+
+// fieldsMode whether documents are exported from fields instead of _source
+func (e *exportSession) fieldsMode() bool {
+	return len(e.docvalueFields) > 0 || len(e.storedFields) > 0
+}
+
+// newExportSession validate job export settings and prepare run state
+func (s *Service) newExportSession(ctx context.Context, job config.BackupJob) (*exportSession, error) {
+	t, err := newTuner(job)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoder pageEncoder
+	if jobFormat(job) == FormatAvro {
+		// Schema may come from index mapping and registry, so needs cluster access
+		encoder, err = s.newAvroFormat(ctx, job)
+	} else {
+		encoder, err = newEncoder(job)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rcpts, err := recipients(job)
+	if err != nil {
+		return nil, err
+	}
+	// Key is read again for the manifest, a bad one fails before the export
+	if _, err := signingKey(job); err != nil {
+		return nil, err
+	}
+	if err := checkInterval(job); err != nil {
+		return nil, err
+	}
+	var window config.UploadWindowConfig
+	if s.config != nil {
+		window = s.config.UploadWindow
+	}
+	if err := checkPurge(job, window); err != nil {
+		return nil, err
+	}
+	if job.Dedup && jobFormat(job) == FormatSearch {
+		return nil, fmt.Errorf("%w: dedup needs a document format, raw search responses cannot be filtered", errs.ErrInvalidConfig)
+	}
+	if job.SampleRate < 0 || job.SampleRate > 1 {
+		return nil, fmt.Errorf("%w: sample_rate %v must be between 0 and 1", errs.ErrInvalidConfig, job.SampleRate)
+	}
+	names, err := naming.Parse(job.FilenameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errs.ErrInvalidConfig, err)
+	}
+
+	session := &exportSession{
+		tuner:          t,
+		encoder:        encoder,
+		recipients:     rcpts,
+		names:          names,
+		searchParams:   searchParams(job),
+		docvalueFields: job.DocValueFields,
+		storedFields:   job.StoredFields,
+		hours:          hourCounts{},
+	}
+	if job.SampleRate < 1 {
+		session.sampleRate = job.SampleRate
+	}
+	if session.maxPeriodDocs, err = s.maxPeriodDocs(ctx, job); err != nil {
+		return nil, err
+	}
+	if job.LateOverlap != "" {
+		if session.lateOverlap, err = config.ParseDuration(job.LateOverlap); err != nil || session.lateOverlap < 0 {
+			return nil, fmt.Errorf("%w: late_overlap %q", errs.ErrInvalidConfig, job.LateOverlap)
+		}
+	}
+	if session.filter, err = s.exportFilter(ctx, job); err != nil {
+		return nil, err
+	}
+	if session.index, err = s.searchTarget(ctx, job); err != nil {
+		return nil, err
+	}
+	if session.extras, err = s.extraFormats(ctx, job); err != nil {
+		return nil, err
+	}
+	if session.codec, err = jobCodec(job); err != nil {
+		return nil, err
+	}
+	if s.config != nil {
+		if session.codec.pool, err = sharedPool(s.config.CompressionPool); err != nil {
+			return nil, err
+		}
+	}
+	if session.pitKeepAlive, err = pitKeepAlive(job); err != nil {
+		return nil, err
+	}
+	if session.slices, err = exportSlices(job); err != nil {
+		return nil, err
+	}
+	if session.profileRate, err = profileRate(job); err != nil {
+		return nil, err
+	}
+	if o := run.OverridesOf(ctx); o.Ranged() {
+		session.from, session.to = o.From, o.To
+	}
+	if job.Dedup {
+		session.seen = make(dedupSet)
+	}
+	return session, nil
+}
+
+// searchParams query string pinning export searches to shard copies or routing values
+func searchParams(job config.BackupJob) string {
+	params := url.Values{}
+	if job.Preference != "" {
+		params.Set("preference", job.Preference)
+	}
+	if job.Routing != "" {
+		params.Set("routing", job.Routing)
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + params.Encode()
+}
+
+// cleanup delete temporary files of failed period
+func (s *Service) cleanup(tempFiles []exportFile) {
+	for _, file := range tempFiles {
+		os.Remove(file.Name)
+	}
+}
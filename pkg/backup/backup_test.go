@@ -0,0 +1,48 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+)
+
+func TestCheckIndexMissing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"indices":[],"aliases":[],"data_streams":[]}`))
+	}))
+	defer srv.Close()
+	client, err := opensearch.NewClient(config.OpenSearchConfig{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{client: client}
+
+	tests := []struct {
+		missingIndex string
+		skipped      bool
+	}{
+		{"", true},
+		{config.MissingIndexSkip, true},
+		{config.MissingIndexFail, false},
+	}
+	for _, tt := range tests {
+		err := s.checkIndex(context.Background(), config.BackupJob{IndexName: "tenant-*", MissingIndex: tt.missingIndex})
+		if !errors.Is(err, errs.ErrIndexNotFound) {
+			t.Errorf("missing_index %q: error %v, want index not found", tt.missingIndex, err)
+		}
+		if errors.Is(err, errs.ErrSkipped) != tt.skipped {
+			t.Errorf("missing_index %q: skipped = %v, want %v", tt.missingIndex, !tt.skipped, tt.skipped)
+		}
+	}
+
+	if err := s.checkIndex(context.Background(), config.BackupJob{IndexName: "logs", MissingIndex: "ignore"}); !errors.Is(err, errs.ErrInvalidConfig) {
+		t.Errorf("unknown missing_index: error %v, want invalid config", err)
+	}
+}
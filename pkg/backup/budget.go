@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	humanize "github.com/dustin/go-humanize"
+	log "github.com/sirupsen/logrus"
+)
+
+// diskBudget bounds bytes of exported files waiting for compression or upload;
+// export pauses before next period while usage is above the cap.
+// Nil budget means unlimited
+type diskBudget struct {
+	limit uint64
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	used uint64
+}
+
+// newDiskBudget create budget from human-readable size ("20GB"), nil when empty
+func newDiskBudget(maxUsage string) (*diskBudget, error) {
+	if maxUsage == "" {
+		return nil, nil
+	}
+
+	limit, err := humanize.ParseBytes(maxUsage)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &diskBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b, nil
+}
+
+// track account sizes of freshly exported files
+func (b *diskBudget) track(files []exportFile) {
+	for i := range files {
+		if info, err := os.Stat(files[i].Name); err == nil {
+			files[i].Size = info.Size()
+		}
+	}
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	for _, file := range files {
+		b.used += uint64(file.Size)
+	}
+	b.mu.Unlock()
+}
+
+// release return space of file removed from disk
+func (b *diskBudget) release(file exportFile) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	b.used -= min(b.used, uint64(file.Size))
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// wait block while usage is above the cap, until queued files drain
+func (b *diskBudget) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+
+	// Wake waiter on cancellation
+	stop := context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		b.cond.Broadcast()
+		b.mu.Unlock()
+	})
+	defer stop()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.used >= b.limit {
+		log.Infof("Local disk usage %s reached max_disk_usage %s, pausing export until uploads drain",
+			humanize.Bytes(b.used), humanize.Bytes(b.limit))
+	}
+	for b.used >= b.limit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b.cond.Wait()
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"github.com/okto/opensearch-backup-manager/pkg/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// progressSuffix object name suffix of progress checkpoint, next to the manifest
+const progressSuffix = ".progress.json"
+
+// progressPeriod export period completed by a run
+type progressPeriod struct {
+	Period    int        `json:"period"` // 1-based
+	Start     time.Time  `json:"start"`
+	End       time.Time  `json:"end"`
+	Documents int        `json:"documents"`
+	Objects   []string   `json:"objects,omitempty"` // uploaded chunks, chunked jobs only
+	Hours     hourCounts `json:"hours,omitempty"`   // documents per hour, see manifest.HourLayout
+}
+
+// progress checkpoint of running backup, uploaded after every completed period
+// (or saved in the state store) and removed once the manifest is written
+type progress struct {
+	Index       string           `json:"index"`
+	Date        string           `json:"date"`
+	RunID       string           `json:"run_id"`
+	ResumedFrom []string         `json:"resumed_from,omitempty"` // earlier runs periods were taken over from
+	Periods     int              `json:"periods"`
+	Completed   []progressPeriod `json:"completed"`
+	Documents   int              `json:"documents"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// checkpoint progress of one run; a nil *checkpoint is valid and records
+// nothing (checkpoint disabled)
+type checkpoint struct {
+	s       *Service
+	ctx     context.Context
+	key     string
+	storage string // storage profile of job, part of the state store key
+	chunked bool
+
+	mu      sync.Mutex
+	state   progress
+	resumed map[int]progressPeriod  // by 0-based period, taken over from earlier run
+	pending map[int]*progressPeriod // exported chunked periods waiting for uploads
+	waiting map[int]int             // chunks still uploading by period
+}
+
+// startCheckpoint checkpoint of run exporting ranges of date, nil unless job
+// has checkpoint; chunked jobs take over periods a failed earlier run of the
+// same window completed, since their chunks are already in storage
+func (s *Service) startCheckpoint(ctx context.Context, job config.BackupJob, session *exportSession, date time.Time, ranges []period) (*checkpoint, error) {
+	if !job.Checkpoint {
+		return nil, nil
+	}
+	// Rendered without run ID, a template using .RunID still finds the
+	// checkpoint of the failed run
+	name, err := session.names.Artifact(job.IndexName, date, "")
+	if err != nil {
+		return nil, err
+	}
+	c := &checkpoint{
+		s:       s,
+		ctx:     ctx,
+		key:     path.Join(job.S3Path, name+progressSuffix),
+		storage: job.Storage,
+		chunked: job.Chunked,
+		state: progress{
+			Index:   job.IndexName,
+			Date:    date.Format("2006-01-02"),
+			RunID:   run.ID(ctx),
+			Periods: len(ranges),
+		},
+		resumed: make(map[int]progressPeriod),
+		pending: make(map[int]*progressPeriod),
+		waiting: make(map[int]int),
+	}
+	if !job.Chunked {
+		return c, nil
+	}
+
+	data, err := c.load()
+	if err != nil || data == nil {
+		return c, err
+	}
+	var previous progress
+	if err := json.Unmarshal(data, &previous); err != nil {
+		log.Warnf("Ignoring invalid progress checkpoint %s: %v", c.key, err)
+		return c, nil
+	}
+	if previous.Date != c.state.Date || previous.Index != c.state.Index {
+		return c, nil
+	}
+	// Only periods with the same bounds are taken over
+	for _, p := range previous.Completed {
+		i := p.Period - 1
+		if i < 0 || i >= len(ranges) || !ranges[i].start.Equal(p.Start) || !ranges[i].end.Equal(p.End) {
+			continue
+		}
+		c.resumed[i] = p
+		c.state.Completed = append(c.state.Completed, p)
+		session.hours.merge(p.Hours)
+		c.state.Documents += p.Documents
+	}
+	if len(c.resumed) > 0 {
+		c.state.ResumedFrom = append(previous.ResumedFrom, previous.RunID)
+		log.WithField("run_id", run.ID(ctx)).Infof("Resuming backup of %s for %s from run %s: %d of %d periods already completed",
+			job.IndexName, c.state.Date, previous.RunID, len(c.resumed), len(ranges))
+	}
+	return c, nil
+}
+
+// done check if period i was completed by an earlier run
+func (c *checkpoint) done(i int) bool {
+	if c == nil {
+		return false
+	}
+	_, ok := c.resumed[i]
+	return ok
+}
+
+// resumedTotals documents and objects of periods taken over from earlier runs
+func (c *checkpoint) resumedTotals() (int, []string) {
+	if c == nil {
+		return 0, nil
+	}
+	docs := 0
+	var objects []string
+	for i := 0; i < c.state.Periods; i++ {
+		if p, ok := c.resumed[i]; ok {
+			docs += p.Documents
+			objects = append(objects, p.Objects...)
+		}
+	}
+	return docs, objects
+}
+
+// exported record period i exported into files; chunked periods complete
+// once all their chunks are uploaded
+func (c *checkpoint) exported(i int, p period, files []exportFile) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	done := progressPeriod{Period: i + 1, Start: p.start, End: p.end, Hours: hourCounts{}}
+	for _, file := range files {
+		if file.Format != "" {
+			continue
+		}
+		done.Documents += file.Docs
+		done.Hours.merge(file.Hours)
+	}
+	if !c.chunked || len(files) == 0 {
+		c.complete(done)
+		return
+	}
+	c.pending[i] = &done
+	c.waiting[i] = len(files)
+}
+
+// uploaded record chunk of period uploaded to key
+func (c *checkpoint) uploaded(file exportFile, key string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	i := file.Period - 1
+	p, ok := c.pending[i]
+	if !ok {
+		return
+	}
+	p.Objects = append(p.Objects, key)
+	if c.waiting[i]--; c.waiting[i] == 0 {
+		delete(c.pending, i)
+		delete(c.waiting, i)
+		sort.Strings(p.Objects)
+		c.complete(*p)
+	}
+}
+
+// complete add completed period and upload checkpoint; failures are logged,
+// the checkpoint never fails the backup. Called with mu held
+func (c *checkpoint) complete(p progressPeriod) {
+	c.state.Completed = append(c.state.Completed, p)
+	sort.Slice(c.state.Completed, func(a, b int) bool { return c.state.Completed[a].Period < c.state.Completed[b].Period })
+	c.state.Documents += p.Documents
+	c.state.UpdatedAt = c.s.clock.Now().UTC()
+
+	data, err := json.MarshalIndent(c.state, "", "  ")
+	if err == nil {
+		if c.s.state != nil {
+			err = c.s.state.Put(c.ctx, state.Checkpoints, c.stateKey(), data)
+		} else {
+			err = c.s.s3Client.Put(c.ctx, c.key, data, map[string]string{"run-id": c.state.RunID})
+		}
+	}
+	if err != nil {
+		log.Warnf("Failed to upload progress checkpoint %s: %v", c.key, err)
+	}
+}
+
+// load previous checkpoint of the same artifact, nil when there is none
+func (c *checkpoint) load() ([]byte, error) {
+	if c.s.state != nil {
+		data, err := c.s.state.Get(c.ctx, state.Checkpoints, c.stateKey())
+		if errors.Is(err, state.ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read progress checkpoint: %w", err)
+		}
+		return data, nil
+	}
+	exists, err := c.s.s3Client.Exists(c.ctx, c.key)
+	if err != nil || !exists {
+		return nil, err
+	}
+	data, err := c.s.s3Client.Get(c.ctx, c.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read progress checkpoint: %w", err)
+	}
+	return data, nil
+}
+
+// stateKey key of checkpoint in state store, object key within storage profile
+func (c *checkpoint) stateKey() string {
+	return c.storage + ":" + c.key
+}
+
+// finish remove checkpoint once the manifest is written or the artifact is
+// queued for upload
+func (c *checkpoint) finish() {
+	if c == nil {
+		return
+	}
+	var err error
+	if c.s.state != nil {
+		err = c.s.state.Delete(c.ctx, state.Checkpoints, c.stateKey())
+	} else {
+		err = c.s.s3Client.Delete(c.ctx, c.key)
+	}
+	if err != nil {
+		log.Warnf("Failed to remove progress checkpoint %s: %v", c.key, err)
+	}
+}
@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/clock"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/naming"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"github.com/okto/opensearch-backup-manager/pkg/state"
+)
+
+// memState checkpoints namespace of state store; other Store methods are not used
+type memState struct {
+	state.Store
+	values map[string][]byte
+}
+
+func (m *memState) Get(_ context.Context, namespace, key string) ([]byte, error) {
+	value, ok := m.values[namespace+"/"+key]
+	if !ok {
+		return nil, state.ErrNotFound
+	}
+	return value, nil
+}
+
+func (m *memState) Put(_ context.Context, namespace, key string, value []byte) error {
+	m.values[namespace+"/"+key] = value
+	return nil
+}
+
+func (m *memState) Delete(_ context.Context, namespace, key string) error {
+	delete(m.values, namespace+"/"+key)
+	return nil
+}
+
+func TestCheckpointResume(t *testing.T) {
+	date := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	ranges := func(hours int) []period {
+		var ranges []period
+		for start := date; start.Before(date.AddDate(0, 0, 1)); start = start.Add(time.Duration(hours) * time.Hour) {
+			ranges = append(ranges, period{start: start, end: start.Add(time.Duration(hours) * time.Hour)})
+		}
+		return ranges
+	}
+	// .RunID makes artifact names differ between runs, the checkpoint must not
+	names, err := naming.Parse(`{{.ISODate}}-{{.Index}}-{{.RunID}}{{with .Part}}-{{.}}{{end}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := config.BackupJob{IndexName: "logs", S3Path: "backups", Checkpoint: true, Chunked: true}
+
+	// Failed run: period 1 uploaded, period 2 exported with one of two chunks uploaded
+	store := &memState{values: map[string][]byte{}}
+	s := &Service{clock: clock.Fixed(date.AddDate(0, 0, 1)), state: store}
+	failed := &exportSession{names: names, hours: hourCounts{}}
+	c, err := s.startCheckpoint(run.WithID(context.Background(), "run-a"), job, failed, date, ranges(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.exported(0, ranges(8)[0], []exportFile{{Docs: 10, Period: 1}})
+	c.uploaded(exportFile{Period: 1}, "backups/2026-03-10-logs-run-a-1")
+	c.exported(1, ranges(8)[1], []exportFile{{Docs: 5, Period: 2}, {Docs: 5, Period: 2}})
+	c.uploaded(exportFile{Period: 2}, "backups/2026-03-10-logs-run-a-2")
+	if len(store.values) != 1 {
+		t.Fatalf("checkpoints = %d, want 1", len(store.values))
+	}
+
+	tests := []struct {
+		name       string
+		job        config.BackupJob
+		date       time.Time
+		ranges     []period
+		wantDone   []bool
+		wantDocs   int
+		wantObject []string
+	}{
+		{"resumed", job, date, ranges(8), []bool{true, false, false}, 10, []string{"backups/2026-03-10-logs-run-a-1"}},
+		{"other periods", job, date, ranges(6), []bool{false, false, false, false}, 0, nil},
+		{"file mode", config.BackupJob{IndexName: "logs", S3Path: "backups", Checkpoint: true}, date, ranges(8), []bool{false, false, false}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &exportSession{names: names, hours: hourCounts{}}
+			c, err := s.startCheckpoint(run.WithID(context.Background(), "run-b"), tt.job, session, tt.date, tt.ranges)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range tt.wantDone {
+				if got := c.done(i); got != want {
+					t.Errorf("done(%d) = %v, want %v", i, got, want)
+				}
+			}
+			docs, objects := c.resumedTotals()
+			if docs != tt.wantDocs || !reflect.DeepEqual(objects, tt.wantObject) {
+				t.Errorf("resumed = %d %v, want %d %v", docs, objects, tt.wantDocs, tt.wantObject)
+			}
+			if tt.wantDocs > 0 && !reflect.DeepEqual(c.state.ResumedFrom, []string{"run-a"}) {
+				t.Errorf("resumed_from = %v, want [run-a]", c.state.ResumedFrom)
+			}
+		})
+	}
+
+	// Checkpoint is gone once the manifest is written
+	c.finish()
+	if len(store.values) != 0 {
+		t.Errorf("checkpoints = %d after finish, want 0", len(store.values))
+	}
+}
+
+func TestCheckpointDisabled(t *testing.T) {
+	s := &Service{}
+	c, err := s.startCheckpoint(context.Background(), config.BackupJob{IndexName: "logs"}, &exportSession{}, time.Now(), nil)
+	if err != nil || c != nil {
+		t.Fatalf("checkpoint = %v, %v, want nil", c, err)
+	}
+	// nil checkpoint records nothing
+	c.exported(0, period{}, nil)
+	c.uploaded(exportFile{Period: 1}, "key")
+	c.finish()
+	if c.done(0) {
+		t.Error("nil checkpoint done")
+	}
+}
@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+
+	"filippo.io/age"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultUploadConcurrency parallel chunk uploads when not configured
+const defaultUploadConcurrency = 2
+
+// periodSink consumes exported period files while export continues
+type periodSink interface {
+	add(files []exportFile)
+	finish() (int, error)
+}
+
+// chunkUploader uploads every finished part file as a separate object while
+// subsequent parts are still being exported
+type chunkUploader struct {
+	files chan exportFile
+	wg    sync.WaitGroup
+
+	mu   sync.Mutex
+	keys []string
+	docs int
+	errs []error
+}
+
+// startChunkUploader start upload workers for chunked output, calling
+// uploaded after every successful chunk upload
+func (s *Service) startChunkUploader(ctx context.Context, job config.BackupJob, budget *diskBudget, rcpts []age.Recipient, compression codec, uploaded func(file exportFile, key string)) *chunkUploader {
+	workers := job.UploadConcurrency
+	if workers <= 0 {
+		workers = defaultUploadConcurrency
+	}
+
+	u := &chunkUploader{
+		files: make(chan exportFile, 64),
+	}
+
+	for i := 0; i < workers; i++ {
+		u.wg.Add(1)
+		go func() {
+			defer u.wg.Done()
+			for file := range u.files {
+				var key string
+				err := run.Protect(ctx, "upload of "+file.Object, func() (err error) {
+					key, err = s.uploadChunk(ctx, job, file, rcpts, compression)
+					return err
+				})
+				// Part file is removed even on failed upload
+				budget.release(file)
+
+				u.mu.Lock()
+				if err != nil {
+					u.errs = append(u.errs, fmt.Errorf("%s: %w", file.Object, err))
+				} else {
+					u.keys = append(u.keys, key)
+					u.docs += file.Docs
+				}
+				u.mu.Unlock()
+				if err == nil {
+					uploaded(file, key)
+				}
+			}
+		}()
+	}
+
+	return u
+}
+
+// uploadChunk upload single compressed part file, encrypted first when
+// recipients are set; local files are removed afterwards
+func (s *Service) uploadChunk(ctx context.Context, job config.BackupJob, file exportFile, rcpts []age.Recipient, compression codec) (string, error) {
+	defer os.Remove(file.Name)
+
+	name := file.Name
+	if len(rcpts) > 0 {
+		name = file.Name + encryptedSuffix
+		if err := encryptFile(file.Name, name, rcpts); err != nil {
+			return "", err
+		}
+		defer os.Remove(name)
+	}
+
+	key := chunkKey(job, file)
+	if err := s.s3Client.Upload(ctx, name, key, file.Docs, compression.metadata(uploadMetadata(ctx, job, file.Docs))); err != nil {
+		return "", fmt.Errorf("failed to upload to S3: %w", err)
+	}
+
+	return key, nil
+}
+
+// chunkKey S3 key of uploaded part file
+func chunkKey(job config.BackupJob, file exportFile) string {
+	object := file.Object
+	if job.Encryption.Enabled() {
+		object += encryptedSuffix
+	}
+	return path.Join(job.S3Path, object)
+}
+
+// add queue finished part files for upload
+func (u *chunkUploader) add(files []exportFile) {
+	for _, file := range files {
+		u.files <- file
+	}
+}
+
+// finish wait for all queued chunks, returns total uploaded documents
+func (u *chunkUploader) finish() (int, error) {
+	close(u.files)
+	u.wg.Wait()
+
+	if len(u.errs) > 0 {
+		return u.docs, fmt.Errorf("%w: %d of %d chunks failed, first: %w", errs.ErrPartialFailure, len(u.errs), len(u.errs)+len(u.keys), u.errs[0])
+	}
+
+	log.Infof("Uploaded %d chunks (total documents: %d)", len(u.keys), u.docs)
+	return u.docs, nil
+}
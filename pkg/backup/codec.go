@@ -0,0 +1,291 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	codecGzip = "gzip"
+	codecZstd = "zstd"
+)
+
+const (
+	// dictionarySuffix object name suffix of trained zstd dictionaries
+	dictionarySuffix = ".zdict"
+	// dictionaryPointerSuffix object name suffix of the record of current dictionary of index
+	dictionaryPointerSuffix = ".zdict.json"
+
+	defaultDictionarySamples = 5000
+	defaultDictionarySize    = 112 << 10
+	defaultDictionaryMaxAge  = 30 * 24 * time.Hour
+	// minDictionarySamples fewer documents are not worth a dictionary
+	minDictionarySamples = 100
+	// maxSamplePage documents per sampling search, default max_result_window
+	maxSamplePage = 10000
+)
+
+// codec compression of artifacts; the zero value is gzip. Part files are
+// standalone gzip members or zstd frames, both concatenate into valid streams
+type codec struct {
+	zstd    bool
+	dict    []byte // zstd dictionary, nil for none
+	dictKey string // S3 key of dict
+	// shared compression threads, nil compresses with workers of every writer
+	pool *cpuPool
+}
+
+// jobCodec codec of job compression settings, without dictionary
+func jobCodec(job config.BackupJob) (codec, error) {
+	switch job.Compression.Codec {
+	case "", codecGzip:
+		if job.Compression.Dictionary.Enabled {
+			return codec{}, fmt.Errorf("%w: compression.dictionary needs codec %q", errs.ErrInvalidConfig, codecZstd)
+		}
+		return codec{}, nil
+	case codecZstd:
+		return codec{zstd: true}, nil
+	}
+	return codec{}, fmt.Errorf("%w: compression.codec %q must be %q or %q", errs.ErrInvalidConfig, job.Compression.Codec, codecGzip, codecZstd)
+}
+
+// extension object name extension of compressed artifacts
+func (c codec) extension() string {
+	if c.zstd {
+		return ".zst"
+	}
+	return ".gz"
+}
+
+// writer compressing writer into w; name is recorded in gzip header
+func (c codec) writer(w io.Writer, name string, workers int) (io.WriteCloser, error) {
+	if c.pool != nil {
+		return c.pool.writer(w, c, name)
+	}
+	if c.zstd {
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(workers)}
+		if c.dict != nil {
+			opts = append(opts, zstd.WithEncoderDict(c.dict))
+		}
+		return zstd.NewWriter(w, opts...)
+	}
+
+	gzipWriter := pgzip.NewWriter(w)
+	gzipWriter.Name = name
+	if err := gzipWriter.SetConcurrency(compressionBlockSize, workers); err != nil {
+		return nil, err
+	}
+	return gzipWriter, nil
+}
+
+// member write data as standalone gzip member or zstd frame
+func (c codec) member(w io.Writer, data []byte) error {
+	if c.zstd {
+		enc, err := c.writer(w, "", 1)
+		if err != nil {
+			return err
+		}
+		if _, err := enc.Write(data); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	if _, err := gzipWriter.Write(data); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// name codec recorded in manifests and metadata, empty for gzip
+func (c codec) name() string {
+	if c.zstd {
+		return codecZstd
+	}
+	return ""
+}
+
+// metadata add codec and dictionary of artifact to its metadata
+func (c codec) metadata(metadata map[string]string) map[string]string {
+	if name := c.name(); name != "" {
+		metadata[manifest.MetadataCompression] = name
+	}
+	if c.dictKey != "" {
+		metadata[manifest.MetadataDictionary] = c.dictKey
+	}
+	return metadata
+}
+
+// dictionaryPointer current dictionary of index, stored next to its backups
+type dictionaryPointer struct {
+	Key       string    `json:"key"`
+	TrainedAt time.Time `json:"trained_at"`
+	Samples   int       `json:"samples"` // documents trained on
+}
+
+// loadDictionary set zstd dictionary of session: the current one of index
+// while younger than max_age, otherwise one trained on documents sampled
+// from ranges. Failed training only costs compression ratio, artifacts are
+// then written without dictionary
+func (s *Service) loadDictionary(ctx context.Context, job config.BackupJob, session *exportSession, ranges []period) error {
+	cfg := job.Compression.Dictionary
+	if !session.codec.zstd || !cfg.Enabled || len(ranges) == 0 {
+		return nil
+	}
+	maxAge := defaultDictionaryMaxAge
+	if cfg.MaxAge != "" {
+		var err error
+		if maxAge, err = config.ParseDuration(cfg.MaxAge); err != nil || maxAge <= 0 {
+			return fmt.Errorf("%w: compression.dictionary.max_age %q", errs.ErrInvalidConfig, cfg.MaxAge)
+		}
+	}
+	maxSize := uint64(defaultDictionarySize)
+	if cfg.MaxSize != "" {
+		var err error
+		if maxSize, err = humanize.ParseBytes(cfg.MaxSize); err != nil || maxSize == 0 {
+			return fmt.Errorf("%w: compression.dictionary.max_size %q", errs.ErrInvalidConfig, cfg.MaxSize)
+		}
+	}
+	samples := cfg.Samples
+	if samples <= 0 {
+		samples = defaultDictionarySamples
+	}
+
+	pointerKey := path.Join(job.S3Path, job.IndexName+dictionaryPointerSuffix)
+	exists, err := s.s3Client.Exists(ctx, pointerKey)
+	if err != nil {
+		return fmt.Errorf("failed to check zstd dictionary: %w", err)
+	}
+	if exists {
+		data, err := s.s3Client.Get(ctx, pointerKey)
+		if err != nil {
+			return fmt.Errorf("failed to read zstd dictionary: %w", err)
+		}
+		var current dictionaryPointer
+		if err := json.Unmarshal(data, &current); err != nil {
+			log.Warnf("Ignoring invalid zstd dictionary record %s: %v", pointerKey, err)
+		} else if s.clock.Now().Sub(current.TrainedAt) < maxAge {
+			if session.codec.dict, err = s.s3Client.Get(ctx, current.Key); err != nil {
+				return fmt.Errorf("failed to read zstd dictionary %s: %w", current.Key, err)
+			}
+			session.codec.dictKey = current.Key
+			log.Infof("Compressing %s with zstd dictionary %s", job.IndexName, current.Key)
+			return nil
+		}
+	}
+
+	trained, sampled, err := s.trainDictionary(ctx, session, ranges, samples, int(maxSize))
+	if err != nil {
+		log.Warnf("Compressing %s without zstd dictionary: %v", job.IndexName, err)
+		return nil
+	}
+
+	now := s.clock.Now().UTC()
+	pointer := dictionaryPointer{
+		Key:       path.Join(job.S3Path, job.IndexName+"-"+now.Format("20060102T150405Z")+dictionarySuffix),
+		TrainedAt: now,
+		Samples:   sampled,
+	}
+	if err := s.s3Client.Put(ctx, pointer.Key, trained, map[string]string{
+		"run-id":  run.ID(ctx),
+		"samples": strconv.Itoa(sampled),
+	}); err != nil {
+		return fmt.Errorf("failed to upload zstd dictionary: %w", err)
+	}
+	data, err := json.MarshalIndent(pointer, "", "  ")
+	if err == nil {
+		err = s.s3Client.Put(ctx, pointerKey, data, map[string]string{"run-id": run.ID(ctx)})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record zstd dictionary: %w", err)
+	}
+
+	session.codec.dict, session.codec.dictKey = trained, pointer.Key
+	log.Infof("Trained zstd dictionary %s (%s) for %s from %d documents",
+		pointer.Key, humanize.Bytes(uint64(len(trained))), job.IndexName, sampled)
+	return nil
+}
+
+// trainDictionary build zstd dictionary from _source of up to samples
+// documents, taken evenly from the start of every range
+func (s *Service) trainDictionary(ctx context.Context, session *exportSession, ranges []period, samples, maxSize int) (trained []byte, sampled int, err error) {
+	size := min((samples+len(ranges)-1)/len(ranges), maxSamplePage)
+	var input [][]byte
+	for _, p := range ranges {
+		if len(input) >= samples {
+			break
+		}
+		page := getBuffer()
+		hits, err := s.searchPage(ctx, session, nil, p.start, p.end, min(size, samples-len(input)), nil, page)
+		putBuffer(page)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to sample documents: %w", err)
+		}
+		for _, hit := range hits {
+			input = append(input, hit.Source)
+		}
+	}
+	if len(input) < minDictionarySamples {
+		return nil, 0, fmt.Errorf("only %d documents sampled, at least %d needed for training", len(input), minDictionarySamples)
+	}
+
+	// Builder panics on degenerate input, e.g. identical documents
+	defer func() {
+		if r := recover(); r != nil {
+			trained, sampled, err = nil, 0, fmt.Errorf("dictionary training failed: %v", r)
+		}
+	}()
+	trained, err = dict.BuildZstdDict(input, dict.Options{MaxDictSize: maxSize, HashBytes: 6})
+	if err != nil {
+		return nil, 0, fmt.Errorf("dictionary training failed: %w", err)
+	}
+	return trained, len(input), nil
+}
+
+// decompress reader of artifact key: gzip, or zstd with the dictionary
+// recorded in its metadata
+func (s *Service) decompress(ctx context.Context, key string, r io.Reader) (io.ReadCloser, error) {
+	if !strings.HasSuffix(strings.TrimSuffix(key, encryptedSuffix), ".zst") {
+		gzipReader, err := pgzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		return gzipReader, nil
+	}
+
+	info, err := s.s3Client.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	var opts []zstd.DOption
+	if dictKey := info.Metadata[manifest.MetadataDictionary]; dictKey != "" {
+		trained, err := s.s3Client.Get(ctx, dictKey)
+		if err != nil {
+			return nil, fmt.Errorf("zstd dictionary %s: %w", dictKey, err)
+		}
+		opts = append(opts, zstd.WithDecoderDicts(trained))
+	}
+	decoder, err := zstd.NewReader(r, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("zstd: %w", err)
+	}
+	return decoder.IOReadCloser(), nil
+}
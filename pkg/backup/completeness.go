@@ -0,0 +1,45 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultCompletenessRetryDelay pause before re-running incomplete backup
+const defaultCompletenessRetryDelay = time.Minute
+
+// checkCompleteness count the whole day once more and compare it with exported
+// documents (plus dropped duplicates); a mismatch from late-arriving data or
+// failed periods is returned as ErrIncomplete
+func (s *Service) checkCompleteness(ctx context.Context, job config.BackupJob, session *exportSession, date time.Time, exported int) error {
+	if !job.Completeness.Enabled {
+		return nil
+	}
+
+	day := session.window(job, date)
+	expected, err := s.getCount(ctx, session, session.index, day[0].start, day[len(day)-1].end)
+	if err != nil {
+		return fmt.Errorf("failed to count day for completeness check: %w", opensearch.Classify(err))
+	}
+
+	session.expected = expected
+	actual := exported + session.duplicates
+	if actual == expected {
+		log.Infof("Backup of %s is complete: %d documents", job.IndexName, expected)
+		return nil
+	}
+
+	session.incomplete = true
+	log.WithFields(log.Fields{
+		"expected": expected,
+		"exported": actual,
+		"missing":  expected - actual,
+	}).Warnf("Backup of %s for %s does not match index count", job.IndexName, date.Format("2006-01-02"))
+	return fmt.Errorf("%w: exported %d of %d documents of %s for %s", errs.ErrIncomplete, actual, expected, job.IndexName, date.Format("2006-01-02"))
+}
@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"io"
+	"os"
+	"runtime"
+
+	"filippo.io/age"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// compressionWorkers parallel compression workers for job, all CPUs by default
+func compressionWorkers(job config.BackupJob) int {
+	if job.CompressionWorkers > 0 {
+		return job.CompressionWorkers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// compressor merges completed period files into a single artifact in
+// background, so merging overlaps with downloading of further periods.
+// Period files are already gzip members (or zstd frames), and concatenated
+// members are a valid gzip file (RFC 1952), so merging is a plain copy
+// without recompression
+type compressor struct {
+	name   string
+	files  chan []exportFile
+	done   chan error
+	docs   int
+	merged int
+	budget *diskBudget
+}
+
+// startCompressor create artifact file and start consuming period files
+func (s *Service) startCompressor(name string, budget *diskBudget, rcpts []age.Recipient, compression codec, header []byte) (*compressor, error) {
+	dest, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encryption (when enabled) wraps the whole concatenated artifact
+	w, err := encryptWriter(dest, rcpts)
+	if err != nil {
+		dest.Close()
+		return nil, err
+	}
+
+	// Format header (csv) as leading member of its own
+	if len(header) > 0 {
+		if err := compression.member(w, header); err != nil {
+			dest.Close()
+			return nil, err
+		}
+	}
+
+	c := &compressor{
+		name:   name,
+		files:  make(chan []exportFile, 64),
+		done:   make(chan error, 1),
+		budget: budget,
+	}
+
+	go func() {
+		var firstErr error
+		for files := range c.files {
+			for _, file := range files {
+				// Keep draining after an error so producer never blocks
+				if firstErr != nil {
+					c.budget.release(file)
+					continue
+				}
+				if err := c.append(w, file); err != nil {
+					firstErr = err
+					c.budget.release(file)
+				}
+			}
+		}
+
+		if err := w.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if err := dest.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		c.done <- firstErr
+	}()
+
+	return c, nil
+}
+
+// append copy member of period file into artifact and remove it from disk
+func (c *compressor) append(w io.Writer, file exportFile) error {
+	source, err := os.Open(file.Name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, source)
+	source.Close()
+	if err != nil {
+		return err
+	}
+
+	os.Remove(file.Name)
+	c.budget.release(file)
+	c.docs += file.Docs
+	c.merged++
+	return nil
+}
+
+// add queue completed period files for merging
+func (c *compressor) add(files []exportFile) {
+	if len(files) > 0 {
+		c.files <- files
+	}
+}
+
+// finish wait for queued files to be merged, returns total documents
+func (c *compressor) finish() (int, error) {
+	close(c.files)
+	if err := <-c.done; err != nil {
+		return 0, err
+	}
+
+	log.Infof("Merged %d members into %s (total documents: %d)", c.merged, c.name, c.docs)
+	return c.docs, nil
+}
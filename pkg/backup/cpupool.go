@@ -0,0 +1,186 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	log "github.com/sirupsen/logrus"
+)
+
+// pendingPerThread blocks of one writer in flight per pool thread before the
+// writer waits for the oldest, bounding memory of a fast producer
+const pendingPerThread = 2
+
+// cpuPool compression threads shared by every writer of the process, each
+// goroutine locked to an OS thread of its own with lowered priority
+type cpuPool struct {
+	threads int
+	jobs    chan func()
+}
+
+var (
+	poolsMu sync.Mutex
+	pools   = make(map[config.CompressionPoolConfig]*cpuPool)
+)
+
+// sharedPool pool of compression_pool settings, started on first use and
+// shared by every service of the process; nil without threads
+func sharedPool(cfg config.CompressionPoolConfig) (*cpuPool, error) {
+	if cfg.Threads == 0 {
+		return nil, nil
+	}
+	if cfg.Threads < 0 || cfg.Nice < -20 || cfg.Nice > 19 {
+		return nil, fmt.Errorf("%w: compression_pool needs threads above 0 and nice between -20 and 19", errs.ErrInvalidConfig)
+	}
+
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	if p, ok := pools[cfg]; ok {
+		return p, nil
+	}
+	p := &cpuPool{threads: cfg.Threads, jobs: make(chan func())}
+	var warnOnce sync.Once
+	for i := 0; i < cfg.Threads; i++ {
+		go func() {
+			// Never unlocked: the thread keeps its niceness for the life of the process
+			runtime.LockOSThread()
+			if cfg.Nice != 0 {
+				if err := setThreadNice(cfg.Nice); err != nil {
+					warnOnce.Do(func() { log.Warnf("Compression threads keep default priority: %v", err) })
+				}
+			}
+			for job := range p.jobs {
+				job()
+			}
+		}()
+	}
+	log.Infof("Started %d compression threads (nice %d)", cfg.Threads, cfg.Nice)
+	pools[cfg] = p
+	return p, nil
+}
+
+// writer compressing writer into w with blocks compressed on pool threads
+func (p *cpuPool) writer(w io.Writer, c codec, name string) (io.WriteCloser, error) {
+	pw := &pooledWriter{w: w, pool: p, name: name, buf: make([]byte, 0, compressionBlockSize)}
+	if c.zstd {
+		// EncodeAll runs on the calling pool thread, concurrently for every thread
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(p.threads)}
+		if c.dict != nil {
+			opts = append(opts, zstd.WithEncoderDict(c.dict))
+		}
+		enc, err := zstd.NewWriter(nil, opts...)
+		if err != nil {
+			return nil, err
+		}
+		pw.zstd = enc
+	}
+	return pw, nil
+}
+
+// pooledWriter cut written data into blocks of compressionBlockSize, each
+// compressed on the pool into a standalone gzip member or zstd frame and
+// written to w in order; members concatenate into one valid stream, like
+// part files into artifacts
+type pooledWriter struct {
+	w       io.Writer
+	pool    *cpuPool
+	name    string        // gzip header name, set on first member
+	zstd    *zstd.Encoder // nil for gzip
+	buf     []byte
+	blocks  int // submitted blocks
+	pending []chan compressedBlock
+	err     error
+}
+
+type compressedBlock struct {
+	data []byte
+	err  error
+}
+
+func (w *pooledWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+		if len(w.buf) == cap(w.buf) {
+			if err := w.submit(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close compress the last block and write every pending one; w is not closed
+func (w *pooledWriter) Close() error {
+	if w.err == nil && (len(w.buf) > 0 || w.blocks == 0) {
+		// An empty stream still gets one empty member, as with pgzip
+		w.submit()
+	}
+	for len(w.pending) > 0 && w.err == nil {
+		w.writeOldest()
+	}
+	if w.zstd != nil {
+		w.zstd.Close()
+	}
+	return w.err
+}
+
+// submit queue current block on the pool, writing finished blocks while
+// more than pendingPerThread per thread are in flight
+func (w *pooledWriter) submit() error {
+	block, name := w.buf, ""
+	if w.blocks == 0 {
+		name = w.name
+	}
+	w.buf = make([]byte, 0, compressionBlockSize)
+	w.blocks++
+
+	done := make(chan compressedBlock, 1)
+	w.pool.jobs <- func() {
+		data, err := w.compress(block, name)
+		done <- compressedBlock{data: data, err: err}
+	}
+	w.pending = append(w.pending, done)
+	for len(w.pending) > pendingPerThread*w.pool.threads && w.err == nil {
+		w.writeOldest()
+	}
+	return w.err
+}
+
+func (w *pooledWriter) writeOldest() {
+	result := <-w.pending[0]
+	w.pending = w.pending[1:]
+	if w.err = result.err; w.err == nil {
+		_, w.err = w.w.Write(result.data)
+	}
+}
+
+// compress block into a member of its own, on a pool thread
+func (w *pooledWriter) compress(block []byte, name string) ([]byte, error) {
+	if w.zstd != nil {
+		return w.zstd.EncodeAll(block, nil), nil
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(block)/4))
+	gz := gzip.NewWriter(out)
+	gz.Name = name
+	if _, err := gz.Write(block); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,32 @@
+package backup
+
+import "hash/fnv"
+
+// dedupSet _id hashes exported during run; 8 bytes per document keeps a day
+// of hundreds of millions of ids in memory, at a negligible collision risk
+type dedupSet map[uint64]struct{}
+
+// unique hits not exported before in this run, counting dropped duplicates;
+// hits itself is left intact, since paging continues after its last hit
+func (e *exportSession) unique(hits []pageHit) []pageHit {
+	if e.seen == nil {
+		return hits
+	}
+	e.seenMu.Lock()
+	defer e.seenMu.Unlock()
+
+	kept := make([]pageHit, 0, len(hits))
+	for _, hit := range hits {
+		h := fnv.New64a()
+		h.Write([]byte(hit.ID))
+		key := h.Sum64()
+
+		if _, ok := e.seen[key]; ok {
+			e.duplicates++
+			continue
+		}
+		e.seen[key] = struct{}{}
+		kept = append(kept, hit)
+	}
+	return kept
+}
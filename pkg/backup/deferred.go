@@ -0,0 +1,387 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+// queuedUploadFile description of queued artifact in its queue directory
+const queuedUploadFile = "upload.json"
+
+// drainPollInterval check for artifacts left in the queue (failed uploads)
+// while the upload window is open
+const drainPollInterval = 5 * time.Minute
+
+// uploadWindow daily off-peak hours [start, end) in loc; end before start
+// wraps midnight
+type uploadWindow struct {
+	start, end time.Duration
+	loc        *time.Location
+	dir        string
+	limit      uint64 // queue disk cap, 0 unlimited
+}
+
+// parseUploadWindow window of config, nil when uploads are not deferred
+func parseUploadWindow(cfg config.UploadWindowConfig) (*uploadWindow, error) {
+	if cfg.Start == "" && cfg.End == "" {
+		return nil, nil
+	}
+	w := &uploadWindow{loc: time.UTC, dir: cfg.Dir}
+	var err error
+	if w.start, err = parseClock(cfg.Start); err != nil {
+		return nil, fmt.Errorf("%w: upload_window.start: %v", errs.ErrInvalidConfig, err)
+	}
+	if w.end, err = parseClock(cfg.End); err != nil {
+		return nil, fmt.Errorf("%w: upload_window.end: %v", errs.ErrInvalidConfig, err)
+	}
+	if w.start == w.end {
+		return nil, fmt.Errorf("%w: upload_window start and end are both %s", errs.ErrInvalidConfig, cfg.Start)
+	}
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("%w: upload_window needs dir for queued artifacts", errs.ErrInvalidConfig)
+	}
+	if cfg.Timezone != "" {
+		if w.loc, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: upload_window.timezone %q: %v", errs.ErrInvalidConfig, cfg.Timezone, err)
+		}
+	}
+	if cfg.MaxQueue != "" {
+		if w.limit, err = humanize.ParseBytes(cfg.MaxQueue); err != nil {
+			return nil, fmt.Errorf("%w: upload_window.max_queue: %v", errs.ErrInvalidConfig, err)
+		}
+	}
+	return w, nil
+}
+
+// parseClock time of day "HH:MM" as offset from midnight
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q: expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// open check if uploads are allowed at t
+func (w *uploadWindow) open(t time.Time) bool {
+	local := t.In(w.loc)
+	since := local.Sub(time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.loc))
+	if w.start < w.end {
+		return since >= w.start && since < w.end
+	}
+	return since >= w.start || since < w.end
+}
+
+// next start of window at or after t, t itself while open
+func (w *uploadWindow) next(t time.Time) time.Time {
+	if w.open(t) {
+		return t
+	}
+	local := t.In(w.loc)
+	opens := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.loc).Add(w.start)
+	if opens.Before(t) {
+		opens = time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, w.loc).Add(w.start)
+	}
+	return opens
+}
+
+// queuedUpload artifact exported outside the upload window, uploaded with
+// its manifest once the window opens
+type queuedUpload struct {
+	Job         config.BackupJob  `json:"job"`
+	File        string            `json:"file"` // artifact file in queue directory
+	Key         string            `json:"key"`
+	Documents   int               `json:"documents"`
+	Metadata    map[string]string `json:"metadata"`
+	ManifestKey string            `json:"manifest_key"`
+	Manifest    manifest.Manifest `json:"manifest"`
+	QueuedAt    time.Time         `json:"queued_at"`
+	Extras      []queuedObject    `json:"extras,omitempty"` // artifacts of extra formats
+}
+
+// queuedObject additional artifact of queued upload
+type queuedObject struct {
+	File     string            `json:"file"`
+	Key      string            `json:"key"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// deferUpload queue compressed artifact of run (with artifacts of extra
+// formats) for the upload window instead of uploading it now; false when the
+// window is open, not configured or the queue is full, and the caller uploads
+// right away
+func (s *Service) deferUpload(ctx context.Context, job config.BackupJob, session *exportSession, date time.Time, file, key string, documents int, extras map[string]*extraArtifact) (bool, error) {
+	if s.config == nil {
+		return false, nil
+	}
+	window, err := parseUploadWindow(s.config.UploadWindow)
+	if err != nil || window == nil || window.open(s.clock.Now()) {
+		return false, err
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return false, err
+	}
+	size := uint64(info.Size())
+	for _, extra := range extras {
+		info, err := os.Stat(extra.file)
+		if err != nil {
+			return false, err
+		}
+		size += uint64(info.Size())
+	}
+	if window.limit > 0 {
+		used, _, err := queueUsage(window.dir)
+		if err != nil {
+			return false, err
+		}
+		if used+size > window.limit {
+			log.Warnf("Upload queue is full (%s of %s), uploading %s outside the upload window",
+				humanize.Bytes(used), humanize.Bytes(window.limit), key)
+			return false, nil
+		}
+	}
+
+	manifestKey, err := session.manifestKey(ctx, job, date)
+	if err != nil {
+		return false, err
+	}
+	// Mapping is taken now, while the export it belongs to is fresh
+	if err := s.archiveMapping(ctx, job, session, date); err != nil {
+		return false, err
+	}
+	entry := queuedUpload{
+		Job:         job,
+		File:        filepath.Base(file),
+		Key:         key,
+		Documents:   documents,
+		Metadata:    session.codec.metadata(uploadMetadata(ctx, job, documents)),
+		ManifestKey: manifestKey,
+		Manifest:    s.newManifest(ctx, job, session, date, []string{key}, documents),
+		QueuedAt:    s.clock.Now().UTC(),
+	}
+	for _, extra := range extras {
+		entry.Extras = append(entry.Extras, queuedObject{
+			File:     filepath.Base(extra.file),
+			Key:      extra.key,
+			Metadata: session.codec.metadata(uploadMetadata(ctx, extra.job, documents)),
+		})
+	}
+
+	// Entry appears under its final name only once complete
+	final := filepath.Join(window.dir, run.ID(ctx))
+	tmp := filepath.Join(window.dir, "."+run.ID(ctx))
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return false, fmt.Errorf("failed to create upload queue entry: %w", err)
+	}
+	for _, extra := range extras {
+		if err := moveFile(extra.file, filepath.Join(tmp, filepath.Base(extra.file))); err != nil {
+			os.RemoveAll(tmp)
+			return false, fmt.Errorf("failed to queue %s artifact: %w", extra.job.Format, err)
+		}
+	}
+	if err := moveFile(file, filepath.Join(tmp, entry.File)); err != nil {
+		os.RemoveAll(tmp)
+		return false, fmt.Errorf("failed to queue artifact: %w", err)
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(tmp, queuedUploadFile), data, 0644)
+	}
+	if err == nil {
+		os.RemoveAll(final)
+		err = os.Rename(tmp, final)
+	}
+	if err != nil {
+		os.RemoveAll(tmp)
+		return false, fmt.Errorf("failed to queue artifact: %w", err)
+	}
+
+	used, count := reportQueue(window.dir)
+	log.WithFields(log.Fields{
+		"run_id": run.ID(ctx),
+		"key":    key,
+		"queued": count,
+		"bytes":  used,
+	}).Infof("Upload of %s deferred to upload window opening %s", key, window.next(s.clock.Now()).Format(time.RFC3339))
+	return true, nil
+}
+
+// DrainUploads upload queued artifacts while the upload window is open,
+// oldest first, until ctx is cancelled; artifacts failing to upload stay
+// queued and are retried
+func (s *Service) DrainUploads(ctx context.Context) {
+	if s.config == nil {
+		return
+	}
+	window, err := parseUploadWindow(s.config.UploadWindow)
+	if err != nil {
+		log.Errorf("Deferred uploads disabled: %v", err)
+		return
+	}
+	if window == nil {
+		return
+	}
+	reportQueue(window.dir)
+
+	for {
+		now := s.clock.Now()
+		wait := window.next(now).Sub(now)
+		if wait <= 0 {
+			s.drainQueue(ctx, window)
+			wait = drainPollInterval
+		} else {
+			log.Debugf("Upload window opens in %v", wait.Round(time.Second))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(wait):
+		}
+	}
+}
+
+// drainQueue upload queued artifacts until the queue is empty or the window
+// closes; an upload in progress when it closes is finished
+func (s *Service) drainQueue(ctx context.Context, window *uploadWindow) {
+	entries, err := os.ReadDir(window.dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Errorf("Failed to read upload queue: %v", err)
+		}
+		return
+	}
+	// Entries are named by run ID, which sorts by start time
+	sort.Slice(entries, func(a, b int) bool { return entries[a].Name() < entries[b].Name() })
+	for _, e := range entries {
+		if ctx.Err() != nil || !window.open(s.clock.Now()) {
+			return
+		}
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		dir := filepath.Join(window.dir, e.Name())
+		if err := s.uploadQueued(ctx, dir); err != nil {
+			log.WithField("run_id", e.Name()).Errorf("Deferred upload failed, keeping it queued: %v", err)
+		}
+		reportQueue(window.dir)
+	}
+}
+
+// uploadQueued upload artifact of queue directory, spot check it and write
+// its manifest, then remove the directory
+func (s *Service) uploadQueued(ctx context.Context, dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, queuedUploadFile))
+	if err != nil {
+		return fmt.Errorf("failed to read queued upload: %w", err)
+	}
+	var entry queuedUpload
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return fmt.Errorf("invalid queued upload %s: %w", dir, err)
+	}
+	svc, err := s.forJob(entry.Job)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.s3Client.Upload(ctx, filepath.Join(dir, entry.File), entry.Key, entry.Documents, entry.Metadata); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", entry.Key, err)
+	}
+	for _, extra := range entry.Extras {
+		if err := svc.s3Client.Upload(ctx, filepath.Join(dir, extra.File), extra.Key, entry.Documents, extra.Metadata); err != nil {
+			return fmt.Errorf("failed to upload %s: %w", extra.Key, err)
+		}
+	}
+	if err := svc.spotCheck(ctx, entry.Job, entry.Key); err != nil {
+		return err
+	}
+	if err := svc.putManifest(ctx, entry.Job, entry.ManifestKey, entry.Manifest, entry.Metadata); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		log.Warnf("Failed to remove uploaded queue entry %s: %v", dir, err)
+	}
+	log.WithField("run_id", entry.Manifest.RunID).Infof("Deferred upload completed for %s: %s (queued %v)",
+		entry.Job.Name(), entry.Key, s.clock.Now().Sub(entry.QueuedAt).Round(time.Second))
+	return nil
+}
+
+// queueUsage bytes and number of complete entries in queue
+func queueUsage(dir string) (uint64, int, error) {
+	var used uint64
+	count := 0
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if d.Name() == queuedUploadFile && !strings.HasPrefix(filepath.Base(filepath.Dir(p)), ".") {
+			count++
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		used += uint64(info.Size())
+		return nil
+	})
+	return used, count, err
+}
+
+// reportQueue update queue metrics, returns bytes and entries
+func reportQueue(dir string) (uint64, int) {
+	used, count, err := queueUsage(dir)
+	if err != nil {
+		log.Warnf("Failed to measure upload queue: %v", err)
+	}
+	metrics.UploadQueueBytes.Set(float64(used))
+	metrics.UploadQueueArtifacts.Set(float64(count))
+	return used, count
+}
+
+// moveFile rename file, copying it when source and target are on different
+// filesystems
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+)
+
+func TestParseUploadWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.UploadWindowConfig
+		wantNil bool
+		wantErr bool
+	}{
+		{"not configured", config.UploadWindowConfig{}, true, false},
+		{"valid", config.UploadWindowConfig{Start: "22:00", End: "06:00", Dir: "/queue", MaxQueue: "200GB"}, false, false},
+		{"bad start", config.UploadWindowConfig{Start: "22", End: "06:00", Dir: "/queue"}, false, true},
+		{"missing end", config.UploadWindowConfig{Start: "22:00", Dir: "/queue"}, false, true},
+		{"empty window", config.UploadWindowConfig{Start: "22:00", End: "22:00", Dir: "/queue"}, false, true},
+		{"no dir", config.UploadWindowConfig{Start: "22:00", End: "06:00"}, false, true},
+		{"bad timezone", config.UploadWindowConfig{Start: "22:00", End: "06:00", Dir: "/queue", Timezone: "Mars/Olympus"}, false, true},
+		{"bad max_queue", config.UploadWindowConfig{Start: "22:00", End: "06:00", Dir: "/queue", MaxQueue: "lots"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := parseUploadWindow(tt.cfg)
+			if tt.wantErr {
+				if !errors.Is(err, errs.ErrInvalidConfig) {
+					t.Fatalf("error %v, want invalid config", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (w == nil) != tt.wantNil {
+				t.Errorf("window = %v, want nil %v", w, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestUploadWindow(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skip(err)
+	}
+	at := func(value string) time.Time {
+		t, _ := time.Parse(time.RFC3339, value)
+		return t
+	}
+	tests := []struct {
+		name     string
+		cfg      config.UploadWindowConfig
+		now      string
+		wantOpen bool
+		wantNext string
+	}{
+		{"day window before", config.UploadWindowConfig{Start: "01:00", End: "05:00"}, "2026-03-10T00:30:00Z", false, "2026-03-10T01:00:00Z"},
+		{"day window start", config.UploadWindowConfig{Start: "01:00", End: "05:00"}, "2026-03-10T01:00:00Z", true, "2026-03-10T01:00:00Z"},
+		{"day window end", config.UploadWindowConfig{Start: "01:00", End: "05:00"}, "2026-03-10T05:00:00Z", false, "2026-03-11T01:00:00Z"},
+		{"wrap before midnight", config.UploadWindowConfig{Start: "22:00", End: "06:00"}, "2026-03-10T23:00:00Z", true, "2026-03-10T23:00:00Z"},
+		{"wrap after midnight", config.UploadWindowConfig{Start: "22:00", End: "06:00"}, "2026-03-11T05:59:00Z", true, "2026-03-11T05:59:00Z"},
+		{"wrap closed", config.UploadWindowConfig{Start: "22:00", End: "06:00"}, "2026-03-10T12:00:00Z", false, "2026-03-10T22:00:00Z"},
+		{"wrap at end", config.UploadWindowConfig{Start: "22:00", End: "06:00"}, "2026-03-11T06:00:00Z", false, "2026-03-11T22:00:00Z"},
+		// 22:00 in Berlin (UTC+1 in March) is 21:00Z
+		{"timezone closed", config.UploadWindowConfig{Start: "22:00", End: "06:00", Timezone: "Europe/Berlin"}, "2026-03-10T20:30:00Z", false, "2026-03-10T21:00:00Z"},
+		{"timezone open", config.UploadWindowConfig{Start: "22:00", End: "06:00", Timezone: "Europe/Berlin"}, "2026-03-11T04:30:00Z", true, "2026-03-11T04:30:00Z"},
+		// Window of Berlin day that starts before midnight UTC
+		{"timezone next day", config.UploadWindowConfig{Start: "00:30", End: "02:00", Timezone: "Europe/Berlin"}, "2026-03-10T23:00:00Z", false, "2026-03-10T23:30:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Dir = "/queue"
+			w, err := parseUploadWindow(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := at(tt.now)
+			if got := w.open(now); got != tt.wantOpen {
+				t.Errorf("open(%s) = %v, want %v", tt.now, got, tt.wantOpen)
+			}
+			if got := w.next(now); !got.Equal(at(tt.wantNext)) {
+				t.Errorf("next(%s) = %s, want %s", tt.now, got.In(time.UTC).Format(time.RFC3339), tt.wantNext)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+)
+
+// encryptedSuffix appended to object names of encrypted artifacts
+const encryptedSuffix = ".age"
+
+// recipients parse age recipients of job, nil when encryption is disabled;
+// only public keys are needed, artifacts are decrypted elsewhere with the identity
+func recipients(job config.BackupJob) ([]age.Recipient, error) {
+	if !job.Encryption.Enabled() {
+		return nil, nil
+	}
+
+	lines := append([]string{}, job.Encryption.Recipients...)
+	if job.Encryption.RecipientsFile != "" {
+		data, err := os.ReadFile(job.Encryption.RecipientsFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: encryption.recipients_file: %w", errs.ErrInvalidConfig, err)
+		}
+		lines = append(lines, string(data))
+	}
+
+	parsed, err := age.ParseRecipients(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return nil, fmt.Errorf("%w: encryption recipients: %w", errs.ErrInvalidConfig, err)
+	}
+	return parsed, nil
+}
+
+// encryptWriter wrap w with age encryption, w is returned as is without recipients;
+// Close must be called to finish encrypted stream, it does not close w
+func encryptWriter(w io.Writer, rcpts []age.Recipient) (io.WriteCloser, error) {
+	if len(rcpts) == 0 {
+		return nopWriteCloser{w}, nil
+	}
+	return age.Encrypt(w, rcpts...)
+}
+
+// encryptFile encrypt src into dst for recipients
+func encryptFile(src, dst string, rcpts []age.Recipient) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	dest, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	enc, err := encryptWriter(dest, rcpts)
+	if err == nil {
+		if _, err = io.Copy(enc, source); err == nil {
+			err = enc.Close()
+		}
+	}
+	if closeErr := dest.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("failed to encrypt %s: %w", src, err)
+	}
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// fieldsSource rebuild document from hit fields: dotted names become nested
+// objects and single-value arrays are unwrapped, so every format can encode it
+// like _source
+func fieldsSource(fields json.RawMessage) (json.RawMessage, error) {
+	if len(fields) == 0 {
+		return json.RawMessage("{}"), nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(fields))
+	decoder.UseNumber()
+	var values map[string][]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	source := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		var v interface{} = value
+		if len(value) == 1 {
+			v = value[0]
+		}
+
+		parts := strings.Split(name, ".")
+		node := source
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = v
+	}
+
+	return json.Marshal(source)
+}
+
+// withSource set _source of every hit of search response page to the one
+// built from its fields, so raw pages written by format: search restore like
+// pages of _source searches; hits are in page order
+func withSource(page *bytes.Buffer, hits []pageHit) error {
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(page.Bytes(), &response); err != nil {
+		return err
+	}
+	var outer map[string]json.RawMessage
+	if err := json.Unmarshal(response["hits"], &outer); err != nil {
+		return err
+	}
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal(outer["hits"], &raw); err != nil {
+		return err
+	}
+	if len(raw) != len(hits) {
+		return fmt.Errorf("page has %d hits, decoded %d", len(raw), len(hits))
+	}
+	for i := range raw {
+		raw[i]["_source"] = hits[i].Source
+	}
+
+	var err error
+	if outer["hits"], err = json.Marshal(raw); err != nil {
+		return err
+	}
+	if response["hits"], err = json.Marshal(outer); err != nil {
+		return err
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	page.Reset()
+	page.Write(data)
+	return nil
+}
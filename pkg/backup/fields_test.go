@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWithSource(t *testing.T) {
+	page := bytes.NewBufferString(`{"took":3,"hits":{"total":{"value":2},"hits":[` +
+		`{"_index":"logs","_id":"a","fields":{"status":[200]},"sort":[1,"a"]},` +
+		`{"_index":"logs","_id":"b","fields":{"status":[500]},"sort":[2,"b"]}]}}`)
+	hits := []pageHit{
+		{ID: "a", Source: json.RawMessage(`{"status":200}`)},
+		{ID: "b", Source: json.RawMessage(`{"status":500}`)},
+	}
+	if err := withSource(page, hits); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Took int `json:"took"`
+		Hits struct {
+			Total json.RawMessage `json:"total"`
+			Hits  []pageHit       `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(page.Bytes(), &got); err != nil {
+		t.Fatalf("page is not valid JSON: %v", err)
+	}
+	if got.Took != 3 || string(got.Hits.Total) != `{"value":2}` {
+		t.Errorf("response fields changed: took %d, total %s", got.Took, got.Hits.Total)
+	}
+	if len(got.Hits.Hits) != 2 {
+		t.Fatalf("hits = %d, want 2", len(got.Hits.Hits))
+	}
+	for i, hit := range got.Hits.Hits {
+		if hit.ID != hits[i].ID || string(hit.Source) != string(hits[i].Source) {
+			t.Errorf("hit %d = %s %s, want %s %s", i, hit.ID, hit.Source, hits[i].ID, hits[i].Source)
+		}
+		if len(hit.Fields) == 0 || len(hit.Sort) != 2 {
+			t.Errorf("hit %d lost fields or sort: %s %v", i, hit.Fields, hit.Sort)
+		}
+	}
+
+	if err := withSource(bytes.NewBufferString(`{"hits":{"hits":[{"_id":"a"}]}}`), nil); err == nil {
+		t.Error("hit count mismatch accepted")
+	}
+}
+
+func TestFieldsSource(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		want   string
+	}{
+		{"empty", ``, `{}`},
+		{"single values unwrapped", `{"status":[200],"message":["ok"]}`, `{"message":"ok","status":200}`},
+		{"multi values kept", `{"tags":["a","b"]}`, `{"tags":["a","b"]}`},
+		{"dotted names nested", `{"host.name":["web-1"],"host.ip":["10.0.0.1"],"level":["info"]}`,
+			`{"host":{"ip":"10.0.0.1","name":"web-1"},"level":"info"}`},
+		{"large numbers exact", `{"id":[9007199254740993]}`, `{"id":9007199254740993}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fieldsSource(json.RawMessage(tt.fields))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("source = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := fieldsSource(json.RawMessage(`{"status":200}`)); err == nil {
+		t.Error("fields without value arrays accepted")
+	}
+}
@@ -0,0 +1,86 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"gopkg.in/yaml.v3"
+)
+
+// exportFilter resolve job query, query_file or stored_query into query
+// clause; files and templates are read on every run, so they can change
+// without touching manager config
+func (s *Service) exportFilter(ctx context.Context, job config.BackupJob) (map[string]interface{}, error) {
+	sources := 0
+	for _, set := range []bool{len(job.Query) > 0, job.QueryFile != "", job.StoredQuery.ID != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, fmt.Errorf("%w: only one of query, query_file and stored_query can be set", errs.ErrInvalidConfig)
+	}
+
+	switch {
+	case len(job.Query) > 0:
+		return job.Query, nil
+	case job.QueryFile != "":
+		return queryFromFile(job.QueryFile)
+	case job.StoredQuery.ID != "":
+		return s.renderStoredQuery(ctx, job.StoredQuery)
+	}
+	return nil, nil
+}
+
+// queryFromFile read query clause from YAML or JSON file, with or without
+// top-level "query" key
+func queryFromFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: query_file: %w", errs.ErrInvalidConfig, err)
+	}
+	var query map[string]interface{}
+	if err := yaml.Unmarshal(data, &query); err != nil {
+		return nil, fmt.Errorf("%w: query_file %s: %w", errs.ErrInvalidConfig, path, err)
+	}
+	if len(query) == 0 {
+		return nil, fmt.Errorf("%w: query_file %s is empty", errs.ErrInvalidConfig, path)
+	}
+	return unwrapQuery(query), nil
+}
+
+// renderStoredQuery render stored search template with job params
+func (s *Service) renderStoredQuery(ctx context.Context, stored config.StoredQuery) (map[string]interface{}, error) {
+	body, err := json.Marshal(map[string]interface{}{"params": stored.Params})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode stored_query params: %w", err)
+	}
+
+	var resp struct {
+		TemplateOutput map[string]interface{} `json:"template_output"`
+	}
+	path := "/_render/template/" + url.PathEscape(stored.ID)
+	if err := s.client.Do(ctx, "POST", path, bytes.NewReader(body), &resp); err != nil {
+		return nil, fmt.Errorf("failed to render stored_query %s: %w", stored.ID, opensearch.Classify(err))
+	}
+	if len(resp.TemplateOutput) == 0 {
+		return nil, fmt.Errorf("%w: stored_query %s rendered empty", errs.ErrInvalidConfig, stored.ID)
+	}
+	return unwrapQuery(resp.TemplateOutput), nil
+}
+
+// unwrapQuery query clause of search body like {"query": {...}, "size": 0},
+// other search body keys are ignored
+func unwrapQuery(body map[string]interface{}) map[string]interface{} {
+	if query, ok := body["query"].(map[string]interface{}); ok {
+		return query
+	}
+	return body
+}
@@ -0,0 +1,255 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+)
+
+// Export formats
+const (
+	FormatSearch = "search" // raw search response per line (default)
+	FormatSource = "source" // documents _source per line
+	FormatCSV    = "csv"    // mapped columns, one row per document
+	FormatBulk   = "bulk"   // _bulk action and source line pairs
+)
+
+// pageEncoder converts search pages into archive lines
+type pageEncoder interface {
+	// header written once at start of every standalone object, nil if none
+	header() []byte
+	// encode append lines of page to buf
+	encode(buf *bytes.Buffer, page []byte, hits []pageHit) error
+}
+
+// jobFormat effective export format of job
+func jobFormat(job config.BackupJob) string {
+	if job.Format != "" {
+		return job.Format
+	}
+	if job.RawSource {
+		return FormatSource
+	}
+	return FormatSearch
+}
+
+// newEncoder create encoder for job format
+func newEncoder(job config.BackupJob) (pageEncoder, error) {
+	switch format := jobFormat(job); format {
+	case FormatSearch:
+		return searchEncoder{}, nil
+	case FormatSource:
+		return sourceEncoder{}, nil
+	case FormatCSV:
+		return newCSVEncoder(job.CSV)
+	case FormatBulk:
+		return bulkEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", errs.ErrInvalidConfig, format)
+	}
+}
+
+// formatExtension file extension of job format
+func formatExtension(job config.BackupJob) string {
+	switch jobFormat(job) {
+	case FormatCSV:
+		return ".csv"
+	case FormatAvro:
+		return ".avro"
+	case FormatBulk:
+		return ".ndjson"
+	}
+	return ".json"
+}
+
+// searchEncoder page as single line of raw search response, without re-marshaling
+type searchEncoder struct{}
+
+func (searchEncoder) header() []byte { return nil }
+
+func (searchEncoder) encode(buf *bytes.Buffer, page []byte, hits []pageHit) error {
+	if err := json.Compact(buf, page); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	return nil
+}
+
+// sourceEncoder documents _source one per line
+type sourceEncoder struct{}
+
+func (sourceEncoder) header() []byte { return nil }
+
+func (sourceEncoder) encode(buf *bytes.Buffer, page []byte, hits []pageHit) error {
+	for _, hit := range hits {
+		buf.Write(hit.Source)
+		buf.WriteByte('\n')
+	}
+	return nil
+}
+
+// bulkEncoder _bulk-ready lines, so archives can be re-ingested with plain curl
+type bulkEncoder struct{}
+
+func (bulkEncoder) header() []byte { return nil }
+
+func (bulkEncoder) encode(buf *bytes.Buffer, page []byte, hits []pageHit) error {
+	type metadata struct {
+		Index   string `json:"_index"`
+		ID      string `json:"_id"`
+		Routing string `json:"routing,omitempty"`
+	}
+
+	encoder := json.NewEncoder(buf)
+	for _, hit := range hits {
+		action := map[string]metadata{"index": {Index: hit.Index, ID: hit.ID, Routing: hit.Routing}}
+		if err := encoder.Encode(action); err != nil {
+			return err
+		}
+		// Source must stay on a single line
+		if err := json.Compact(buf, hit.Source); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+	}
+	return nil
+}
+
+// csvEncoder documents flattened into configured columns
+type csvEncoder struct {
+	columns   []config.CSVColumn
+	delimiter rune
+}
+
+func newCSVEncoder(cfg config.CSVConfig) (*csvEncoder, error) {
+	if len(cfg.Columns) == 0 {
+		return nil, fmt.Errorf("%w: csv format requires csv.columns", errs.ErrInvalidConfig)
+	}
+	for _, column := range cfg.Columns {
+		if column.Field == "" {
+			return nil, fmt.Errorf("%w: csv column %q has no field", errs.ErrInvalidConfig, column.Name)
+		}
+	}
+
+	delimiter := ','
+	if cfg.Delimiter != "" {
+		runes := []rune(cfg.Delimiter)
+		if len(runes) != 1 {
+			return nil, fmt.Errorf("%w: csv delimiter %q must be a single character", errs.ErrInvalidConfig, cfg.Delimiter)
+		}
+		delimiter = runes[0]
+	}
+
+	return &csvEncoder{columns: cfg.Columns, delimiter: delimiter}, nil
+}
+
+func (e *csvEncoder) header() []byte {
+	row := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		row[i] = column.Name
+		if row[i] == "" {
+			row[i] = column.Field
+		}
+	}
+
+	var buf bytes.Buffer
+	e.write(&buf, [][]string{row})
+	return buf.Bytes()
+}
+
+func (e *csvEncoder) encode(buf *bytes.Buffer, page []byte, hits []pageHit) error {
+	rows := make([][]string, 0, len(hits))
+	for _, hit := range hits {
+		decoder := json.NewDecoder(bytes.NewReader(hit.Source))
+		decoder.UseNumber()
+
+		var source map[string]interface{}
+		if err := decoder.Decode(&source); err != nil {
+			return fmt.Errorf("failed to decode document %s: %w", hit.ID, err)
+		}
+
+		row := make([]string, len(e.columns))
+		for i, column := range e.columns {
+			var value interface{}
+			switch column.Field {
+			case "_id":
+				value = hit.ID
+			case "_index":
+				value = hit.Index
+			default:
+				value = lookupField(source, column.Field)
+			}
+			row[i] = formatValue(value, column.DateFormat)
+		}
+		rows = append(rows, row)
+	}
+
+	return e.write(buf, rows)
+}
+
+func (e *csvEncoder) write(buf *bytes.Buffer, rows [][]string) error {
+	w := csv.NewWriter(buf)
+	w.Comma = e.delimiter
+	return w.WriteAll(rows)
+}
+
+// lookupField resolve dotted path through nested objects, also matching
+// keys that contain dots themselves ("user.name" stored flat)
+func lookupField(source map[string]interface{}, path string) interface{} {
+	if value, ok := source[path]; ok {
+		return value
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if nested, ok := source[path[:i]].(map[string]interface{}); ok {
+			if value := lookupField(nested, path[i+1:]); value != nil {
+				return value
+			}
+		}
+	}
+	return nil
+}
+
+// formatValue render flattened value as cell: arrays joined with ";", objects
+// as JSON, dates reformatted with dateFormat layout when set
+func formatValue(value interface{}, dateFormat string) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		if dateFormat != "" {
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t.Format(dateFormat)
+			}
+		}
+		return v
+	case json.Number:
+		if dateFormat != "" {
+			// Epoch milliseconds
+			if ms, err := v.Int64(); err == nil {
+				return time.UnixMilli(ms).UTC().Format(dateFormat)
+			}
+		}
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = formatValue(item, dateFormat)
+		}
+		return strings.Join(parts, ";")
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
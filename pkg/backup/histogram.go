@@ -0,0 +1,37 @@
+package backup
+
+import (
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+)
+
+// hourCounts exported documents per hour, keyed by manifest.HourLayout
+type hourCounts map[string]int
+
+// add count hits by their @timestamp sort value; hits without one (missing
+// timestamp) are not counted
+func (h hourCounts) add(hits []pageHit) {
+	for _, hit := range hits {
+		if len(hit.Sort) == 0 {
+			continue
+		}
+		value, ok := hit.Sort[0].(float64)
+		if !ok {
+			continue
+		}
+		// date fields sort by epoch millis, date_nanos fields by epoch nanos
+		t := time.UnixMilli(int64(value))
+		if value > 1e15 {
+			t = time.Unix(0, int64(value))
+		}
+		h[t.UTC().Truncate(time.Hour).Format(manifest.HourLayout)]++
+	}
+}
+
+// merge add counts of other
+func (h hourCounts) merge(other map[string]int) {
+	for hour, docs := range other {
+		h[hour] += docs
+	}
+}
@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// lifecycleRulePrefix ID prefix of bucket lifecycle rules owned by the manager
+const lifecycleRulePrefix = "opensearch-backup-manager:"
+
+// syncLifecycle replace bucket lifecycle rules of job path with ones derived
+// from job retention: expiration once a backup is older than every tier,
+// plus configured storage class transitions; expiration is dropped while a
+// legal hold covers the job, as bucket rules cannot skip held objects
+func (s *Service) syncLifecycle(ctx context.Context, job config.BackupJob) error {
+	prefix := config.NormalizePrefix(job.S3Path)
+	// "#" ends the path, so rules of nested job paths are not matched
+	idPrefix := lifecycleRulePrefix + prefix + "#"
+
+	var rules []storage.LifecycleRule
+	expire := retentionHorizonDays(job.Retention)
+	holds, err := s.legalHolds()
+	if err != nil {
+		return err
+	}
+	h, held := holds.SuspendsRetention(job.IndexName)
+	if !held {
+		h, held = holds.Prefix(job.Storage, prefix)
+	}
+	if held && expire > 0 {
+		log.Warnf("Lifecycle expiration of %q suspended: legal hold %s", prefix, h)
+		expire = 0
+	}
+	if expire > 0 {
+		rules = append(rules, storage.LifecycleRule{ID: idPrefix + "expire", Prefix: prefix, ExpireDays: expire})
+	}
+	for _, t := range job.Retention.Lifecycle.Transitions {
+		if t.Days <= 0 || t.StorageClass == "" {
+			return fmt.Errorf("%w: lifecycle transition needs days and storage_class", errs.ErrInvalidConfig)
+		}
+		if expire > 0 && t.Days >= expire {
+			return fmt.Errorf("%w: lifecycle transition to %s after %d days is past expiration after %d days",
+				errs.ErrInvalidConfig, t.StorageClass, t.Days, expire)
+		}
+		rules = append(rules, storage.LifecycleRule{
+			ID:           idPrefix + "transition-" + strings.ToLower(t.StorageClass),
+			Prefix:       prefix,
+			TransitDays:  t.Days,
+			StorageClass: t.StorageClass,
+		})
+	}
+
+	if err := s.s3Client.SetLifecycleRules(ctx, idPrefix, rules); err != nil {
+		return err
+	}
+	log.Infof("Lifecycle rules of %q synced: expire after %d days, %d transitions", prefix, expire, len(job.Retention.Lifecycle.Transitions))
+	return nil
+}
+
+// retentionHorizonDays age in days past which no retention tier keeps a
+// backup (0 without retention): first backups of a week or month are kept
+// for up to a week or month beyond the tier count, plus one day of margin
+func retentionHorizonDays(policy config.RetentionPolicy) int {
+	if !policy.Enabled() {
+		return 0
+	}
+	horizon := policy.Daily
+	if policy.Weekly > 0 {
+		horizon = max(horizon, policy.Weekly*7+6)
+	}
+	if policy.Monthly > 0 {
+		horizon = max(horizon, policy.Monthly*31+30)
+	}
+	return horizon + 1
+}
@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultMultipartMaxAge incomplete uploads younger than this may still be running
+const defaultMultipartMaxAge = 24 * time.Hour
+
+// CollectIncompleteUploads abort multipart uploads left by crashed runs under
+// s3_path of every backup job, in storage of the job; uploads started less
+// than max age ago are kept
+func (s *Service) CollectIncompleteUploads(ctx context.Context, jobs []config.BackupJob, maintenance config.MaintenanceConfig) error {
+	maxAge := defaultMultipartMaxAge
+	if maintenance.MultipartMaxAge != "" {
+		var err error
+		if maxAge, err = config.ParseDuration(maintenance.MultipartMaxAge); err != nil || maxAge <= 0 {
+			return fmt.Errorf("%w: multipart_max_age %q", errs.ErrInvalidConfig, maintenance.MultipartMaxAge)
+		}
+	}
+	before := s.clock.Now().Add(-maxAge)
+
+	byStorage := make(map[string][]config.BackupJob)
+	var names []string
+	for _, job := range jobs {
+		if _, ok := byStorage[job.Storage]; !ok {
+			names = append(names, job.Storage)
+		}
+		byStorage[job.Storage] = append(byStorage[job.Storage], job)
+	}
+
+	total := 0
+	for _, name := range names {
+		svc, err := s.forJob(byStorage[name][0])
+		if err != nil {
+			return err
+		}
+		for _, prefix := range uploadPrefixes(byStorage[name]) {
+			aborted, err := svc.s3Client.AbortIncompleteUploads(ctx, prefix, before)
+			total += aborted
+			if err != nil {
+				return fmt.Errorf("failed to collect uploads under %q: %w", prefix, err)
+			}
+		}
+	}
+	log.Infof("Multipart GC: aborted %d incomplete uploads older than %v", total, maxAge)
+	return nil
+}
+
+// uploadPrefixes distinct s3_path of jobs, without paths nested in others
+func uploadPrefixes(jobs []config.BackupJob) []string {
+	var prefixes []string
+	for _, job := range jobs {
+		prefix := strings.TrimPrefix(job.S3Path, "/")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	var result []string
+	for _, prefix := range prefixes {
+		if len(result) > 0 && strings.HasPrefix(prefix, result[len(result)-1]) {
+			continue
+		}
+		result = append(result, prefix)
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package backup
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+// manifestKey S3 key of day manifest
+func (e *exportSession) manifestKey(ctx context.Context, job config.BackupJob, date time.Time) (string, error) {
+	name, err := e.names.Artifact(job.IndexName, date, run.ID(ctx))
+	if err != nil {
+		return "", err
+	}
+	return path.Join(job.S3Path, name+manifest.Suffix), nil
+}
+
+// writeManifest upload manifest of finished backup, with archived mapping
+// when the job keeps one, and record it in index catalog; with purge the
+// backed up documents are deleted from the cluster afterwards
+func (s *Service) writeManifest(ctx context.Context, job config.BackupJob, session *exportSession, date time.Time, objects []string, documents int) error {
+	key, err := session.manifestKey(ctx, job, date)
+	if err != nil {
+		return err
+	}
+	if err := s.archiveMapping(ctx, job, session, date); err != nil {
+		return err
+	}
+	if err := s.putManifest(ctx, job, key, s.newManifest(ctx, job, session, date, objects, documents), uploadMetadata(ctx, job, documents)); err != nil {
+		return err
+	}
+	return s.purge(ctx, job, session, date, documents)
+}
+
+// newManifest manifest of run export, without sizes and checksums of its objects
+func (s *Service) newManifest(ctx context.Context, job config.BackupJob, session *exportSession, date time.Time, objects []string, documents int) manifest.Manifest {
+	return manifest.Manifest{
+		Index:         job.IndexName,
+		Date:          date.Format(manifest.DateLayout),
+		RunID:         run.ID(ctx),
+		FormatVersion: manifest.FormatVersion,
+		Format:        jobFormat(job),
+		Encrypted:     job.Encryption.Enabled(),
+		Compression:   session.codec.name(),
+		Dictionary:    session.codec.dictKey,
+		Objects:       objects,
+		Extras:        session.extraObjects,
+		Mapping:       session.mapping,
+		Documents:     documents,
+		Duplicates:    session.duplicates,
+		Expected:      session.expected,
+		Incomplete:    session.incomplete,
+		Partial:       len(session.failedPeriods) > 0,
+		FailedPeriods: session.failedPeriods,
+		Hours:         session.hours,
+	}
+}
+
+// putManifest fill in sizes and checksums of uploaded objects, upload
+// manifest to key, signed when the job has a signing key, and record it in
+// index catalog
+func (s *Service) putManifest(ctx context.Context, job config.BackupJob, key string, m manifest.Manifest, metadata map[string]string) error {
+	m.Checksums = make(map[string]string, len(m.Objects)+len(m.Extras))
+	m.CreatedAt = s.clock.Now().UTC()
+	for _, object := range m.Keys() {
+		info, err := s.s3Client.Stat(ctx, object)
+		if err != nil {
+			return fmt.Errorf("failed to read checksum: %w", err)
+		}
+		m.Bytes += info.Size
+		m.Checksums[object] = info.ETag
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	// Signature goes first, so a visible manifest is never without it
+	signer, err := signingKey(job)
+	if err != nil {
+		return err
+	}
+	if signer != nil {
+		if err := s.s3Client.Put(ctx, manifest.SignatureKey(key), ed25519.Sign(signer, data), nil); err != nil {
+			return fmt.Errorf("failed to upload manifest signature: %w", err)
+		}
+	}
+	if err := s.s3Client.Put(ctx, key, data, metadata); err != nil {
+		return fmt.Errorf("failed to upload manifest: %w", err)
+	}
+	log.Infof("Manifest written to %s", key)
+
+	if err := manifest.Record(ctx, s.s3Client, job.S3Path, key, m, s.clock.Now()); err != nil {
+		return fmt.Errorf("failed to update catalog of %s: %w", job.IndexName, err)
+	}
+	return nil
+}
+
+// signingKey private key manifests of job are signed with, nil without signing
+func signingKey(job config.BackupJob) (ed25519.PrivateKey, error) {
+	if !job.Signing.Enabled() {
+		return nil, nil
+	}
+	key, err := manifest.LoadSigningKey(job.Signing.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("signing.key_file: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,54 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+)
+
+// mappingSuffix appended to artifact name of archived index mapping
+const mappingSuffix = ".mapping.json"
+
+// archiveMapping upload _mapping response of searched indices next to the
+// artifacts of date, encrypted like them, and record its key for the
+// manifest; restores create missing indices with it
+func (s *Service) archiveMapping(ctx context.Context, job config.BackupJob, session *exportSession, date time.Time) error {
+	if !job.ArchiveMapping {
+		return nil
+	}
+	name, err := session.names.Artifact(job.IndexName, date, run.ID(ctx))
+	if err != nil {
+		return err
+	}
+
+	raw := &bytes.Buffer{}
+	if err := s.client.DoRaw(ctx, "GET", "/"+session.index+"/_mapping", nil, raw); err != nil {
+		return fmt.Errorf("failed to get mapping of %s: %w", session.index, err)
+	}
+	key := path.Join(job.S3Path, name+mappingSuffix)
+	data := raw.Bytes()
+	if len(session.recipients) > 0 {
+		encrypted := &bytes.Buffer{}
+		w, err := encryptWriter(encrypted, session.recipients)
+		if err == nil {
+			if _, err = w.Write(data); err == nil {
+				err = w.Close()
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encrypt mapping: %w", err)
+		}
+		key, data = key+encryptedSuffix, encrypted.Bytes()
+	}
+
+	if err := s.s3Client.Put(ctx, key, data, map[string]string{"run-id": run.ID(ctx)}); err != nil {
+		return fmt.Errorf("failed to upload mapping: %w", err)
+	}
+	session.mapping = key
+	return nil
+}
@@ -0,0 +1,134 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	log "github.com/sirupsen/logrus"
+)
+
+// extraFormat format written next to the job format from the same pages, so
+// a second format costs encoding and disk but no second read of the cluster
+type extraFormat struct {
+	job     config.BackupJob // job with Format set to the extra format
+	encoder pageEncoder
+}
+
+// extraFormats encoders of job extra_formats; their artifacts must not share
+// a name with the job artifact or with each other
+func (s *Service) extraFormats(ctx context.Context, job config.BackupJob) ([]extraFormat, error) {
+	if len(job.ExtraFormats) == 0 {
+		return nil, nil
+	}
+	if job.Chunked || job.Stream {
+		return nil, fmt.Errorf("%w: extra_formats are written into single artifacts, not with chunked or stream", errs.ErrInvalidConfig)
+	}
+
+	extensions := map[string]string{formatExtension(job): jobFormat(job)}
+	extras := make([]extraFormat, 0, len(job.ExtraFormats))
+	for _, format := range job.ExtraFormats {
+		extra := job
+		extra.Format, extra.RawSource, extra.ExtraFormats = format, false, nil
+		if job.Dedup && format == FormatSearch {
+			return nil, fmt.Errorf("%w: dedup needs document formats, extra format search writes raw responses", errs.ErrInvalidConfig)
+		}
+		ext := formatExtension(extra)
+		if other, ok := extensions[ext]; ok {
+			return nil, fmt.Errorf("%w: extra format %q writes %s artifacts like format %q", errs.ErrInvalidConfig, format, ext, other)
+		}
+		extensions[ext] = format
+
+		var encoder pageEncoder
+		var err error
+		if format == FormatAvro {
+			encoder, err = s.newAvroFormat(ctx, extra)
+		} else {
+			encoder, err = newEncoder(extra)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("extra format %s: %w", format, err)
+		}
+		extras = append(extras, extraFormat{job: extra, encoder: encoder})
+	}
+	return extras, nil
+}
+
+// extraArtifact artifact of an extra format being merged from period files
+type extraArtifact struct {
+	extraFormat
+	file string // local artifact
+	key  string
+	comp *compressor
+}
+
+// formatSink routes period files of the job format to the primary sink and
+// those of extra formats to a compressor of their own
+type formatSink struct {
+	primary periodSink
+	extras  map[string]*extraArtifact // by format
+}
+
+// startExtraArtifacts start compressor of every extra format of session and
+// record their keys for the manifest
+func (s *Service) startExtraArtifacts(ctx context.Context, job config.BackupJob, session *exportSession, date time.Time, budget *diskBudget) (map[string]*extraArtifact, error) {
+	artifacts := make(map[string]*extraArtifact, len(session.extras))
+	session.extraObjects = make(map[string]string, len(session.extras))
+	for _, extra := range session.extras {
+		name, err := session.artifactName(ctx, extra.job, date)
+		if err != nil {
+			return nil, err
+		}
+		a := &extraArtifact{extraFormat: extra, file: s.tempPath(ctx, name), key: path.Join(job.S3Path, name)}
+		if a.comp, err = s.startCompressor(a.file, budget, session.recipients, session.codec, extra.encoder.header()); err != nil {
+			for _, started := range artifacts {
+				started.comp.finish()
+			}
+			return nil, fmt.Errorf("failed to start compressor of %s: %w", extra.job.Format, err)
+		}
+		artifacts[extra.job.Format] = a
+		session.extraObjects[extra.job.Format] = a.key
+	}
+	return artifacts, nil
+}
+
+func (f *formatSink) add(files []exportFile) {
+	var primary []exportFile
+	byFormat := make(map[string][]exportFile)
+	for _, file := range files {
+		if file.Format == "" {
+			primary = append(primary, file)
+		} else {
+			byFormat[file.Format] = append(byFormat[file.Format], file)
+		}
+	}
+	f.primary.add(primary)
+	for format, extra := range byFormat {
+		f.extras[format].comp.add(extra)
+	}
+}
+
+// finish wait for every sink, returns documents of the job format
+func (f *formatSink) finish() (int, error) {
+	docs, err := f.primary.finish()
+	for format, extra := range f.extras {
+		if _, extraErr := extra.comp.finish(); extraErr != nil && err == nil {
+			err = fmt.Errorf("extra format %s: %w", format, extraErr)
+		}
+	}
+	return docs, err
+}
+
+// uploadExtras upload merged artifacts of extra formats
+func (s *Service) uploadExtras(ctx context.Context, compression codec, artifacts map[string]*extraArtifact, documents int) error {
+	for format, a := range artifacts {
+		if err := s.s3Client.Upload(ctx, a.file, a.key, documents, compression.metadata(uploadMetadata(ctx, a.job, documents))); err != nil {
+			return fmt.Errorf("failed to upload %s artifact to S3: %w", format, err)
+		}
+		log.Infof("Uploaded %s artifact %s", format, a.key)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build linux
+
+package backup
+
+import "golang.org/x/sys/unix"
+
+// setThreadNice set niceness of calling OS thread; Linux schedules threads
+// individually, and the runtime starts new threads from its template thread
+// rather than a locked one, so the rest of the process keeps its priority
+func setThreadNice(nice int) error {
+	return unix.Setpriority(unix.PRIO_PROCESS, unix.Gettid(), nice)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package backup
+
+import "errors"
+
+// setThreadNice thread priorities are only set on Linux, elsewhere niceness
+// applies to the whole process
+func setThreadNice(nice int) error {
+	return errors.New("thread niceness is only supported on Linux")
+}
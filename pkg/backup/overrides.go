@@ -0,0 +1,63 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+// windowOf start of job window containing t
+func windowOf(job config.BackupJob, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch jobWindow(job) {
+	case config.WindowWeek:
+		return weekStart(day)
+	case config.WindowMonth:
+		return monthStart(day)
+	}
+	return day
+}
+
+// overriddenWindow target window of manual run: the one containing overridden
+// date or range start; a range must not leave that window
+func overriddenWindow(job config.BackupJob, target time.Time, o run.Overrides) (time.Time, error) {
+	switch {
+	case !o.Date.IsZero():
+		return windowOf(job, o.Date), nil
+	case o.Ranged():
+		start := windowOf(job, o.From)
+		if end := windowEnd(job, start); o.To.After(end) {
+			return time.Time{}, fmt.Errorf("%w: range %s..%s must lie within one %s window (ends %s)", errs.ErrInvalidConfig,
+				o.From.Format(time.RFC3339), o.To.Format(time.RFC3339), jobWindow(job), end.Format(time.RFC3339))
+		}
+		return start, nil
+	}
+	return target, nil
+}
+
+// dryRun count documents the run would export, period by period, without
+// exporting or uploading anything
+func (s *Service) dryRun(ctx context.Context, job config.BackupJob, session *exportSession, date time.Time, s3Key string) error {
+	total := 0
+	for i, p := range session.window(job, date) {
+		count, err := s.getCount(ctx, session, session.index, p.start, p.end)
+		if err != nil {
+			return fmt.Errorf("failed to get count: %w", opensearch.Classify(err))
+		}
+		log.Infof("Dry run: period %d %s - %s has %d documents", i+1,
+			p.start.Format(time.RFC3339), p.end.Format(time.RFC3339), count)
+		total += count
+	}
+	log.WithFields(log.Fields{
+		"run_id":    run.ID(ctx),
+		"documents": total,
+		"key":       s3Key,
+	}).Infof("Dry run: backup of %s for %s would export %d documents to %s", job.IndexName, date.Format("2006-01-02"), total, s3Key)
+	return nil
+}
@@ -0,0 +1,85 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultPITKeepAlive time a point in time is kept open between two pages
+const defaultPITKeepAlive = 5 * time.Minute
+
+// pointInTime point in time a period is paged through, so its pages see
+// the indices as they were when the period started; a nil *pointInTime
+// pages the live indices. Sliced searches page each their own copy
+type pointInTime struct {
+	id        string // replaced by the id of every response
+	keepAlive string
+	slice     int // searched slice of slices, when slices > 1
+	slices    int
+}
+
+// pitKeepAlive keep_alive of job point_in_time in OpenSearch units, empty
+// unless enabled; sliced exports always page a point in time
+func pitKeepAlive(job config.BackupJob) (string, error) {
+	if !job.PointInTime.Enabled && job.Slices <= 1 {
+		return "", nil
+	}
+	keepAlive := defaultPITKeepAlive
+	if job.PointInTime.KeepAlive != "" {
+		var err error
+		if keepAlive, err = config.ParseDuration(job.PointInTime.KeepAlive); err != nil || keepAlive < time.Second {
+			return "", fmt.Errorf("%w: point_in_time.keep_alive %q must be at least 1s", errs.ErrInvalidConfig, job.PointInTime.KeepAlive)
+		}
+	}
+	return fmt.Sprintf("%dms", keepAlive.Milliseconds()), nil
+}
+
+// openPointInTime open point in time over searched indices of session, with
+// the shard selection of export searches; nil without point_in_time
+func (s *Service) openPointInTime(ctx context.Context, session *exportSession) (*pointInTime, error) {
+	if session.pitKeepAlive == "" {
+		return nil, nil
+	}
+	// Searches through the point in time cannot select shards, its creation does
+	params, err := url.ParseQuery(strings.TrimPrefix(session.searchParams, "?"))
+	if err != nil {
+		return nil, err
+	}
+	params.Set("keep_alive", session.pitKeepAlive)
+
+	var resp struct {
+		PitID string `json:"pit_id"`
+	}
+	if err := s.client.Do(ctx, "POST", "/"+session.index+"/_search/point_in_time?"+params.Encode(), nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to open point in time: %w", opensearch.Classify(err))
+	}
+	if resp.PitID == "" {
+		return nil, fmt.Errorf("failed to open point in time: no pit_id in response")
+	}
+	return &pointInTime{id: resp.PitID, keepAlive: session.pitKeepAlive, slices: session.slices}, nil
+}
+
+// closePointInTime delete point in time of finished period; failures are
+// only logged, it expires after keep_alive anyway
+func (s *Service) closePointInTime(ctx context.Context, pit *pointInTime) {
+	if pit == nil {
+		return
+	}
+	body, err := json.Marshal(map[string]interface{}{"pit_id": []string{pit.id}})
+	if err == nil {
+		err = s.client.Do(context.WithoutCancel(ctx), "DELETE", "/_search/point_in_time", bytes.NewReader(body), nil)
+	}
+	if err != nil {
+		log.Warnf("Failed to delete point in time, it expires after %s: %v", pit.keepAlive, err)
+	}
+}
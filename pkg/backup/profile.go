@@ -0,0 +1,153 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultProfileSampleRate fraction of export pages profiled by default
+	defaultProfileSampleRate = 0.01
+	// profileTopShards shards listed by timings of a profiled page
+	profileTopShards = 5
+	// profileQueryLength query description kept in logs
+	profileQueryLength = 200
+)
+
+// profileRate fraction of export pages profiled for job, 0 without profiling
+func profileRate(job config.BackupJob) (float64, error) {
+	if !job.Profile.Enabled {
+		return 0, nil
+	}
+	if job.Profile.SampleRate < 0 || job.Profile.SampleRate > 1 {
+		return 0, fmt.Errorf("%w: profile.sample_rate %v must be between 0 and 1", errs.ErrInvalidConfig, job.Profile.SampleRate)
+	}
+	if job.Profile.SampleRate == 0 {
+		return defaultProfileSampleRate, nil
+	}
+	return job.Profile.SampleRate, nil
+}
+
+// profiled whether next search page is profiled
+func (e *exportSession) profiled() bool {
+	return e.profileRate > 0 && rand.Float64() < e.profileRate
+}
+
+// profileNode timed query or collector of a shard profile
+type profileNode struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	TimeInNanos int64  `json:"time_in_nanos"`
+}
+
+// shardProfile profile of one shard, "[node][index][shard]"
+type shardProfile struct {
+	ID       string `json:"id"`
+	Searches []struct {
+		Query       []profileNode `json:"query"`
+		RewriteTime int64         `json:"rewrite_time"`
+		Collector   []profileNode `json:"collector"`
+	} `json:"searches"`
+}
+
+// shardTimings query, rewrite and collector time of shard and its most
+// expensive top-level query
+type shardTimings struct {
+	id                        string
+	query, rewrite, collector time.Duration
+	top                       profileNode
+}
+
+func (p shardProfile) timings() shardTimings {
+	t := shardTimings{id: p.ID}
+	for _, search := range p.Searches {
+		for _, node := range search.Query {
+			t.query += time.Duration(node.TimeInNanos)
+			if node.TimeInNanos > t.top.TimeInNanos {
+				t.top = node
+			}
+		}
+		t.rewrite += time.Duration(search.RewriteTime)
+		for _, node := range search.Collector {
+			t.collector += time.Duration(node.TimeInNanos)
+		}
+	}
+	return t
+}
+
+// logProfile log shard timings of profiled search response in page and remove
+// the profile from it, since pages are written to artifacts as returned
+func logProfile(index string, startTime, endTime time.Time, hits int, page *bytes.Buffer) error {
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(page.Bytes(), &response); err != nil {
+		return err
+	}
+	var profile struct {
+		Shards []shardProfile `json:"shards"`
+	}
+	if raw, ok := response["profile"]; ok {
+		if err := json.Unmarshal(raw, &profile); err != nil {
+			return err
+		}
+		delete(response, "profile")
+		stripped, err := json.Marshal(response)
+		if err != nil {
+			return err
+		}
+		page.Reset()
+		page.Write(stripped)
+	}
+	if len(profile.Shards) == 0 {
+		return nil
+	}
+	var took int64
+	json.Unmarshal(response["took"], &took)
+
+	shards := make([]shardTimings, 0, len(profile.Shards))
+	for _, shard := range profile.Shards {
+		shards = append(shards, shard.timings())
+	}
+	sort.Slice(shards, func(i, j int) bool {
+		return shards[i].query+shards[i].collector > shards[j].query+shards[j].collector
+	})
+	top := make(map[string]float64, profileTopShards)
+	for _, shard := range shards[:min(len(shards), profileTopShards)] {
+		top[shard.id] = milliseconds(shard.query + shard.collector)
+	}
+
+	slowest := shards[0]
+	query := slowest.top.Description
+	if len(query) > profileQueryLength {
+		query = query[:profileQueryLength] + "..."
+	}
+	log.WithFields(log.Fields{
+		"index":         index,
+		"from":          startTime.Format(time.RFC3339),
+		"to":            endTime.Format(time.RFC3339),
+		"hits":          hits,
+		"took_ms":       took,
+		"shards":        len(shards),
+		"slowest_shard": slowest.id,
+		"query_ms":      milliseconds(slowest.query),
+		"rewrite_ms":    milliseconds(slowest.rewrite),
+		"collector_ms":  milliseconds(slowest.collector),
+		"query_type":    slowest.top.Type,
+		"query":         query,
+		"shard_ms":      top,
+	}).Infof("Profiled export search of %s: slowest of %d shards %s spent %.1fms in %s",
+		index, len(shards), slowest.id, milliseconds(slowest.query+slowest.collector), slowest.top.Type)
+	return nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
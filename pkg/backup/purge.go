@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/blackout"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkPurge refuse purge for jobs whose artifacts cannot bring every deleted
+// document back, or are not uploaded by the run that would purge
+func checkPurge(job config.BackupJob, window config.UploadWindowConfig) error {
+	if !job.Purge.Enabled {
+		return nil
+	}
+	if job.Purge.Conflicts != "" && job.Purge.Conflicts != "abort" && job.Purge.Conflicts != "proceed" {
+		return fmt.Errorf("%w: purge.conflicts value %q must be \"abort\" or \"proceed\"", errs.ErrInvalidConfig, job.Purge.Conflicts)
+	}
+	switch {
+	case job.SampleRate > 0 && job.SampleRate < 1:
+		return fmt.Errorf("%w: purge needs every document exported, not with sample_rate", errs.ErrInvalidConfig)
+	case len(job.DocValueFields) > 0 || len(job.StoredFields) > 0:
+		return fmt.Errorf("%w: purge needs whole documents exported, not with docvalue_fields or stored_fields", errs.ErrInvalidConfig)
+	case jobFormat(job) == FormatCSV || jobFormat(job) == FormatAvro:
+		return fmt.Errorf("%w: purge needs a restorable format, %s artifacts cannot be restored", errs.ErrInvalidConfig, jobFormat(job))
+	case window.Start != "" || window.End != "":
+		return fmt.Errorf("%w: purge runs once the backup is uploaded, not with upload_window queueing the upload", errs.ErrInvalidConfig)
+	}
+	return nil
+}
+
+// purge delete the documents of the backed up window that match the export
+// query from the cluster, with that same query, once the backup of date holds
+// all of them. Skipped for incomplete backups, on blackout days and for
+// indices under legal hold; documents of held tenants are kept
+func (s *Service) purge(ctx context.Context, job config.BackupJob, session *exportSession, date time.Time, documents int) error {
+	if !job.Purge.Enabled {
+		return nil
+	}
+	day := date.Format(manifest.DateLayout)
+	if session.incomplete || len(session.failedPeriods) > 0 {
+		log.Warnf("Not purging %s: backup of %s is incomplete", job.IndexName, day)
+		return nil
+	}
+	if s.config != nil {
+		frozen, reason, err := blackout.Active(s.config.Blackout, s.clock.Now())
+		if err != nil {
+			return err
+		}
+		if frozen {
+			log.Warnf("Skipping purge for %s: blackout day (%s)", job.IndexName, reason)
+			return nil
+		}
+	}
+	holds, err := s.legalHolds()
+	if err != nil {
+		return err
+	}
+	if h, ok := holds.SuspendsRetention(job.IndexName); ok {
+		log.Warnf("Skipping purge for %s: legal hold %s", job.IndexName, h)
+		return nil
+	}
+
+	ranges := session.window(job, date)
+	if len(ranges) == 0 {
+		return nil
+	}
+	query := session.periodQuery(ranges[0].start, ranges[len(ranges)-1].end)
+	tenants := holds.Tenants(job.IndexName)
+	if len(tenants) > 0 {
+		log.Infof("Keeping documents of %d tenants under legal hold in %s", len(tenants), job.IndexName)
+		held := make([]interface{}, 0, len(tenants))
+		for _, h := range tenants {
+			held = append(held, map[string]interface{}{"term": map[string]interface{}{h.Field: h.Value}})
+		}
+		query = map[string]interface{}{
+			"bool": map[string]interface{}{"filter": []interface{}{query}, "must_not": held},
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{"query": query})
+	if err != nil {
+		return fmt.Errorf("failed to build purge query: %w", err)
+	}
+
+	// Documents indexed after the export match too; they are in no backup yet
+	var count struct {
+		Count int `json:"count"`
+	}
+	if err := s.client.Do(ctx, "POST", "/"+session.index+"/_count"+session.searchParams, bytes.NewReader(body), &count); err != nil {
+		return fmt.Errorf("failed to count documents to purge: %w", err)
+	}
+	exported := documents + session.duplicates
+	if count.Count > exported {
+		return fmt.Errorf("%w: purge of %s would delete %d documents, backup of %s holds %d; run the day again to back up the rest",
+			errs.ErrSafetyGuard, job.IndexName, count.Count, day, exported)
+	}
+	if count.Count == 0 {
+		return nil
+	}
+
+	params := url.Values{}
+	params.Set("max_docs", strconv.Itoa(count.Count))
+	params.Set("slices", "auto")
+	params.Set("refresh", "true")
+	if job.Purge.Conflicts != "" {
+		params.Set("conflicts", job.Purge.Conflicts)
+	}
+	if job.Purge.RequestsPerSecond > 0 {
+		params.Set("requests_per_second", strconv.Itoa(job.Purge.RequestsPerSecond))
+	}
+	if job.Routing != "" {
+		params.Set("routing", job.Routing)
+	}
+	var resp struct {
+		Deleted          int               `json:"deleted"`
+		VersionConflicts int               `json:"version_conflicts"`
+		Failures         []json.RawMessage `json:"failures"`
+	}
+	if err := s.client.Do(ctx, "POST", "/"+session.index+"/_delete_by_query?"+params.Encode(), bytes.NewReader(body), &resp); err != nil {
+		return fmt.Errorf("purge of %s failed: %w", job.IndexName, err)
+	}
+	if resp.VersionConflicts > 0 {
+		log.Warnf("Purge of %s hit %d version conflicts", job.IndexName, resp.VersionConflicts)
+	}
+	if len(resp.Failures) > 0 {
+		return fmt.Errorf("%w: purge of %s deleted %d documents with %d failures, first: %s",
+			errs.ErrPartialFailure, job.IndexName, resp.Deleted, len(resp.Failures), resp.Failures[0])
+	}
+	log.Infof("Purged %d documents of %s backed up for %s", resp.Deleted, job.IndexName, day)
+	return nil
+}
@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+)
+
+func TestCheckPurge(t *testing.T) {
+	purge := config.PurgeConfig{Enabled: true}
+	window := config.UploadWindowConfig{Start: "22:00", End: "06:00", Dir: "/var/lib/queue"}
+	tests := []struct {
+		name   string
+		job    config.BackupJob
+		window config.UploadWindowConfig
+		valid  bool
+	}{
+		{"disabled", config.BackupJob{SampleRate: 0.1}, window, true},
+		{"plain", config.BackupJob{Purge: purge}, config.UploadWindowConfig{}, true},
+		{"bad conflicts", config.BackupJob{Purge: config.PurgeConfig{Enabled: true, Conflicts: "ignore"}}, config.UploadWindowConfig{}, false},
+		{"sampled", config.BackupJob{Purge: purge, SampleRate: 0.1}, config.UploadWindowConfig{}, false},
+		{"doc values", config.BackupJob{Purge: purge, DocValueFields: []string{"status"}}, config.UploadWindowConfig{}, false},
+		{"csv", config.BackupJob{Purge: purge, Format: FormatCSV}, config.UploadWindowConfig{}, false},
+		{"upload window", config.BackupJob{Purge: purge}, window, false},
+	}
+	for _, tt := range tests {
+		err := checkPurge(tt.job, tt.window)
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, errs.ErrInvalidConfig) {
+			t.Errorf("%s: error %v, want invalid config", tt.name, err)
+		}
+	}
+}
+
+func TestPurgeCountGuard(t *testing.T) {
+	tests := []struct {
+		name       string
+		count      int // documents matching purge query
+		documents  int // exported by backup
+		duplicates int
+		incomplete bool
+		failures   bool
+		wantErr    error
+		wantDelete string // max_docs of _delete_by_query, empty when nothing is deleted
+	}{
+		{"all backed up", 100, 100, 0, false, false, nil, "100"},
+		{"duplicates count as backed up", 100, 90, 10, false, false, nil, "100"},
+		{"indexed after export", 101, 100, 0, false, false, errs.ErrSafetyGuard, ""},
+		{"nothing to purge", 0, 100, 0, false, false, nil, ""},
+		{"incomplete backup", 100, 100, 0, true, false, nil, ""},
+		{"delete failures", 100, 100, 0, false, true, errs.ErrPartialFailure, "100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counted, deleted := false, ""
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/_count"):
+					counted = true
+					fmt.Fprintf(w, `{"count":%d}`, tt.count)
+				case strings.HasSuffix(r.URL.Path, "/_delete_by_query"):
+					deleted = r.URL.Query().Get("max_docs")
+					if tt.failures {
+						fmt.Fprintf(w, `{"deleted":%d,"failures":[{"index":"logs","cause":{"type":"es_rejected_execution_exception"}}]}`, tt.count-1)
+						return
+					}
+					fmt.Fprintf(w, `{"deleted":%d}`, tt.count)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+			client, err := opensearch.NewClient(config.OpenSearchConfig{Addresses: []string{srv.URL}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := &Service{client: client}
+			job := config.BackupJob{IndexName: "logs", IntervalHours: 24, Purge: config.PurgeConfig{Enabled: true}}
+			session := &exportSession{index: "logs", duplicates: tt.duplicates, incomplete: tt.incomplete}
+			err = s.purge(context.Background(), job, session, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), tt.documents)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if counted == tt.incomplete {
+				t.Errorf("counted %v for incomplete %v backup", counted, tt.incomplete)
+			}
+			if deleted != tt.wantDelete {
+				t.Errorf("deleted max_docs %q, want %q", deleted, tt.wantDelete)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/hold"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+	log "github.com/sirupsen/logrus"
+)
+
+// Retention tiers of kept backups, also used as retention-tier tag values
+const (
+	TierDaily   = "daily"
+	TierWeekly  = "weekly"
+	TierMonthly = "monthly"
+)
+
+// Rotate apply job retention policy to backups in S3: backups outside every
+// tier are deleted together with their manifest, kept ones are optionally tagged
+func (s *Service) Rotate(ctx context.Context, job config.BackupJob) error {
+	svc, err := s.forJob(job)
+	if err != nil {
+		return err
+	}
+	return svc.rotate(ctx, job)
+}
+
+func (s *Service) rotate(ctx context.Context, job config.BackupJob) error {
+	backups, err := manifest.List(ctx, s.s3Client, job.S3Path, job.IndexName)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return nil
+	}
+
+	holds, err := s.legalHolds()
+	if err != nil {
+		return err
+	}
+	if h, ok := holds.SuspendsRetention(job.IndexName); ok {
+		log.Warnf("Skipping retention for %s: legal hold %s", job.IndexName, h)
+		return nil
+	}
+
+	tiers := retentionTiers(job.Retention, s.clock.Now(), backups)
+
+	// Catalog is updated on every exit, so it never lists deleted backups
+	var deleted []string
+	defer func() {
+		if err := manifest.Forget(ctx, s.s3Client, job.S3Path, job.IndexName, deleted, s.clock.Now()); err != nil {
+			log.Errorf("Failed to update catalog of %s after retention: %v", job.IndexName, err)
+		}
+	}()
+
+	for i, backup := range backups {
+		tier := tiers[i]
+		if tier == "" {
+			if h, ok := heldArtifact(holds, job.Storage, backup); ok {
+				log.Warnf("Retention: keeping backup of %s for %s: legal hold %s", job.IndexName, backup.Manifest.Date, h)
+				continue
+			}
+			log.Infof("Retention: deleting backup of %s for %s (%d objects)", job.IndexName, backup.Manifest.Date, len(backup.Manifest.Keys()))
+			// Manifest goes last, so an interrupted deletion is retried on next
+			// rotation; its signature, if any, right before it
+			keys := append(backup.Manifest.Keys(), manifest.SignatureKey(backup.Key), backup.Key)
+			if err := s.s3Client.Delete(ctx, keys...); err != nil {
+				return fmt.Errorf("failed to delete backup for %s: %w", backup.Manifest.Date, err)
+			}
+			deleted = append(deleted, backup.Key)
+			continue
+		}
+
+		if job.Retention.Tag {
+			for _, key := range append(backup.Manifest.Keys(), backup.Key) {
+				if err := s.s3Client.Tag(ctx, key, map[string]string{"retention-tier": tier}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	log.Infof("Retention for %s: %d backups kept, %d deleted", job.IndexName, len(backups)-len(deleted), len(deleted))
+	return nil
+}
+
+// legalHolds holds active now, none without config
+func (s *Service) legalHolds() (hold.Set, error) {
+	if s.config == nil {
+		return nil, nil
+	}
+	return hold.New(s.config.LegalHold).List()
+}
+
+// heldArtifact first artifact hold of storage covering backup manifest or
+// any of its objects
+func heldArtifact(holds hold.Set, storage string, backup manifest.Entry) (hold.Hold, bool) {
+	for _, key := range append([]string{backup.Key}, backup.Manifest.Keys()...) {
+		if h, ok := holds.Artifact(storage, key); ok {
+			return h, true
+		}
+	}
+	return hold.Hold{}, false
+}
+
+// retentionTiers tier keeping each backup, empty when it is to be deleted;
+// weekly and monthly tiers keep the earliest backup of each week (Monday
+// based) and month, so the kept one does not change as newer days arrive
+func retentionTiers(policy config.RetentionPolicy, now time.Time, backups []manifest.Entry) []string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	firstOfWeek := map[time.Time]time.Time{}
+	firstOfMonth := map[time.Time]time.Time{}
+	newest := time.Time{}
+	for _, b := range backups {
+		week, month := weekStart(b.Date), monthStart(b.Date)
+		if first, ok := firstOfWeek[week]; !ok || b.Date.Before(first) {
+			firstOfWeek[week] = b.Date
+		}
+		if first, ok := firstOfMonth[month]; !ok || b.Date.Before(first) {
+			firstOfMonth[month] = b.Date
+		}
+		if b.Date.After(newest) {
+			newest = b.Date
+		}
+	}
+
+	tiers := make([]string, len(backups))
+	for i, b := range backups {
+		age := int(today.Sub(b.Date).Hours() / 24)
+		weeks := int(weekStart(today).Sub(weekStart(b.Date)).Hours() / (24 * 7))
+		months := (today.Year()-b.Date.Year())*12 + int(today.Month()) - int(b.Date.Month())
+
+		switch {
+		case age < policy.Daily:
+			tiers[i] = TierDaily
+		case weeks < policy.Weekly && b.Date.Equal(firstOfWeek[weekStart(b.Date)]):
+			tiers[i] = TierWeekly
+		case months < policy.Monthly && b.Date.Equal(firstOfMonth[monthStart(b.Date)]):
+			tiers[i] = TierMonthly
+		case b.Date.Equal(newest):
+			// Never leave a job without any backup
+			tiers[i] = TierDaily
+		}
+	}
+	return tiers
+}
+
+// weekStart Monday of date week
+func weekStart(date time.Time) time.Time {
+	offset := (int(date.Weekday()) + 6) % 7
+	return date.AddDate(0, 0, -offset)
+}
+
+// monthStart first day of date month
+func monthStart(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,57 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+)
+
+func TestRetentionTiers(t *testing.T) {
+	now := time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC) // Wednesday
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+
+	var backups []manifest.Entry
+	for d := day(1, 1); d.Before(day(3, 11)); d = d.AddDate(0, 0, 1) {
+		backups = append(backups, manifest.Entry{Date: d})
+	}
+	want := map[time.Time]string{
+		day(3, 10): TierDaily, day(3, 9): TierDaily, day(3, 8): TierDaily,
+		day(3, 7): TierDaily, day(3, 6): TierDaily, day(3, 5): TierDaily,
+		// first backup of the Monday-based weeks before
+		day(3, 2): TierWeekly, day(2, 23): TierWeekly, day(2, 16): TierWeekly,
+		// first backup of the months, unless kept as weekly
+		day(3, 1): TierMonthly, day(2, 1): TierMonthly, day(1, 1): TierMonthly,
+	}
+
+	tiers := retentionTiers(config.RetentionPolicy{Daily: 7, Weekly: 4, Monthly: 3}, now, backups)
+	for i, b := range backups {
+		if tiers[i] != want[b.Date] {
+			t.Errorf("%s: tier %q, want %q", b.Date.Format("2006-01-02"), tiers[i], want[b.Date])
+		}
+	}
+
+	// Order of backups does not matter
+	reversed := make([]manifest.Entry, len(backups))
+	for i, b := range backups {
+		reversed[len(backups)-1-i] = b
+	}
+	for i, tier := range retentionTiers(config.RetentionPolicy{Daily: 7, Weekly: 4, Monthly: 3}, now, reversed) {
+		if tier != want[reversed[i].Date] {
+			t.Errorf("reversed %s: tier %q, want %q", reversed[i].Date.Format("2006-01-02"), tier, want[reversed[i].Date])
+		}
+	}
+}
+
+func TestRetentionTiersKeepsNewest(t *testing.T) {
+	now := time.Date(2026, 3, 11, 9, 0, 0, 0, time.UTC)
+	backups := []manifest.Entry{
+		{Date: time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Date: time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	tiers := retentionTiers(config.RetentionPolicy{Daily: 7}, now, backups)
+	if tiers[0] != "" || tiers[1] != TierDaily {
+		t.Errorf("tiers = %q, want only the newest kept", tiers)
+	}
+}
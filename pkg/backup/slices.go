@@ -0,0 +1,94 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxSlices upper bound of slices, beyond it the cluster gains nothing
+// while every slice holds search threads and a part file open
+const maxSlices = 64
+
+// exportSlices sliced searches a period is fetched with, 1 when unsliced
+func exportSlices(job config.BackupJob) (int, error) {
+	switch {
+	case job.Slices < 0 || job.Slices > maxSlices:
+		return 0, fmt.Errorf("%w: slices %d must be between 1 and %d", errs.ErrInvalidConfig, job.Slices, maxSlices)
+	case job.Slices > 1 && job.Stream:
+		return 0, fmt.Errorf("%w: slices needs local files, stream exports pages in order", errs.ErrInvalidConfig)
+	case job.Slices == 0:
+		return 1, nil
+	}
+	return job.Slices, nil
+}
+
+// partNumbers part numbers of a period, handed out as part files are
+// created, so concurrent slices never share one and numbers have no gaps
+type partNumbers struct {
+	mu   sync.Mutex
+	last int
+}
+
+// take next part number, 1-based
+func (n *partNumbers) take() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.last++
+	return n.last
+}
+
+// exportSliced export slice of period with one searchAndSave per sliced
+// search of pit, concurrently, each into its own part files; the files of
+// all slices together make up the slice of period. The first failure
+// cancels the other slices
+func (s *Service) exportSliced(ctx context.Context, job config.BackupJob, session *exportSession, pit *pointInTime, date time.Time, slice period, periodNum int, parts *partNumbers) ([]exportFile, error) {
+	if pit == nil || pit.slices <= 1 {
+		return s.searchAndSave(ctx, job, session, pit, date, slice, periodNum, parts)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type outcome struct {
+		files []exportFile
+		err   error
+	}
+	outcomes := make([]outcome, pit.slices)
+	var wg sync.WaitGroup
+	for i := range outcomes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sliced := *pit
+			sliced.slice = i
+			files, err := s.searchAndSave(ctx, job, session, &sliced, date, slice, periodNum, parts)
+			if err != nil {
+				cancel()
+			}
+			outcomes[i] = outcome{files: files, err: err}
+		}(i)
+	}
+	wg.Wait()
+
+	var files []exportFile
+	var err error
+	for i, o := range outcomes {
+		files = append(files, o.files...)
+		// Slices cancelled after the first failure are not the cause
+		if o.err != nil && (err == nil || errors.Is(err, context.Canceled)) {
+			err = fmt.Errorf("slice %d of %d: %w", i+1, pit.slices, o.err)
+		}
+	}
+	if err == nil {
+		log.Debugf("Exported %s - %s with %d sliced searches into %d part files",
+			slice.start.Format(time.RFC3339), slice.end.Format(time.RFC3339), pit.slices, session.parts(files))
+	}
+	return files, err
+}
@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	log "github.com/sirupsen/logrus"
+)
+
+// minSliceDuration periods are not split below this duration, documents of
+// a single instant cannot be separated by time anyway
+const minSliceDuration = time.Second
+
+// maxPeriodDocs period size cap of job; -1 means smallest max_result_window of
+// indices behind job index
+func (s *Service) maxPeriodDocs(ctx context.Context, job config.BackupJob) (int, error) {
+	if job.MaxPeriodDocs >= 0 {
+		return job.MaxPeriodDocs, nil
+	}
+	if job.MaxPeriodDocs != -1 {
+		return 0, fmt.Errorf("%w: max_period_docs must be positive, 0 or -1", errs.ErrInvalidConfig)
+	}
+
+	var resp map[string]struct {
+		Settings map[string]map[string]string `json:"settings"`
+		Defaults map[string]map[string]string `json:"defaults"`
+	}
+	path := "/" + job.IndexName + "/_settings/index.max_result_window?include_defaults=true"
+	if err := s.client.Do(ctx, "GET", path, nil, &resp); err != nil {
+		return 0, fmt.Errorf("failed to read max_result_window: %w", err)
+	}
+
+	limit := 0
+	for _, settings := range resp {
+		value := settings.Settings["index"]["max_result_window"]
+		if value == "" {
+			value = settings.Defaults["index"]["max_result_window"]
+		}
+		window, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		if limit == 0 || window < limit {
+			limit = window
+		}
+	}
+	log.Infof("Periods of %s are split above max_result_window %d", job.IndexName, limit)
+	return limit, nil
+}
+
+// splitPeriod halve period recursively until every slice holds at most
+// maxPeriodDocs documents; empty slices are dropped
+func (s *Service) splitPeriod(ctx context.Context, session *exportSession, indexName string, p period, count int) ([]period, error) {
+	if count <= session.maxPeriodDocs || p.end.Sub(p.start) < 2*minSliceDuration {
+		if count > session.maxPeriodDocs {
+			log.Warnf("Slice %s - %s still holds %d documents, exporting it whole",
+				p.start.Format(time.RFC3339Nano), p.end.Format(time.RFC3339Nano), count)
+		}
+		return []period{p}, nil
+	}
+
+	mid := p.start.Add(p.end.Sub(p.start) / 2).Truncate(time.Millisecond)
+	halves := []period{
+		{start: p.start, end: mid.Add(-time.Millisecond)},
+		{start: mid, end: p.end},
+	}
+
+	var slices []period
+	for _, half := range halves {
+		halfCount, err := s.getCount(ctx, session, indexName, half.start, half.end)
+		if err != nil {
+			return nil, err
+		}
+		if halfCount == 0 {
+			continue
+		}
+		split, err := s.splitPeriod(ctx, session, indexName, half, halfCount)
+		if err != nil {
+			return nil, err
+		}
+		slices = append(slices, split...)
+	}
+	return slices, nil
+}
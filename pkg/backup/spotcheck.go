@@ -0,0 +1,162 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultSpotCheckDocuments documents parsed back by spot check
+const defaultSpotCheckDocuments = 100
+
+// spotCheck download start of uploaded object, decrypt, decompress and parse
+// its first documents, catching pipeline bugs that produce objects which
+// upload with a valid checksum but cannot be read back
+func (s *Service) spotCheck(ctx context.Context, job config.BackupJob, key string) error {
+	if !job.SpotCheck.Enabled {
+		return nil
+	}
+	limit := job.SpotCheck.Documents
+	if limit <= 0 {
+		limit = defaultSpotCheckDocuments
+	}
+
+	object, err := s.s3Client.Download(ctx, key)
+	if err != nil {
+		return fmt.Errorf("spot check of %s: %w", key, err)
+	}
+	defer object.Close()
+
+	var compressed io.Reader = object
+	if strings.HasSuffix(key, ".age") {
+		if s.config == nil || s.config.Restore.IdentityFile == "" {
+			log.Warnf("Spot check of %s skipped: encrypted artifact needs restore.identity_file", key)
+			return nil
+		}
+		identities, err := readIdentities(s.config.Restore.IdentityFile)
+		if err != nil {
+			return err
+		}
+		if compressed, err = age.Decrypt(object, identities...); err != nil {
+			return fmt.Errorf("spot check of %s failed: decrypt: %w", key, err)
+		}
+	}
+
+	decompressed, err := s.decompress(ctx, key, compressed)
+	if err != nil {
+		return fmt.Errorf("spot check of %s failed: %w", key, err)
+	}
+	defer decompressed.Close()
+
+	parsed, err := parseSample(jobFormat(job), decompressed, limit)
+	if err != nil {
+		return fmt.Errorf("spot check of %s failed after %d documents: %w", key, parsed, err)
+	}
+	log.Infof("Spot check of %s passed: %d documents read back", key, parsed)
+	return nil
+}
+
+// readIdentities age identities of identity file
+func readIdentities(path string) ([]age.Identity, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("identity file: %w", err)
+	}
+	defer file.Close()
+
+	identities, err := age.ParseIdentities(file)
+	if err != nil {
+		return nil, fmt.Errorf("identity file: %w", err)
+	}
+	return identities, nil
+}
+
+// parseSample parse up to limit documents of format from r, returns parsed count;
+// Avro containers are only checked for their header
+func parseSample(format string, r io.Reader, limit int) (int, error) {
+	switch format {
+	case FormatAvro:
+		magic := make([]byte, len(avroMagic))
+		if _, err := io.ReadFull(r, magic); err != nil {
+			return 0, fmt.Errorf("avro header: %w", err)
+		}
+		if !bytes.Equal(magic, avroMagic) {
+			return 0, fmt.Errorf("not an avro container")
+		}
+		return 0, nil
+
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		parsed := 0
+		for ; parsed < limit; parsed++ {
+			if _, err := reader.Read(); err != nil {
+				if errors.Is(err, io.EOF) {
+					return parsed, nil
+				}
+				return parsed, err
+			}
+		}
+		return parsed, nil
+	}
+
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	parsed := 0
+	for parsed < limit {
+		switch format {
+		case FormatSearch:
+			var page struct {
+				Hits *struct {
+					Hits []json.RawMessage `json:"hits"`
+				} `json:"hits"`
+			}
+			if err := decoder.Decode(&page); err != nil {
+				return parsed, eofOK(err)
+			}
+			if page.Hits == nil {
+				return parsed, fmt.Errorf("line is not a search response")
+			}
+			parsed += len(page.Hits.Hits)
+
+		case FormatBulk:
+			var action map[string]json.RawMessage
+			if err := decoder.Decode(&action); err != nil {
+				return parsed, eofOK(err)
+			}
+			if _, ok := action["index"]; !ok {
+				return parsed, fmt.Errorf("expected index action line")
+			}
+			var source map[string]json.RawMessage
+			if err := decoder.Decode(&source); err != nil {
+				return parsed, fmt.Errorf("source line: %w", err)
+			}
+			parsed++
+
+		default:
+			var source map[string]json.RawMessage
+			if err := decoder.Decode(&source); err != nil {
+				return parsed, eofOK(err)
+			}
+			parsed++
+		}
+	}
+	return parsed, nil
+}
+
+// eofOK end of stream is no error, artifact may hold fewer documents than sampled
+func eofOK(err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,240 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/events"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultStreamPartSizeMB in-memory S3 part buffer for stream mode
+const defaultStreamPartSizeMB = 16
+
+// period time range of a single export request
+type period struct {
+	start, end time.Time
+}
+
+// checkInterval refuse interval_hours that do not split windows into
+// periods aligned to midnight: divisors of a day, or whole days for week and
+// month windows
+func checkInterval(job config.BackupJob) error {
+	n := job.IntervalHours
+	if n <= 0 || (24%n != 0 && n%24 != 0) {
+		return fmt.Errorf("%w: interval_hours %d must divide 24 (1, 2, 3, 4, 6, 8, 12) or be whole days (24, 48, ...)", errs.ErrInvalidConfig, n)
+	}
+	return nil
+}
+
+// periods split job window starting at date into job intervals; none for an
+// invalid interval_hours (see checkInterval)
+func periods(job config.BackupJob, date time.Time) []period {
+	if checkInterval(job) != nil {
+		return nil
+	}
+	windowStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	windowEnd := windowEnd(job, windowStart)
+
+	var result []period
+	step := time.Duration(job.IntervalHours) * time.Hour
+	for start := windowStart; start.Before(windowEnd); start = start.Add(step) {
+		end := start.Add(step)
+		if end.After(windowEnd) {
+			end = windowEnd
+		}
+		result = append(result, period{start: start, end: end.Add(-time.Millisecond)})
+	}
+	return result
+}
+
+// window export periods of target date; the first one also covers the
+// late_overlap tail of previous day, re-exporting documents that arrived
+// after previous run
+func (e *exportSession) window(job config.BackupJob, date time.Time) []period {
+	ranges := periods(job, date)
+	if len(ranges) == 0 {
+		return nil
+	}
+	if e.from.IsZero() {
+		ranges[0].start = ranges[0].start.Add(-e.lateOverlap)
+		return ranges
+	}
+
+	// Manual range run exports only periods overlapping [from, to)
+	var clipped []period
+	last := e.to.Add(-time.Millisecond)
+	for _, p := range ranges {
+		if p.end.Before(e.from) || !p.start.Before(e.to) {
+			continue
+		}
+		if p.start.Before(e.from) {
+			p.start = e.from
+		}
+		if p.end.After(last) {
+			p.end = last
+		}
+		clipped = append(clipped, p)
+	}
+	return clipped
+}
+
+// backupStream export day straight through compression into S3 multipart upload,
+// never touching local disk; memory is bounded by stream_part_size_mb
+func (s *Service) backupStream(ctx context.Context, job config.BackupJob, session *exportSession, date time.Time, s3Key string) error {
+	ranges := session.window(job, date)
+
+	// Count upfront, so an empty day does not produce an empty object
+	expected := 0
+	var slices []period
+	for _, p := range ranges {
+		count, err := s.getCount(ctx, session, session.index, p.start, p.end)
+		if err != nil {
+			return fmt.Errorf("failed to get count: %w", opensearch.Classify(err))
+		}
+		expected += count
+
+		if session.maxPeriodDocs > 0 && count > session.maxPeriodDocs {
+			split, err := s.splitPeriod(ctx, session, session.index, p, count)
+			if err != nil {
+				return fmt.Errorf("failed to split period: %w", opensearch.Classify(err))
+			}
+			slices = append(slices, split...)
+			continue
+		}
+		slices = append(slices, p)
+	}
+	ranges = slices
+	if expected == 0 {
+		log.Warnf("No data downloaded for %s", job.IndexName)
+		return nil
+	}
+
+	workers := compressionWorkers(job)
+	partSize := job.StreamPartSizeMB
+	if partSize <= 0 {
+		partSize = defaultStreamPartSizeMB
+	}
+
+	log.Infof("Streaming %d documents of %s directly to S3", expected, job.IndexName)
+
+	pipeReader, pipeWriter := io.Pipe()
+	uploaded := make(chan error, 1)
+	go func() {
+		// Document count is not known until stream ends, only expected one
+		err := run.Protect(ctx, "stream upload of "+s3Key, func() error {
+			_, err := s.s3Client.UploadStream(ctx, pipeReader, s3Key, uint64(partSize)<<20, session.codec.metadata(uploadMetadata(ctx, job, expected)))
+			return err
+		})
+		// Unblock writer if upload gave up early
+		pipeReader.CloseWithError(err)
+		uploaded <- err
+	}()
+
+	docs, err := s.encryptedExport(ctx, job, session, ranges, pipeWriter, workers)
+	// Export error aborts multipart upload instead of completing a truncated object
+	pipeWriter.CloseWithError(err)
+	uploadErr := <-uploaded
+
+	if err != nil {
+		return fmt.Errorf("failed to export: %w", err)
+	}
+	if uploadErr != nil {
+		return fmt.Errorf("failed to upload to S3: %w", uploadErr)
+	}
+	if err := s.spotCheck(ctx, job, s3Key); err != nil {
+		return err
+	}
+
+	incomplete := s.checkCompleteness(ctx, job, session, date, docs)
+	if incomplete != nil && !errors.Is(incomplete, errs.ErrIncomplete) {
+		return incomplete
+	}
+	if err := s.writeManifest(ctx, job, session, date, []string{s3Key}, docs); err != nil {
+		return err
+	}
+
+	log.Infof("Backup completed for %s: %s (%d documents, run %s)", job.IndexName, s3Key, docs, run.ID(ctx))
+	return incomplete
+}
+
+// encryptedExport export into w through age encryption when recipients are set
+func (s *Service) encryptedExport(ctx context.Context, job config.BackupJob, session *exportSession, ranges []period, w io.Writer, workers int) (int, error) {
+	enc, err := encryptWriter(w, session.recipients)
+	if err != nil {
+		return 0, err
+	}
+
+	docs, err := s.exportStream(ctx, job, session, ranges, enc, workers)
+	if err != nil {
+		return docs, err
+	}
+	return docs, enc.Close()
+}
+
+// exportStream write all periods pages into single compressed stream
+func (s *Service) exportStream(ctx context.Context, job config.BackupJob, session *exportSession, ranges []period, w io.Writer, workers int) (int, error) {
+	writer, err := session.codec.writer(w, "", workers)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := writer.Write(session.encoder.header()); err != nil {
+		return 0, err
+	}
+
+	docs := 0
+	for i, p := range ranges {
+		pit, err := s.openPointInTime(ctx, session)
+		if err != nil {
+			writer.Close()
+			return docs, fmt.Errorf("period %d: %w", i+1, err)
+		}
+		var searchAfter []interface{}
+		for {
+			page := getBuffer()
+			hits, size, err := s.fetchPage(ctx, session, pit, p.start, p.end, 0, searchAfter, page)
+			kept := session.unique(hits)
+			if err == nil && len(kept) > 0 {
+				err = writePage(writer, session.encoder, page.Bytes(), kept)
+			}
+			putBuffer(page)
+			if err != nil {
+				s.closePointInTime(ctx, pit)
+				writer.Close()
+				return docs, fmt.Errorf("period %d: %w", i+1, err)
+			}
+
+			docs += len(kept)
+			session.hours.add(kept)
+			if len(hits) < size {
+				break
+			}
+			searchAfter = hits[len(hits)-1].Sort
+		}
+		s.closePointInTime(ctx, pit)
+		events.ReportProgress(ctx, i+1, len(ranges), fmt.Sprintf("period %d/%d streamed (%d documents)", i+1, len(ranges), docs))
+
+		// Pause between requests
+		if i < len(ranges)-1 && job.RequestInterval > 0 {
+			log.Infof("Waiting %d seconds before next request...", job.RequestInterval)
+			select {
+			case <-ctx.Done():
+				writer.Close()
+				return docs, ctx.Err()
+			case <-s.clock.After(time.Duration(job.RequestInterval) * time.Second):
+			}
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return docs, err
+	}
+	return docs, nil
+}
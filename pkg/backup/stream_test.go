@@ -0,0 +1,64 @@
+package backup
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+)
+
+func TestPeriods(t *testing.T) {
+	day := time.Date(2026, 3, 9, 15, 4, 5, 0, time.UTC)
+	midnight := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		job   config.BackupJob
+		count int
+		last  time.Time // end of the last period
+	}{
+		{"whole day", config.BackupJob{IntervalHours: 24}, 1, midnight.Add(24*time.Hour - time.Millisecond)},
+		{"six hours", config.BackupJob{IntervalHours: 6}, 4, midnight.Add(24*time.Hour - time.Millisecond)},
+		{"one hour", config.BackupJob{IntervalHours: 1}, 24, midnight.Add(24*time.Hour - time.Millisecond)},
+		{"week of days", config.BackupJob{IntervalHours: 24, Window: config.WindowWeek}, 7, midnight.AddDate(0, 0, 7).Add(-time.Millisecond)},
+		{"week of two days", config.BackupJob{IntervalHours: 48, Window: config.WindowWeek}, 4, midnight.AddDate(0, 0, 7).Add(-time.Millisecond)},
+		{"unset", config.BackupJob{}, 0, time.Time{}},
+		{"negative", config.BackupJob{IntervalHours: -6}, 0, time.Time{}},
+		{"not dividing a day", config.BackupJob{IntervalHours: 5}, 0, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := periods(tt.job, day)
+			if len(got) != tt.count {
+				t.Fatalf("periods = %d, want %d", len(got), tt.count)
+			}
+			if tt.count == 0 {
+				return
+			}
+			if !got[0].start.Equal(midnight) {
+				t.Errorf("first start = %v, want %v", got[0].start, midnight)
+			}
+			if end := got[len(got)-1].end; !end.Equal(tt.last) {
+				t.Errorf("last end = %v, want %v", end, tt.last)
+			}
+			for i := 1; i < len(got); i++ {
+				if !got[i].start.Equal(got[i-1].end.Add(time.Millisecond)) {
+					t.Errorf("period %d starts at %v, previous ends at %v", i, got[i].start, got[i-1].end)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckInterval(t *testing.T) {
+	for hours, valid := range map[int]bool{1: true, 2: true, 6: true, 12: true, 24: true, 48: true, 168: true, 0: false, -1: false, 5: false, 36: false} {
+		err := checkInterval(config.BackupJob{IntervalHours: hours})
+		if valid && err != nil {
+			t.Errorf("interval_hours %d: unexpected error %v", hours, err)
+		}
+		if !valid && !errors.Is(err, errs.ErrInvalidConfig) {
+			t.Errorf("interval_hours %d: error %v, want invalid config", hours, err)
+		}
+	}
+}
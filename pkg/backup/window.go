@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/robfig/cron/v3"
+)
+
+// jobWindow window of job, "auto" is resolved by targetWindow
+func jobWindow(job config.BackupJob) string {
+	if job.Window == "" {
+		return config.WindowDay
+	}
+	return job.Window
+}
+
+// windowEnd exclusive end of job window starting at start
+func windowEnd(job config.BackupJob, start time.Time) time.Time {
+	switch jobWindow(job) {
+	case config.WindowWeek:
+		return start.AddDate(0, 0, 7)
+	case config.WindowMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// TargetWindow start of the window a run of job at now exports
+func TargetWindow(job config.BackupJob, now time.Time) (time.Time, error) {
+	return targetWindow(&job, now)
+}
+
+// targetWindow start of last complete window before now: yesterday, previous
+// Monday-based week or previous month; "auto" picks the window from the gap
+// between scheduled runs, so a weekly schedule exports a week
+func targetWindow(job *config.BackupJob, now time.Time) (time.Time, error) {
+	if job.Window == config.WindowAuto {
+		window, err := scheduleWindow(job.Schedule, now)
+		if err != nil {
+			return time.Time{}, err
+		}
+		job.Window = window
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch jobWindow(*job) {
+	case config.WindowDay:
+		return today.AddDate(0, 0, -1), nil
+	case config.WindowWeek:
+		return weekStart(today).AddDate(0, 0, -7), nil
+	case config.WindowMonth:
+		return monthStart(today).AddDate(0, -1, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("%w: window %q must be %q, %q, %q or %q", errs.ErrInvalidConfig, job.Window,
+		config.WindowDay, config.WindowWeek, config.WindowMonth, config.WindowAuto)
+}
+
+// scheduleWindow window matching cron schedule: month when runs are at least
+// 28 days apart, week when at least 7, day otherwise
+func scheduleWindow(schedule string, now time.Time) (string, error) {
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return "", fmt.Errorf("%w: window auto: schedule: %w", errs.ErrInvalidConfig, err)
+	}
+
+	next := sched.Next(now)
+	gap := sched.Next(next).Sub(next)
+	switch {
+	case gap >= 28*24*time.Hour:
+		return config.WindowMonth, nil
+	case gap >= 7*24*time.Hour:
+		return config.WindowWeek, nil
+	}
+	return config.WindowDay, nil
+}
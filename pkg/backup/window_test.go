@@ -0,0 +1,65 @@
+package backup
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+)
+
+func TestTargetWindow(t *testing.T) {
+	now := time.Date(2026, 3, 11, 14, 30, 0, 0, time.UTC) // Wednesday
+	tests := []struct {
+		name     string
+		job      config.BackupJob
+		want     time.Time
+		window   string // resolved window of the job
+		wantFail bool
+	}{
+		{"default day", config.BackupJob{}, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), config.WindowDay, false},
+		{"day", config.BackupJob{Window: config.WindowDay}, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), config.WindowDay, false},
+		{"week", config.BackupJob{Window: config.WindowWeek}, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), config.WindowWeek, false},
+		{"month", config.BackupJob{Window: config.WindowMonth}, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), config.WindowMonth, false},
+		{"auto daily", config.BackupJob{Window: config.WindowAuto, Schedule: "0 2 * * *"}, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), config.WindowDay, false},
+		{"auto weekly", config.BackupJob{Window: config.WindowAuto, Schedule: "0 2 * * 1"}, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), config.WindowWeek, false},
+		{"auto monthly", config.BackupJob{Window: config.WindowAuto, Schedule: "0 2 1 * *"}, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), config.WindowMonth, false},
+		{"auto bad schedule", config.BackupJob{Window: config.WindowAuto, Schedule: "daily"}, time.Time{}, "", true},
+		{"unknown", config.BackupJob{Window: "year"}, time.Time{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := tt.job
+			got, err := targetWindow(&job, now)
+			if tt.wantFail {
+				if !errors.Is(err, errs.ErrInvalidConfig) {
+					t.Fatalf("error %v, want invalid config", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("window start = %v, want %v", got, tt.want)
+			}
+			if w := jobWindow(job); w != tt.window {
+				t.Errorf("window = %q, want %q", w, tt.window)
+			}
+		})
+	}
+}
+
+func TestWindowEnd(t *testing.T) {
+	start := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	for window, want := range map[string]time.Time{
+		"":                 time.Date(2026, 2, 2, 0, 0, 0, 0, time.UTC),
+		config.WindowWeek:  time.Date(2026, 2, 8, 0, 0, 0, 0, time.UTC),
+		config.WindowMonth: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		if got := windowEnd(config.BackupJob{Window: window}, start); !got.Equal(want) {
+			t.Errorf("end of %q window = %v, want %v", window, got, want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Package blackout decides whether a day is a change freeze on which
+// destructive jobs (cleanup, retention rotation) must not run.
+package blackout
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+)
+
+const dateLayout = "2006-01-02"
+
+// period blackout days [from, to) as civil dates at UTC midnight
+type period struct {
+	from, to time.Time
+	name     string
+}
+
+// Active reports whether t falls on a blackout day and names the matching entry.
+// The calendar file is read on every call so edits apply without restart.
+func Active(cfg config.BlackoutConfig, t time.Time) (bool, string, error) {
+	if len(cfg.Dates) == 0 && cfg.File == "" {
+		return false, "", nil
+	}
+	loc := time.UTC
+	if cfg.Timezone != "" {
+		l, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return false, "", fmt.Errorf("%w: blackout timezone %q: %v", errs.ErrInvalidConfig, cfg.Timezone, err)
+		}
+		loc = l
+	}
+
+	var periods []period
+	for _, d := range cfg.Dates {
+		p, err := parseDates(d)
+		if err != nil {
+			return false, "", err
+		}
+		periods = append(periods, p)
+	}
+	if cfg.File != "" {
+		data, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return false, "", fmt.Errorf("failed to read blackout calendar: %w", err)
+		}
+		var fromFile []period
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("BEGIN:VCALENDAR")) {
+			fromFile, err = parseICal(data, loc)
+		} else {
+			fromFile, err = parseList(data)
+		}
+		if err != nil {
+			return false, "", fmt.Errorf("blackout calendar %s: %w", cfg.File, err)
+		}
+		periods = append(periods, fromFile...)
+	}
+
+	day := civil(t, loc)
+	for _, p := range periods {
+		if !day.Before(p.from) && day.Before(p.to) {
+			return true, p.name, nil
+		}
+	}
+	return false, "", nil
+}
+
+// parseDates parse "2026-11-27" or inclusive range "2026-12-20..2027-01-05",
+// optionally followed by "# comment" naming the freeze
+func parseDates(s string) (period, error) {
+	entry, name, _ := strings.Cut(s, "#")
+	entry, name = strings.TrimSpace(entry), strings.TrimSpace(name)
+	first, last, isRange := strings.Cut(entry, "..")
+	from, err := time.Parse(dateLayout, strings.TrimSpace(first))
+	if err != nil {
+		return period{}, fmt.Errorf("%w: blackout date %q: expected YYYY-MM-DD or YYYY-MM-DD..YYYY-MM-DD", errs.ErrInvalidConfig, s)
+	}
+	to := from
+	if isRange {
+		if to, err = time.Parse(dateLayout, strings.TrimSpace(last)); err != nil || to.Before(from) {
+			return period{}, fmt.Errorf("%w: blackout range %q: expected YYYY-MM-DD..YYYY-MM-DD with end not before start", errs.ErrInvalidConfig, s)
+		}
+	}
+	if name == "" {
+		name = entry
+	}
+	return period{from: from, to: to.AddDate(0, 0, 1), name: name}, nil
+}
+
+// parseList parse calendar file with one date or range per line,
+// blank lines and lines starting with # are ignored
+func parseList(data []byte) ([]period, error) {
+	var periods []period
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p, err := parseDates(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		periods = append(periods, p)
+	}
+	return periods, scanner.Err()
+}
+
+// parseICal take VEVENT DTSTART/DTEND of iCalendar data; recurrence rules
+// are not expanded, so yearly freezes must be listed per year
+func parseICal(data []byte, loc *time.Location) ([]period, error) {
+	var (
+		periods    []period
+		inEvent    bool
+		start, end time.Time
+		allDay     bool
+		summary    string
+	)
+	for _, line := range unfold(data) {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		prop, params, _ := strings.Cut(name, ";")
+		switch strings.ToUpper(prop) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				inEvent, start, end, allDay, summary = true, time.Time{}, time.Time{}, false, ""
+			}
+		case "END":
+			if !inEvent || !strings.EqualFold(value, "VEVENT") {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				return nil, fmt.Errorf("%w: VEVENT %q without DTSTART", errs.ErrInvalidConfig, summary)
+			}
+			periods = append(periods, eventPeriod(start, end, allDay, summary, loc))
+		case "DTSTART", "DTEND":
+			if !inEvent {
+				continue
+			}
+			t, date, err := parseICalTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s %q: %v", errs.ErrInvalidConfig, prop, value, err)
+			}
+			if strings.EqualFold(prop, "DTSTART") {
+				start, allDay = t, date
+			} else {
+				end = t
+			}
+		case "SUMMARY":
+			if inEvent {
+				summary = value
+			}
+		}
+	}
+	return periods, nil
+}
+
+// eventPeriod blackout days covered by event; DTEND is exclusive, a missing
+// one means a single day
+func eventPeriod(start, end time.Time, allDay bool, summary string, loc *time.Location) period {
+	from := civil(start, loc)
+	var to time.Time
+	switch {
+	case end.IsZero() || !end.After(start):
+		to = from.AddDate(0, 0, 1)
+	case allDay:
+		to = civil(end, loc)
+	default:
+		// timed event blocks every day it touches
+		to = civil(end.Add(-time.Nanosecond), loc).AddDate(0, 0, 1)
+	}
+	if summary == "" {
+		summary = from.Format(dateLayout)
+	}
+	return period{from: from, to: to, name: summary}
+}
+
+// parseICalTime parse DATE (20261127) or DATE-TIME (20261127T090000[Z]) value
+// honouring TZID parameter
+func parseICalTime(value, params string, loc *time.Location) (time.Time, bool, error) {
+	if len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t, false, err
+	}
+	for _, p := range strings.Split(params, ";") {
+		if k, v, ok := strings.Cut(p, "="); ok && strings.EqualFold(k, "TZID") {
+			l, err := time.LoadLocation(strings.Trim(v, `"`))
+			if err != nil {
+				return time.Time{}, false, err
+			}
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// unfold join iCalendar continuation lines (starting with space or tab)
+func unfold(data []byte) []string {
+	var lines []string
+	for _, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += raw[1:]
+			continue
+		}
+		lines = append(lines, strings.TrimRight(raw, "\r"))
+	}
+	return lines
+}
+
+// civil date of t in loc as UTC midnight
+func civil(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
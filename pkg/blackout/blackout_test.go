@@ -0,0 +1,120 @@
+package blackout
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+)
+
+func TestParseDates(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		entry    string
+		from, to time.Time
+		name     string
+		wantErr  bool
+	}{
+		{"2026-11-27", day(2026, 11, 27), day(2026, 11, 28), "2026-11-27", false},
+		{"2026-11-27 # Black Friday", day(2026, 11, 27), day(2026, 11, 28), "Black Friday", false},
+		{"2026-12-20..2027-01-05", day(2026, 12, 20), day(2027, 1, 6), "2026-12-20..2027-01-05", false},
+		{" 2026-12-20 .. 2027-01-05 #year end", day(2026, 12, 20), day(2027, 1, 6), "year end", false},
+		{"2026-12-20..2026-12-19", time.Time{}, time.Time{}, "", true},
+		{"27.11.2026", time.Time{}, time.Time{}, "", true},
+		{"2026-12-20..", time.Time{}, time.Time{}, "", true},
+	}
+	for _, tt := range tests {
+		p, err := parseDates(tt.entry)
+		if tt.wantErr {
+			if !errors.Is(err, errs.ErrInvalidConfig) {
+				t.Errorf("parseDates(%q): error %v, want invalid config", tt.entry, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseDates(%q): %v", tt.entry, err)
+			continue
+		}
+		if !p.from.Equal(tt.from) || !p.to.Equal(tt.to) || p.name != tt.name {
+			t.Errorf("parseDates(%q) = %v..%v %q, want %v..%v %q", tt.entry, p.from, p.to, p.name, tt.from, tt.to, tt.name)
+		}
+	}
+}
+
+func TestActive(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "freeze.txt")
+	os.WriteFile(list, []byte("# change freezes\n\n2026-12-20..2026-12-31 # year end\n2026-06-01\n"), 0644)
+	ics := filepath.Join(dir, "freeze.ics")
+	os.WriteFile(ics, []byte("BEGIN:VCALENDAR\r\n"+
+		"BEGIN:VEVENT\r\nSUMMARY:Christmas\r\nDTSTART;VALUE=DATE:20261224\r\nDTEND;VALUE=DATE:20261227\r\nEND:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\nSUMMARY:Black\r\n  Friday sale\r\nDTSTART:20261127T220000Z\r\nDTEND:20261128T020000Z\r\nEND:VEVENT\r\n"+
+		"BEGIN:VEVENT\r\nSUMMARY:Berlin launch\r\nDTSTART;TZID=Europe/Berlin:20260310T000000\r\nEND:VEVENT\r\n"+
+		"END:VCALENDAR\r\n"), 0644)
+
+	at := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	tests := []struct {
+		name   string
+		cfg    config.BlackoutConfig
+		t      time.Time
+		active bool
+		reason string
+	}{
+		{"none configured", config.BlackoutConfig{}, at("2026-11-27T12:00:00Z"), false, ""},
+		{"listed date", config.BlackoutConfig{Dates: []string{"2026-11-27"}}, at("2026-11-27T23:59:59Z"), true, "2026-11-27"},
+		{"day after", config.BlackoutConfig{Dates: []string{"2026-11-27"}}, at("2026-11-28T00:00:00Z"), false, ""},
+		{"date in timezone", config.BlackoutConfig{Dates: []string{"2026-11-27"}, Timezone: "America/New_York"}, at("2026-11-28T03:00:00Z"), true, "2026-11-27"},
+		{"list range", config.BlackoutConfig{File: list}, at("2026-12-31T10:00:00Z"), true, "year end"},
+		{"list single", config.BlackoutConfig{File: list}, at("2026-06-01T10:00:00Z"), true, "2026-06-01"},
+		{"list outside", config.BlackoutConfig{File: list}, at("2027-01-01T10:00:00Z"), false, ""},
+		{"all-day event", config.BlackoutConfig{File: ics}, at("2026-12-26T10:00:00Z"), true, "Christmas"},
+		{"all-day event end exclusive", config.BlackoutConfig{File: ics}, at("2026-12-27T10:00:00Z"), false, ""},
+		{"timed event over midnight", config.BlackoutConfig{File: ics}, at("2026-11-28T10:00:00Z"), true, "Black Friday sale"},
+		// Berlin midnight is 23:00 UTC of the day before
+		{"zoned event by UTC days", config.BlackoutConfig{File: ics}, at("2026-03-10T12:00:00Z"), false, ""},
+		{"zoned event by its days", config.BlackoutConfig{File: ics, Timezone: "Europe/Berlin"}, at("2026-03-10T12:00:00Z"), true, "Berlin launch"},
+	}
+	for _, tt := range tests {
+		active, reason, err := Active(tt.cfg, tt.t)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if active != tt.active || reason != tt.reason {
+			t.Errorf("%s: Active = %v %q, want %v %q", tt.name, active, reason, tt.active, tt.reason)
+		}
+	}
+}
+
+func TestActiveInvalid(t *testing.T) {
+	dir := t.TempDir()
+	noStart := filepath.Join(dir, "bad.ics")
+	os.WriteFile(noStart, []byte("BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:x\nEND:VEVENT\nEND:VCALENDAR\n"), 0644)
+	badLine := filepath.Join(dir, "bad.txt")
+	os.WriteFile(badLine, []byte("2026-11-27\nnext friday\n"), 0644)
+
+	now := time.Date(2026, 11, 27, 12, 0, 0, 0, time.UTC)
+	for name, cfg := range map[string]config.BlackoutConfig{
+		"bad date":         {Dates: []string{"tomorrow"}},
+		"bad timezone":     {Dates: []string{"2026-11-27"}, Timezone: "Mars/Olympus"},
+		"event no DTSTART": {File: noStart},
+		"bad list line":    {File: badLine},
+	} {
+		if _, _, err := Active(cfg, now); !errors.Is(err, errs.ErrInvalidConfig) {
+			t.Errorf("%s: error %v, want invalid config", name, err)
+		}
+	}
+	if _, _, err := Active(config.BlackoutConfig{File: filepath.Join(dir, "missing")}, now); err == nil {
+		t.Error("missing calendar file accepted")
+	}
+}
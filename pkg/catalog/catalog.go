@@ -0,0 +1,174 @@
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultIndex default name of catalog index
+const DefaultIndex = "opensearch-backup-catalog"
+
+// Catalog persists job run records into an OpenSearch index.
+// A nil *Catalog is valid and drops all records (catalog disabled).
+type Catalog struct {
+	client opensearch.API
+	index  string
+}
+
+// CleanupRecord per-index cleanup outcome for auditors
+type CleanupRecord struct {
+	Timestamp          time.Time `json:"@timestamp"`
+	Type               string    `json:"type"`
+	RunID              string    `json:"run_id"`
+	Date               string    `json:"date"`
+	Job                string    `json:"job"`
+	Index              string    `json:"index"`
+	Status             string    `json:"status"`
+	Error              string    `json:"error,omitempty"`
+	CountBefore        int       `json:"count_before"`
+	Matched            int       `json:"matched"`
+	Deleted            int       `json:"deleted"`
+	CountAfter         int       `json:"count_after"`
+	SizeBefore         int64     `json:"size_before"`
+	SizeAfter          int64     `json:"size_after"`
+	EstimatedReclaimed int64     `json:"estimated_reclaimed"`
+}
+
+// RunRecord job run outcome not covered by per-index records, e.g. a run
+// interrupted by a crash
+type RunRecord struct {
+	Timestamp time.Time `json:"@timestamp"`
+	Type      string    `json:"type"`
+	RunID     string    `json:"run_id"`
+	Date      string    `json:"date"`
+	Job       string    `json:"job"`
+	JobType   string    `json:"job_type"` // "cleanup", "backup" or "fire_drill"
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Started   time.Time `json:"started"`
+}
+
+const mapping = `{
+	"mappings": {
+		"dynamic": true,
+		"properties": {
+			"@timestamp": {"type": "date"},
+			"type": {"type": "keyword"},
+			"run_id": {"type": "keyword"},
+			"date": {"type": "date", "format": "yyyy-MM-dd"},
+			"job": {"type": "keyword"},
+			"index": {"type": "keyword"},
+			"status": {"type": "keyword"},
+			"error": {"type": "text"}
+		}
+	}
+}`
+
+// New create catalog, returns nil when catalog is disabled
+func New(client opensearch.API, cfg config.CatalogConfig) *Catalog {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	index := cfg.Index
+	if index == "" {
+		index = DefaultIndex
+	}
+
+	return &Catalog{
+		client: client,
+		index:  index,
+	}
+}
+
+// EnsureIndex create catalog index with mapping if it does not exist
+func (c *Catalog) EnsureIndex(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+
+	resp, err := c.client.GetClient().Indices.Exists(ctx, opensearchapi.IndicesExistsReq{
+		Indices: []string{c.index},
+	})
+	if resp != nil && resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to check catalog index %s: %w", c.index, err)
+	}
+
+	if _, err := c.client.GetClient().Indices.Create(ctx, opensearchapi.IndicesCreateReq{
+		Index: c.index,
+		Body:  strings.NewReader(mapping),
+	}); err != nil {
+		return fmt.Errorf("failed to create catalog index %s: %w", c.index, err)
+	}
+
+	log.Infof("Created catalog index %s", c.index)
+	return nil
+}
+
+// RecordCleanup persist cleanup outcome of single index; Timestamp comes from
+// the caller's clock, the wall clock only fills in a zero one
+func (c *Catalog) RecordCleanup(ctx context.Context, record CleanupRecord) error {
+	if c == nil {
+		return nil
+	}
+
+	record.Type = "cleanup"
+	if record.RunID == "" {
+		record.RunID = run.ID(ctx)
+	}
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now().UTC()
+	}
+	if record.Date == "" {
+		record.Date = record.Timestamp.Format("2006-01-02")
+	}
+
+	return c.put(ctx, record)
+}
+
+// RecordRun persist outcome of whole run, timestamped like RecordCleanup
+func (c *Catalog) RecordRun(ctx context.Context, record RunRecord) error {
+	if c == nil {
+		return nil
+	}
+
+	record.Type = "run"
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now().UTC()
+	}
+	if record.Date == "" {
+		record.Date = record.Timestamp.Format("2006-01-02")
+	}
+
+	return c.put(ctx, record)
+}
+
+func (c *Catalog) put(ctx context.Context, record interface{}) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode catalog record: %w", err)
+	}
+
+	if _, err := c.client.GetClient().Index(ctx, opensearchapi.IndexReq{
+		Index: c.index,
+		Body:  bytes.NewReader(body),
+	}); err != nil {
+		return fmt.Errorf("failed to write catalog record: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,736 @@
+// Package cleanup deletes expired documents and backing indices from OpenSearch
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/okto/opensearch-backup-manager/pkg/blackout"
+	"github.com/okto/opensearch-backup-manager/pkg/catalog"
+	"github.com/okto/opensearch-backup-manager/pkg/clock"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/events"
+	"github.com/okto/opensearch-backup-manager/pkg/hold"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	log "github.com/sirupsen/logrus"
+)
+
+// retryBaseDelay base delay between retries of failed deletions
+const retryBaseDelay = 5 * time.Second
+
+// deleteParams delete-by-query parameters shared by all indices of a run
+type deleteParams struct {
+	slices            interface{}
+	requestsPerSecond *int     // share of job requests_per_second, nil unthrottled
+	holds             hold.Set // legal holds active when the run started
+}
+
+// Service for cleaning up old records
+type Service struct {
+	client  opensearch.API
+	catalog *catalog.Catalog
+	clock   clock.Clock
+	config  *config.Config
+	// named clusters of fan-out jobs
+	clusters map[string]opensearch.API
+}
+
+// Options cleanup service dependencies for embedding into other programs
+type Options struct {
+	Client  opensearch.API
+	Catalog *catalog.Catalog // optional, nil disables run records
+	Clock   clock.Clock      // default wall clock
+	Config  *config.Config   // optional
+	// named clusters of fan-out jobs, Client is the default
+	Clusters map[string]opensearch.API
+}
+
+// New create cleanup service from options
+func New(opts Options) (*Service, error) {
+	if opts.Client == nil {
+		return nil, fmt.Errorf("%w: cleanup requires OpenSearch client", errs.ErrInvalidConfig)
+	}
+	s := NewService(opts.Client, opts.Catalog, opts.Config)
+	if opts.Clock != nil {
+		s.clock = opts.Clock
+	}
+	s.clusters = opts.Clusters
+	return s, nil
+}
+
+// NewService create new cleanup service
+func NewService(client opensearch.API, catalog *catalog.Catalog, cfg *config.Config) *Service {
+	return &Service{
+		client:  client,
+		catalog: catalog,
+		clock:   clock.Real{},
+		config:  cfg,
+	}
+}
+
+// AddCluster register named cluster for fan-out jobs with cluster: name
+func (s *Service) AddCluster(name string, client opensearch.API) {
+	if s.clusters == nil {
+		s.clusters = make(map[string]opensearch.API)
+	}
+	s.clusters[name] = client
+}
+
+// forJob service deleting from cluster of job
+func (s *Service) forJob(job config.CleanupJob) (*Service, error) {
+	if job.Cluster == "" {
+		return s, nil
+	}
+	client, ok := s.clusters[job.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("%w: cleanup job %s: unknown cluster %q", errs.ErrInvalidConfig, job.Name(), job.Cluster)
+	}
+	svc := *s
+	svc.client = client
+	return &svc, nil
+}
+
+// Cleanup delete old records from index
+func (s *Service) Cleanup(ctx context.Context, job config.CleanupJob) error {
+	svc, err := s.forJob(job)
+	if err != nil {
+		return err
+	}
+	return svc.cleanup(ctx, job)
+}
+
+func (s *Service) cleanup(ctx context.Context, job config.CleanupJob) error {
+	ctx, runID := run.Ensure(ctx)
+	overrides := run.OverridesOf(ctx)
+	if !overrides.Date.IsZero() || overrides.Ranged() {
+		return fmt.Errorf("%w: cleanup does not take a date or time range, only index and dry-run overrides", errs.ErrInvalidConfig)
+	}
+	if overrides.Index != "" {
+		job.IndexName = overrides.Index
+	}
+	log.Infof("Starting cleanup for index %s (retention: %s, run %s)", job.Name(), job.RetentionPeriod(), runID)
+
+	// Dry run deletes nothing, so it may preview a blackout day
+	if s.config != nil && !overrides.DryRun {
+		frozen, reason, err := blackout.Active(s.config.Blackout, s.clock.Now())
+		if err != nil {
+			return err
+		}
+		if frozen {
+			log.Warnf("Skipping cleanup for %s: blackout day (%s)", job.IndexName, reason)
+			return nil
+		}
+	}
+
+	slices, err := parseSlices(job.Slices)
+	if err != nil {
+		return err
+	}
+
+	if job.Conflicts != "" && job.Conflicts != "abort" && job.Conflicts != "proceed" {
+		return fmt.Errorf("%w: conflicts value %q must be \"abort\" or \"proceed\"", errs.ErrInvalidConfig, job.Conflicts)
+	}
+
+	if err := s.client.WaitForHealthy(ctx, job.HealthGate); err != nil {
+		return fmt.Errorf("health gate: %w", err)
+	}
+	if err := s.client.WaitForLoad(ctx, job.LoadGate); err != nil {
+		return fmt.Errorf("load gate: %w", err)
+	}
+
+	holds, err := s.legalHolds()
+	if err != nil {
+		return err
+	}
+
+	if err := checkMissingIndex(job); err != nil {
+		return err
+	}
+	if err := checkTrash(job); err != nil {
+		return err
+	}
+	switch job.Mode {
+	case "", "documents":
+	case "data_stream":
+		return s.cleanupDataStreams(ctx, job, holds)
+	default:
+		return fmt.Errorf("%w: cleanup mode %q must be \"documents\" or \"data_stream\"", errs.ErrInvalidConfig, job.Mode)
+	}
+
+	indices, err := s.client.ResolveIndices(ctx, job.IndexName)
+	if errors.Is(err, errs.ErrIndexNotFound) {
+		return missingIndex(job)
+	}
+	if err != nil {
+		return err
+	}
+	if len(indices) == 0 {
+		return missingIndex(job)
+	}
+	indices, excluded := config.ExcludeIndices(indices, job.ExcludeIndices)
+	if len(excluded) > 0 {
+		log.Infof("Excluded %d indices of %s: %s", len(excluded), job.IndexName, strings.Join(excluded, ", "))
+	}
+	if len(indices) == 0 {
+		log.Warnf("Every index of %s is excluded, nothing to clean up", job.IndexName)
+		return nil
+	}
+	log.Infof("Resolved %s to %d indices: %s", job.IndexName, len(indices), strings.Join(indices, ", "))
+	if job.Trash.Enabled {
+		s.expireTrash(ctx, job, holds)
+	}
+
+	protected, err := s.protectedIndices(ctx, job, indices, holds)
+	if err != nil {
+		return err
+	}
+
+	workers := job.Concurrency
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(indices) {
+		workers = len(indices)
+	}
+	params := deleteParams{slices: slices, holds: holds}
+	if job.RequestsPerSecond > 0 {
+		// Every in-flight deletion gets an equal share, so the run as a whole
+		// never exceeds the budget
+		share := job.RequestsPerSecond / workers
+		if share < 1 {
+			share = 1
+		}
+		params.requestsPerSecond = &share
+	}
+	if workers > 1 {
+		log.Infof("Cleaning %d indices of %s, %d at a time", len(indices), job.IndexName, workers)
+	}
+
+	type outcome struct {
+		result    indexResult
+		err       error
+		protected bool
+	}
+	var (
+		outcomes = make([]outcome, len(indices))
+		next     = make(chan int)
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		done     int
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				index := indices[i]
+				if reason, ok := protected[index]; ok {
+					log.Warnf("Skipping protected index %s: %s", index, reason)
+					outcomes[i].protected = true
+				} else {
+					var result indexResult
+					err := run.Protect(ctx, "cleanup of "+index, func() (err error) {
+						result, err = s.cleanupOne(ctx, job, index, params)
+						return err
+					})
+					outcomes[i] = outcome{result: result, err: err}
+					if err != nil {
+						log.Errorf("Cleanup failed for index %s: %v", index, err)
+					} else if !result.Skipped {
+						result.log()
+					}
+					if !result.Skipped {
+						if err := s.catalog.RecordCleanup(ctx, result.record(job, s.clock.Now(), err)); err != nil {
+							log.Warnf("Failed to record cleanup of %s in catalog: %v", index, err)
+						}
+					}
+				}
+
+				mu.Lock()
+				done++
+				events.ReportProgress(ctx, done, len(indices), fmt.Sprintf("cleaned %s (%d/%d)", index, done, len(indices)))
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range indices {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	totalDeleted := 0
+	var totalReclaimed int64
+	var failed, touched []string
+	for i, o := range outcomes {
+		if o.protected {
+			continue
+		}
+		if o.result.Deleted > 0 || (o.err == nil && !o.result.Skipped) {
+			touched = append(touched, indices[i])
+		}
+		totalDeleted += o.result.Deleted
+		if o.err != nil {
+			failed = append(failed, indices[i])
+		} else if !o.result.Skipped {
+			totalReclaimed += o.result.estimatedReclaimed()
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"deleted":             totalDeleted,
+		"indices":             len(indices),
+		"touched":             touched,
+		"protected":           len(protected),
+		"estimated_reclaimed": totalReclaimed,
+	}).Infof("Cleanup completed for %s: deleted %d documents in %d indices (~%s reclaimed)",
+		job.IndexName, totalDeleted, len(indices), humanize.Bytes(uint64(totalReclaimed)))
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: cleanup failed for %d of %d indices: %s", errs.ErrPartialFailure, len(failed), len(indices), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// cleanupOne preview, check and delete old records of single index
+func (s *Service) cleanupOne(ctx context.Context, job config.CleanupJob, index string, params deleteParams) (indexResult, error) {
+	result := indexResult{Index: index}
+
+	var limitCutoff *time.Time
+	if job.HasLimits() {
+		var err error
+		limitCutoff, err = s.limitCutoff(ctx, job, index)
+		if err != nil {
+			return result, fmt.Errorf("failed to compute size/count limit: %w", err)
+		}
+		if limitCutoff == nil && !job.HasRetention() {
+			log.Infof("Index %s is within configured limits, nothing to clean up", index)
+			result.Skipped = true
+			return result, nil
+		}
+	}
+
+	tenants := params.holds.Tenants(index)
+	if len(tenants) > 0 {
+		log.Infof("Keeping documents of %d tenants under legal hold in %s", len(tenants), index)
+	}
+	query, err := buildQuery(job, s.clock.Now(), limitCutoff, tenants)
+	if err != nil {
+		return result, err
+	}
+
+	// Preview: how many documents the run is going to remove
+	result.Matched, result.CountBefore, err = s.preview(ctx, index, query)
+	if err != nil {
+		return result, err
+	}
+
+	if err := checkSafety(job, index, result.Matched, result.CountBefore); err != nil {
+		return result, err
+	}
+	if run.OverridesOf(ctx).DryRun {
+		log.Infof("Dry run: would delete %d of %d documents from %s", result.Matched, result.CountBefore, index)
+		result.Skipped = true
+		return result, nil
+	}
+
+	if job.Trash.Enabled && result.Matched > 0 {
+		if err := s.trash(ctx, index, query, params); err != nil {
+			return result, err
+		}
+	}
+
+	if result.Before, err = s.indexStats(ctx, index); err != nil {
+		log.Warnf("Failed to get stats before cleanup for %s: %v", index, err)
+	}
+
+	result.Deleted, err = s.cleanupIndex(ctx, job, index, query, params)
+	if err != nil {
+		return result, err
+	}
+
+	if result.Deleted > 0 {
+		s.refreshAndFlush(ctx, job, index)
+	}
+
+	if result.After, err = s.indexStats(ctx, index); err != nil {
+		log.Warnf("Failed to get stats after cleanup for %s: %v", index, err)
+	}
+	if result.CountAfter, err = s.count(ctx, index, ""); err != nil {
+		log.Warnf("Failed to count documents after cleanup for %s: %v", index, err)
+	}
+
+	return result, nil
+}
+
+// refreshAndFlush make deletions visible to searches (refresh) and persisted
+// to disk (flush) right away when configured, failures are only logged
+func (s *Service) refreshAndFlush(ctx context.Context, job config.CleanupJob, index string) {
+	if job.Refresh {
+		if _, err := s.client.GetClient().Indices.Refresh(ctx, &opensearchapi.IndicesRefreshReq{
+			Indices: []string{index},
+		}); err != nil {
+			log.Warnf("Failed to refresh %s after cleanup: %v", index, err)
+		} else {
+			log.Infof("Refreshed %s after cleanup", index)
+		}
+	}
+
+	if job.Flush {
+		if _, err := s.client.GetClient().Indices.Flush(ctx, &opensearchapi.IndicesFlushReq{
+			Indices: []string{index},
+		}); err != nil {
+			log.Warnf("Failed to flush %s after cleanup: %v", index, err)
+		} else {
+			log.Infof("Flushed %s after cleanup", index)
+		}
+	}
+}
+
+// preview count documents matching deletion query and total documents of index
+func (s *Service) preview(ctx context.Context, index, query string) (int, int, error) {
+	matched, err := s.count(ctx, index, query)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count documents to delete: %w", err)
+	}
+
+	total, err := s.count(ctx, index, "")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count index documents: %w", err)
+	}
+
+	if total > 0 {
+		log.Infof("Cleanup for %s will delete %d of %d documents (%.1f%%)",
+			index, matched, total, float64(matched)/float64(total)*100)
+	}
+
+	return matched, total, nil
+}
+
+// count count documents of index matching query (all documents for empty query)
+func (s *Service) count(ctx context.Context, index, query string) (int, error) {
+	req := opensearchapi.IndicesCountReq{
+		Indices: []string{index},
+	}
+	if query != "" {
+		req.Body = strings.NewReader(query)
+	}
+
+	resp, err := s.client.GetClient().Indices.Count(ctx, &req)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.Count, nil
+}
+
+// checkMissingIndex validate missing_index of job
+func checkMissingIndex(job config.CleanupJob) error {
+	switch job.MissingIndex {
+	case "", config.MissingIndexSkip, config.MissingIndexFail:
+		return nil
+	}
+	return fmt.Errorf("%w: missing_index %q must be \"skip\" or \"fail\"", errs.ErrInvalidConfig, job.MissingIndex)
+}
+
+// missingIndex outcome of a run whose index_name matches no index: skipped
+// (errs.ErrSkipped) by default, failed with missing_index: fail
+func missingIndex(job config.CleanupJob) error {
+	missing := fmt.Errorf("no such index %s: %w", job.IndexName, errs.ErrIndexNotFound)
+	if job.MissingIndex == config.MissingIndexFail {
+		return missing
+	}
+	return fmt.Errorf("%w: %w", errs.ErrSkipped, missing)
+}
+
+// checkSafety refuse to delete more than max_delete_ratio of the index
+// or more than max_delete_docs, unless forced
+func checkSafety(job config.CleanupJob, index string, matched, total int) error {
+	if matched == 0 || total == 0 {
+		return nil
+	}
+
+	ratio := float64(matched) / float64(total)
+
+	var violation string
+	if job.MaxDeleteRatio > 0 && ratio > job.MaxDeleteRatio {
+		violation = fmt.Sprintf("deletion of %d of %d documents (%.1f%%) exceeds max_delete_ratio %.1f%%",
+			matched, total, ratio*100, job.MaxDeleteRatio*100)
+	} else if job.MaxDeleteDocs > 0 && matched > job.MaxDeleteDocs {
+		violation = fmt.Sprintf("deletion of %d documents exceeds max_delete_docs %d", matched, job.MaxDeleteDocs)
+	}
+	if violation == "" {
+		return nil
+	}
+
+	if job.Force {
+		log.Warnf("Safety guard overridden for %s (force: true): %s", index, violation)
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s (set force: true to override)", errs.ErrSafetyGuard, violation)
+}
+
+// cleanupIndex run delete-by-query against single index, retrying retriable failures
+func (s *Service) cleanupIndex(ctx context.Context, job config.CleanupJob, index, query string, params deleteParams) (int, error) {
+	totalDeleted := 0
+	for attempt := 1; ; attempt++ {
+		// Form request for deletion (body is consumed on each attempt)
+		deleteQuery := opensearchapi.DocumentDeleteByQueryReq{
+			Indices: []string{index},
+			Params: opensearchapi.DocumentDeleteByQueryParams{
+				Slices:            params.slices,
+				Conflicts:         job.Conflicts,
+				RequestsPerSecond: params.requestsPerSecond,
+			},
+			Body: strings.NewReader(query),
+		}
+
+		// Execute request
+		resp, err := s.client.GetClient().Document.DeleteByQuery(ctx, deleteQuery)
+		if err != nil {
+			// Failures of some batches come with an error status, the body
+			// still counts deleted documents and lists failures
+			partial, ok := partialResponse(err)
+			if !ok {
+				return totalDeleted, fmt.Errorf("delete by query failed: %w", opensearch.Classify(err))
+			}
+			log.Warnf("Delete by query for %s returned status %d with %d failures",
+				index, opensearch.ResponseStatus(err), len(partial.Failures))
+			resp = partial
+		}
+
+		totalDeleted += resp.Deleted
+		if resp.VersionConflicts > 0 {
+			log.Warnf("Cleanup for %s hit %d version conflicts", index, resp.VersionConflicts)
+		}
+
+		failures := parseFailures(resp.Failures)
+		if len(failures) == 0 {
+			return totalDeleted, nil
+		}
+
+		for _, failure := range failures {
+			log.WithFields(log.Fields{
+				"index":  failure.Index,
+				"shard":  failure.Shard,
+				"status": failure.Status,
+				"type":   failure.errorType(),
+				"reason": failure.errorReason(),
+			}).Warnf("Cleanup failure for %s", index)
+		}
+
+		// Deletion is idempotent, so the whole request can be repeated
+		if allRetriable(failures) && attempt <= job.MaxRetries {
+			delay := retryBaseDelay * time.Duration(attempt)
+			if err := run.Retry(ctx, delay); err != nil {
+				return totalDeleted, fmt.Errorf("%w: %d retriable failures, first: %s: %s",
+					err, len(failures), failures[0].errorType(), failures[0].errorReason())
+			}
+			log.Warnf("Cleanup for %s had %d retriable failures, retrying in %v (attempt %d/%d)",
+				index, len(failures), delay, attempt, job.MaxRetries)
+
+			select {
+			case <-ctx.Done():
+				return totalDeleted, ctx.Err()
+			case <-s.clock.After(delay):
+			}
+			continue
+		}
+
+		if len(failures) > job.MaxFailures {
+			return totalDeleted, fmt.Errorf("%w: finished with %d failures (threshold: %d), first: %s: %s",
+				errs.ErrPartialFailure, len(failures), job.MaxFailures, failures[0].errorType(), failures[0].errorReason())
+		}
+
+		log.Warnf("Cleanup for %s finished with %d failures (within threshold: %d)",
+			index, len(failures), job.MaxFailures)
+		return totalDeleted, nil
+	}
+}
+
+// partialResponse delete-by-query result carried by error status err, false
+// unless its body lists failures, e.g. transport or request errors
+func partialResponse(err error) (*opensearchapi.DocumentDeleteByQueryResp, bool) {
+	body := opensearch.ErrorBody(err)
+	if body == nil {
+		return nil, false
+	}
+	var resp opensearchapi.DocumentDeleteByQueryResp
+	if json.Unmarshal(body, &resp) != nil || len(resp.Failures) == 0 {
+		return nil, false
+	}
+	return &resp, true
+}
+
+// buildQuery build delete-by-query body: retention range (documents older than
+// retention OR than the size/count limit cutoff) AND-ed with optional
+// query filter, documents matching exclude_query are preserved
+func buildQuery(job config.CleanupJob, now time.Time, limitCutoff *time.Time, tenants []hold.Hold) (string, error) {
+	var ranges []interface{}
+	if job.Retention != "" {
+		// Precise cutoff without day rounding
+		retention, err := config.ParseDuration(job.Retention)
+		if err != nil {
+			return "", fmt.Errorf("%w: retention: %w", errs.ErrInvalidConfig, err)
+		}
+		ranges = append(ranges, map[string]interface{}{
+			"range": map[string]interface{}{
+				"@timestamp": map[string]interface{}{
+					"lt": now.Add(-retention).UTC().Format(timestampFormat),
+				},
+			},
+		})
+	} else if job.RetentionDays > 0 || !job.HasLimits() {
+		ranges = append(ranges, map[string]interface{}{
+			"range": map[string]interface{}{
+				"@timestamp": map[string]interface{}{
+					// "now-Nd/d" anchored to service clock
+					"lte": fmt.Sprintf("%s||-%dd/d", now.UTC().Format(timestampFormat), job.RetentionDays),
+				},
+			},
+		})
+	}
+	if limitCutoff != nil {
+		ranges = append(ranges, map[string]interface{}{
+			"range": map[string]interface{}{
+				"@timestamp": map[string]interface{}{
+					"lte": limitCutoff.UTC().Format(timestampFormat),
+				},
+			},
+		})
+	}
+
+	filter := []interface{}{
+		map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should":               ranges,
+				"minimum_should_match": 1,
+			},
+		},
+	}
+	if len(job.Query) > 0 {
+		filter = append(filter, job.Query)
+	}
+
+	boolQuery := map[string]interface{}{
+		"filter": filter,
+	}
+	var mustNot []interface{}
+	if len(job.ExcludeQuery) > 0 {
+		mustNot = append(mustNot, job.ExcludeQuery)
+	}
+	mustNot = append(mustNot, tenantQueries(tenants)...)
+	if len(mustNot) > 0 {
+		boolQuery["must_not"] = mustNot
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": boolQuery,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to build cleanup query: %w", err)
+	}
+
+	return string(body), nil
+}
+
+// parseSlices convert slices option to delete-by-query parameter
+func parseSlices(value string) (interface{}, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if value == "auto" {
+		return value, nil
+	}
+
+	slices, err := strconv.Atoi(value)
+	if err != nil || slices < 1 {
+		return nil, fmt.Errorf("%w: slices value %q must be \"auto\" or a positive number", errs.ErrInvalidConfig, value)
+	}
+
+	return slices, nil
+}
+
+// deleteFailure single entry of delete-by-query failures array
+// (bulk failures carry cause/status, search failures carry shard/reason)
+type deleteFailure struct {
+	Index  string       `json:"index"`
+	ID     string       `json:"id"`
+	Shard  *int         `json:"shard"`
+	Node   string       `json:"node"`
+	Status int          `json:"status"`
+	Cause  *failureInfo `json:"cause"`
+	Reason *failureInfo `json:"reason"`
+}
+
+type failureInfo struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+func (f deleteFailure) info() failureInfo {
+	if f.Cause != nil {
+		return *f.Cause
+	}
+	if f.Reason != nil {
+		return *f.Reason
+	}
+	return failureInfo{}
+}
+
+func (f deleteFailure) errorType() string {
+	return f.info().Type
+}
+
+func (f deleteFailure) errorReason() string {
+	return f.info().Reason
+}
+
+// retriable check if failure is caused by temporary cluster overload or unavailability
+func (f deleteFailure) retriable() bool {
+	switch f.Status {
+	case 429, 502, 503, 504:
+		return true
+	}
+
+	errorType := f.errorType()
+	return strings.Contains(errorType, "rejected_execution") ||
+		strings.Contains(errorType, "node_not_connected") ||
+		strings.Contains(errorType, "no_shard_available")
+}
+
+// parseFailures decode failures array of delete-by-query response
+func parseFailures(raw []json.RawMessage) []deleteFailure {
+	failures := make([]deleteFailure, 0, len(raw))
+	for _, item := range raw {
+		var failure deleteFailure
+		if err := json.Unmarshal(item, &failure); err != nil {
+			failure.Reason = &failureInfo{Type: "unknown", Reason: string(item)}
+		}
+		failures = append(failures, failure)
+	}
+	return failures
+}
+
+func allRetriable(failures []deleteFailure) bool {
+	for _, failure := range failures {
+		if !failure.retriable() {
+			return false
+		}
+	}
+	return true
+}
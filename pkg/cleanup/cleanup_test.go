@@ -0,0 +1,268 @@
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/hold"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+)
+
+func TestCleanupIndexErrorStatus(t *testing.T) {
+	const failed = `{"took":12,"deleted":5,"failures":[{"index":"logs","id":"a","status":400,` +
+		`"cause":{"type":"mapper_parsing_exception","reason":"failed to parse"}}]}`
+	tests := []struct {
+		name        string
+		body        string
+		maxFailures int
+		deleted     int
+		wantErr     bool
+		partial     bool // error is a partial failure, not a failed request
+	}{
+		{"failures over threshold", failed, 0, 5, true, true},
+		{"failures within threshold", failed, 1, 5, false, false},
+		{"request error", `{"error":{"type":"search_phase_execution_exception","reason":"all shards failed"},"status":500}`, 1, 0, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			client, err := opensearch.NewClient(config.OpenSearchConfig{Addresses: []string{srv.URL}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			s := NewService(client, nil, &config.Config{})
+			job := config.CleanupJob{IndexName: "logs", MaxFailures: tt.maxFailures}
+			deleted, err := s.cleanupIndex(context.Background(), job, "logs", `{"query":{"match_all":{}}}`, deleteParams{})
+			if deleted != tt.deleted {
+				t.Errorf("deleted = %d, want %d", deleted, tt.deleted)
+			}
+			switch {
+			case !tt.wantErr && err != nil:
+				t.Errorf("unexpected error %v", err)
+			case tt.wantErr && err == nil:
+				t.Error("error status accepted")
+			case tt.partial && !errors.Is(err, errs.ErrPartialFailure):
+				t.Errorf("error %v, want partial failure", err)
+			case tt.wantErr && !tt.partial && errors.Is(err, errs.ErrPartialFailure):
+				t.Errorf("request error %v reported as partial failure", err)
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	now := time.Date(2026, 3, 11, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*3600))
+	for _, tt := range []struct {
+		job  config.CleanupJob
+		want string
+	}{
+		{config.CleanupJob{IndexName: "logs-*"}, "logs-*"},
+		{config.CleanupJob{IndexName: "logs-*", Cluster: "eu"}, "eu:logs-*"},
+	} {
+		record := (indexResult{Index: "logs-1"}).record(tt.job, now, nil)
+		if record.Job != tt.want {
+			t.Errorf("job of %+v = %q, want %q", tt.job, record.Job, tt.want)
+		}
+		if !record.Timestamp.Equal(now) || record.Timestamp.Location() != time.UTC {
+			t.Errorf("timestamp = %v, want %v in UTC", record.Timestamp, now)
+		}
+	}
+}
+
+func TestBuildQuery(t *testing.T) {
+	now := time.Date(2026, 3, 11, 14, 30, 0, 0, time.UTC)
+	cutoff := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	daysRange := `{"range":{"@timestamp":{"lte":"2026-03-11T14:30:00.000Z||-30d/d"}}}`
+	cutoffRange := `{"range":{"@timestamp":{"lte":"2026-03-01T08:00:00.000Z"}}}`
+	should := func(ranges string) string {
+		return `{"bool":{"minimum_should_match":1,"should":[` + ranges + `]}}`
+	}
+	tests := []struct {
+		name    string
+		job     config.CleanupJob
+		cutoff  *time.Time
+		tenants []hold.Hold
+		want    string
+	}{
+		{"retention days", config.CleanupJob{RetentionDays: 30}, nil, nil,
+			`{"query":{"bool":{"filter":[` + should(daysRange) + `]}}}`},
+		{"precise retention", config.CleanupJob{Retention: "36h"}, nil, nil,
+			`{"query":{"bool":{"filter":[` + should(`{"range":{"@timestamp":{"lt":"2026-03-10T02:30:00.000Z"}}}`) + `]}}}`},
+		{"limit only", config.CleanupJob{MaxDocs: 1000}, &cutoff, nil,
+			`{"query":{"bool":{"filter":[` + should(cutoffRange) + `]}}}`},
+		{"retention or limit", config.CleanupJob{RetentionDays: 30, MaxDocs: 1000}, &cutoff, nil,
+			`{"query":{"bool":{"filter":[` + should(daysRange+","+cutoffRange) + `]}}}`},
+		{"filters and holds",
+			config.CleanupJob{
+				RetentionDays: 30,
+				Query:         map[string]interface{}{"term": map[string]interface{}{"level": "debug"}},
+				ExcludeQuery:  map[string]interface{}{"term": map[string]interface{}{"pinned": true}},
+			},
+			nil, []hold.Hold{{Field: "tenant.id", Value: "acme"}},
+			`{"query":{"bool":{"filter":[` + should(daysRange) + `,{"term":{"level":"debug"}}],` +
+				`"must_not":[{"term":{"pinned":true}},{"term":{"tenant.id":"acme"}}]}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildQuery(tt.job, now, tt.cutoff, tt.tenants)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("query =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := buildQuery(config.CleanupJob{Retention: "soon"}, now, nil, nil); !errors.Is(err, errs.ErrInvalidConfig) {
+		t.Errorf("invalid retention: error %v, want invalid config", err)
+	}
+}
+
+func TestParseFailures(t *testing.T) {
+	failures := parseFailures([]json.RawMessage{
+		json.RawMessage(`{"index":"logs","shard":0,"status":429,"reason":{"type":"es_rejected_execution_exception","reason":"queue full"}}`),
+		json.RawMessage(`{"index":"logs","id":"a","status":409,"cause":{"type":"version_conflict_engine_exception","reason":"conflict"}}`),
+		json.RawMessage(`"not an object"`),
+	})
+	if len(failures) != 3 {
+		t.Fatalf("failures = %d, want 3", len(failures))
+	}
+	if f := failures[0]; f.Shard == nil || *f.Shard != 0 || f.errorType() != "es_rejected_execution_exception" {
+		t.Errorf("shard failure decoded as %+v", f)
+	}
+	if f := failures[1]; f.ID != "a" || f.errorType() != "version_conflict_engine_exception" || f.errorReason() != "conflict" {
+		t.Errorf("document failure decoded as %+v", f)
+	}
+	if f := failures[2]; f.errorType() != "unknown" || f.errorReason() != `"not an object"` {
+		t.Errorf("undecodable failure kept as %s: %s", f.errorType(), f.errorReason())
+	}
+}
+
+func TestAllRetriable(t *testing.T) {
+	failure := func(status int, errorType string) deleteFailure {
+		return deleteFailure{Status: status, Cause: &failureInfo{Type: errorType}}
+	}
+	tests := []struct {
+		name     string
+		failures []deleteFailure
+		want     bool
+	}{
+		{"too many requests", []deleteFailure{failure(429, "")}, true},
+		{"unavailable", []deleteFailure{failure(503, ""), failure(504, "")}, true},
+		{"rejected execution", []deleteFailure{failure(500, "es_rejected_execution_exception")}, true},
+		{"no shard", []deleteFailure{failure(0, "no_shard_available_action_exception")}, true},
+		{"node not connected", []deleteFailure{failure(0, "node_not_connected_exception")}, true},
+		{"version conflict", []deleteFailure{failure(409, "version_conflict_engine_exception")}, false},
+		{"mixed", []deleteFailure{failure(429, ""), failure(400, "mapper_parsing_exception")}, false},
+	}
+	for _, tt := range tests {
+		if got := allRetriable(tt.failures); got != tt.want {
+			t.Errorf("%s: allRetriable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrashThrottle(t *testing.T) {
+	var query url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/_reindex" {
+			query = r.URL.Query()
+			w.Write([]byte(`{"created":3,"failures":[]}`))
+		}
+		// otherwise HEAD of the trash index, answered 200 as existing
+	}))
+	defer srv.Close()
+	client, err := opensearch.NewClient(config.OpenSearchConfig{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	share := 50
+	s := NewService(client, nil, &config.Config{})
+	if err := s.trash(context.Background(), "logs", `{"query":{"match_all":{}}}`, deleteParams{slices: 4, requestsPerSecond: &share}); err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("slices") != "4" || query.Get("requests_per_second") != "50" {
+		t.Errorf("reindex params %v, want slices 4 and requests_per_second 50", query)
+	}
+}
+
+func TestDataStreamSafetyGuard(t *testing.T) {
+	docs := map[string]int{".ds-logs-000001": 900, ".ds-logs-000002": 50, ".ds-logs-000003": 50}
+	tests := []struct {
+		name    string
+		job     config.CleanupJob
+		deleted int
+		wantErr bool
+	}{
+		{"ratio exceeded", config.CleanupJob{MaxDeleteRatio: 0.5}, 0, true},
+		{"docs exceeded", config.CleanupJob{MaxDeleteDocs: 500}, 0, true},
+		{"forced", config.CleanupJob{MaxDeleteRatio: 0.5, Force: true}, 2, false},
+		{"within guard", config.CleanupJob{MaxDeleteRatio: 0.99}, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.URL.Path == "/_data_stream/logs":
+					w.Write([]byte(`{"data_streams":[{"name":"logs","timestamp_field":{"name":"@timestamp"},"indices":[` +
+						`{"index_name":".ds-logs-000001"},{"index_name":".ds-logs-000002"},{"index_name":".ds-logs-000003"}]}]}`))
+				case strings.HasSuffix(r.URL.Path, "/_search"):
+					// newest document in 2020, long expired
+					w.Write([]byte(`{"hits":{"hits":[]},"aggregations":{"oldest":{"value":1577836800000},"newest":{"value":1577836800000}}}`))
+				case strings.Contains(r.URL.Path, "/_stats"):
+					stats := map[string]interface{}{}
+					for index, count := range docs {
+						stats[index] = map[string]interface{}{"primaries": map[string]interface{}{"docs": map[string]int{"count": count}}}
+					}
+					json.NewEncoder(w).Encode(map[string]interface{}{"indices": stats})
+				case r.Method == http.MethodDelete:
+					deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/"))
+					w.Write([]byte(`{"acknowledged":true}`))
+				default:
+					// index settings: nothing protected
+					w.Write([]byte(`{}`))
+				}
+			}))
+			defer srv.Close()
+			client, err := opensearch.NewClient(config.OpenSearchConfig{Addresses: []string{srv.URL}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			job := tt.job
+			job.IndexName, job.Mode, job.RetentionDays = "logs", "data_stream", 30
+			err = NewService(client, nil, nil).Cleanup(context.Background(), job)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(deleted) != tt.deleted {
+				t.Errorf("deleted %v, want %d backing indices", deleted, tt.deleted)
+			}
+			for _, index := range deleted {
+				if index == ".ds-logs-000003" {
+					t.Error("write index deleted")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/hold"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	log "github.com/sirupsen/logrus"
+)
+
+// cleanupDataStreams delete whole backing indices of data streams whose newest
+// document is older than retention; the current write index is never deleted
+func (s *Service) cleanupDataStreams(ctx context.Context, job config.CleanupJob, holds hold.Set) error {
+	cutoff, err := retentionCutoff(job, s.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.GetClient().DataStream.Get(ctx, &opensearchapi.DataStreamGetReq{
+		DataStreams: strings.Split(job.IndexName, ","),
+	})
+	if err != nil {
+		err = opensearch.Classify(err)
+		if errors.Is(err, errs.ErrIndexNotFound) {
+			return missingIndex(job)
+		}
+		return fmt.Errorf("failed to get data streams %s: %w", job.IndexName, err)
+	}
+	if len(resp.DataStreams) == 0 {
+		return missingIndex(job)
+	}
+
+	var backing []string
+	for _, stream := range resp.DataStreams {
+		for _, index := range stream.Indices {
+			backing = append(backing, index.Name)
+		}
+	}
+	backing, excluded := config.ExcludeIndices(backing, job.ExcludeIndices)
+	if len(excluded) > 0 {
+		log.Infof("Excluded %d backing indices of %s: %s", len(excluded), job.IndexName, strings.Join(excluded, ", "))
+	}
+
+	protected, err := s.protectedIndices(ctx, job, backing, holds)
+	if err != nil {
+		return err
+	}
+
+	totalDeleted := 0
+	var failed, touched []string
+	for _, stream := range resp.DataStreams {
+		field := stream.TimestampField.Name
+		if field == "" {
+			field = "@timestamp"
+		}
+
+		if config.Excluded(job.ExcludeIndices, stream.Name) {
+			log.Infof("Skipping excluded data stream %s", stream.Name)
+			continue
+		}
+		if h, ok := holds.Index(stream.Name); ok {
+			log.Warnf("Skipping data stream %s: legal hold %s", stream.Name, h)
+			continue
+		}
+		tenants := holds.Tenants(stream.Name)
+
+		// Last generation is the write index
+		var expired []generation
+		for i, index := range stream.Indices {
+			if i == len(stream.Indices)-1 {
+				break
+			}
+			if config.Excluded(job.ExcludeIndices, index.Name) {
+				continue
+			}
+			if reason, ok := protected[index.Name]; ok {
+				log.Warnf("Skipping protected backing index %s: %s", index.Name, reason)
+				continue
+			}
+			// Whole generations are deleted, so one held document keeps all of it
+			if held, err := s.heldTenantDocs(ctx, index.Name, tenants); err != nil || held > 0 {
+				if err != nil {
+					log.Errorf("Failed to check legal holds of backing index %s: %v", index.Name, err)
+					failed = append(failed, index.Name)
+				} else {
+					log.Warnf("Skipping backing index %s: %d documents of tenants under legal hold", index.Name, held)
+				}
+				continue
+			}
+
+			_, newest, err := s.timestampBounds(ctx, index.Name, field)
+			if err != nil {
+				log.Errorf("Failed to get newest document of backing index %s: %v", index.Name, err)
+				failed = append(failed, index.Name)
+				continue
+			}
+			if newest == nil || newest.Before(cutoff) {
+				expired = append(expired, generation{index: index.Name, newest: newest})
+			}
+		}
+		if len(expired) == 0 {
+			continue
+		}
+
+		// Same guard as delete-by-query: expired generations against all
+		// documents of the stream, write index included
+		names := make([]string, 0, len(stream.Indices))
+		for _, index := range stream.Indices {
+			names = append(names, index.Name)
+		}
+		stats, err := s.generationStats(ctx, names)
+		if err != nil {
+			log.Errorf("Not cleaning data stream %s: %v", stream.Name, err)
+			failed = append(failed, stream.Name)
+			continue
+		}
+		matched, total := 0, 0
+		for _, gen := range expired {
+			matched += stats[gen.index].Docs
+		}
+		for _, st := range stats {
+			total += st.Docs
+		}
+		if err := checkSafety(job, stream.Name, matched, total); err != nil {
+			log.Errorf("Not cleaning data stream %s: %v", stream.Name, err)
+			failed = append(failed, stream.Name)
+			continue
+		}
+
+		for _, gen := range expired {
+			result, err := s.deleteGeneration(ctx, gen, stats[gen.index])
+			if result.Skipped {
+				continue
+			}
+			if err != nil {
+				log.Errorf("Failed to delete backing index %s of %s: %v", gen.index, stream.Name, err)
+				failed = append(failed, gen.index)
+			} else {
+				touched = append(touched, gen.index)
+				totalDeleted += result.Deleted
+			}
+
+			if err := s.catalog.RecordCleanup(ctx, result.record(job, s.clock.Now(), err)); err != nil {
+				log.Warnf("Failed to record cleanup of %s in catalog: %v", gen.index, err)
+			}
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"deleted": totalDeleted,
+		"touched": touched,
+	}).Infof("Data stream cleanup completed for %s: deleted %d backing indices (%d documents)",
+		job.IndexName, len(touched), totalDeleted)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: failed to delete %d backing indices: %s", errs.ErrPartialFailure, len(failed), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// generation expired backing index of data stream
+type generation struct {
+	index  string
+	newest *time.Time // newest document, nil for an empty index
+}
+
+// generationStats primary documents count and store size of backing indices
+func (s *Service) generationStats(ctx context.Context, indices []string) (map[string]indexStats, error) {
+	resp, err := s.client.GetClient().Indices.Stats(ctx, &opensearchapi.IndicesStatsReq{
+		Indices: indices,
+		Metrics: []string{"docs", "store"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backing index stats: %w", err)
+	}
+
+	stats := make(map[string]indexStats, len(indices))
+	for _, index := range indices {
+		st, ok := resp.Indices[index]
+		if !ok {
+			return nil, fmt.Errorf("no stats returned for backing index %s", index)
+		}
+		stats[index] = indexStats{Docs: st.Primaries.Docs.Count, SizeBytes: st.Primaries.Store.SizeInBytes}
+	}
+	return stats, nil
+}
+
+// deleteGeneration delete expired backing index with stats before deletion
+func (s *Service) deleteGeneration(ctx context.Context, gen generation, before indexStats) (indexResult, error) {
+	result := indexResult{Index: gen.index, Before: before, CountBefore: before.Docs, Matched: before.Docs}
+
+	if run.OverridesOf(ctx).DryRun {
+		log.Infof("Dry run: would delete backing index %s (%d documents, newest: %v)", gen.index, result.Matched, gen.newest)
+		result.Skipped = true
+		return result, nil
+	}
+
+	if _, err := s.client.GetClient().Indices.Delete(ctx, opensearchapi.IndicesDeleteReq{
+		Indices: []string{gen.index},
+	}); err != nil {
+		return result, fmt.Errorf("failed to delete index: %w", err)
+	}
+
+	result.Deleted = before.Docs
+	log.Infof("Deleted backing index %s (%d documents, newest: %v)", gen.index, result.Deleted, gen.newest)
+	return result, nil
+}
+
+// retentionCutoff absolute time before which documents are expired
+func retentionCutoff(job config.CleanupJob, now time.Time) (time.Time, error) {
+	if job.Retention != "" {
+		retention, err := config.ParseDuration(job.Retention)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: retention: %w", errs.ErrInvalidConfig, err)
+		}
+		return now.Add(-retention), nil
+	}
+	if job.RetentionDays <= 0 {
+		return time.Time{}, fmt.Errorf("%w: data stream cleanup requires retention or retention_days", errs.ErrInvalidConfig)
+	}
+
+	// Same as "now-Nd/d" rounding: up to the end of that day
+	day := now.UTC().AddDate(0, 0, -job.RetentionDays).Truncate(24 * time.Hour)
+	return day.Add(24 * time.Hour), nil
+}
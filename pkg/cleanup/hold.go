@@ -0,0 +1,56 @@
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/okto/opensearch-backup-manager/pkg/hold"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+)
+
+// legalHolds holds active now, none without config
+func (s *Service) legalHolds() (hold.Set, error) {
+	if s.config == nil {
+		return nil, nil
+	}
+	return hold.New(s.config.LegalHold).List()
+}
+
+// tenantQueries term queries of documents under tenant holds, excluded from deletion
+func tenantQueries(tenants []hold.Hold) []interface{} {
+	queries := make([]interface{}, 0, len(tenants))
+	for _, h := range tenants {
+		queries = append(queries, map[string]interface{}{
+			"term": map[string]interface{}{h.Field: h.Value},
+		})
+	}
+	return queries
+}
+
+// heldTenantDocs count documents of index under tenant holds
+func (s *Service) heldTenantDocs(ctx context.Context, index string, tenants []hold.Hold) (int, error) {
+	if len(tenants) == 0 {
+		return 0, nil
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should":               tenantQueries(tenants),
+				"minimum_should_match": 1,
+			},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.client.GetClient().Indices.Count(ctx, &opensearchapi.IndicesCountReq{
+		Indices: []string{index},
+		Body:    strings.NewReader(string(body)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count documents under legal hold: %w", err)
+	}
+	return resp.Count, nil
+}
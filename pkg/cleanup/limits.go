@@ -0,0 +1,145 @@
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	log "github.com/sirupsen/logrus"
+)
+
+// timestampFormat format of absolute @timestamp bounds in queries
+const timestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// limitCutoff find timestamp such that keeping only newer documents satisfies
+// max_docs / max_size limits of the job, nil when index is within limits
+func (s *Service) limitCutoff(ctx context.Context, job config.CleanupJob, index string) (*time.Time, error) {
+	keep := -1
+	if job.MaxDocs > 0 {
+		keep = job.MaxDocs
+	}
+
+	if job.MaxSize != "" {
+		maxBytes, err := humanize.ParseBytes(job.MaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("%w: max_size %q: %w", errs.ErrInvalidConfig, job.MaxSize, err)
+		}
+
+		stats, err := s.indexStats(ctx, index)
+		if err != nil {
+			return nil, err
+		}
+
+		// Estimate documents to keep assuming uniform document size
+		if stats.SizeBytes > 0 && uint64(stats.SizeBytes) > maxBytes {
+			sizeKeep := int(float64(stats.Docs) * float64(maxBytes) / float64(stats.SizeBytes))
+			log.Infof("Index %s store size %s exceeds max_size %s, keeping ~%d of %d documents",
+				index, humanize.Bytes(uint64(stats.SizeBytes)), job.MaxSize, sizeKeep, stats.Docs)
+			if keep < 0 || sizeKeep < keep {
+				keep = sizeKeep
+			}
+		}
+	}
+
+	if keep < 0 {
+		return nil, nil
+	}
+
+	minTime, maxTime, err := s.timestampBounds(ctx, index, "@timestamp")
+	if err != nil {
+		return nil, err
+	}
+	if minTime == nil {
+		return nil, nil
+	}
+
+	total, err := s.countNewerThan(ctx, index, minTime.Add(-time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+	if total <= keep {
+		return nil, nil
+	}
+
+	// Binary search for the oldest timestamp with at most `keep` newer documents
+	lo, hi := minTime.Add(-time.Millisecond), *maxTime
+	for hi.Sub(lo) > time.Millisecond {
+		mid := lo.Add(hi.Sub(lo) / 2).Truncate(time.Millisecond)
+		count, err := s.countNewerThan(ctx, index, mid)
+		if err != nil {
+			return nil, err
+		}
+		if count <= keep {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	log.Infof("Index %s has %d documents, limit %d: deleting documents up to %s",
+		index, total, keep, hi.UTC().Format(timestampFormat))
+	return &hi, nil
+}
+
+// timestampBounds get oldest and newest timestamp of index, nil for empty index
+func (s *Service) timestampBounds(ctx context.Context, index, field string) (*time.Time, *time.Time, error) {
+	resp, err := s.client.GetClient().Search(ctx, &opensearchapi.SearchReq{
+		Indices: []string{index},
+		Body: strings.NewReader(fmt.Sprintf(`{
+			"size": 0,
+			"aggs": {
+				"oldest": {"min": {"field": %q}},
+				"newest": {"max": {"field": %q}}
+			}
+		}`, field, field)),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get timestamp bounds: %w", err)
+	}
+
+	var aggs struct {
+		Oldest struct {
+			Value *float64 `json:"value"`
+		} `json:"oldest"`
+		Newest struct {
+			Value *float64 `json:"value"`
+		} `json:"newest"`
+	}
+	if err := json.Unmarshal(resp.Aggregations, &aggs); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode timestamp bounds: %w", err)
+	}
+	if aggs.Oldest.Value == nil || aggs.Newest.Value == nil {
+		return nil, nil, nil
+	}
+
+	oldest := time.UnixMilli(int64(*aggs.Oldest.Value)).UTC()
+	newest := time.UnixMilli(int64(*aggs.Newest.Value)).UTC()
+	return &oldest, &newest, nil
+}
+
+// countNewerThan count documents with @timestamp strictly after given time
+func (s *Service) countNewerThan(ctx context.Context, index string, t time.Time) (int, error) {
+	resp, err := s.client.GetClient().Indices.Count(ctx, &opensearchapi.IndicesCountReq{
+		Indices: []string{index},
+		Body: strings.NewReader(fmt.Sprintf(`{
+			"query": {
+				"range": {
+					"@timestamp": {
+						"gt": "%s"
+					}
+				}
+			}
+		}`, t.UTC().Format(timestampFormat))),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	return resp.Count, nil
+}
@@ -0,0 +1,118 @@
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+)
+
+// limitsServer index of documents one minute apart from base, 1000 bytes each
+func limitsServer(t *testing.T, base time.Time, docs int) (*opensearch.Client, *int) {
+	t.Helper()
+	counts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			if docs == 0 {
+				w.Write([]byte(`{"hits":{"hits":[]},"aggregations":{"oldest":{"value":null},"newest":{"value":null}}}`))
+				return
+			}
+			fmt.Fprintf(w, `{"hits":{"hits":[]},"aggregations":{"oldest":{"value":%d},"newest":{"value":%d}}}`,
+				base.UnixMilli(), base.Add(time.Duration(docs-1)*time.Minute).UnixMilli())
+		case strings.HasSuffix(r.URL.Path, "/_count"):
+			counts++
+			var body struct {
+				Query struct {
+					Range map[string]struct {
+						Gt string `json:"gt"`
+					} `json:"range"`
+				} `json:"query"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			after, err := time.Parse(timestampFormat, body.Query.Range["@timestamp"].Gt)
+			if err != nil {
+				t.Errorf("count bound: %v", err)
+			}
+			newer := 0
+			for i := 0; i < docs; i++ {
+				if base.Add(time.Duration(i) * time.Minute).After(after) {
+					newer++
+				}
+			}
+			fmt.Fprintf(w, `{"count":%d}`, newer)
+		case strings.Contains(r.URL.Path, "/_stats"):
+			fmt.Fprintf(w, `{"indices":{"logs":{"primaries":{"docs":{"count":%d},"store":{"size_in_bytes":%d}}}}}`, docs, docs*1000)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client, err := opensearch.NewClient(config.OpenSearchConfig{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, &counts
+}
+
+func TestLimitCutoff(t *testing.T) {
+	base := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	minute := func(i int) *time.Time {
+		cutoff := base.Add(time.Duration(i) * time.Minute)
+		return &cutoff
+	}
+	tests := []struct {
+		name    string
+		job     config.CleanupJob
+		docs    int
+		want    *time.Time // newest deleted timestamp, nil within limits
+		wantErr bool
+	}{
+		// documents 900..999 stay
+		{"max_docs", config.CleanupJob{MaxDocs: 100}, 1000, minute(899), false},
+		// 250KB of 1MB keeps a quarter
+		{"max_size", config.CleanupJob{MaxSize: "250KB"}, 1000, minute(749), false},
+		{"stricter limit wins", config.CleanupJob{MaxDocs: 100, MaxSize: "250KB"}, 1000, minute(899), false},
+		{"keep nothing", config.CleanupJob{MaxSize: "1B"}, 1000, minute(999), false},
+		{"within max_docs", config.CleanupJob{MaxDocs: 1000}, 1000, nil, false},
+		{"within max_size", config.CleanupJob{MaxSize: "2MB"}, 1000, nil, false},
+		{"no limits", config.CleanupJob{}, 1000, nil, false},
+		{"empty index", config.CleanupJob{MaxDocs: 100}, 0, nil, false},
+		{"invalid max_size", config.CleanupJob{MaxSize: "lots"}, 1000, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, counts := limitsServer(t, base, tt.docs)
+			got, err := NewService(client, nil, nil).limitCutoff(context.Background(), tt.job, "logs")
+			if tt.wantErr {
+				if !errors.Is(err, errs.ErrInvalidConfig) {
+					t.Fatalf("error %v, want invalid config", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("cutoff = %s, want none", got)
+			case tt.want != nil && (got == nil || !got.Equal(*tt.want)):
+				t.Errorf("cutoff = %v, want %s", got, tt.want)
+			}
+			// Binary search over ~1000 minutes at millisecond precision
+			if *counts > 40 {
+				t.Errorf("%d count requests", *counts)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/hold"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+)
+
+// defaultProtectSettings index settings marking index as protected when protect is not configured
+var defaultProtectSettings = []string{"index.blocks.write", "index.blocks.read_only"}
+
+// protectedIndices find indices that must not be touched by cleanup: under
+// legal hold, having any of protect settings set to "true" or any of protect
+// aliases, returns index name to reason
+func (s *Service) protectedIndices(ctx context.Context, job config.CleanupJob, indices []string, holds hold.Set) (map[string]string, error) {
+	protectSettings := job.Protect.Settings
+	if len(protectSettings) == 0 {
+		protectSettings = defaultProtectSettings
+	}
+
+	protected := make(map[string]string)
+	for _, index := range indices {
+		if h, ok := holds.Index(index); ok {
+			protected[index] = "legal hold " + h.String()
+		}
+	}
+
+	settings, err := s.client.GetClient().Indices.Settings.Get(ctx, &opensearchapi.SettingsGetReq{
+		Indices:  indices,
+		Settings: protectSettings,
+		Params: opensearchapi.SettingsGetParams{
+			FlatSettings: opensearchapi.ToPointer(true),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index settings: %w", err)
+	}
+
+	for index, item := range settings.Indices {
+		if _, ok := protected[index]; ok {
+			continue
+		}
+		var flat map[string]interface{}
+		if err := json.Unmarshal(item.Settings, &flat); err != nil {
+			return nil, fmt.Errorf("failed to decode settings of %s: %w", index, err)
+		}
+		for _, name := range protectSettings {
+			if fmt.Sprint(flat[name]) == "true" {
+				protected[index] = fmt.Sprintf("setting %s is true", name)
+				break
+			}
+		}
+	}
+
+	if len(job.Protect.Aliases) == 0 {
+		return protected, nil
+	}
+
+	aliases, err := s.client.GetClient().Indices.Alias.Get(ctx, opensearchapi.AliasGetReq{
+		Indices: indices,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index aliases: %w", err)
+	}
+
+	for index, item := range aliases.Indices {
+		if _, ok := protected[index]; ok {
+			continue
+		}
+		for _, alias := range job.Protect.Aliases {
+			if _, ok := item.Aliases[alias]; ok {
+				protected[index] = fmt.Sprintf("has protect alias %s", alias)
+				break
+			}
+		}
+	}
+
+	return protected, nil
+}
@@ -0,0 +1,96 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/okto/opensearch-backup-manager/pkg/catalog"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	log "github.com/sirupsen/logrus"
+)
+
+// indexStats primary documents count and store size of index
+type indexStats struct {
+	Docs      int
+	SizeBytes int64
+}
+
+// indexResult cleanup outcome of single index
+type indexResult struct {
+	Index       string
+	Skipped     bool
+	CountBefore int
+	Matched     int
+	Deleted     int
+	CountAfter  int
+	Before      indexStats
+	After       indexStats
+}
+
+// estimatedReclaimed estimate bytes freed by deletion from average document size
+// (store size itself only shrinks after segment merges)
+func (r indexResult) estimatedReclaimed() int64 {
+	if r.Before.Docs == 0 || r.Deleted == 0 {
+		return 0
+	}
+	return r.Before.SizeBytes / int64(r.Before.Docs) * int64(r.Deleted)
+}
+
+func (r indexResult) log() {
+	log.WithFields(log.Fields{
+		"index":               r.Index,
+		"deleted":             r.Deleted,
+		"docs_before":         r.Before.Docs,
+		"docs_after":          r.After.Docs,
+		"size_before":         r.Before.SizeBytes,
+		"size_after":          r.After.SizeBytes,
+		"estimated_reclaimed": r.estimatedReclaimed(),
+	}).Infof("Cleanup completed for index %s: deleted %d documents (~%s reclaimed)",
+		r.Index, r.Deleted, humanize.Bytes(uint64(r.estimatedReclaimed())))
+}
+
+// record convert result to catalog record of cleanup finished at now
+func (r indexResult) record(job config.CleanupJob, now time.Time, err error) catalog.CleanupRecord {
+	record := catalog.CleanupRecord{
+		Timestamp:          now.UTC(),
+		Job:                job.Name(),
+		Index:              r.Index,
+		Status:             "success",
+		CountBefore:        r.CountBefore,
+		Matched:            r.Matched,
+		Deleted:            r.Deleted,
+		CountAfter:         r.CountAfter,
+		SizeBefore:         r.Before.SizeBytes,
+		SizeAfter:          r.After.SizeBytes,
+		EstimatedReclaimed: r.estimatedReclaimed(),
+	}
+	if err != nil {
+		record.Status = "failed"
+		record.Error = err.Error()
+	}
+	return record
+}
+
+// indexStats get primary documents count and store size of index
+func (s *Service) indexStats(ctx context.Context, index string) (indexStats, error) {
+	resp, err := s.client.GetClient().Indices.Stats(ctx, &opensearchapi.IndicesStatsReq{
+		Indices: []string{index},
+		Metrics: []string{"docs", "store"},
+	})
+	if err != nil {
+		return indexStats{}, fmt.Errorf("failed to get index stats: %w", err)
+	}
+
+	stats, ok := resp.Indices[index]
+	if !ok {
+		return indexStats{}, fmt.Errorf("no stats returned for index %s", index)
+	}
+
+	return indexStats{
+		Docs:      stats.Primaries.Docs.Count,
+		SizeBytes: stats.Primaries.Store.SizeInBytes,
+	}, nil
+}
@@ -0,0 +1,186 @@
+package cleanup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/hold"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// trashPrefix prefix of trash indices, trash-<index>-<date>
+	trashPrefix = "trash-"
+	// trashDateLayout date suffix of trash indices, day of the cleanup run
+	trashDateLayout = "2006.01.02"
+	// defaultTrashKeepDays days trash indices are kept by default
+	defaultTrashKeepDays = 7
+)
+
+// checkTrash validate trash settings of job
+func checkTrash(job config.CleanupJob) error {
+	if !job.Trash.Enabled {
+		return nil
+	}
+	if job.Mode == "data_stream" {
+		return fmt.Errorf("%w: trash copies documents before delete-by-query, not with mode data_stream", errs.ErrInvalidConfig)
+	}
+	if job.Trash.KeepDays < 0 {
+		return fmt.Errorf("%w: trash.keep_days must not be negative", errs.ErrInvalidConfig)
+	}
+	return nil
+}
+
+// trashIndex trash index of index for cleanup run at now
+func trashIndex(index string, now time.Time) string {
+	return trashPrefix + index + "-" + now.UTC().Format(trashDateLayout)
+}
+
+// trash copy documents of index matching query (delete-by-query body) into
+// its trash index of today, created hidden with the mapping of index so that
+// wildcard jobs neither back it up nor clean it. Any failed copy fails the
+// run before deletion, so nothing is deleted without its copy. The copy runs
+// with the slices and requests_per_second share of the deletion that follows
+func (s *Service) trash(ctx context.Context, index, query string, params deleteParams) error {
+	var body struct {
+		Query json.RawMessage `json:"query"`
+	}
+	if err := json.Unmarshal([]byte(query), &body); err != nil {
+		return fmt.Errorf("failed to read cleanup query: %w", err)
+	}
+	target := trashIndex(index, s.clock.Now())
+	if err := s.createTrash(ctx, index, target); err != nil {
+		return err
+	}
+
+	reindex, err := json.Marshal(map[string]interface{}{
+		"source": map[string]interface{}{"index": index, "query": body.Query},
+		"dest":   map[string]interface{}{"index": target},
+	})
+	if err != nil {
+		return err
+	}
+	wait, refresh := true, true
+	resp, err := s.client.GetClient().Reindex(ctx, opensearchapi.ReindexReq{
+		Body: bytes.NewReader(reindex),
+		Params: opensearchapi.ReindexParams{
+			WaitForCompletion: &wait,
+			Refresh:           &refresh,
+			Slices:            params.slices,
+			RequestsPerSecond: params.requestsPerSecond,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("reindex into %s failed: %w", target, opensearch.Classify(err))
+	}
+	if len(resp.Failures) > 0 {
+		return fmt.Errorf("%w: reindex into %s finished with %d failures, first: %s; nothing deleted",
+			errs.ErrPartialFailure, target, len(resp.Failures), resp.Failures[0])
+	}
+	log.Infof("Copied %d documents of %s to %s before deletion", resp.Created+resp.Updated, index, target)
+	return nil
+}
+
+// createTrash create hidden trash index target with mapping of index unless
+// it exists, e.g. from an earlier run of the day
+func (s *Service) createTrash(ctx context.Context, index, target string) error {
+	exists, err := s.client.GetClient().Indices.Exists(ctx, opensearchapi.IndicesExistsReq{Indices: []string{target}})
+	if exists == nil || (exists.StatusCode != http.StatusOK && exists.StatusCode != http.StatusNotFound) {
+		return fmt.Errorf("failed to check trash index %s: %w", target, err)
+	}
+	if exists.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	mappings, err := s.client.GetClient().Indices.Mapping.Get(ctx, &opensearchapi.MappingGetReq{Indices: []string{index}})
+	if err != nil {
+		return fmt.Errorf("failed to get mapping of %s: %w", index, opensearch.Classify(err))
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"settings": map[string]interface{}{"index.hidden": true},
+		"mappings": mappings.Indices[index].Mappings,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := s.client.GetClient().Indices.Create(ctx, opensearchapi.IndicesCreateReq{
+		Index: target,
+		Body:  bytes.NewReader(body),
+	}); err != nil && opensearch.ResponseStatus(err) != http.StatusBadRequest {
+		// 400 resource_already_exists when a parallel deletion created it meanwhile
+		return fmt.Errorf("failed to create trash index %s: %w", target, err)
+	}
+	log.Infof("Created trash index %s", target)
+	return nil
+}
+
+// expireTrash delete trash indices of indices matching index_name whose day
+// is more than keep_days ago, except those of indices under legal hold;
+// failures are only logged, the cleanup itself goes on
+func (s *Service) expireTrash(ctx context.Context, job config.CleanupJob, holds hold.Set) {
+	keepDays := job.Trash.KeepDays
+	if keepDays == 0 {
+		keepDays = defaultTrashKeepDays
+	}
+	cutoff := s.clock.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -keepDays)
+
+	resp, err := s.client.GetClient().Cat.Indices(ctx, &opensearchapi.CatIndicesReq{
+		Indices: []string{trashPrefix + "*"},
+		Params:  opensearchapi.CatIndicesParams{ExpandWildcards: "all", H: []string{"index"}},
+	})
+	if err != nil {
+		log.Warnf("Failed to list trash indices of %s: %v", job.IndexName, err)
+		return
+	}
+	patterns := strings.Split(job.IndexName, ",")
+	for i := range patterns {
+		patterns[i] = strings.TrimSpace(patterns[i])
+	}
+	var expired []string
+	for _, row := range resp.Indices {
+		index, day, ok := parseTrashIndex(row.Index)
+		if !ok || !day.Before(cutoff) || !config.MatchesAny(patterns, index) {
+			continue
+		}
+		if h, held := holds.SuspendsRetention(index); held {
+			log.Infof("Keeping trash index %s: legal hold %s", row.Index, h)
+			continue
+		}
+		expired = append(expired, row.Index)
+	}
+	if len(expired) == 0 {
+		return
+	}
+	if run.OverridesOf(ctx).DryRun {
+		log.Infof("Dry run: would delete %d expired trash indices of %s: %s", len(expired), job.IndexName, strings.Join(expired, ", "))
+		return
+	}
+	if _, err := s.client.GetClient().Indices.Delete(ctx, opensearchapi.IndicesDeleteReq{Indices: expired}); err != nil {
+		log.Warnf("Failed to delete expired trash indices %s: %v", strings.Join(expired, ", "), err)
+		return
+	}
+	log.Infof("Deleted %d trash indices of %s older than %d days: %s", len(expired), job.IndexName, keepDays, strings.Join(expired, ", "))
+}
+
+// parseTrashIndex source index and day of trash index name
+func parseTrashIndex(name string) (string, time.Time, bool) {
+	if !strings.HasPrefix(name, trashPrefix) || len(name) < len(trashPrefix)+len(trashDateLayout)+2 {
+		return "", time.Time{}, false
+	}
+	suffix := name[len(name)-len(trashDateLayout):]
+	day, err := time.Parse(trashDateLayout, suffix)
+	if err != nil || name[len(name)-len(trashDateLayout)-1] != '-' {
+		return "", time.Time{}, false
+	}
+	return name[len(trashPrefix) : len(name)-len(trashDateLayout)-1], day, true
+}
@@ -0,0 +1,28 @@
+// Package clock abstracts time so schedules and retention cutoffs can be pinned in tests
+package clock
+
+import "time"
+
+// Clock source of current time and timers
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Real wall clock
+type Real struct{}
+
+func (Real) Now() time.Time { return time.Now() }
+
+func (Real) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Fixed clock frozen at given time, timers fire immediately
+type Fixed time.Time
+
+func (f Fixed) Now() time.Time { return time.Time(f) }
+
+func (f Fixed) After(time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- time.Time(f)
+	return ch
+}
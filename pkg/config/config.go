@@ -0,0 +1,940 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config main application configuration
+type Config struct {
+	OpenSearch  OpenSearchConfig    `yaml:"opensearch"`
+	S3          S3Config            `yaml:"s3"`
+	Storages    map[string]S3Config `yaml:"storages"` // named storage profiles for jobs with storage: <name>
+	Catalog     CatalogConfig       `yaml:"catalog"`
+	CleanupJobs []CleanupJob        `yaml:"cleanup_jobs"`
+	BackupJobs  []BackupJob         `yaml:"backup_jobs"`
+	FireDrills  []FireDrillJob      `yaml:"fire_drill_jobs"`
+	Restore     RestoreConfig       `yaml:"restore"`
+	Metrics     MetricsConfig       `yaml:"metrics"`
+	Health      HealthConfig        `yaml:"health"`
+	Maintenance MaintenanceConfig   `yaml:"maintenance"`
+	RetryBudget RetryBudgetConfig   `yaml:"retry_budget"`
+
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Admin         AdminConfig         `yaml:"admin"`
+	Agent         AgentConfig         `yaml:"agent"`
+	Journal       JournalConfig       `yaml:"journal"`
+	State         StateConfig         `yaml:"state"`
+	Blackout      BlackoutConfig      `yaml:"blackout"`
+	LegalHold     LegalHoldConfig     `yaml:"legal_hold"`
+	UploadWindow  UploadWindowConfig  `yaml:"upload_window"`
+
+	CompressionPool CompressionPoolConfig `yaml:"compression_pool"` // dedicated low-priority compression threads shared by all backups
+
+	Clusters map[string]OpenSearchConfig `yaml:"clusters"` // named clusters for fan-out jobs with clusters: [<name>, ...]
+
+	ExcludeIndices []string `yaml:"exclude_indices"` // index patterns no job backs up or cleans, e.g. ".kibana*", "*-reindexed"
+}
+
+// UploadWindowConfig off-peak hours S3 uploads of backup artifacts are
+// deferred to; exports still run on schedule and queue artifacts locally
+type UploadWindowConfig struct {
+	Start    string `yaml:"start"`     // "22:00"; empty uploads right away
+	End      string `yaml:"end"`       // "06:00", before start wraps midnight
+	Timezone string `yaml:"timezone"`  // zone of start and end, default UTC
+	Dir      string `yaml:"dir"`       // persistent queue of artifacts awaiting the window
+	MaxQueue string `yaml:"max_queue"` // disk cap of the queue (e.g. "200GB"), artifacts above it upload right away
+}
+
+// CompressionPoolConfig compression threads shared by every backup of the
+// process instead of compression_workers per part file, so compression on a
+// shared host never takes more than threads CPUs and yields to other services
+type CompressionPoolConfig struct {
+	Threads int `yaml:"threads"` // OS threads compressing blocks, 0 disables the pool
+	Nice    int `yaml:"nice"`    // niceness of the threads, 1 to 19 lowers priority (Linux)
+}
+
+// LegalHoldConfig registry of legal holds skipped by cleanup and retention
+type LegalHoldConfig struct {
+	Dir string `yaml:"dir"` // persistent directory of active holds and their audit log, empty disables holds
+}
+
+// BlackoutConfig change freeze days on which destructive jobs (cleanup,
+// retention rotation) are skipped
+type BlackoutConfig struct {
+	Dates    []string `yaml:"dates"`    // "2026-11-27" or inclusive range "2026-12-20..2027-01-05"
+	File     string   `yaml:"file"`     // one date or range per line, or iCalendar (.ics) events; re-read on every check
+	Timezone string   `yaml:"timezone"` // zone days are evaluated in, default UTC
+}
+
+// JournalConfig crash recovery journal of in-flight runs
+type JournalConfig struct {
+	Dir    string `yaml:"dir"`    // persistent directory of entries, empty disables journal
+	Resume bool   `yaml:"resume"` // re-run interrupted backups whose window is still current
+}
+
+// StateConfig store of job locks, run history, watermarks and backup
+// checkpoints, shared by manager instances using the same store
+type StateConfig struct {
+	Type  string `yaml:"type"`  // "bolt" (embedded file) or "opensearch" (index); empty disables
+	Path  string `yaml:"path"`  // bolt database file, e.g. "/var/lib/opensearch-backup-manager/state.db"
+	Index string `yaml:"index"` // opensearch index, default "opensearch-backup-state"
+
+	History int `yaml:"history"` // finished runs kept per job (default 100)
+}
+
+// AdminConfig HTTP admin API
+type AdminConfig struct {
+	Enabled bool         `yaml:"enabled"`
+	Listen  string       `yaml:"listen"` // default ":8080"
+	Tokens  []AdminToken `yaml:"tokens"`
+
+	GRPCListen string `yaml:"grpc_listen"` // gRPC JobService address (e.g. ":9443"), empty disables
+
+	Agents AgentsConfig `yaml:"agents"` // runs of jobs with agent: <name>, claimed by remote agents
+}
+
+// AgentsConfig runs queued on the admin API for remote agents
+type AgentsConfig struct {
+	Lease     string `yaml:"lease"`      // claimed runs fail when their agent is silent this long (default "1m")
+	QueueWait string `yaml:"queue_wait"` // queued runs fail when no agent claims them this long (default "1h")
+}
+
+// AgentConfig agent mode (agent subcommand): runs of backup jobs with
+// agent: <name> are claimed from the scheduler admin API and executed here
+type AgentConfig struct {
+	Name   string `yaml:"name"`    // agent name matched by agent of jobs, shared by agents of one pool
+	Server string `yaml:"server"`  // admin API of the scheduler, e.g. "https://manager:8080"
+	Token  string `yaml:"token"`   // admin token with agent role, overridden by AGENT_TOKEN
+	CACert string `yaml:"ca_cert"` // extra CA bundle of server
+}
+
+// AdminToken static bearer token of admin API client
+type AdminToken struct {
+	Name  string `yaml:"name"`  // client name for audit logs
+	Token string `yaml:"token"` // overridden by ADMIN_TOKEN_<NAME> environment variable
+	Role  string `yaml:"role"`  // "read" (status, listings), "admin" (also trigger) or "agent" (claim runs only)
+	Agent string `yaml:"agent"` // agent name whose runs an agent token may claim, required with role agent
+}
+
+// NotificationsConfig where job run outcomes are sent
+type NotificationsConfig struct {
+	Channels  map[string]NotificationChannel `yaml:"channels"`
+	Routes    []NotificationRoute            `yaml:"routes"`     // checked in order, first match wins
+	Default   []string                       `yaml:"default"`    // channels of jobs matching no route
+	OnSuccess bool                           `yaml:"on_success"` // notify about successful runs too, not only failures
+}
+
+// NotificationChannel single notification destination
+type NotificationChannel struct {
+	Type string `yaml:"type"` // "slack" (incoming webhook) or "webhook" (JSON POST of the event)
+	URL  string `yaml:"url"`
+}
+
+// NotificationRoute send events of jobs whose labels contain every match pair to channels
+type NotificationRoute struct {
+	Match    map[string]string `yaml:"match"`
+	Channels []string          `yaml:"channels"`
+	Continue bool              `yaml:"continue"` // keep checking following routes after a match
+}
+
+// RetryBudgetConfig retries one job run may spend on OpenSearch and S3 before failing fast
+type RetryBudgetConfig struct {
+	MaxRetries int    `yaml:"max_retries"` // total retries of a run, 0 unlimited
+	MaxWait    string `yaml:"max_wait"`    // total backoff time of a run ("30m"), empty unlimited
+}
+
+// MaintenanceConfig scheduled storage housekeeping
+type MaintenanceConfig struct {
+	Schedule        string `yaml:"schedule"`          // cron format, empty disables maintenance
+	MultipartMaxAge string `yaml:"multipart_max_age"` // abort incomplete multipart uploads older than this (default 24h)
+}
+
+// FireDrillJob scheduled restore of a random recent backup into a scratch
+// index, checked against its manifest and deleted afterwards
+type FireDrillJob struct {
+	IndexName     string            `yaml:"index_name"`      // backed up index, as recorded in manifests
+	Schedule      string            `yaml:"schedule"`        // cron format
+	S3Path        string            `yaml:"s3_path"`         // default s3_path of backup job of index
+	Storage       string            `yaml:"storage"`         // default storage of backup job of index
+	SourceCluster string            `yaml:"source_cluster"`  // named cluster of fan-out backup job of index to drill
+	MaxAge        string            `yaml:"max_age"`         // pick among backups of days not older than this (default "7d")
+	ScratchIndex  string            `yaml:"scratch_index"`   // default "fire-drill-<index>-<run_id>", must not exist
+	Cluster       *OpenSearchConfig `yaml:"cluster"`         // scratch cluster to restore into, default the opensearch section
+	KeepOnFailure bool              `yaml:"keep_on_failure"` // leave scratch index of a failed drill for inspection
+	Labels        map[string]string `yaml:"labels"`          // e.g. team: payments, matched by notification routes
+}
+
+// MetricsConfig Prometheus endpoint
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"` // default ":9090"
+}
+
+// HealthConfig liveness and readiness endpoints reporting connectivity to
+// OpenSearch and S3
+type HealthConfig struct {
+	Listen   string `yaml:"listen"`   // address of /healthz and /readyz (e.g. ":8081"), empty disables
+	Interval string `yaml:"interval"` // between connectivity checks (default 15s)
+	Timeout  string `yaml:"timeout"`  // of one check (default 5s)
+	Grace    string `yaml:"grace"`    // /healthz fails once a dependency is unreachable this long (default 5m)
+}
+
+// OpenSearch configuration
+type OpenSearchConfig struct {
+	Addresses []string `yaml:"addresses"`
+	Username  string   `yaml:"username"`
+	Password  string   `yaml:"password"`
+	CertPath  string   `yaml:"cert_path"`
+
+	SlowLog   SlowLogConfig   `yaml:"slow_log"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+}
+
+// RateLimitConfig token bucket shared by all requests of all jobs to the cluster
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"` // sustained request rate, 0 disables
+	Burst             int     `yaml:"burst"`               // requests let through at once after idle time (default 1)
+}
+
+// SlowLogConfig logging of slow export and delete requests
+type SlowLogConfig struct {
+	Threshold  string `yaml:"threshold"`   // log search/scroll/delete_by_query requests slower than this ("10s"), empty disables
+	ShowValues bool   `yaml:"show_values"` // log query string values verbatim instead of <redacted>
+}
+
+// S3Config configuration
+type S3Config struct {
+	Endpoint        string `yaml:"endpoint"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	Bucket          string `yaml:"bucket"`
+	Region          string `yaml:"region"`
+	UseSSL          bool   `yaml:"use_ssl"`
+	Accelerate      bool   `yaml:"accelerate"`      // use S3 Transfer Acceleration endpoint (AWS only)
+	CACert          string `yaml:"ca_cert"`         // PEM bundle trusted in addition to system CAs
+	TLSMinVersion   string `yaml:"tls_min_version"` // "1.2" or "1.3" (default Go minimum)
+
+	KeyPrefix string            `yaml:"key_prefix"` // prepended to every key of this manager instance
+	Tenants   map[string]string `yaml:"tenants"`    // tenant name -> prefix prepended to s3_path of its jobs
+
+	SkipUploadVerify bool `yaml:"skip_upload_verify"` // do not HEAD objects after upload to confirm size and checksum
+	Trace            bool `yaml:"trace"`              // dump S3 HTTP requests and responses to debug log
+
+	Objects ObjectHeaders `yaml:"objects"`
+}
+
+// ObjectHeaders HTTP headers of uploaded objects, keyed by last key extension
+type ObjectHeaders struct {
+	ContentTypes     map[string]string `yaml:"content_types"`     // e.g. ".zst": "application/zstd", overrides built-in types
+	ContentEncodings map[string]string `yaml:"content_encodings"` // e.g. ".gz": "gzip", so HTTP clients decompress on download
+	CacheControl     string            `yaml:"cache_control"`     // Cache-Control of every object, e.g. "no-store"
+}
+
+// CatalogConfig catalog index recording job runs
+type CatalogConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Index   string `yaml:"index"` // default "opensearch-backup-catalog"
+}
+
+// CleanupJob cleanup job
+type CleanupJob struct {
+	IndexName     string `yaml:"index_name"` // index, wildcard or comma-separated list
+	Mode          string `yaml:"mode"`       // "documents" (delete-by-query, default) or "data_stream"
+	RetentionDays int    `yaml:"retention_days"`
+	Retention     string `yaml:"retention"`    // duration ("36h", "90d") with precise cutoff, overrides retention_days
+	Schedule      string `yaml:"schedule"`     // cron format
+	Slices        string `yaml:"slices"`       // "auto" or number of slices for delete-by-query
+	Conflicts     string `yaml:"conflicts"`    // "abort" (default) or "proceed" on version conflicts
+	MaxRetries    int    `yaml:"max_retries"`  // retries when all failures are retriable (429, rejected execution)
+	MaxFailures   int    `yaml:"max_failures"` // allowed failures before the job is marked as failed
+	MaxDocs       int    `yaml:"max_docs"`     // keep at most N newest documents per index
+	MaxSize       string `yaml:"max_size"`     // keep at most this store size per index (e.g. "50GB")
+
+	Concurrency       int `yaml:"concurrency"`         // indices of a wildcard cleaned in parallel (default 1)
+	RequestsPerSecond int `yaml:"requests_per_second"` // delete-by-query throttle shared by all parallel deletions, 0 unthrottled
+
+	MaxDeleteRatio float64 `yaml:"max_delete_ratio"` // abort when run would delete more than this fraction of index
+	MaxDeleteDocs  int     `yaml:"max_delete_docs"`  // abort when run would delete more than N documents
+	Force          bool    `yaml:"force"`            // override safety guard
+
+	Refresh bool `yaml:"refresh"` // refresh index after deletion, so counts see post-cleanup state
+	Flush   bool `yaml:"flush"`   // flush index after deletion
+
+	HealthGate HealthGate    `yaml:"health_gate"`
+	LoadGate   LoadGate      `yaml:"load_gate"`
+	Protect    ProtectConfig `yaml:"protect"`
+	Trash      TrashConfig   `yaml:"trash"` // copy documents into a trash index before deleting them
+
+	Query        map[string]interface{} `yaml:"query"`         // additional filter, AND-ed with retention range
+	ExcludeQuery map[string]interface{} `yaml:"exclude_query"` // matching documents are never deleted
+
+	ExcludeIndices []string `yaml:"exclude_indices"` // resolved indices matching these patterns are skipped, in addition to global ones
+
+	MissingIndex string `yaml:"missing_index"` // "skip" (default) or "fail" when index_name matches no index
+
+	Clusters []string `yaml:"clusters"` // run on each of these named clusters instead of opensearch
+	Cluster  string   `yaml:"-"`        // named cluster of one fan-out run, set on load
+
+	Labels map[string]string `yaml:"labels"` // e.g. team: payments, matched by notification routes
+}
+
+// Name job name, qualified by cluster of fan-out jobs ("eu-west:audit-*")
+func (j CleanupJob) Name() string {
+	return qualify(j.Cluster, j.IndexName)
+}
+
+// HealthGate cluster health requirements before destructive jobs
+type HealthGate struct {
+	Enabled   bool   `yaml:"enabled"`
+	MinStatus string `yaml:"min_status"` // "green" (default) or "yellow"
+	MaxWait   string `yaml:"max_wait"`   // give up after this duration (default 1h)
+	Backoff   string `yaml:"backoff"`    // initial delay between checks, doubled each time (default 1m)
+}
+
+// LoadGate node load limits before heavy jobs, so they yield to user traffic
+type LoadGate struct {
+	Enabled        bool   `yaml:"enabled"`
+	MaxCPUPercent  int    `yaml:"max_cpu_percent"`  // defer while CPU of any node is above this (default 80)
+	MaxSearchQueue int    `yaml:"max_search_queue"` // defer while search queue of any node is longer (default 100)
+	MaxWait        string `yaml:"max_wait"`         // give up after this duration (default 1h)
+	Backoff        string `yaml:"backoff"`          // initial delay between checks, doubled each time (default 1m)
+}
+
+// ProtectConfig marks indices that cleanup must never touch
+type ProtectConfig struct {
+	Settings []string `yaml:"settings"` // index settings set to true (default: index.blocks.write, index.blocks.read_only)
+	Aliases  []string `yaml:"aliases"`  // indices having any of these aliases
+}
+
+// TrashConfig undo window of cleanup: documents about to be deleted are
+// first reindexed into hidden trash-<index>-<date> indices, which later runs
+// delete once they are older than keep_days
+type TrashConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	KeepDays int  `yaml:"keep_days"` // days trash indices are kept (default 7)
+}
+
+// HasRetention check if job deletes documents by age
+func (j CleanupJob) HasRetention() bool {
+	return j.Retention != "" || j.RetentionDays > 0
+}
+
+// RetentionPeriod human-readable retention for logs
+func (j CleanupJob) RetentionPeriod() string {
+	if j.Retention != "" {
+		return j.Retention
+	}
+	return fmt.Sprintf("%dd", j.RetentionDays)
+}
+
+// HasLimits check if job limits index by documents count or size
+func (j CleanupJob) HasLimits() bool {
+	return j.MaxDocs > 0 || j.MaxSize != ""
+}
+
+// Windows exported by a backup run
+const (
+	WindowDay   = "day"
+	WindowWeek  = "week"
+	WindowMonth = "month"
+	WindowAuto  = "auto"
+)
+
+// Policies of failed export periods
+const (
+	OnErrorFailFast           = "fail_fast"
+	OnErrorRetryFailedPeriods = "retry_failed_periods"
+	OnErrorAllowPartial       = "allow_partial"
+)
+
+// Outcomes of a run whose index_name matches no index
+const (
+	MissingIndexFail = "fail"
+	MissingIndexSkip = "skip"
+)
+
+// BackupJob backup job
+type BackupJob struct {
+	IndexName       string `yaml:"index_name"`
+	Schedule        string `yaml:"schedule"`       // cron format
+	IntervalHours   int    `yaml:"interval_hours"` // interval of splitting, a divisor of 24 or whole days (2, 4, 6, 24)
+	Window          string `yaml:"window"`         // exported per run: "day" (default), "week", "month" or "auto" (from schedule)
+	S3Path          string `yaml:"s3_path"`        // path in S3 bucket
+	Storage         string `yaml:"storage"`        // storages profile to write to (default s3)
+	Tenant          string `yaml:"tenant"`         // tenants entry of the storage whose prefix is prepended to s3_path
+	RequestInterval int    `yaml:"request_interval_seconds"`
+	DataDelay       string `yaml:"data_delay"`        // start export only once target day ended this long ago (e.g. "2h")
+	LateOverlap     string `yaml:"late_overlap"`      // also re-export this tail of previous day (e.g. "1h")
+	PageSize        int    `yaml:"page_size"`         // documents per search request (default 1000)
+	MaxDocsPerFile  int    `yaml:"max_docs_per_file"` // spill period into -partN files above this count
+	MaxPeriodDocs   int    `yaml:"max_period_docs"`   // split periods into smaller time slices above this count (-1 = max_result_window)
+	Slices          int    `yaml:"slices"`            // concurrent sliced searches per period through a point in time (default 1, not with stream)
+	RawSource       bool   `yaml:"raw_source"`        // write only documents _source, one per line (same as format: source)
+	Format          string `yaml:"format"`            // "search" (default), "source", "csv", "avro" or "bulk"
+	Preference      string `yaml:"preference"`        // search preference, e.g. "_replica", "_local" or custom string
+	Routing         string `yaml:"routing"`           // comma-separated routing values limiting searched shards
+
+	ExtraFormats []string `yaml:"extra_formats"` // also write these formats from the same pages, one artifact each (not chunked or stream)
+
+	OnError          string  `yaml:"on_error"`          // failed period policy: "allow_partial" (default), "fail_fast", "retry_failed_periods"
+	PeriodRetries    int     `yaml:"period_retries"`    // retries of failed periods with retry_failed_periods (default 3)
+	SampleRate       float64 `yaml:"sample_rate"`       // export random fraction of documents, e.g. 0.01 for 1% (default all)
+	Dedup            bool    `yaml:"dedup"`             // export every _id once per day, e.g. when overlapping indices match (not with format: search)
+	FilenameTemplate string  `yaml:"filename_template"` // Go template of object names without extension (see pkg/naming)
+
+	Query       map[string]interface{} `yaml:"query"`        // export only matching documents, AND-ed with period range
+	QueryFile   string                 `yaml:"query_file"`   // same as query, read from YAML/JSON file on every run
+	StoredQuery StoredQuery            `yaml:"stored_query"` // same as query, rendered from search template stored in cluster
+
+	DocValueFields []string `yaml:"docvalue_fields"` // export these doc values instead of _source
+	StoredFields   []string `yaml:"stored_fields"`   // export these stored fields instead of _source
+	ExcludeIndices []string `yaml:"exclude_indices"` // resolved indices matching these patterns are not exported, in addition to global ones
+
+	MissingIndex string `yaml:"missing_index"` // "skip" (default) or "fail" when index_name matches no index
+
+	CompressionWorkers int    `yaml:"compression_workers"` // parallel gzip workers (default: all CPUs)
+	Chunked            bool   `yaml:"chunked"`             // upload every part file as separate object
+	UploadConcurrency  int    `yaml:"upload_concurrency"`  // parallel chunk uploads (default 2)
+	MaxDiskUsage       string `yaml:"max_disk_usage"`      // pause export while queued local files exceed this size (e.g. "20GB")
+	SkipExisting       bool   `yaml:"skip_existing"`       // skip run when daily artifact already exists in S3 (not chunked)
+	Checkpoint         bool   `yaml:"checkpoint"`          // upload progress after every period; chunked runs resume from it
+	KeepOnFailure      bool   `yaml:"keep_on_failure"`     // keep temporary files of failed run in work dir for debugging
+	Stream             bool   `yaml:"stream"`              // stream through gzip into S3 without local files
+	StreamPartSizeMB   int    `yaml:"stream_part_size_mb"` // in-memory S3 part buffer for stream mode (default 16)
+
+	Autotune   AutotuneConfig   `yaml:"autotune"`
+	LoadGate   LoadGate         `yaml:"load_gate"`
+	Encryption EncryptionConfig `yaml:"encryption"`
+	CSV        CSVConfig        `yaml:"csv"`
+	Avro       AvroConfig       `yaml:"avro"`
+	Retention  RetentionPolicy  `yaml:"retention"`
+
+	Compression CompressionConfig `yaml:"compression"` // artifact codec, gzip unless set
+
+	PointInTime PointInTimeConfig `yaml:"point_in_time"` // page every period through its own point in time
+
+	Signing SigningConfig `yaml:"signing"` // ed25519 signature of every manifest, checked on restore
+
+	ArchiveMapping bool `yaml:"archive_mapping"` // upload index mapping next to artifacts, for indices created on restore
+
+	Purge PurgeConfig `yaml:"purge"` // delete exported documents from the cluster once their backup is complete
+
+	Profile ProfileConfig `yaml:"profile"` // profile a sample of export searches and log where shards spend time
+
+	Completeness CompletenessCheck `yaml:"completeness"`
+	SpotCheck    SpotCheck         `yaml:"spot_check"`
+
+	Clusters []string `yaml:"clusters"` // run on each of these named clusters instead of opensearch, under <s3_path>/<cluster>
+	Cluster  string   `yaml:"-"`        // named cluster of one fan-out run, set on load
+
+	Agent string `yaml:"agent"` // run on remote agents of this agent.name instead of in the scheduler
+
+	Labels map[string]string `yaml:"labels"` // e.g. team: payments, matched by notification routes
+}
+
+// Name job name, qualified by cluster of fan-out jobs ("eu-west:audit-*")
+func (j BackupJob) Name() string {
+	return qualify(j.Cluster, j.IndexName)
+}
+
+// PointInTimeConfig consistent export of periods: each period is paged
+// through a point in time opened when its download starts, so documents
+// indexed or deleted meanwhile neither appear nor vanish mid-period
+type PointInTimeConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	KeepAlive string `yaml:"keep_alive"` // kept open this long after every page (default "5m")
+}
+
+// SpotCheck read-back of uploaded artifact before its manifest is written
+type SpotCheck struct {
+	Enabled   bool `yaml:"enabled"`
+	Documents int  `yaml:"documents"` // first documents parsed back (default 100)
+}
+
+// CompressionConfig codec of artifacts
+type CompressionConfig struct {
+	Codec      string           `yaml:"codec"` // "gzip" (default) or "zstd"
+	Dictionary DictionaryConfig `yaml:"dictionary"`
+}
+
+// DictionaryConfig zstd dictionary trained on sampled documents and shared by
+// artifacts of the index, stored next to them
+type DictionaryConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Samples int    `yaml:"samples"`  // documents sampled for training (default 5000)
+	MaxSize string `yaml:"max_size"` // dictionary size limit (default "112KB")
+	MaxAge  string `yaml:"max_age"`  // train a new dictionary once the current one is older (default "30d")
+}
+
+// StoredQuery search template stored in cluster (PUT _scripts/<id>) whose
+// rendered query filters exported documents
+type StoredQuery struct {
+	ID     string                 `yaml:"id"`
+	Params map[string]interface{} `yaml:"params"` // template parameters
+}
+
+// CompletenessCheck comparison of exported documents with day count of index
+type CompletenessCheck struct {
+	Enabled    bool   `yaml:"enabled"`
+	Retries    int    `yaml:"retries"`     // re-run backup of the day on mismatch (default 0, only flag)
+	RetryDelay string `yaml:"retry_delay"` // pause before re-run (default 1m)
+}
+
+// RetentionPolicy grandfather-father-son rotation of backups in S3
+type RetentionPolicy struct {
+	Daily   int  `yaml:"daily"`   // keep every backup of last N days
+	Weekly  int  `yaml:"weekly"`  // keep first backup of each of last N weeks
+	Monthly int  `yaml:"monthly"` // keep first backup of each of last N months
+	Tag     bool `yaml:"tag"`     // tag kept objects with retention-tier (daily, weekly, monthly)
+
+	Lifecycle LifecycleConfig `yaml:"lifecycle"`
+}
+
+// LifecycleConfig bucket lifecycle rules maintained for job prefix
+type LifecycleConfig struct {
+	Enabled     bool                  `yaml:"enabled"`
+	Transitions []LifecycleTransition `yaml:"transitions"`
+}
+
+// LifecycleTransition move objects to cheaper storage class after some days
+type LifecycleTransition struct {
+	Days         int    `yaml:"days"`
+	StorageClass string `yaml:"storage_class"` // e.g. "STANDARD_IA", "GLACIER"
+}
+
+// Enabled check if old backups are rotated
+func (r RetentionPolicy) Enabled() bool {
+	return r.Daily > 0 || r.Weekly > 0 || r.Monthly > 0
+}
+
+// AvroConfig schema of avro export format, derived from index mapping when not set
+type AvroConfig struct {
+	Schema           string `yaml:"schema"`       // inline record schema JSON
+	SchemaFile       string `yaml:"schema_file"`  // or path to schema file
+	RegistryURL      string `yaml:"registry_url"` // register schema in Confluent-compatible registry
+	Subject          string `yaml:"subject"`      // default "<index_name>-value"
+	RegistryUsername string `yaml:"registry_username"`
+	RegistryPassword string `yaml:"registry_password"`
+}
+
+// CSVConfig column mapping of csv export format
+type CSVConfig struct {
+	Columns   []CSVColumn `yaml:"columns"`
+	Delimiter string      `yaml:"delimiter"` // default ","
+}
+
+// CSVColumn single csv column
+type CSVColumn struct {
+	Name       string `yaml:"name"`        // header, default field
+	Field      string `yaml:"field"`       // dotted path in _source ("user.name"), or _id, _index
+	DateFormat string `yaml:"date_format"` // Go layout for date values ("2006-01-02 15:04:05")
+}
+
+// EncryptionConfig recipient-based encryption of uploaded artifacts
+type EncryptionConfig struct {
+	Recipients     []string `yaml:"recipients"`      // age X25519 public keys ("age1...")
+	RecipientsFile string   `yaml:"recipients_file"` // file with one recipient per line
+}
+
+// Enabled check if artifacts are encrypted
+func (e EncryptionConfig) Enabled() bool {
+	return len(e.Recipients) > 0 || e.RecipientsFile != ""
+}
+
+// SigningConfig detached ed25519 signatures of manifests; manifests record
+// the checksum of every object, so the signature covers the artifacts too
+type SigningConfig struct {
+	KeyFile string `yaml:"key_file"` // ed25519 private key, PKCS#8 PEM
+}
+
+// Enabled check if manifests are signed
+func (s SigningConfig) Enabled() bool {
+	return s.KeyFile != ""
+}
+
+// PurgeConfig deletion of backed up documents: after a complete backup the
+// documents selected by the export query are deleted with that same query, so
+// a job filtered on a tag archives and removes the tagged documents only
+type PurgeConfig struct {
+	Enabled           bool   `yaml:"enabled"`
+	Conflicts         string `yaml:"conflicts"`           // "abort" (default) or "proceed"
+	RequestsPerSecond int    `yaml:"requests_per_second"` // delete-by-query throttle, 0 unthrottled
+}
+
+// ProfileConfig search profiling ("profile": true) of a random sample of
+// export pages, whose shard timings are logged to tell why an index exports
+// slowly; profiling adds overhead to the sampled searches only
+type ProfileConfig struct {
+	Enabled    bool    `yaml:"enabled"`
+	SampleRate float64 `yaml:"sample_rate"` // fraction of search pages profiled (default 0.01)
+}
+
+// RestoreConfig settings of restoring artifacts back into OpenSearch
+type RestoreConfig struct {
+	BulkSize     int              `yaml:"bulk_size"`     // documents per _bulk request (default 1000)
+	IdentityFile string           `yaml:"identity_file"` // age identities for encrypted artifacts
+	DeadLetter   string           `yaml:"dead_letter"`   // NDJSON file rejected documents are appended to instead of failing restore
+	Transform    RestoreTransform `yaml:"transform"`
+
+	// ed25519 public keys (PKIX PEM files), manifests must be signed by one of
+	// them before their backups are restored
+	VerifyKeys    []string `yaml:"verify_keys"`
+	AllowUnsigned bool     `yaml:"allow_unsigned"` // restore unsigned manifests, written before signing was enabled
+}
+
+// RestoreTransform document changes applied before restored documents are
+// indexed, for archives older than target mapping
+type RestoreTransform struct {
+	Rename map[string]string `yaml:"rename"` // field path -> new field path
+	Drop   []string          `yaml:"drop"`   // field paths removed from documents
+	Dates  []DateTransform   `yaml:"dates"`  // date fields converted between formats
+}
+
+// Empty check if transform changes nothing
+func (t RestoreTransform) Empty() bool {
+	return len(t.Rename) == 0 && len(t.Drop) == 0 && len(t.Dates) == 0
+}
+
+// DateTransform date field conversion
+type DateTransform struct {
+	Field string `yaml:"field"`
+	From  string `yaml:"from"` // Go layout, "epoch_millis" or "epoch_second" (default RFC3339)
+	To    string `yaml:"to"`   // Go layout, "epoch_millis" or "epoch_second" (default RFC3339)
+}
+
+// AutotuneConfig adaptive export page size and pacing
+type AutotuneConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	MinPageSize   int    `yaml:"min_page_size"`  // default 100
+	MaxPageSize   int    `yaml:"max_page_size"`  // default 10000
+	TargetLatency string `yaml:"target_latency"` // grow pages below half of it, shrink above (default 1s)
+}
+
+func LoadConfig() (*Config, error) {
+	configPath := ConfigPath()
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	// Override from environment variables
+	if val := os.Getenv("OPENSEARCH_USERNAME"); val != "" {
+		cfg.OpenSearch.Username = val
+	}
+	if val := os.Getenv("OPENSEARCH_PASSWORD"); val != "" {
+		cfg.OpenSearch.Password = val
+	}
+	if val := os.Getenv("OPENSEARCH_ADDRESSES"); val != "" {
+		cfg.OpenSearch.Addresses = []string{val}
+	}
+	if val := os.Getenv("OPENSEARCH_CERT_PATH"); val != "" {
+		cfg.OpenSearch.CertPath = val
+	}
+
+	if val := os.Getenv("S3_ENDPOINT"); val != "" {
+		cfg.S3.Endpoint = val
+	}
+	if val := os.Getenv("S3_ACCESS_KEY_ID"); val != "" {
+		cfg.S3.AccessKeyID = val
+	}
+	if val := os.Getenv("S3_SECRET_ACCESS_KEY"); val != "" {
+		cfg.S3.SecretAccessKey = val
+	}
+	if val := os.Getenv("S3_BUCKET"); val != "" {
+		cfg.S3.Bucket = val
+	}
+	if val := os.Getenv("S3_REGION"); val != "" {
+		cfg.S3.Region = val
+	}
+	if val := os.Getenv("S3_CA_CERT"); val != "" {
+		cfg.S3.CACert = val
+	}
+
+	for i, t := range cfg.Admin.Tokens {
+		if val := os.Getenv("ADMIN_TOKEN_" + strings.ToUpper(t.Name)); val != "" {
+			cfg.Admin.Tokens[i].Token = val
+		}
+	}
+	if val := os.Getenv("AGENT_TOKEN"); val != "" {
+		cfg.Agent.Token = val
+	}
+
+	if err := applyExclusions(&cfg); err != nil {
+		return nil, err
+	}
+	if err := expandClusters(&cfg); err != nil {
+		return nil, err
+	}
+	if err := resolveTenants(&cfg); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// NormalizePrefix key prefix without leading slash and with one trailing slash
+func NormalizePrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// StorageConfig storage profile of job storage name, the s3 section when empty
+func (c *Config) StorageConfig(name string) (S3Config, error) {
+	if name == "" {
+		return c.S3, nil
+	}
+	profile, ok := c.Storages[name]
+	if !ok {
+		return S3Config{}, fmt.Errorf("unknown storage %q", name)
+	}
+	return profile, nil
+}
+
+// applyExclusions append global exclude_indices to every cleanup and backup
+// job and check the patterns
+func applyExclusions(cfg *Config) error {
+	merge := func(job string, patterns []string) ([]string, error) {
+		merged := append(append([]string(nil), patterns...), cfg.ExcludeIndices...)
+		for _, pattern := range merged {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("job %s: exclude_indices pattern %q: %w", job, pattern, err)
+			}
+		}
+		return merged, nil
+	}
+	var err error
+	for i := range cfg.CleanupJobs {
+		job := &cfg.CleanupJobs[i]
+		if job.ExcludeIndices, err = merge(job.IndexName, job.ExcludeIndices); err != nil {
+			return fmt.Errorf("cleanup %w", err)
+		}
+	}
+	for i := range cfg.BackupJobs {
+		job := &cfg.BackupJobs[i]
+		if job.ExcludeIndices, err = merge(job.IndexName, job.ExcludeIndices); err != nil {
+			return fmt.Errorf("backup %w", err)
+		}
+	}
+	return nil
+}
+
+// ExcludeIndices split resolved index names into those kept and those
+// matching any of patterns
+func ExcludeIndices(names, patterns []string) (kept, excluded []string) {
+	for _, name := range names {
+		if Excluded(patterns, name) {
+			excluded = append(excluded, name)
+		} else {
+			kept = append(kept, name)
+		}
+	}
+	return kept, excluded
+}
+
+// Excluded check if index name matches any of exclude patterns
+func Excluded(patterns []string, name string) bool {
+	return MatchesAny(patterns, name)
+}
+
+// MatchesAny check if index name matches any of wildcard patterns
+func MatchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// qualify index name prefixed by cluster, like cross-cluster search
+func qualify(cluster, index string) string {
+	if cluster == "" {
+		return index
+	}
+	return cluster + ":" + index
+}
+
+// expandClusters replace every job with clusters by one job per named
+// cluster, labeled cluster: <name>; backups of each cluster go under
+// <s3_path>/<cluster>, so their artifacts never mix
+func expandClusters(cfg *Config) error {
+	for name := range cfg.Clusters {
+		if name == "" || strings.ContainsAny(name, ":/") {
+			return fmt.Errorf("cluster name %q must be non-empty without ':' or '/'", name)
+		}
+	}
+	fanOut := func(clusters []string, index string) error {
+		if len(clusters) == 0 {
+			return nil
+		}
+		seen := make(map[string]bool, len(clusters))
+		for _, name := range clusters {
+			if _, ok := cfg.Clusters[name]; !ok {
+				return fmt.Errorf("job %s: unknown cluster %q", index, name)
+			}
+			if seen[name] {
+				return fmt.Errorf("job %s: cluster %q listed twice", index, name)
+			}
+			seen[name] = true
+		}
+		return nil
+	}
+
+	var cleanups []CleanupJob
+	for _, job := range cfg.CleanupJobs {
+		if err := fanOut(job.Clusters, job.IndexName); err != nil {
+			return fmt.Errorf("cleanup %w", err)
+		}
+		if len(job.Clusters) == 0 {
+			cleanups = append(cleanups, job)
+			continue
+		}
+		for _, name := range job.Clusters {
+			one := job
+			one.Clusters, one.Cluster, one.Labels = nil, name, clusterLabels(job.Labels, name)
+			cleanups = append(cleanups, one)
+		}
+	}
+	cfg.CleanupJobs = cleanups
+
+	var backups []BackupJob
+	for _, job := range cfg.BackupJobs {
+		if err := fanOut(job.Clusters, job.IndexName); err != nil {
+			return fmt.Errorf("backup %w", err)
+		}
+		if len(job.Clusters) == 0 {
+			backups = append(backups, job)
+			continue
+		}
+		for _, name := range job.Clusters {
+			one := job
+			one.Clusters, one.Cluster, one.Labels = nil, name, clusterLabels(job.Labels, name)
+			one.S3Path = NormalizePrefix(job.S3Path) + name
+			backups = append(backups, one)
+		}
+	}
+	cfg.BackupJobs = backups
+	return nil
+}
+
+// clusterLabels copy of job labels with cluster label
+func clusterLabels(labels map[string]string, cluster string) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out["cluster"] = cluster
+	return out
+}
+
+// resolveTenants prepend tenant prefixes of job storage to s3_path of backup
+// jobs and check isolation: tenant prefixes may not contain each other, and no
+// two jobs of the same index may write under the same path of one storage
+func resolveTenants(cfg *Config) error {
+	for name, profile := range cfg.Storages {
+		if err := checkTenants(profile.Tenants); err != nil {
+			return fmt.Errorf("storage %s: %w", name, err)
+		}
+	}
+	if err := checkTenants(cfg.S3.Tenants); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for i := range cfg.BackupJobs {
+		job := &cfg.BackupJobs[i]
+		profile, err := cfg.StorageConfig(job.Storage)
+		if err != nil {
+			return fmt.Errorf("backup job %s: %w", job.IndexName, err)
+		}
+
+		if job.Tenant != "" {
+			prefix, ok := profile.Tenants[job.Tenant]
+			if !ok {
+				return fmt.Errorf("backup job %s: unknown tenant %q", job.IndexName, job.Tenant)
+			}
+			job.S3Path = NormalizePrefix(prefix) + strings.TrimPrefix(job.S3Path, "/")
+		} else {
+			for name, prefix := range profile.Tenants {
+				if strings.HasPrefix(NormalizePrefix(job.S3Path), NormalizePrefix(prefix)) {
+					return fmt.Errorf("backup job %s: s3_path %q is inside prefix of tenant %s, set tenant: %s", job.IndexName, job.S3Path, name, name)
+				}
+			}
+		}
+
+		key := job.Storage + "\x00" + NormalizePrefix(job.S3Path) + "\x00" + job.IndexName
+		if seen[key] {
+			return fmt.Errorf("backup jobs of index %s share s3_path %q", job.IndexName, job.S3Path)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// checkTenants tenant prefixes are set and do not contain each other
+func checkTenants(tenants map[string]string) error {
+	names := make([]string, 0, len(tenants))
+	for name, prefix := range tenants {
+		if NormalizePrefix(prefix) == "" {
+			return fmt.Errorf("tenant %s: empty prefix", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, a := range names {
+		for _, b := range names[i+1:] {
+			pa, pb := NormalizePrefix(tenants[a]), NormalizePrefix(tenants[b])
+			if strings.HasPrefix(pa, pb) || strings.HasPrefix(pb, pa) {
+				return fmt.Errorf("tenants %s and %s have overlapping prefixes %q and %q", a, b, pa, pb)
+			}
+		}
+	}
+	return nil
+}
+
+// durationUnits non-standard duration units supported in addition to time.ParseDuration
+var durationUnits = regexp.MustCompile(`(\d+(?:\.\d+)?)([dw])`)
+
+// ParseDuration parse duration string, additionally accepting days ("90d") and weeks ("2w")
+func ParseDuration(value string) (time.Duration, error) {
+	converted := durationUnits.ReplaceAllStringFunc(value, func(match string) string {
+		parts := durationUnits.FindStringSubmatch(match)
+		number, _ := strconv.ParseFloat(parts[1], 64)
+		hours := number * 24
+		if parts[2] == "w" {
+			hours *= 7
+		}
+		return strconv.FormatFloat(hours, 'f', -1, 64) + "h"
+	})
+
+	duration, err := time.ParseDuration(converted)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: %w", value, err)
+	}
+	return duration, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
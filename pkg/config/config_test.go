@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90d", 90 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"1d12h", 36 * time.Hour, false},
+		{"1w2d", 9 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"30m", 30 * time.Minute, false},
+		{"", 0, true},
+		{"d", 0, true},
+		{"10y", 0, true},
+		{"-5", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDuration(%q) = %v, want error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDuration(%q): %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	patterns := []string{"logs-*", "audit"}
+	for name, want := range map[string]bool{"logs-2026.03.10": true, "audit": true, "audit-1": false, "metrics": false} {
+		if got := MatchesAny(patterns, name); got != want {
+			t.Errorf("MatchesAny(%v, %s) = %v, want %v", patterns, name, got, want)
+		}
+	}
+	if MatchesAny(nil, "logs") {
+		t.Error("no patterns matched")
+	}
+}
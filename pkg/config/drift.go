@@ -0,0 +1,110 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Drift difference between running configuration and the file on disk
+type Drift struct {
+	Added    []string `json:"added,omitempty"`    // jobs only in the file
+	Removed  []string `json:"removed,omitempty"`  // jobs only in running configuration
+	Changed  []string `json:"changed,omitempty"`  // jobs with different settings
+	Sections []string `json:"sections,omitempty"` // other changed top-level sections (s3, admin, ...)
+}
+
+// Empty check if file matches running configuration
+func (d Drift) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && len(d.Sections) == 0
+}
+
+// String short summary for logs
+func (d Drift) String() string {
+	var parts []string
+	for _, p := range []struct {
+		name  string
+		items []string
+	}{{"added", d.Added}, {"removed", d.Removed}, {"changed", d.Changed}, {"sections", d.Sections}} {
+		if len(p.items) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s", p.name, strings.Join(p.items, ", ")))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ConfigPath path of configuration file read by LoadConfig
+func ConfigPath() string {
+	return getEnv("CONFIG_PATH", "/app/config/config.yaml")
+}
+
+// Diff compare running configuration with current one. Jobs are keyed by
+// type and index ("backup/logs", "backup/logs#2" for second job of same index)
+func Diff(running, current *Config) Drift {
+	var d Drift
+
+	was, now := jobSet(running), jobSet(current)
+	for key, settings := range now {
+		old, ok := was[key]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, key)
+		case old != settings:
+			d.Changed = append(d.Changed, key)
+		}
+	}
+	for key := range was {
+		if _, ok := now[key]; !ok {
+			d.Removed = append(d.Removed, key)
+		}
+	}
+
+	rv, cv := reflect.ValueOf(*running), reflect.ValueOf(*current)
+	for i := 0; i < rv.NumField(); i++ {
+		name := strings.Split(rv.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "cleanup_jobs" || name == "backup_jobs" {
+			continue
+		}
+		if marshal(rv.Field(i).Interface()) != marshal(cv.Field(i).Interface()) {
+			d.Sections = append(d.Sections, name)
+		}
+	}
+
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+// jobSet serialized settings of every job by key
+func jobSet(cfg *Config) map[string]string {
+	jobs := make(map[string]string)
+	add := func(jobType, index string, job interface{}) {
+		key := jobType + "/" + index
+		for n := 2; ; n++ {
+			if _, ok := jobs[key]; !ok {
+				break
+			}
+			key = fmt.Sprintf("%s/%s#%d", jobType, index, n)
+		}
+		jobs[key] = marshal(job)
+	}
+	for _, job := range cfg.CleanupJobs {
+		add("cleanup", job.Name(), job)
+	}
+	for _, job := range cfg.BackupJobs {
+		add("backup", job.Name(), job)
+	}
+	return jobs
+}
+
+func marshal(v interface{}) string {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%#v", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,98 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrIndexNotFound index, alias or data stream does not exist
+	ErrIndexNotFound = errors.New("index not found")
+	// ErrClusterUnavailable cluster is unreachable, overloaded or not healthy enough; retrying later may succeed
+	ErrClusterUnavailable = errors.New("cluster unavailable")
+	// ErrInvalidConfig job configuration is invalid; retrying never succeeds
+	ErrInvalidConfig = errors.New("invalid configuration")
+	// ErrSafetyGuard destructive run refused by safety limits
+	ErrSafetyGuard = errors.New("safety guard")
+	// ErrPartialFailure job finished but some indices, shards or chunks failed
+	ErrPartialFailure = errors.New("partial failure")
+	// ErrIncomplete backup holds fewer (or more) documents than the index for the same range
+	ErrIncomplete = errors.New("incomplete backup")
+	// ErrInterrupted run did not finish because the manager crashed or was killed
+	ErrInterrupted = errors.New("run interrupted")
+	// ErrRetryBudgetExhausted run spent its retry budget on a failing dependency
+	ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+	// ErrAgentUnavailable no remote agent claimed the run in time
+	ErrAgentUnavailable = errors.New("agent unavailable")
+	// ErrSignature manifest is unsigned, its signature does not match or its objects changed since signing
+	ErrSignature = errors.New("signature verification failed")
+	// ErrSkipped run did nothing on purpose, e.g. its index does not exist and the job is set to skip it
+	ErrSkipped = errors.New("run skipped")
+	// ErrPanic run was aborted by a panic in job code, a bug rather than an operational failure
+	ErrPanic = errors.New("panic")
+)
+
+// UploadError failed upload of an object to S3
+type UploadError struct {
+	Key       string
+	Retriable bool // network error, throttling or 5xx
+	Err       error
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("upload of %s failed: %v", e.Key, e.Err)
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+// Retriable check if error is temporary, so running job again later may succeed
+func Retriable(err error) bool {
+	if errors.Is(err, ErrClusterUnavailable) || errors.Is(err, ErrIncomplete) || errors.Is(err, ErrInterrupted) ||
+		errors.Is(err, ErrAgentUnavailable) {
+		return true
+	}
+	var uploadErr *UploadError
+	if errors.As(err, &uploadErr) {
+		return uploadErr.Retriable
+	}
+	return false
+}
+
+// Kind short classification of error for logs and notifications
+func Kind(err error) string {
+	var uploadErr *UploadError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrPanic):
+		return "panic"
+	case errors.Is(err, ErrRetryBudgetExhausted):
+		return "retry_budget_exhausted"
+	case errors.Is(err, ErrInvalidConfig):
+		return "invalid_config"
+	case errors.Is(err, ErrSafetyGuard):
+		return "safety_guard"
+	case errors.Is(err, ErrSignature):
+		return "signature_invalid"
+	case errors.Is(err, ErrIndexNotFound):
+		return "index_not_found"
+	case errors.Is(err, ErrClusterUnavailable):
+		return "cluster_unavailable"
+	case errors.As(err, &uploadErr):
+		return "upload_failed"
+	case errors.Is(err, ErrPartialFailure):
+		return "partial_failure"
+	case errors.Is(err, ErrIncomplete):
+		return "incomplete"
+	case errors.Is(err, ErrInterrupted):
+		return "interrupted"
+	case errors.Is(err, ErrAgentUnavailable):
+		return "agent_unavailable"
+	case errors.Is(err, ErrSkipped):
+		return "skipped"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Kinds of run lifecycle events
+const (
+	Queued   = "queued"
+	Started  = "started"
+	Progress = "progress"
+	Finished = "finished"
+	Failed   = "failed"
+	Skipped  = "skipped"
+)
+
+// subscriberBuffer events buffered per subscriber; a slower one misses events
+// instead of blocking job runs
+const subscriberBuffer = 64
+
+// Event lifecycle change of one job run
+type Event struct {
+	Kind      string    `json:"kind"`
+	Type      string    `json:"type"` // "cleanup", "backup" or "fire_drill"
+	Index     string    `json:"index"`
+	RunID     string    `json:"run_id"`
+	Time      time.Time `json:"time"`
+	Error     string    `json:"error,omitempty"`
+	ErrorKind string    `json:"error_kind,omitempty"`
+
+	// progress events only
+	Message string `json:"message,omitempty"`
+	Done    int    `json:"done,omitempty"`  // finished steps (periods, indices)
+	Total   int    `json:"total,omitempty"` // steps of the run
+}
+
+// Hub fan-out of run events to live subscribers (API streams).
+// A nil *Hub is valid and drops all events
+type Hub struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+// NewHub create hub without subscribers
+func NewHub() *Hub {
+	return &Hub{subs: make(map[chan Event]struct{})}
+}
+
+// Publish send event to every subscriber with room in its buffer
+func (h *Hub) Publish(ev Event) {
+	if h == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Subscribe channel of following events, cancel stops delivery and closes it
+func (h *Hub) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs, ch)
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+type runKey struct{}
+
+// runReporter hub and run of context progress events
+type runReporter struct {
+	hub *Hub
+	run Event
+}
+
+// WithRun attach run (type, index, run identifier) publishing progress to hub
+func WithRun(ctx context.Context, hub *Hub, run Event) context.Context {
+	return context.WithValue(ctx, runKey{}, runReporter{hub: hub, run: run})
+}
+
+// ReportProgress publish progress of context run, no-op outside of scheduled runs
+func ReportProgress(ctx context.Context, done, total int, message string) {
+	r, ok := ctx.Value(runKey{}).(runReporter)
+	if !ok {
+		return
+	}
+	ev := r.run
+	ev.Kind, ev.Done, ev.Total, ev.Message = Progress, done, total, message
+	r.hub.Publish(ev)
+}
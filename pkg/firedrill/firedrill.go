@@ -0,0 +1,265 @@
+// Package firedrill proves backups are restorable: it restores a random recent
+// backup into a scratch index, checks its counts against the manifest and
+// deletes the scratch index again.
+package firedrill
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/clock"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/restore"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultMaxAge backups of the last week are drilled when max_age is not set
+const defaultMaxAge = 7 * 24 * time.Hour
+
+// scratchPrefix prefix of generated scratch index names
+const scratchPrefix = "fire-drill-"
+
+// invalidIndexChars runs of characters not allowed in index names, with
+// surrounding dashes so "logs-*" does not end up as "logs--"
+var invalidIndexChars = regexp.MustCompile(`[^a-z0-9._]+`)
+
+// Service runs fire drills
+type Service struct {
+	client   opensearch.API // opensearch section, scratch cluster unless job has cluster
+	s3Client storage.Backend
+	clock    clock.Clock
+	config   *config.Config
+	// named storage profiles referenced by job storage
+	storages map[string]storage.Backend
+}
+
+// NewService create new fire drill service
+func NewService(client opensearch.API, s3Client storage.Backend, cfg *config.Config) *Service {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	return &Service{
+		client:   client,
+		s3Client: s3Client,
+		clock:    clock.Real{},
+		config:   cfg,
+	}
+}
+
+// AddStorage register named storage profile for jobs with storage: name
+func (s *Service) AddStorage(name string, backend storage.Backend) {
+	if s.storages == nil {
+		s.storages = make(map[string]storage.Backend)
+	}
+	s.storages[name] = backend
+}
+
+// Result outcome of one drill
+type Result struct {
+	Date         string // day of drilled backup
+	ScratchIndex string
+	Expected     int // documents recorded in manifest
+	Restored     int // documents indexed by restore
+	Counted      int // documents found in scratch index afterwards
+}
+
+// Drill restore a random backup of job index not older than max_age (or the
+// one of run override date) into a scratch index, compare restored and
+// counted documents with its manifest, then delete the scratch index
+func (s *Service) Drill(ctx context.Context, job config.FireDrillJob) (result Result, err error) {
+	ctx, runID := run.Ensure(ctx)
+	defer func() {
+		status := "success"
+		if err != nil {
+			status = "failure"
+		} else {
+			metrics.FireDrillLastSuccess.WithLabelValues(job.IndexName).SetToCurrentTime()
+		}
+		metrics.FireDrills.WithLabelValues(job.IndexName, status).Inc()
+	}()
+
+	s3Path, store, err := s.source(job)
+	if err != nil {
+		return result, err
+	}
+	day, err := s.pick(ctx, job, store, s3Path)
+	if err != nil {
+		return result, err
+	}
+	result.Date = day.Manifest.Date
+	result.Expected = day.Manifest.Documents
+
+	client := s.client
+	if job.Cluster != nil {
+		scratch, err := opensearch.NewClient(*job.Cluster)
+		if err != nil {
+			return result, fmt.Errorf("failed to create scratch cluster client: %w", err)
+		}
+		client = scratch
+	}
+
+	result.ScratchIndex = scratchIndex(job, runID)
+	if err := ensureAbsent(ctx, client, result.ScratchIndex); err != nil {
+		return result, err
+	}
+	log.Infof("Starting fire drill of %s: restoring backup of %s (%d documents) into %s (run %s)",
+		job.IndexName, result.Date, result.Expected, result.ScratchIndex, runID)
+
+	defer func() {
+		if err != nil && job.KeepOnFailure {
+			log.Warnf("Keeping scratch index %s of failed fire drill for inspection", result.ScratchIndex)
+			return
+		}
+		if _, delErr := client.GetClient().Indices.Delete(context.WithoutCancel(ctx), opensearchapi.IndicesDeleteReq{
+			Indices: []string{result.ScratchIndex},
+		}); delErr != nil {
+			log.Errorf("Failed to delete scratch index %s: %v", result.ScratchIndex, delErr)
+			if err == nil {
+				err = fmt.Errorf("failed to delete scratch index %s: %w", result.ScratchIndex, delErr)
+			}
+		}
+	}()
+
+	restored, err := restore.NewService(client, store, s.config).RestoreRange(ctx, restore.RangeRequest{
+		Index:       job.IndexName,
+		S3Path:      s3Path,
+		From:        day.Date,
+		To:          day.Date,
+		TargetIndex: result.ScratchIndex,
+	})
+	result.Restored = restored.Documents
+	if err != nil {
+		return result, fmt.Errorf("fire drill of %s for %s: %w", job.IndexName, result.Date, err)
+	}
+
+	if _, err := client.GetClient().Indices.Refresh(ctx, &opensearchapi.IndicesRefreshReq{
+		Indices: []string{result.ScratchIndex},
+	}); err != nil {
+		return result, fmt.Errorf("failed to refresh scratch index %s: %w", result.ScratchIndex, err)
+	}
+	count, err := client.GetClient().Indices.Count(ctx, &opensearchapi.IndicesCountReq{
+		Indices: []string{result.ScratchIndex},
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to count scratch index %s: %w", result.ScratchIndex, err)
+	}
+	result.Counted = count.Count
+
+	fields := log.Fields{
+		"run_id":   runID,
+		"date":     result.Date,
+		"expected": result.Expected,
+		"restored": result.Restored,
+		"counted":  result.Counted,
+	}
+	if result.Restored != result.Expected || result.Counted != result.Expected {
+		log.WithFields(fields).Errorf("Fire drill of %s failed: backup of %s does not restore to its manifest count", job.IndexName, result.Date)
+		return result, fmt.Errorf("%w: fire drill of %s for %s: manifest records %d documents, %d restored, %d counted",
+			errs.ErrIncomplete, job.IndexName, result.Date, result.Expected, result.Restored, result.Counted)
+	}
+	log.WithFields(fields).Infof("Fire drill of %s passed: backup of %s restored all %d documents", job.IndexName, result.Date, result.Expected)
+	return result, nil
+}
+
+// source backup path and storage of job, defaulting to the backup job of index
+// (on source cluster for fan-out jobs)
+func (s *Service) source(job config.FireDrillJob) (string, storage.Backend, error) {
+	s3Path, storageName := job.S3Path, job.Storage
+	for _, backup := range s.config.BackupJobs {
+		if backup.IndexName == job.IndexName && backup.Cluster == job.SourceCluster {
+			if s3Path == "" {
+				s3Path = backup.S3Path
+			}
+			if storageName == "" {
+				storageName = backup.Storage
+			}
+			break
+		}
+	}
+	if s3Path == "" {
+		return "", nil, fmt.Errorf("%w: fire drill of %s: no s3_path and no backup job of the index", errs.ErrInvalidConfig, job.IndexName)
+	}
+	if storageName == "" {
+		return s3Path, s.s3Client, nil
+	}
+	backend, ok := s.storages[storageName]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: fire drill of %s: unknown storage %q", errs.ErrInvalidConfig, job.IndexName, storageName)
+	}
+	return s3Path, backend, nil
+}
+
+// pick manifest to drill: of override date when set, otherwise a random one
+// with documents among the days of max_age
+func (s *Service) pick(ctx context.Context, job config.FireDrillJob, store storage.Backend, s3Path string) (manifest.Entry, error) {
+	maxAge := defaultMaxAge
+	if job.MaxAge != "" {
+		var err error
+		if maxAge, err = config.ParseDuration(job.MaxAge); err != nil || maxAge <= 0 {
+			return manifest.Entry{}, fmt.Errorf("%w: fire drill max_age %q", errs.ErrInvalidConfig, job.MaxAge)
+		}
+	}
+
+	entries, err := manifest.List(ctx, store, s3Path, job.IndexName)
+	if err != nil {
+		return manifest.Entry{}, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	if date := run.OverridesOf(ctx).Date; !date.IsZero() {
+		for _, entry := range entries {
+			if entry.Date.Equal(date) {
+				return entry, nil
+			}
+		}
+		return manifest.Entry{}, fmt.Errorf("no backup of %s for %s", job.IndexName, date.Format(manifest.DateLayout))
+	}
+
+	now := s.clock.Now().UTC()
+	oldest := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(-maxAge)
+	var candidates []manifest.Entry
+	for _, entry := range entries {
+		if !entry.Date.Before(oldest) && entry.Manifest.Documents > 0 {
+			candidates = append(candidates, entry)
+		}
+	}
+	if len(candidates) == 0 {
+		return manifest.Entry{}, fmt.Errorf("no backups of %s with documents since %s", job.IndexName, oldest.Format(manifest.DateLayout))
+	}
+	return candidates[rand.IntN(len(candidates))], nil
+}
+
+// scratchIndex configured scratch index or one named after index and run
+func scratchIndex(job config.FireDrillJob, runID string) string {
+	if job.ScratchIndex != "" {
+		return job.ScratchIndex
+	}
+	name := strings.ToLower(scratchPrefix + job.IndexName + "-" + runID)
+	return invalidIndexChars.ReplaceAllString(name, "-")
+}
+
+// ensureAbsent refuse to restore into an existing index, which the drill
+// would mix into and then delete
+func ensureAbsent(ctx context.Context, client opensearch.API, index string) error {
+	resp, err := client.GetClient().Indices.Exists(ctx, opensearchapi.IndicesExistsReq{
+		Indices: []string{index},
+	})
+	switch {
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		return nil
+	case resp != nil && resp.StatusCode == http.StatusOK:
+		return fmt.Errorf("%w: scratch index %s already exists", errs.ErrSafetyGuard, index)
+	}
+	return fmt.Errorf("failed to check scratch index %s: %w", index, err)
+}
@@ -0,0 +1,242 @@
+// Package health serves liveness and readiness endpoints reporting whether
+// the manager reaches OpenSearch and S3, so an orchestrator restarts or
+// drains it instead of every job failing silently
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Defaults of health section
+const (
+	DefaultInterval = 15 * time.Second
+	DefaultTimeout  = 5 * time.Second
+	DefaultGrace    = 5 * time.Minute
+)
+
+// probeKey object looked up to reach the bucket; it does not need to exist
+const probeKey = ".healthz"
+
+// Check connectivity check of one dependency, nil when it is reachable
+type Check func(ctx context.Context) error
+
+// OpenSearch check reaching cluster of client
+func OpenSearch(client opensearch.API) Check {
+	return func(ctx context.Context) error {
+		return client.Do(ctx, http.MethodGet, "/", nil, nil)
+	}
+}
+
+// Storage check reaching bucket of backend with its credentials; a missing
+// probe object is fine, denied or failed requests are not
+func Storage(backend storage.Backend) Check {
+	return func(ctx context.Context) error {
+		_, err := backend.Exists(ctx, probeKey)
+		return err
+	}
+}
+
+// Status last check result of a dependency
+type Status struct {
+	Up      bool      `json:"up"`
+	Error   string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked"`
+	// Since start of current state, i.e. of the outage when down
+	Since time.Time `json:"since"`
+}
+
+// Checker checks dependencies every interval and serves their last status
+type Checker struct {
+	listen                   string
+	interval, timeout, grace time.Duration
+	names                    []string
+	checks                   map[string]Check
+
+	mu     sync.RWMutex
+	status map[string]Status
+}
+
+// New checker of health section, nil without listen address
+func New(cfg config.HealthConfig) (*Checker, error) {
+	if cfg.Listen == "" {
+		return nil, nil
+	}
+	c := &Checker{
+		listen:   cfg.Listen,
+		interval: DefaultInterval,
+		timeout:  DefaultTimeout,
+		grace:    DefaultGrace,
+		checks:   make(map[string]Check),
+		status:   make(map[string]Status),
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"interval", cfg.Interval, &c.interval},
+		{"timeout", cfg.Timeout, &c.timeout},
+		{"grace", cfg.Grace, &c.grace},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := config.ParseDuration(field.value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%w: health.%s %q", errs.ErrInvalidConfig, field.name, field.value)
+		}
+		*field.dst = d
+	}
+	return c, nil
+}
+
+// Add register check of dependency name
+func (c *Checker) Add(name string, check Check) {
+	if c == nil {
+		return
+	}
+	c.names = append(c.names, name)
+	c.checks[name] = check
+}
+
+// Serve check dependencies and serve /healthz and /readyz until ctx is cancelled
+func (c *Checker) Serve(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	go c.loop(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", c.serveLive)
+	mux.HandleFunc("GET /readyz", c.serveReady)
+	server := &http.Server{Addr: c.listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Infof("Serving health checks on %s/healthz and %s/readyz", c.listen, c.listen)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Errorf("Health server failed: %v", err)
+	}
+}
+
+func (c *Checker) loop(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.checkAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkAll run all checks concurrently, so one hanging dependency does not
+// delay the status of the others
+func (c *Checker) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, name := range c.names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			err := c.checks[name](checkCtx)
+			cancel()
+			if ctx.Err() == nil {
+				c.record(name, err)
+			}
+		}(name)
+	}
+	wg.Wait()
+}
+
+func (c *Checker) record(name string, err error) {
+	now := time.Now().UTC()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, seen := c.status[name]
+	status := Status{Up: err == nil, Checked: now, Since: now}
+	if seen && prev.Up == status.Up {
+		status.Since = prev.Since
+	}
+	if err != nil {
+		status.Error = err.Error()
+		if !seen || prev.Up {
+			log.Warnf("Health check of %s failed: %v", name, err)
+		}
+	} else if seen && !prev.Up {
+		log.Infof("Health check of %s recovered after %s", name, now.Sub(prev.Since).Round(time.Second))
+	}
+	c.status[name] = status
+
+	up := 0.0
+	if status.Up {
+		up = 1
+	}
+	metrics.DependencyUp.WithLabelValues(name).Set(up)
+}
+
+// report status of every dependency and whether all pass ok
+func (c *Checker) report(ok func(Status, bool) bool) (map[string]Status, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	report := make(map[string]Status, len(c.names))
+	passed := true
+	for _, name := range c.names {
+		status, seen := c.status[name]
+		if seen {
+			report[name] = status
+		}
+		passed = passed && ok(status, seen)
+	}
+	return report, passed
+}
+
+// serveLive 200 unless a dependency has been unreachable longer than grace,
+// so a short outage does not restart the process
+func (c *Checker) serveLive(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	report, passed := c.report(func(status Status, seen bool) bool {
+		return !seen || status.Up || now.Sub(status.Since) < c.grace
+	})
+	writeReport(w, report, passed)
+}
+
+// serveReady 200 once every dependency passed its last check
+func (c *Checker) serveReady(w http.ResponseWriter, r *http.Request) {
+	report, passed := c.report(func(status Status, seen bool) bool {
+		return seen && status.Up
+	})
+	writeReport(w, report, passed)
+}
+
+func writeReport(w http.ResponseWriter, report map[string]Status, passed bool) {
+	body := struct {
+		Status       string            `json:"status"`
+		Dependencies map[string]Status `json:"dependencies"`
+	}{Status: "ok", Dependencies: report}
+	code := http.StatusOK
+	if !passed {
+		body.Status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
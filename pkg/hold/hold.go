@@ -0,0 +1,356 @@
+// Package hold keeps legal holds: indices, tenant documents and S3 artifacts
+// that cleanup and backup retention must not delete until the hold is
+// released. Every placed and released hold is appended to an audit log.
+package hold
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+// Hold kinds
+const (
+	KindIndex    = "index"    // indices matching Index are not cleaned up
+	KindTenant   = "tenant"   // documents with Field = Value in indices matching Index are not deleted
+	KindArtifact = "artifact" // S3 objects under Key of Storage are not pruned by retention
+)
+
+// Audit actions
+const (
+	ActionPlace   = "place"
+	ActionRelease = "release"
+)
+
+const (
+	holdsFile = "holds.json"
+	auditFile = "audit.ndjson"
+)
+
+// ErrUnknownHold release of hold that is not active
+var ErrUnknownHold = errors.New("unknown legal hold")
+
+// Hold single legal hold
+type Hold struct {
+	ID       string    `json:"id"`
+	Kind     string    `json:"kind"`
+	Index    string    `json:"index,omitempty"`   // index, data stream or pattern; scope of tenant hold, empty for all
+	Field    string    `json:"field,omitempty"`   // tenant field, e.g. "tenant.id"
+	Value    string    `json:"value,omitempty"`   // tenant field value
+	Key      string    `json:"key,omitempty"`     // artifact key or key prefix
+	Storage  string    `json:"storage,omitempty"` // storage profile of artifact, empty for the s3 section
+	Reason   string    `json:"reason"`            // case or ticket reference
+	PlacedBy string    `json:"placed_by"`
+	PlacedAt time.Time `json:"placed_at"`
+}
+
+// String short description of held target for logs
+func (h Hold) String() string {
+	switch h.Kind {
+	case KindTenant:
+		scope := h.Index
+		if scope == "" {
+			scope = "*"
+		}
+		return fmt.Sprintf("%s %s=%s in %s (%s)", h.ID, h.Field, h.Value, scope, h.Reason)
+	case KindArtifact:
+		return fmt.Sprintf("%s %s (%s)", h.ID, h.Key, h.Reason)
+	}
+	return fmt.Sprintf("%s %s (%s)", h.ID, h.Index, h.Reason)
+}
+
+// validate required fields of hold kind
+func (h Hold) validate() error {
+	if h.Reason == "" {
+		return fmt.Errorf("%w: legal hold needs a reason", errs.ErrInvalidConfig)
+	}
+	switch h.Kind {
+	case KindIndex:
+		if h.Index == "" {
+			return fmt.Errorf("%w: index hold needs index", errs.ErrInvalidConfig)
+		}
+	case KindTenant:
+		if h.Field == "" || h.Value == "" {
+			return fmt.Errorf("%w: tenant hold needs field and value", errs.ErrInvalidConfig)
+		}
+	case KindArtifact:
+		if h.Key == "" {
+			return fmt.Errorf("%w: artifact hold needs key", errs.ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: hold kind %q must be %q, %q or %q", errs.ErrInvalidConfig, h.Kind, KindIndex, KindTenant, KindArtifact)
+	}
+	if h.Index != "" {
+		if _, err := path.Match(h.Index, ""); err != nil {
+			return fmt.Errorf("%w: hold index pattern %q: %v", errs.ErrInvalidConfig, h.Index, err)
+		}
+	}
+	return nil
+}
+
+// AuditRecord one line of audit log
+type AuditRecord struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"` // place or release
+	Actor  string    `json:"actor"`  // admin token name or OS user of CLI
+	Reason string    `json:"reason,omitempty"`
+	Hold   Hold      `json:"hold"`
+}
+
+// Registry directory with active holds and their audit log, re-read on every
+// call so holds placed through CLI apply to a running manager.
+// A nil *Registry is valid and holds nothing (legal holds disabled)
+type Registry struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// New registry of config, nil when no directory is configured
+func New(cfg config.LegalHoldConfig) *Registry {
+	if cfg.Dir == "" {
+		return nil
+	}
+	return &Registry{dir: cfg.Dir}
+}
+
+// List active holds, oldest first
+func (r *Registry) List() (Set, error) {
+	if r == nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(r.dir, holdsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legal holds: %w", err)
+	}
+	var holds Set
+	if err := json.Unmarshal(data, &holds); err != nil {
+		return nil, fmt.Errorf("invalid legal holds file: %w", err)
+	}
+	return holds, nil
+}
+
+// Place add hold by actor and audit it
+func (r *Registry) Place(h Hold, actor string) (Hold, error) {
+	if r == nil {
+		return Hold{}, fmt.Errorf("%w: legal holds are disabled, set legal_hold.dir", errs.ErrInvalidConfig)
+	}
+	if err := h.validate(); err != nil {
+		return Hold{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	holds, err := r.List()
+	if err != nil {
+		return Hold{}, err
+	}
+	h.ID = "hold-" + run.NewID()
+	h.PlacedBy = actor
+	h.PlacedAt = time.Now().UTC()
+	// Audited first: a hold never exists without its record
+	if err := r.audit(AuditRecord{Time: h.PlacedAt, Action: ActionPlace, Actor: actor, Hold: h}); err != nil {
+		return Hold{}, err
+	}
+	if err := r.save(append(holds, h)); err != nil {
+		return Hold{}, err
+	}
+	log.WithFields(log.Fields{"hold": h.ID, "kind": h.Kind, "actor": actor}).Warnf("Legal hold placed: %s", h)
+	return h, nil
+}
+
+// Release remove hold by actor and audit it
+func (r *Registry) Release(id, actor, reason string) (Hold, error) {
+	if r == nil {
+		return Hold{}, fmt.Errorf("%w: %s", ErrUnknownHold, id)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	holds, err := r.List()
+	if err != nil {
+		return Hold{}, err
+	}
+	for i, h := range holds {
+		if h.ID != id {
+			continue
+		}
+		if err := r.audit(AuditRecord{Time: time.Now().UTC(), Action: ActionRelease, Actor: actor, Reason: reason, Hold: h}); err != nil {
+			return Hold{}, err
+		}
+		if err := r.save(append(holds[:i:i], holds[i+1:]...)); err != nil {
+			return Hold{}, err
+		}
+		log.WithFields(log.Fields{"hold": h.ID, "kind": h.Kind, "actor": actor}).Warnf("Legal hold released: %s", h)
+		return h, nil
+	}
+	return Hold{}, fmt.Errorf("%w: %s", ErrUnknownHold, id)
+}
+
+// Audit every audit record, oldest first
+func (r *Registry) Audit() ([]AuditRecord, error) {
+	if r == nil {
+		return nil, nil
+	}
+	file, err := os.Open(filepath.Join(r.dir, auditFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legal hold audit log: %w", err)
+	}
+	defer file.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid legal hold audit record: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// audit append record to audit log
+func (r *Registry) audit(record AuditRecord) error {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create legal hold directory: %w", err)
+	}
+	file, err := os.OpenFile(filepath.Join(r.dir, auditFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open legal hold audit log: %w", err)
+	}
+	defer file.Close()
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write legal hold audit log: %w", err)
+	}
+	return file.Sync()
+}
+
+// save replace holds file atomically, so readers never see a partial one
+func (r *Registry) save(holds Set) error {
+	sort.SliceStable(holds, func(a, b int) bool { return holds[a].PlacedAt.Before(holds[b].PlacedAt) })
+	data, err := json.MarshalIndent(holds, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(r.dir, ".holds-*")
+	if err != nil {
+		return fmt.Errorf("failed to write legal holds: %w", err)
+	}
+	if _, err := tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(r.dir, holdsFile))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write legal holds: %w", err)
+	}
+	return nil
+}
+
+// Set active holds
+type Set []Hold
+
+// Index first index hold matching any of names
+func (s Set) Index(names ...string) (Hold, bool) {
+	for _, h := range s {
+		if h.Kind != KindIndex {
+			continue
+		}
+		for _, name := range names {
+			if matches(h.Index, name) {
+				return h, true
+			}
+		}
+	}
+	return Hold{}, false
+}
+
+// Tenants tenant holds scoped to index
+func (s Set) Tenants(index string) []Hold {
+	var tenants []Hold
+	for _, h := range s {
+		if h.Kind == KindTenant && (h.Index == "" || overlaps(h.Index, index)) {
+			tenants = append(tenants, h)
+		}
+	}
+	return tenants
+}
+
+// Artifact first artifact hold covering key of storage
+func (s Set) Artifact(storage, key string) (Hold, bool) {
+	for _, h := range s {
+		if h.Kind == KindArtifact && h.Storage == storage && strings.HasPrefix(key, h.Key) {
+			return h, true
+		}
+	}
+	return Hold{}, false
+}
+
+// Prefix first artifact hold of storage on any key under prefix
+func (s Set) Prefix(storage, prefix string) (Hold, bool) {
+	for _, h := range s {
+		if h.Kind == KindArtifact && h.Storage == storage && (strings.HasPrefix(h.Key, prefix) || strings.HasPrefix(prefix, h.Key)) {
+			return h, true
+		}
+	}
+	return Hold{}, false
+}
+
+// SuspendsRetention first index or tenant hold on data backed up by a job of
+// index (name or pattern); its artifacts may hold that data, so retention of
+// the job is suspended
+func (s Set) SuspendsRetention(index string) (Hold, bool) {
+	for _, h := range s {
+		switch h.Kind {
+		case KindIndex:
+			if overlaps(h.Index, index) {
+				return h, true
+			}
+		case KindTenant:
+			if h.Index == "" || overlaps(h.Index, index) {
+				return h, true
+			}
+		}
+	}
+	return Hold{}, false
+}
+
+// matches check if name matches hold pattern
+func matches(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok || pattern == name
+}
+
+// overlaps check if hold pattern and job pattern may select the same index
+func overlaps(pattern, index string) bool {
+	return matches(pattern, index) || matches(index, pattern)
+}
@@ -0,0 +1,143 @@
+package hold
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+)
+
+func TestSetMatching(t *testing.T) {
+	holds := Set{
+		{ID: "h1", Kind: KindIndex, Index: "audit-*"},
+		{ID: "h2", Kind: KindTenant, Index: "logs-*", Field: "tenant.id", Value: "acme"},
+		{ID: "h3", Kind: KindTenant, Field: "user.id", Value: "42"},
+		{ID: "h4", Kind: KindArtifact, Key: "backups/logs/2026-03-"},
+		{ID: "h5", Kind: KindArtifact, Storage: "cold", Key: "archive/"},
+	}
+
+	index := []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"audit-2026.03.10"}, "h1"},
+		{[]string{"logs-2026.03.10", "audit-2026.03.10"}, "h1"},
+		{[]string{"audit-*"}, "h1"}, // pattern given literally
+		{[]string{"logs-2026.03.10"}, ""},
+	}
+	for _, tt := range index {
+		h, ok := holds.Index(tt.names...)
+		if ok != (tt.want != "") || h.ID != tt.want {
+			t.Errorf("Index(%v) = %s %v, want %q", tt.names, h.ID, ok, tt.want)
+		}
+	}
+
+	tenants := map[string][]string{
+		"logs-2026.03.10": {"h2", "h3"},
+		"logs-*":          {"h2", "h3"},
+		"metrics":         {"h3"},
+	}
+	for name, want := range tenants {
+		got := holds.Tenants(name)
+		if len(got) != len(want) {
+			t.Errorf("Tenants(%s) = %v, want %v", name, got, want)
+			continue
+		}
+		for i := range got {
+			if got[i].ID != want[i] {
+				t.Errorf("Tenants(%s)[%d] = %s, want %s", name, i, got[i].ID, want[i])
+			}
+		}
+	}
+
+	artifacts := []struct {
+		storage, key string
+		want         string
+	}{
+		{"", "backups/logs/2026-03-10.json.gz", "h4"},
+		{"", "backups/logs/2026-02-10.json.gz", ""},
+		{"cold", "backups/logs/2026-03-10.json.gz", ""}, // other storage
+		{"cold", "archive/logs/2026-03-10.json.gz", "h5"},
+	}
+	for _, tt := range artifacts {
+		h, ok := holds.Artifact(tt.storage, tt.key)
+		if ok != (tt.want != "") || h.ID != tt.want {
+			t.Errorf("Artifact(%q, %s) = %s %v, want %q", tt.storage, tt.key, h.ID, ok, tt.want)
+		}
+	}
+	// Prefixes above and below the held key both count
+	for prefix, want := range map[string]bool{"backups/": true, "backups/logs/2026-03-10": true, "backups/metrics/": false} {
+		if _, ok := holds.Prefix("", prefix); ok != want {
+			t.Errorf("Prefix(%s) = %v, want %v", prefix, ok, want)
+		}
+	}
+}
+
+func TestSuspendsRetention(t *testing.T) {
+	tests := []struct {
+		name  string
+		holds Set
+		index string
+		want  bool
+	}{
+		{"index hold on job index", Set{{Kind: KindIndex, Index: "logs-2026.03.10"}}, "logs-*", true},
+		{"index hold pattern", Set{{Kind: KindIndex, Index: "logs-*"}}, "logs-2026.03.10", true},
+		{"unrelated index hold", Set{{Kind: KindIndex, Index: "audit"}}, "logs-*", false},
+		{"unscoped tenant hold", Set{{Kind: KindTenant, Field: "tenant.id", Value: "acme"}}, "metrics", true},
+		{"scoped tenant hold elsewhere", Set{{Kind: KindTenant, Index: "audit", Field: "tenant.id", Value: "acme"}}, "logs-*", false},
+		{"artifact hold", Set{{Kind: KindArtifact, Key: "logs/"}}, "logs-*", false},
+	}
+	for _, tt := range tests {
+		if _, got := tt.holds.SuspendsRetention(tt.index); got != tt.want {
+			t.Errorf("%s: SuspendsRetention(%s) = %v, want %v", tt.name, tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := New(config.LegalHoldConfig{Dir: t.TempDir()})
+
+	if _, err := r.Place(Hold{Kind: KindIndex, Index: "logs"}, "alice"); !errors.Is(err, errs.ErrInvalidConfig) {
+		t.Errorf("hold without reason: error %v, want invalid config", err)
+	}
+	if _, err := r.Place(Hold{Kind: KindTenant, Field: "tenant.id", Reason: "case 1"}, "alice"); !errors.Is(err, errs.ErrInvalidConfig) {
+		t.Errorf("tenant hold without value: error %v, want invalid config", err)
+	}
+
+	placed, err := r.Place(Hold{Kind: KindIndex, Index: "logs-*", Reason: "case 1"}, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if placed.ID == "" || placed.PlacedBy != "alice" || placed.PlacedAt.IsZero() {
+		t.Errorf("placed hold = %+v", placed)
+	}
+	holds, err := r.List()
+	if err != nil || len(holds) != 1 || holds[0].ID != placed.ID {
+		t.Fatalf("List = %v, %v, want the placed hold", holds, err)
+	}
+
+	if _, err := r.Release("hold-unknown", "bob", "done"); !errors.Is(err, ErrUnknownHold) {
+		t.Errorf("release of unknown hold: error %v", err)
+	}
+	if _, err := r.Release(placed.ID, "bob", "case closed"); err != nil {
+		t.Fatal(err)
+	}
+	if holds, _ := r.List(); len(holds) != 0 {
+		t.Errorf("holds after release = %v", holds)
+	}
+
+	records, err := r.Audit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Action != ActionPlace || records[1].Action != ActionRelease ||
+		records[1].Actor != "bob" || records[1].Reason != "case closed" {
+		t.Errorf("audit = %+v", records)
+	}
+
+	var disabled *Registry
+	if holds, err := disabled.List(); holds != nil || err != nil {
+		t.Errorf("disabled registry listed %v, %v", holds, err)
+	}
+}
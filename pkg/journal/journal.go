@@ -0,0 +1,134 @@
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// entrySuffix file extension of journal entries
+const entrySuffix = ".json"
+
+// Entry run in flight; its file exists from run start until completion,
+// so entries found at startup belong to runs interrupted by a crash
+type Entry struct {
+	RunID    string    `json:"run_id"`
+	Type     string    `json:"type"` // "cleanup", "backup" or "fire_drill"
+	Index    string    `json:"index"`
+	Schedule string    `json:"schedule"`
+	Started  time.Time `json:"started"`
+	PID      int       `json:"pid"`
+	// Overridden manual run with one-off overrides, never resumed
+	Overridden bool `json:"overridden,omitempty"`
+}
+
+// Journal directory of entries of in-flight runs.
+// A nil *Journal is valid and records nothing (journal disabled)
+type Journal struct {
+	dir string
+}
+
+// Open create journal in dir, returns nil when dir is empty
+func Open(dir string) (*Journal, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	return &Journal{dir: dir}, nil
+}
+
+// Begin record start of run; written atomically, so a crash never leaves
+// a partial entry
+func (j *Journal) Begin(e Entry) error {
+	if j == nil {
+		return nil
+	}
+	if e.Started.IsZero() {
+		e.Started = time.Now().UTC()
+	}
+	if e.PID == 0 {
+		e.PID = os.Getpid()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(j.dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), j.path(e.RunID))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	return nil
+}
+
+// Complete mark run finished (successfully or not) by removing its entry
+func (j *Journal) Complete(runID string) error {
+	if j == nil {
+		return nil
+	}
+	if err := os.Remove(j.path(runID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to complete journal entry: %w", err)
+	}
+	return nil
+}
+
+// Interrupted entries of runs that never completed, oldest first; call at
+// startup before any run begins
+func (j *Journal) Interrupted() ([]Entry, error) {
+	if j == nil {
+		return nil, nil
+	}
+
+	files, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	var entries []Entry
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, entrySuffix) {
+			// Temporary file of a Begin cut short by the crash
+			if strings.HasPrefix(name, ".entry-") {
+				os.Remove(filepath.Join(j.dir, name))
+			}
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(j.dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read journal entry %s: %w", name, err)
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("invalid journal entry %s: %w", name, err)
+		}
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(a, b int) bool { return entries[a].Started.Before(entries[b].Started) })
+	return entries, nil
+}
+
+func (j *Journal) path(runID string) string {
+	return filepath.Join(j.dir, runID+entrySuffix)
+}
@@ -0,0 +1,130 @@
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+)
+
+// CatalogSuffix object name suffix of index catalog, appended to index name
+const CatalogSuffix = ".index.json"
+
+// Catalog compact list of every backup of an index under one S3 path, kept
+// next to the backups, so restores and retention find them without a LIST
+// over thousands of objects
+type Catalog struct {
+	Index   string         `json:"index"`
+	Updated time.Time      `json:"updated"`
+	Backups []CatalogEntry `json:"backups"` // oldest first
+}
+
+// CatalogEntry backup of catalog with its manifest
+type CatalogEntry struct {
+	Key      string   `json:"key"` // manifest key
+	Manifest Manifest `json:"manifest"`
+}
+
+// CatalogKey S3 key of index catalog under S3 path
+func CatalogKey(s3Path, index string) string {
+	return pathPrefix(s3Path) + index + CatalogSuffix
+}
+
+// LoadCatalog read index catalog, found is false when it was not written yet
+func LoadCatalog(ctx context.Context, store storage.Backend, s3Path, index string) (*Catalog, bool, error) {
+	key := CatalogKey(s3Path, index)
+	exists, err := store.Exists(ctx, key)
+	if err != nil || !exists {
+		return nil, false, err
+	}
+
+	data, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	var catalog Catalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, false, fmt.Errorf("failed to decode catalog %s: %w", key, err)
+	}
+	return &catalog, true, nil
+}
+
+// Record add manifest of finished backup to index catalog, replacing entry
+// with the same manifest key; a missing catalog is first rebuilt from the
+// manifests already under the path
+func Record(ctx context.Context, store storage.Backend, s3Path, key string, m Manifest, now time.Time) error {
+	entries, err := List(ctx, store, s3Path, m.Index)
+	if err != nil {
+		return err
+	}
+	date, err := time.Parse(DateLayout, m.Date)
+	if err != nil {
+		return fmt.Errorf("invalid manifest date %q: %w", m.Date, err)
+	}
+
+	replaced := false
+	for i := range entries {
+		if entries[i].Key == key {
+			entries[i] = Entry{Key: key, Manifest: m, Date: date}
+			replaced = true
+		}
+	}
+	if !replaced {
+		entries = append(entries, Entry{Key: key, Manifest: m, Date: date})
+	}
+	sortEntries(entries)
+	return saveCatalog(ctx, store, s3Path, m.Index, entries, now)
+}
+
+// Forget remove backups with given manifest keys from index catalog
+func Forget(ctx context.Context, store storage.Backend, s3Path, index string, keys []string, now time.Time) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	entries, err := List(ctx, store, s3Path, index)
+	if err != nil {
+		return err
+	}
+
+	drop := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		drop[key] = true
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if !drop[entry.Key] {
+			kept = append(kept, entry)
+		}
+	}
+	return saveCatalog(ctx, store, s3Path, index, kept, now)
+}
+
+func saveCatalog(ctx context.Context, store storage.Backend, s3Path, index string, entries []Entry, now time.Time) error {
+	catalog := Catalog{Index: index, Updated: now.UTC(), Backups: make([]CatalogEntry, 0, len(entries))}
+	for _, entry := range entries {
+		catalog.Backups = append(catalog.Backups, CatalogEntry{Key: entry.Key, Manifest: entry.Manifest})
+	}
+	data, err := json.Marshal(catalog)
+	if err != nil {
+		return fmt.Errorf("failed to encode catalog: %w", err)
+	}
+	if err := store.Put(ctx, CatalogKey(s3Path, index), data, nil); err != nil {
+		return fmt.Errorf("failed to upload catalog: %w", err)
+	}
+	return nil
+}
+
+func (c *Catalog) entries() ([]Entry, error) {
+	entries := make([]Entry, 0, len(c.Backups))
+	for _, backup := range c.Backups {
+		date, err := time.Parse(DateLayout, backup.Manifest.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date of %s in catalog: %w", backup.Key, err)
+		}
+		entries = append(entries, Entry{Key: backup.Key, Manifest: backup.Manifest, Date: date})
+	}
+	sortEntries(entries)
+	return entries, nil
+}
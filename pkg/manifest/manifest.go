@@ -0,0 +1,178 @@
+// Package manifest describes finished daily backups stored next to their artifacts
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+)
+
+// Suffix object name suffix of manifest, appended to artifact name without extension
+const Suffix = ".manifest.json"
+
+// DateLayout layout of Manifest.Date
+const DateLayout = "2006-01-02"
+
+// FormatVersion version of manifest and artifact layout written by this
+// release; bump it whenever restore needs to read artifacts differently
+//
+//	1  releases before versioning: no format_version, json artifacts mix raw
+//	   search responses and _source lines
+//	2  format_version in manifests and artifact metadata, artifacts hold
+//	   exactly the recorded format
+const FormatVersion = 2
+
+// HourLayout layout of Manifest.Hours keys, start of hour in UTC
+const HourLayout = time.RFC3339
+
+// S3 user metadata keys stamped on every artifact
+const (
+	MetadataFormat        = "format"
+	MetadataFormatVersion = "format-version"
+	MetadataCompression   = "compression"     // "zstd", absent for gzip
+	MetadataDictionary    = "zstd-dictionary" // S3 key of zstd dictionary the artifact was compressed with
+)
+
+// Manifest summary of a daily backup, uploaded next to its artifacts
+type Manifest struct {
+	FormatVersion int               `json:"format_version,omitempty"` // 0 in manifests of version 1
+	Index         string            `json:"index"`
+	Date          string            `json:"date"` // day of data, DateLayout
+	RunID         string            `json:"run_id"`
+	Format        string            `json:"format"`
+	Encrypted     bool              `json:"encrypted"`
+	Compression   string            `json:"compression,omitempty"` // "zstd", empty for gzip
+	Dictionary    string            `json:"dictionary,omitempty"`  // S3 key of zstd dictionary
+	Objects       []string          `json:"objects"`               // S3 keys of artifact or chunks
+	Extras        map[string]string `json:"extras,omitempty"`      // S3 key of artifact of every extra format, by format
+	Mapping       string            `json:"mapping,omitempty"`     // S3 key of archived index mapping
+	Documents     int               `json:"documents"`
+	Duplicates    int               `json:"duplicates,omitempty"` // documents dropped by dedup
+	Expected      int               `json:"expected,omitempty"`   // day count of index, when completeness is checked
+	Incomplete    bool              `json:"incomplete,omitempty"` // documents did not match expected
+	Partial       bool              `json:"partial,omitempty"`    // some periods failed and were skipped (allow_partial)
+	FailedPeriods []int             `json:"failed_periods,omitempty"`
+	Hours         map[string]int    `json:"hours,omitempty"`     // exported documents per hour (HourLayout), absent in older manifests
+	Bytes         int64             `json:"bytes,omitempty"`     // total size of objects
+	Checksums     map[string]string `json:"checksums,omitempty"` // S3 ETag of every object
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// Version layout version of manifest and its artifacts
+func (m Manifest) Version() int {
+	if m.FormatVersion == 0 {
+		return 1
+	}
+	return m.FormatVersion
+}
+
+// Keys S3 keys of all objects of the backup: artifact or chunks, then extra
+// format artifacts ordered by format, then archived mapping
+func (m Manifest) Keys() []string {
+	keys := append([]string(nil), m.Objects...)
+	formats := make([]string, 0, len(m.Extras))
+	for format := range m.Extras {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	for _, format := range formats {
+		keys = append(keys, m.Extras[format])
+	}
+	if m.Mapping != "" {
+		keys = append(keys, m.Mapping)
+	}
+	return keys
+}
+
+// HourCount documents recorded for hours starting in [from, to), so partial
+// restores and verification can size a time range without reading artifacts;
+// ok is false when the manifest has no histogram
+func (m Manifest) HourCount(from, to time.Time) (count int, ok bool) {
+	if m.Hours == nil {
+		return 0, m.Documents == 0
+	}
+	for hour, docs := range m.Hours {
+		t, err := time.Parse(HourLayout, hour)
+		if err != nil {
+			continue
+		}
+		if !t.Before(from) && t.Before(to) {
+			count += docs
+		}
+	}
+	return count, true
+}
+
+// Entry manifest found in storage
+type Entry struct {
+	Key      string // manifest key
+	Manifest Manifest
+	Date     time.Time
+}
+
+// List manifests of index backups under S3 path, oldest first: from catalog
+// of the index when there is one, otherwise by listing the path; several
+// jobs may share one path, so manifests of other indices are skipped
+func List(ctx context.Context, store storage.Backend, s3Path, index string) ([]Entry, error) {
+	catalog, found, err := LoadCatalog(ctx, store, s3Path, index)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return catalog.entries()
+	}
+	return scan(ctx, store, s3Path, index)
+}
+
+// scan find manifests of index by listing every object under S3 path
+func scan(ctx context.Context, store storage.Backend, s3Path, index string) ([]Entry, error) {
+	keys, err := store.List(ctx, pathPrefix(s3Path))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, key := range keys {
+		if !strings.HasSuffix(key, Suffix) {
+			continue
+		}
+
+		data, err := store.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest %s: %w", key, err)
+		}
+		if m.Index != index {
+			continue
+		}
+		date, err := time.Parse(DateLayout, m.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date in manifest %s: %w", key, err)
+		}
+
+		entries = append(entries, Entry{Key: key, Manifest: m, Date: date})
+	}
+
+	sortEntries(entries)
+	return entries, nil
+}
+
+// pathPrefix S3 path as listing prefix with trailing slash
+func pathPrefix(s3Path string) string {
+	if s3Path != "" && !strings.HasSuffix(s3Path, "/") {
+		return s3Path + "/"
+	}
+	return s3Path
+}
+
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date.Before(entries[j].Date) })
+}
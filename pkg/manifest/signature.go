@@ -0,0 +1,147 @@
+package manifest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// SignatureSuffix object name suffix of detached manifest signature, appended
+// to manifest key; it holds the raw 64 byte ed25519 signature of the manifest
+// object, so `openssl pkeyutl -verify -rawin` checks it as well
+const SignatureSuffix = ".sig"
+
+// SignatureKey S3 key of signature of manifest key
+func SignatureKey(key string) string {
+	return key + SignatureSuffix
+}
+
+// LoadSigningKey read ed25519 private key from PKCS#8 PEM file, as written by
+// `openssl genpkey -algorithm ed25519`
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	parsed, err := readPEM(path, "PRIVATE KEY", x509.ParsePKCS8PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not an ed25519 private key", errs.ErrInvalidConfig, path)
+	}
+	return key, nil
+}
+
+// LoadPublicKeys read ed25519 public keys from PKIX PEM files, as written by
+// `openssl pkey -pubout`
+func LoadPublicKeys(paths []string) ([]ed25519.PublicKey, error) {
+	keys := make([]ed25519.PublicKey, 0, len(paths))
+	for _, path := range paths {
+		parsed, err := readPEM(path, "PUBLIC KEY", x509.ParsePKIXPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not an ed25519 public key", errs.ErrInvalidConfig, path)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func readPEM(path, blockType string, parse func([]byte) (any, error)) (any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errs.ErrInvalidConfig, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%w: %s holds no %s PEM block", errs.ErrInvalidConfig, path, blockType)
+	}
+	parsed, err := parse(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errs.ErrInvalidConfig, path, err)
+	}
+	return parsed, nil
+}
+
+// Verifier checks manifests against their signatures before their backups
+// are restored; a nil Verifier accepts every manifest
+type Verifier struct {
+	keys          []ed25519.PublicKey
+	allowUnsigned bool
+}
+
+// NewVerifier verifier of signatures by any of keys, nil without keys;
+// allowUnsigned accepts manifests written before signing was enabled
+func NewVerifier(keys []ed25519.PublicKey, allowUnsigned bool) *Verifier {
+	if len(keys) == 0 {
+		return nil
+	}
+	return &Verifier{keys: keys, allowUnsigned: allowUnsigned}
+}
+
+// Verify read manifest object of entry and its signature, check the signature
+// and that every object still has the checksum the signed manifest records;
+// returns the signed manifest, which may differ from the catalog copy in entry
+func (v *Verifier) Verify(ctx context.Context, store storage.Backend, entry Entry) (Manifest, error) {
+	if v == nil {
+		return entry.Manifest, nil
+	}
+
+	signed, err := store.Exists(ctx, SignatureKey(entry.Key))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to check signature of %s: %w", entry.Key, err)
+	}
+	if !signed {
+		if v.allowUnsigned {
+			log.Warnf("Manifest %s is not signed, restoring it unverified", entry.Key)
+			return entry.Manifest, nil
+		}
+		return Manifest{}, fmt.Errorf("%w: manifest %s is not signed", errs.ErrSignature, entry.Key)
+	}
+
+	data, err := store.Get(ctx, entry.Key)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read manifest %s: %w", entry.Key, err)
+	}
+	signature, err := store.Get(ctx, SignatureKey(entry.Key))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read signature of %s: %w", entry.Key, err)
+	}
+	if !v.valid(data, signature) {
+		return Manifest{}, fmt.Errorf("%w: signature of manifest %s does not match", errs.ErrSignature, entry.Key)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Manifest{}, fmt.Errorf("failed to decode manifest %s: %w", entry.Key, err)
+	}
+	// Objects are covered through their checksums, a rewritten object gets a new ETag
+	for _, key := range m.Keys() {
+		info, err := store.Stat(ctx, key)
+		if err != nil {
+			return Manifest{}, fmt.Errorf("failed to read checksum of %s: %w", key, err)
+		}
+		if want, ok := m.Checksums[key]; !ok || info.ETag != want {
+			return Manifest{}, fmt.Errorf("%w: %s has checksum %q, signed manifest %s records %q", errs.ErrSignature, key, info.ETag, entry.Key, want)
+		}
+	}
+	return m, nil
+}
+
+func (v *Verifier) valid(data, signature []byte) bool {
+	for _, key := range v.keys {
+		if ed25519.Verify(key, data, signature) {
+			return true
+		}
+	}
+	return false
+}
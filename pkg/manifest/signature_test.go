@@ -0,0 +1,122 @@
+package manifest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+)
+
+// memStore objects and ETags of a bucket; other Backend methods are not used
+type memStore struct {
+	storage.Backend
+	objects map[string][]byte
+	etags   map[string]string
+}
+
+func (m *memStore) Exists(_ context.Context, key string) (bool, error) {
+	_, ok := m.objects[key]
+	return ok, nil
+}
+
+func (m *memStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("no such key %s", key)
+	}
+	return data, nil
+}
+
+func (m *memStore) Stat(_ context.Context, key string) (storage.ObjectInfo, error) {
+	if _, ok := m.etags[key]; !ok {
+		return storage.ObjectInfo{}, fmt.Errorf("no such key %s", key)
+	}
+	return storage.ObjectInfo{ETag: m.etags[key]}, nil
+}
+
+func TestVerify(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	otherPublic, otherPrivate, _ := ed25519.GenerateKey(nil)
+
+	const key = "backups/logs/2026-03-10.manifest.json"
+	signed := Manifest{
+		Index:     "logs",
+		Date:      "2026-03-10",
+		Objects:   []string{"backups/logs/2026-03-10.json.gz"},
+		Documents: 100,
+		Checksums: map[string]string{"backups/logs/2026-03-10.json.gz": "etag-1"},
+	}
+	data, _ := json.Marshal(signed)
+	// Catalog copy may be stale, Verify returns the signed manifest
+	entry := Entry{Key: key, Manifest: Manifest{Index: "logs", Documents: 99}}
+
+	store := func(change func(*memStore)) *memStore {
+		s := &memStore{
+			objects: map[string][]byte{key: data, SignatureKey(key): ed25519.Sign(private, data)},
+			etags:   map[string]string{"backups/logs/2026-03-10.json.gz": "etag-1"},
+		}
+		if change != nil {
+			change(s)
+		}
+		return s
+	}
+	tests := []struct {
+		name      string
+		verifier  *Verifier
+		store     *memStore
+		documents int  // of returned manifest
+		wantErr   bool // signature error
+	}{
+		{"valid", NewVerifier([]ed25519.PublicKey{public}, false), store(nil), 100, false},
+		{"any of keys", NewVerifier([]ed25519.PublicKey{otherPublic, public}, false), store(nil), 100, false},
+		{"other key", NewVerifier([]ed25519.PublicKey{otherPublic}, false), store(nil), 0, true},
+		{"signed by other key", NewVerifier([]ed25519.PublicKey{public}, false),
+			store(func(s *memStore) { s.objects[SignatureKey(key)] = ed25519.Sign(otherPrivate, data) }), 0, true},
+		{"manifest changed", NewVerifier([]ed25519.PublicKey{public}, false),
+			store(func(s *memStore) { s.objects[key] = append(append([]byte(nil), data[:len(data)-1]...), ' ', '}') }), 0, true},
+		{"object rewritten", NewVerifier([]ed25519.PublicKey{public}, false),
+			store(func(s *memStore) { s.etags["backups/logs/2026-03-10.json.gz"] = "etag-2" }), 0, true},
+		{"unsigned", NewVerifier([]ed25519.PublicKey{public}, false),
+			store(func(s *memStore) { delete(s.objects, SignatureKey(key)) }), 0, true},
+		{"unsigned allowed", NewVerifier([]ed25519.PublicKey{public}, true),
+			store(func(s *memStore) { delete(s.objects, SignatureKey(key)) }), 99, false},
+		{"no keys", NewVerifier(nil, false), store(nil), 99, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tt.verifier.Verify(context.Background(), tt.store, entry)
+			if tt.wantErr {
+				if !errors.Is(err, errs.ErrSignature) {
+					t.Fatalf("error %v, want signature error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Documents != tt.documents {
+				t.Errorf("documents = %d, want %d", m.Documents, tt.documents)
+			}
+		})
+	}
+}
+
+func TestVerifyUnlistedObject(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	const key = "backups/logs/2026-03-10.manifest.json"
+	// Object without recorded checksum cannot be vouched for
+	data, _ := json.Marshal(Manifest{Index: "logs", Objects: []string{"backups/logs/2026-03-10.json.gz"}})
+	s := &memStore{
+		objects: map[string][]byte{key: data, SignatureKey(key): ed25519.Sign(private, data)},
+		etags:   map[string]string{"backups/logs/2026-03-10.json.gz": "etag-1"},
+	}
+	_, err := NewVerifier([]ed25519.PublicKey{public}, false).Verify(context.Background(), s, Entry{Key: key})
+	if !errors.Is(err, errs.ErrSignature) {
+		t.Errorf("error %v, want signature error", err)
+	}
+}
@@ -0,0 +1,191 @@
+// Package metrics Prometheus metrics of the manager, served on /metrics
+package metrics
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultListen address of metrics endpoint
+const DefaultListen = ":9090"
+
+const namespace = "opensearch_backup"
+
+var (
+	// StorageRequestDuration latency of every S3 HTTP request by operation
+	// (put_object, upload_part, head_object, ...) and status code
+	StorageRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of S3 requests by operation and status code.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 16),
+	}, []string{"operation", "code"})
+
+	// StorageBytes bytes sent to and received from S3
+	StorageBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "bytes_total",
+		Help:      "Bytes sent to (direction=sent) and received from (direction=received) S3.",
+	}, []string{"direction"})
+
+	// UploadThroughput average speed of finished uploads
+	UploadThroughput = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "upload_bytes_per_second",
+		Help:      "Average speed of finished uploads by mode (file, stream, put).",
+		Buckets:   prometheus.ExponentialBuckets(64<<10, 2, 14),
+	}, []string{"mode"})
+
+	// UploadParts multipart part count of finished uploads
+	UploadParts = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "upload_parts",
+		Help:      "Parts of finished uploads, 1 for single PUT.",
+		Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+	})
+
+	// UploadQueueBytes size of artifacts queued for the upload window
+	UploadQueueBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "upload_queue_bytes",
+		Help:      "Bytes of artifacts queued locally for the upload window.",
+	})
+
+	// UploadQueueArtifacts artifacts queued for the upload window
+	UploadQueueArtifacts = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "upload_queue_artifacts",
+		Help:      "Artifacts queued locally for the upload window.",
+	})
+
+	// UploadRetries failed upload attempts that were retried
+	UploadRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "storage",
+		Name:      "upload_retries_total",
+		Help:      "Failed upload attempts that were retried.",
+	})
+)
+
+// SlowRequests OpenSearch requests slower than slow_log threshold by operation
+var SlowRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "opensearch",
+	Name:      "slow_requests_total",
+	Help:      "OpenSearch search, scroll and delete_by_query requests slower than the slow_log threshold.",
+}, []string{"operation"})
+
+// LoadDeferrals checks of load_gate that deferred a job by reason
+var LoadDeferrals = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "opensearch",
+	Name:      "load_deferrals_total",
+	Help:      "Job starts deferred because node CPU or search queue was above load_gate thresholds.",
+}, []string{"reason"})
+
+// RateLimitWait time OpenSearch requests waited for rate_limit by cluster host
+var RateLimitWait = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "opensearch",
+	Name:      "rate_limit_wait_seconds_total",
+	Help:      "Time OpenSearch requests waited for the cluster rate limit.",
+}, []string{"host"})
+
+// AgentQueuedRuns runs waiting for a remote agent to claim them by agent name
+var AgentQueuedRuns = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: "agent",
+	Name:      "queued_runs",
+	Help:      "Runs of jobs with agent waiting for a remote agent to claim them.",
+}, []string{"agent"})
+
+// Fire times of registered cron entries by job type, index and schedule
+var (
+	ScheduleNextRun = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "schedule",
+		Name:      "next_run_timestamp_seconds",
+		Help:      "Unix time of the next scheduled run of each cron entry.",
+	}, []string{"type", "index", "schedule"})
+
+	SchedulePreviousRun = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "schedule",
+		Name:      "previous_run_timestamp_seconds",
+		Help:      "Unix time of the last scheduled run of each cron entry since start, 0 before the first.",
+	}, []string{"type", "index", "schedule"})
+)
+
+// Outcomes of scheduled and triggered job runs by type, job name and cluster
+// (empty for the opensearch section); fan-out jobs report one series per cluster
+var (
+	JobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "runs_total",
+		Help:      "Job runs by type, job, cluster and status (success, failure).",
+	}, []string{"type", "job", "cluster", "status"})
+
+	JobLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful run by type, job and cluster.",
+	}, []string{"type", "job", "cluster"})
+)
+
+// Outcomes of fire drills (restores of random recent backups) by index
+var (
+	FireDrills = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "fire_drill",
+		Name:      "runs_total",
+		Help:      "Fire drills by backed up index and status (success, failure).",
+	}, []string{"index", "status"})
+
+	FireDrillLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "fire_drill",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last fire drill that restored a backup with matching counts.",
+	}, []string{"index"})
+)
+
+// ConfigDrift 1 while configuration file differs from running configuration
+var ConfigDrift = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "config_drift",
+	Help:      "1 when the configuration file changed since it was loaded and is not applied.",
+})
+
+// DependencyUp 1 while last connectivity check of dependency succeeded
+var DependencyUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "dependency_up",
+	Help:      "1 when the last health check reached the dependency (opensearch, s3), 0 when it failed.",
+}, []string{"dependency"})
+
+// Serve expose registered metrics on /metrics until process exits
+func Serve(listen string) {
+	if listen == "" {
+		listen = DefaultListen
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	log.Infof("Serving metrics on %s/metrics", listen)
+	if err := http.ListenAndServe(listen, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Errorf("Metrics server failed: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+// Package naming renders artifact object names from per-job templates, shared
+// by backup and everything that has to find artifacts again
+package naming
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultTemplate legacy MM-DD-YY-index names
+const DefaultTemplate = `{{.Date.Format "01-02-06"}}-{{.Index}}{{with .Part}}-{{.}}{{end}}`
+
+// Vars values available to template
+type Vars struct {
+	Index   string
+	Date    time.Time // day of data
+	ISODate string    // Date as 2006-01-02
+	RunID   string
+	Period  int    // 1-based period of day, 0 for daily artifact
+	Part    string // "N" or "N-partM" for period files, empty for daily artifact
+}
+
+// Template parsed object name template, name without extension
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse parse template, empty text means DefaultTemplate
+func Parse(text string) (*Template, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+
+	tmpl, err := template.New("filename").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filename template: %w", err)
+	}
+	t := &Template{tmpl: tmpl}
+
+	// Render both kinds of names once, so broken templates fail before export
+	sample := time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)
+	artifact, err := t.Artifact("index", sample, "run")
+	if err != nil {
+		return nil, err
+	}
+	part, err := t.PartFile("index", sample, "run", 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	if artifact == part {
+		return nil, fmt.Errorf("invalid filename template: period files must differ from artifact (use .Part or .Period)")
+	}
+
+	return t, nil
+}
+
+// Artifact name of daily artifact
+func (t *Template) Artifact(index string, date time.Time, runID string) (string, error) {
+	return t.Name(vars(index, date, runID, 0, ""))
+}
+
+// PartFile name of period file, part > 1 for spilled parts
+func (t *Template) PartFile(index string, date time.Time, runID string, period, part int) (string, error) {
+	suffix := strconv.Itoa(period)
+	if part > 1 {
+		suffix += "-part" + strconv.Itoa(part)
+	}
+	return t.Name(vars(index, date, runID, period, suffix))
+}
+
+// Name render template, result is validated as relative object path
+func (t *Template) Name(v Vars) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, v); err != nil {
+		return "", fmt.Errorf("failed to render filename template: %w", err)
+	}
+
+	name := b.String()
+	if name == "" || strings.HasPrefix(name, "/") || path.Clean(name) != name || strings.HasPrefix(name, "..") {
+		return "", fmt.Errorf("filename template rendered invalid name %q", name)
+	}
+	return name, nil
+}
+
+func vars(index string, date time.Time, runID string, period int, part string) Vars {
+	return Vars{
+		Index:   index,
+		Date:    date,
+		ISODate: date.Format("2006-01-02"),
+		RunID:   runID,
+		Period:  period,
+		Part:    part,
+	}
+}
@@ -0,0 +1,191 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ChannelSlack   = "slack"   // Slack incoming webhook
+	ChannelWebhook = "webhook" // JSON POST of Event
+
+	sendTimeout = 10 * time.Second
+)
+
+// Event outcome of one job run
+type Event struct {
+	Type      string            `json:"type"` // "cleanup", "backup" or "maintenance"
+	Job       string            `json:"job"`
+	RunID     string            `json:"run_id"`
+	Status    string            `json:"status"` // "success", "skipped" or "failure"
+	Error     string            `json:"error,omitempty"`
+	ErrorKind string            `json:"error_kind,omitempty"`
+	Retriable bool              `json:"retriable"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// NewEvent event of finished run, failed when err is set, skipped when it
+// is errs.ErrSkipped
+func NewEvent(eventType, job, runID string, labels map[string]string, err error) Event {
+	ev := Event{Type: eventType, Job: job, RunID: runID, Status: "success", Labels: labels}
+	if err != nil {
+		ev.Status = "failure"
+		if errors.Is(err, errs.ErrSkipped) {
+			ev.Status = "skipped"
+		}
+		ev.Error = err.Error()
+		ev.ErrorKind = errs.Kind(err)
+		ev.Retriable = errs.Retriable(err)
+	}
+	return ev
+}
+
+// Notifier sends run events to channels chosen by job labels.
+// A nil *Notifier is valid and drops all events (notifications disabled).
+type Notifier struct {
+	channels  map[string]config.NotificationChannel
+	routes    []config.NotificationRoute
+	defaults  []string
+	onSuccess bool
+	client    *http.Client
+}
+
+// New create notifier, returns nil when no channel is configured
+func New(cfg config.NotificationsConfig) (*Notifier, error) {
+	if len(cfg.Channels) == 0 {
+		return nil, nil
+	}
+
+	for name, ch := range cfg.Channels {
+		if ch.Type != ChannelSlack && ch.Type != ChannelWebhook {
+			return nil, fmt.Errorf("%w: notification channel %s: unknown type %q", errs.ErrInvalidConfig, name, ch.Type)
+		}
+		if ch.URL == "" {
+			return nil, fmt.Errorf("%w: notification channel %s: url is required", errs.ErrInvalidConfig, name)
+		}
+	}
+	known := func(names []string, where string) error {
+		for _, name := range names {
+			if _, ok := cfg.Channels[name]; !ok {
+				return fmt.Errorf("%w: %s: unknown notification channel %q", errs.ErrInvalidConfig, where, name)
+			}
+		}
+		return nil
+	}
+	for i, route := range cfg.Routes {
+		if err := known(route.Channels, fmt.Sprintf("route #%d", i+1)); err != nil {
+			return nil, err
+		}
+	}
+	if err := known(cfg.Default, "default"); err != nil {
+		return nil, err
+	}
+
+	return &Notifier{
+		channels:  cfg.Channels,
+		routes:    cfg.Routes,
+		defaults:  cfg.Default,
+		onSuccess: cfg.OnSuccess,
+		client:    &http.Client{Timeout: sendTimeout},
+	}, nil
+}
+
+// Notify send event to its channels; delivery errors are only logged,
+// so broken notifications never fail a job
+func (n *Notifier) Notify(ctx context.Context, ev Event) {
+	if n == nil || (ev.Status == "success" && !n.onSuccess) {
+		return
+	}
+
+	for _, name := range n.channelsFor(ev.Labels) {
+		if err := n.send(ctx, n.channels[name], ev); err != nil {
+			log.WithField("run_id", ev.RunID).Warnf("Failed to notify channel %s about %s of %s: %v", name, ev.Type, ev.Job, err)
+		}
+	}
+}
+
+// channelsFor channels of first route matching labels (and following ones
+// while routes have continue), default channels when none match
+func (n *Notifier) channelsFor(labels map[string]string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	matched := false
+	for _, route := range n.routes {
+		if !matches(route.Match, labels) {
+			continue
+		}
+		matched = true
+		for _, name := range route.Channels {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		if !route.Continue {
+			break
+		}
+	}
+	if !matched {
+		return n.defaults
+	}
+	return names
+}
+
+// matches check if labels contain every pair of match
+func matches(match, labels map[string]string) bool {
+	for key, value := range match {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// send post event to channel in its payload format
+func (n *Notifier) send(ctx context.Context, ch config.NotificationChannel, ev Event) error {
+	var payload interface{} = ev
+	if ch.Type == ChannelSlack {
+		payload = map[string]string{"text": slackText(ev)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ch.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// slackText one-line message of event
+func slackText(ev Event) string {
+	switch ev.Status {
+	case "success":
+		return fmt.Sprintf(":white_check_mark: %s of `%s` succeeded (run %s)", ev.Type, ev.Job, ev.RunID)
+	case "skipped":
+		return fmt.Sprintf(":warning: %s of `%s` skipped (run %s, %s): %s", ev.Type, ev.Job, ev.RunID, ev.ErrorKind, ev.Error)
+	}
+	return fmt.Sprintf(":x: %s of `%s` failed (run %s, %s, retriable: %t): %s",
+		ev.Type, ev.Job, ev.RunID, ev.ErrorKind, ev.Retriable, ev.Error)
+}
@@ -0,0 +1,23 @@
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+)
+
+// API операции кластера, которые используют сервисы; реализуется *Client,
+// в тестах может быть подменен оберткой или клиентом к тестовому серверу
+type API interface {
+	GetClient() *opensearchapi.Client
+	Do(ctx context.Context, method, path string, body io.Reader, result interface{}) error
+	DoRaw(ctx context.Context, method, path string, body io.Reader, dst *bytes.Buffer) error
+	ResolveIndices(ctx context.Context, pattern string) ([]string, error)
+	WaitForHealthy(ctx context.Context, gate config.HealthGate) error
+	WaitForLoad(ctx context.Context, gate config.LoadGate) error
+}
+
+var _ API = (*Client)(nil)
@@ -0,0 +1,263 @@
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/opensearch-project/opensearch-go/v4"
+	"github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+)
+
+// Client обертка над OpenSearch клиентом
+type Client struct {
+	client *opensearchapi.Client
+}
+
+// NewClient создает новый OpenSearch API клиент
+func NewClient(cfg config.OpenSearchConfig) (*Client, error) {
+	osConfig := opensearch.Config{
+		Addresses: cfg.Addresses,
+		Username:  cfg.Username,
+		Password:  cfg.Password,
+	}
+
+	// Настройка TLS если указан сертификат
+	if cfg.CertPath != "" {
+		caCert, err := os.ReadFile(cfg.CertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate: %w", err)
+		}
+
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed to parse certificate")
+		}
+
+		osConfig.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs: caCertPool,
+			},
+		}
+	}
+
+	// Логирование медленных запросов выгрузки и удаления
+	transport, err := newSlowLogTransport(osConfig.Transport, cfg.SlowLog)
+	if err != nil {
+		return nil, err
+	}
+	osConfig.Transport = transport
+
+	// Ограничение частоты снаружи slow_log, ожидание токена не считается медленным запросом
+	if osConfig.Transport, err = newRateLimitTransport(osConfig.Transport, cfg.RateLimit); err != nil {
+		return nil, err
+	}
+
+	// Создаем opensearchapi клиент
+	client, err := opensearchapi.NewClient(opensearchapi.Config{
+		Client: osConfig,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenSearch API client: %w", err)
+	}
+
+	return &Client{client: client}, nil
+}
+
+// GetClient возвращает opensearchapi клиент
+func (c *Client) GetClient() *opensearchapi.Client {
+	return c.client
+}
+
+// rawRequest запрос к API, для которого нет готовой обертки в opensearchapi
+type rawRequest struct {
+	method string
+	path   string
+	body   io.Reader
+}
+
+func (r rawRequest) GetRequest() (*http.Request, error) {
+	return opensearch.BuildRequest(r.method, r.path, r.body, nil, nil)
+}
+
+// Do выполняет произвольный запрос и декодирует JSON ответ в result (если не nil)
+func (c *Client) Do(ctx context.Context, method, path string, body io.Reader, result interface{}) error {
+	resp, err := c.client.Client.Do(ctx, rawRequest{method: method, path: path, body: body}, result)
+	if err != nil {
+		return Classify(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		if result != nil {
+			return Classify(&StatusError{Status: resp.StatusCode, Err: opensearch.ParseError(resp)})
+		}
+		return Classify(&StatusError{Status: resp.StatusCode, Err: fmt.Errorf("status: %s", resp.Status())})
+	}
+
+	return nil
+}
+
+// DoRaw выполняет произвольный запрос и дописывает тело ответа в dst без
+// декодирования, чтобы вызывающий мог переиспользовать буферы
+func (c *Client) DoRaw(ctx context.Context, method, path string, body io.Reader, dst *bytes.Buffer) error {
+	resp, err := c.client.Client.Do(ctx, rawRequest{method: method, path: path, body: body}, nil)
+	if err != nil {
+		return Classify(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.IsError() {
+		return Classify(&StatusError{Status: resp.StatusCode, Err: opensearch.ParseError(resp)})
+	}
+
+	if _, err := dst.ReadFrom(resp.Body); err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	return nil
+}
+
+// StatusError ошибка API вместе с HTTP статусом ответа
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %v", e.Status, e.Err)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// ResponseStatus HTTP статус ошибки API, 0 если статус неизвестен
+func ResponseStatus(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status
+	}
+	var structErr *opensearch.StructError
+	if errors.As(err, &structErr) {
+		return structErr.Status
+	}
+	var stringErr *opensearch.StringError
+	if errors.As(err, &stringErr) {
+		return stringErr.Status
+	}
+	return 0
+}
+
+// ErrorBody тело ответа API с ошибочным статусом, которое клиент не распознал
+// как ошибку OpenSearch (нет полей error/status), например результат
+// _delete_by_query со статусом 500 и списком failures; nil, если тела нет
+func ErrorBody(err error) []byte {
+	var stringErr *opensearch.StringError
+	if !errors.As(err, &stringErr) {
+		return nil
+	}
+	return []byte(stringErr.Err)
+}
+
+// ResolveIndices разворачивает шаблоны (wildcard, список через запятую), алиасы
+// и data stream'ы в отсортированный список конкретных открытых индексов.
+// Системные индексы (начинающиеся с точки) пропускаются, если не указаны явно.
+func (c *Client) ResolveIndices(ctx context.Context, pattern string) ([]string, error) {
+	var patterns []string
+	for _, p := range strings.Split(pattern, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("%w: empty index pattern", errs.ErrInvalidConfig)
+	}
+
+	resp, err := c.client.Indices.Resolve(ctx, opensearchapi.IndicesResolveReq{
+		Indices: patterns,
+		Params: opensearchapi.IndicesResolveParams{
+			ExpandWildcards: "open",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve indices %s: %w", pattern, Classify(err))
+	}
+
+	explicit := make(map[string]bool, len(patterns))
+	for _, p := range patterns {
+		explicit[p] = true
+	}
+
+	seen := make(map[string]bool)
+	add := func(name string, direct bool) {
+		if seen[name] {
+			return
+		}
+		if direct && strings.HasPrefix(name, ".") && !explicit[name] {
+			return
+		}
+		seen[name] = true
+	}
+
+	for _, index := range resp.Indices {
+		add(index.Name, true)
+	}
+	for _, alias := range resp.Aliases {
+		for _, index := range alias.Indices {
+			add(index, false)
+		}
+	}
+	for _, stream := range resp.DataStreams {
+		for _, index := range stream.BackingIndices {
+			add(index, false)
+		}
+	}
+
+	indices := make([]string, 0, len(seen))
+	for name := range seen {
+		indices = append(indices, name)
+	}
+	sort.Strings(indices)
+
+	return indices, nil
+}
+
+// Classify оборачивает ошибку API в типизированную (errs.ErrIndexNotFound,
+// errs.ErrClusterUnavailable), чтобы вызывающие могли отличать временные ошибки
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var structErr *opensearch.StructError
+	if errors.As(err, &structErr) && structErr.Err.Type == "index_not_found_exception" {
+		return fmt.Errorf("%w: %w", errs.ErrIndexNotFound, err)
+	}
+
+	switch ResponseStatus(err) {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %w", errs.ErrIndexNotFound, err)
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return fmt.Errorf("%w: %w", errs.ErrClusterUnavailable, err)
+	}
+
+	// Сетевые ошибки: узел недоступен, соединение сброшено, таймаут
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return fmt.Errorf("%w: %w", errs.ErrClusterUnavailable, err)
+	}
+
+	return err
+}
@@ -0,0 +1,118 @@
+package opensearch
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultHealthMaxWait = time.Hour
+	defaultHealthBackoff = time.Minute
+	maxHealthBackoff     = 15 * time.Minute
+)
+
+// WaitForHealthy ждет, пока кластер не станет пригодным для деструктивных операций
+// (нужный статус, нет перемещаемых/инициализируемых шардов и активных снапшотов).
+// Между проверками задержка удваивается, по истечении max_wait возвращается ошибка.
+func (c *Client) WaitForHealthy(ctx context.Context, gate config.HealthGate) error {
+	if !gate.Enabled {
+		return nil
+	}
+	return waitReady(ctx, "health_gate", gate.MaxWait, gate.Backoff, func() (string, error) {
+		return c.checkHealth(ctx, gate.MinStatus)
+	})
+}
+
+// waitReady повторяет check, пока он не вернет пустую причину; name - секция
+// конфигурации max_wait и backoff для сообщений об ошибках
+func waitReady(ctx context.Context, name, maxWaitValue, backoffValue string, check func() (string, error)) error {
+	maxWait := defaultHealthMaxWait
+	if maxWaitValue != "" {
+		d, err := config.ParseDuration(maxWaitValue)
+		if err != nil {
+			return fmt.Errorf("%w: %s.max_wait: %w", errs.ErrInvalidConfig, name, err)
+		}
+		maxWait = d
+	}
+
+	delay := defaultHealthBackoff
+	if backoffValue != "" {
+		d, err := config.ParseDuration(backoffValue)
+		if err != nil {
+			return fmt.Errorf("%w: %s.backoff: %w", errs.ErrInvalidConfig, name, err)
+		}
+		delay = d
+	}
+
+	deadline := time.Now().Add(maxWait)
+	for {
+		reason, err := check()
+		if err != nil {
+			reason = err.Error()
+		}
+		if reason == "" {
+			return nil
+		}
+
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("%w: not ready after %v: %s", errs.ErrClusterUnavailable, maxWait, reason)
+		}
+
+		if err := run.Retry(ctx, delay); err != nil {
+			return fmt.Errorf("%w: %w: %s", errs.ErrClusterUnavailable, err, reason)
+		}
+
+		log.Warnf("Cluster not ready (%s), deferring for %v", reason, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxHealthBackoff {
+			delay = maxHealthBackoff
+		}
+	}
+}
+
+// checkHealth возвращает причину, по которой кластер не готов, или пустую строку
+func (c *Client) checkHealth(ctx context.Context, minStatus string) (string, error) {
+	health, err := c.client.Cluster.Health(ctx, &opensearchapi.ClusterHealthReq{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get cluster health: %w", Classify(err))
+	}
+
+	switch {
+	case health.Status == "red":
+		return "cluster status is red", nil
+	case health.Status == "yellow" && minStatus != "yellow":
+		return "cluster status is yellow", nil
+	case health.RelocatingShards > 0:
+		return fmt.Sprintf("%d shards relocating", health.RelocatingShards), nil
+	case health.InitializingShards > 0:
+		return fmt.Sprintf("%d shards initializing", health.InitializingShards), nil
+	}
+
+	// Активные снапшоты во всех репозиториях
+	var snapshots struct {
+		Snapshots []struct {
+			Snapshot string `json:"snapshot"`
+		} `json:"snapshots"`
+	}
+	if err := c.Do(ctx, "GET", "/_snapshot/_status", nil, &snapshots); err != nil {
+		return "", fmt.Errorf("failed to get snapshot status: %w", err)
+	}
+	if len(snapshots.Snapshots) > 0 {
+		return fmt.Sprintf("snapshot %s in progress", snapshots.Snapshots[0].Snapshot), nil
+	}
+
+	return "", nil
+}
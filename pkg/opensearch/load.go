@@ -0,0 +1,79 @@
+package opensearch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+)
+
+const (
+	defaultMaxCPUPercent  = 80
+	defaultMaxSearchQueue = 100
+)
+
+// nodeStatsPath статистика узлов, нужная проверке нагрузки
+const nodeStatsPath = "/_nodes/stats/os,thread_pool?filter_path=nodes.*.name,nodes.*.os.cpu.percent,nodes.*.thread_pool.search.queue"
+
+// WaitForLoad откладывает тяжелое задание, пока загрузка CPU или очередь поиска
+// какого-либо узла выше порогов, чтобы задания уступали пользовательскому трафику.
+// Задержка между проверками удваивается, как у WaitForHealthy
+func (c *Client) WaitForLoad(ctx context.Context, gate config.LoadGate) error {
+	if !gate.Enabled {
+		return nil
+	}
+	maxCPU, maxQueue := gate.MaxCPUPercent, gate.MaxSearchQueue
+	if maxCPU <= 0 {
+		maxCPU = defaultMaxCPUPercent
+	}
+	if maxQueue <= 0 {
+		maxQueue = defaultMaxSearchQueue
+	}
+	return waitReady(ctx, "load_gate", gate.MaxWait, gate.Backoff, func() (string, error) {
+		return c.checkLoad(ctx, maxCPU, maxQueue)
+	})
+}
+
+// checkLoad возвращает причину отсрочки по самому загруженному узлу или пустую строку
+func (c *Client) checkLoad(ctx context.Context, maxCPU, maxQueue int) (string, error) {
+	var stats struct {
+		Nodes map[string]struct {
+			Name string `json:"name"`
+			OS   struct {
+				CPU struct {
+					Percent int `json:"percent"`
+				} `json:"cpu"`
+			} `json:"os"`
+			ThreadPool struct {
+				Search struct {
+					Queue int `json:"queue"`
+				} `json:"search"`
+			} `json:"thread_pool"`
+		} `json:"nodes"`
+	}
+	if err := c.Do(ctx, "GET", nodeStatsPath, nil, &stats); err != nil {
+		metrics.LoadDeferrals.WithLabelValues("error").Inc()
+		return "", fmt.Errorf("failed to get node stats: %w", err)
+	}
+
+	// Узлы по порядку, чтобы причина была стабильной между проверками
+	ids := make([]string, 0, len(stats.Nodes))
+	for id := range stats.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		node := stats.Nodes[id]
+		switch {
+		case node.OS.CPU.Percent > maxCPU:
+			metrics.LoadDeferrals.WithLabelValues("cpu").Inc()
+			return fmt.Sprintf("node %s CPU at %d%% (max %d%%)", node.Name, node.OS.CPU.Percent, maxCPU), nil
+		case node.ThreadPool.Search.Queue > maxQueue:
+			metrics.LoadDeferrals.WithLabelValues("search_queue").Inc()
+			return fmt.Sprintf("node %s search queue at %d (max %d)", node.Name, node.ThreadPool.Search.Queue, maxQueue), nil
+		}
+	}
+	return "", nil
+}
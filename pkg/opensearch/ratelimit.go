@@ -0,0 +1,91 @@
+package opensearch
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+)
+
+// rateLimitTransport пропускает запросы к кластеру не чаще rate_limit; клиент
+// кластера один на процесс, поэтому лимит общий для всех заданий
+type rateLimitTransport struct {
+	next   http.RoundTripper
+	bucket *tokenBucket
+}
+
+// newRateLimitTransport оборачивает next, если в конфигурации задана скорость
+func newRateLimitTransport(next http.RoundTripper, cfg config.RateLimitConfig) (http.RoundTripper, error) {
+	if cfg.RequestsPerSecond == 0 {
+		return next, nil
+	}
+	if cfg.RequestsPerSecond < 0 || cfg.Burst < 0 {
+		return nil, fmt.Errorf("%w: rate_limit requests_per_second %v and burst %d must not be negative", errs.ErrInvalidConfig, cfg.RequestsPerSecond, cfg.Burst)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &rateLimitTransport{next: next, bucket: newTokenBucket(cfg.RequestsPerSecond, cfg.Burst)}, nil
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	wait := t.bucket.reserve(time.Now())
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			// Токен не использован, возвращаем его следующим запросам
+			t.bucket.refund()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		metrics.RateLimitWait.WithLabelValues(req.URL.Host).Add(wait.Seconds())
+	}
+	return t.next.RoundTrip(req)
+}
+
+// tokenBucket токены пополняются со скоростью rate до burst; запрос без
+// свободного токена резервирует следующий и ждет его, так что ожидающие
+// запросы обслуживаются по очереди
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // токенов в секунду
+	burst  float64
+	tokens float64 // отрицательное значение: токены, уже зарезервированные ожидающими
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// reserve забирает токен и возвращает время до его появления
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(b.burst, b.tokens+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// refund возвращает токен запроса, отмененного во время ожидания
+func (b *tokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
@@ -0,0 +1,128 @@
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	// 2 requests per second, burst 3
+	b := newTokenBucket(2, 3)
+	b.last = start
+
+	steps := []struct {
+		after time.Duration // since start
+		want  time.Duration
+	}{
+		// Burst goes out right away
+		{0, 0},
+		{0, 0},
+		{0, 0},
+		// Then every request reserves the next token, waiters queue up
+		{0, 500 * time.Millisecond},
+		{0, time.Second},
+		// One second refills two tokens, both reserved already
+		{time.Second, 500 * time.Millisecond},
+		// Idle bucket refills up to burst only
+		{time.Minute, 0},
+		{time.Minute, 0},
+		{time.Minute, 0},
+		{time.Minute, 500 * time.Millisecond},
+	}
+	for i, step := range steps {
+		if got := b.reserve(start.Add(step.after)); got != step.want {
+			t.Errorf("request %d at +%s waits %s, want %s", i+1, step.after, got, step.want)
+		}
+	}
+}
+
+func TestTokenBucketRefund(t *testing.T) {
+	start := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	b := newTokenBucket(1, 0) // burst below 1 means 1
+	b.last = start
+
+	if got := b.reserve(start); got != 0 {
+		t.Fatalf("first request waits %s", got)
+	}
+	if got := b.reserve(start); got != time.Second {
+		t.Fatalf("second request waits %s, want 1s", got)
+	}
+	// Cancelled waiter gives its token back to the next request
+	b.refund()
+	if got := b.reserve(start); got != time.Second {
+		t.Errorf("request after refund waits %s, want 1s", got)
+	}
+	// Refund never grows the bucket above burst
+	b.refund()
+	b.refund()
+	b.refund()
+	if b.tokens != b.burst {
+		t.Errorf("tokens = %v, want burst %v", b.tokens, b.burst)
+	}
+}
+
+func TestNewRateLimitTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.RateLimitConfig
+		wantErr bool
+		limited bool
+	}{
+		{"disabled", config.RateLimitConfig{}, false, false},
+		{"limited", config.RateLimitConfig{RequestsPerSecond: 10, Burst: 5}, false, true},
+		{"negative rate", config.RateLimitConfig{RequestsPerSecond: -1}, true, false},
+		{"negative burst", config.RateLimitConfig{RequestsPerSecond: 1, Burst: -1}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := newRateLimitTransport(nil, tt.cfg)
+			if tt.wantErr {
+				if !errors.Is(err, errs.ErrInvalidConfig) {
+					t.Fatalf("error %v, want invalid config", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := transport.(*rateLimitTransport); ok != tt.limited {
+				t.Errorf("transport %T, limited %v", transport, tt.limited)
+			}
+		})
+	}
+}
+
+func TestRateLimitTransportCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	transport, err := newRateLimitTransport(nil, config.RateLimitConfig{RequestsPerSecond: 0.01, Burst: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: transport}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// Next token is 100s away, the request gives up with its context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v, want deadline exceeded", err)
+	}
+	if tokens := transport.(*rateLimitTransport).bucket.tokens; tokens > 0.01 || tokens < -0.01 {
+		t.Errorf("tokens = %v after cancelled wait, want refunded", tokens)
+	}
+}
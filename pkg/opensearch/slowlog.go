@@ -0,0 +1,226 @@
+package opensearch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+// slowLogPeek сколько байт начала ответа читается для took и _shards;
+// эти поля OpenSearch пишет перед hits
+const slowLogPeek = 4096
+
+// redactedValue подставляется вместо строковых значений запроса
+const redactedValue = "<redacted>"
+
+// slowLogKeep ключи, значения которых выводятся как есть: служебные параметры
+// и границы range, которые формирует сам менеджер
+var slowLogKeep = map[string]bool{
+	"gte": true, "gt": true, "lte": true, "lt": true,
+	"format": true, "time_zone": true, "order": true, "sort": true,
+	"_source": true, "keep_alive": true, "scroll": true, "conflicts": true,
+}
+
+// slowLogTransport логирует запросы выгрузки и удаления, выполнявшиеся дольше threshold
+type slowLogTransport struct {
+	next       http.RoundTripper
+	threshold  time.Duration
+	showValues bool
+}
+
+// newSlowLogTransport оборачивает next, если в конфигурации задан порог
+func newSlowLogTransport(next http.RoundTripper, cfg config.SlowLogConfig) (http.RoundTripper, error) {
+	if cfg.Threshold == "" {
+		return next, nil
+	}
+	threshold, err := time.ParseDuration(cfg.Threshold)
+	if err != nil || threshold <= 0 {
+		return nil, fmt.Errorf("%w: slow_log threshold %q must be a positive duration", errs.ErrInvalidConfig, cfg.Threshold)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &slowLogTransport{next: next, threshold: threshold, showValues: cfg.ShowValues}, nil
+}
+
+func (t *slowLogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	op := slowLogOperation(req.URL.Path)
+	if op == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	// Тело запроса небольшое (запрос, scroll_id), сохраняем его для лога
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(started)
+	if elapsed < t.threshold {
+		return resp, err
+	}
+
+	metrics.SlowRequests.WithLabelValues(op).Inc()
+	fields := log.Fields{
+		"run_id":    run.ID(req.Context()),
+		"operation": op,
+		"method":    req.Method,
+		"path":      req.URL.Path,
+		"index":     slowLogIndex(req.URL.Path),
+		"duration":  elapsed.Round(time.Millisecond).String(),
+		"query":     redactQuery(body, t.showValues),
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	} else {
+		fields["status"] = resp.StatusCode
+		if resp.Header.Get("Content-Encoding") == "" {
+			// Читаем начало ответа, не теряя его для вызывающего
+			peeked := bufio.NewReaderSize(resp.Body, slowLogPeek)
+			head, _ := peeked.Peek(slowLogPeek)
+			for k, v := range responseStats(head) {
+				fields[k] = v
+			}
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{peeked, resp.Body}
+		}
+	}
+	log.WithFields(fields).Warnf("Slow OpenSearch %s on %s: %v (threshold %v)",
+		op, fields["index"], elapsed.Round(time.Millisecond), t.threshold)
+
+	return resp, err
+}
+
+// slowLogOperation тип отслеживаемого запроса по пути, пустая строка для остальных
+func slowLogOperation(path string) string {
+	switch {
+	case strings.Contains(path, "/_delete_by_query"):
+		return "delete_by_query"
+	case strings.Contains(path, "/_search/scroll"):
+		return "scroll"
+	case strings.Contains(path, "/_search"):
+		return "search"
+	case strings.Contains(path, "/_count"):
+		return "count"
+	}
+	return ""
+}
+
+// slowLogIndex индекс (шаблон) из первого сегмента пути, "-" для запросов без индекса
+func slowLogIndex(path string) string {
+	first, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if first == "" || strings.HasPrefix(first, "_") {
+		return "-"
+	}
+	return first
+}
+
+// responseStats took, timed_out и _shards из начала ответа; начало может быть
+// обрезано, поэтому ответ разбирается потоково до первой ошибки
+func responseStats(head []byte) log.Fields {
+	stats := log.Fields{}
+	dec := json.NewDecoder(bytes.NewReader(head))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return stats
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return stats
+		}
+		key, _ := tok.(string)
+		switch key {
+		case "took", "timed_out", "total", "deleted", "version_conflicts":
+			var v interface{}
+			if dec.Decode(&v) != nil {
+				return stats
+			}
+			stats[key] = v
+		case "_shards":
+			var shards struct {
+				Total      int `json:"total"`
+				Successful int `json:"successful"`
+				Skipped    int `json:"skipped"`
+				Failed     int `json:"failed"`
+			}
+			if dec.Decode(&shards) != nil {
+				return stats
+			}
+			stats["shards"] = fmt.Sprintf("%d/%d successful, %d skipped, %d failed",
+				shards.Successful, shards.Total, shards.Skipped, shards.Failed)
+		case "hits", "failures", "docs":
+			// Дальше только документы
+			return stats
+		default:
+			var skip json.RawMessage
+			if dec.Decode(&skip) != nil {
+				return stats
+			}
+		}
+	}
+	return stats
+}
+
+// redactQuery тело запроса для лога: строковые значения заменяются на
+// <redacted>, кроме служебных ключей и границ range
+func redactQuery(body []byte, showValues bool) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if showValues {
+		return string(body)
+	}
+	var query interface{}
+	if err := json.Unmarshal(body, &query); err != nil {
+		return fmt.Sprintf("<%d bytes, not JSON>", len(body))
+	}
+	var redacted bytes.Buffer
+	enc := json.NewEncoder(&redacted)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(redact(query)); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(body))
+	}
+	return strings.TrimSpace(redacted.String())
+}
+
+// redact рекурсивно заменяет строковые значения
+func redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if !slowLogKeep[key] {
+				v[key] = redact(value)
+			}
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = redact(v[i])
+		}
+		return v
+	case string:
+		return redactedValue
+	}
+	return v
+}
@@ -0,0 +1,147 @@
+package restore
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+)
+
+// Rejection stages of dead-letter records
+const (
+	StageTransform = "transform" // restore.transform failed on document
+	StageBulk      = "bulk"      // _bulk rejected document
+	StagePartition = "partition" // document has no @timestamp to pick its daily index by
+)
+
+// DeadLetter NDJSON file collecting documents rejected during restore with
+// their error, so they can be fixed and re-indexed later; safe for
+// concurrent use by parallel days, nil records nothing
+type DeadLetter struct {
+	path string
+
+	mu    sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+	count int
+}
+
+// deadLetterRecord one rejected document line
+type deadLetterRecord struct {
+	Time    time.Time       `json:"time"`
+	RunID   string          `json:"run_id,omitempty"`
+	Key     string          `json:"key"` // artifact the document was read from
+	Stage   string          `json:"stage"`
+	Index   string          `json:"index,omitempty"` // target index
+	ID      string          `json:"_id,omitempty"`
+	Routing string          `json:"routing,omitempty"`
+	Status  int             `json:"status,omitempty"` // _bulk item status
+	Error   json.RawMessage `json:"error"`            // _bulk error object or message string
+	Source  json.RawMessage `json:"source"`           // document source, transformed for bulk rejections
+}
+
+// OpenDeadLetter open file at path for appending, nil when path is empty
+func OpenDeadLetter(path string) (*DeadLetter, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+	return &DeadLetter{path: path, file: file, w: bufio.NewWriter(file)}, nil
+}
+
+// Path of dead-letter file
+func (d *DeadLetter) Path() string {
+	if d == nil {
+		return ""
+	}
+	return d.path
+}
+
+// Count documents recorded since open
+func (d *DeadLetter) Count() int {
+	if d == nil {
+		return 0
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.count
+}
+
+// write append rejected documents of one artifact and flush them, so the file
+// is complete up to the last finished batch even if restore is killed
+func (d *DeadLetter) write(ctx context.Context, key string, rejected []rejection) error {
+	if d == nil || len(rejected) == 0 {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	encoder := json.NewEncoder(d.w)
+	now := time.Now().UTC()
+	for _, r := range rejected {
+		record := deadLetterRecord{
+			Time:    now,
+			RunID:   run.ID(ctx),
+			Key:     key,
+			Stage:   r.stage,
+			Index:   r.index,
+			ID:      r.doc.ID,
+			Routing: r.doc.Routing,
+			Status:  r.status,
+			Error:   r.reason,
+			Source:  r.doc.Source,
+		}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to write dead-letter record: %w", err)
+		}
+		d.count++
+	}
+	if err := d.w.Flush(); err != nil {
+		return fmt.Errorf("failed to write dead-letter file: %w", err)
+	}
+	return nil
+}
+
+// Close flush and close file
+func (d *DeadLetter) Close() error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.w.Flush(); err != nil {
+		d.file.Close()
+		return err
+	}
+	return d.file.Close()
+}
+
+// rejection document that was not indexed
+type rejection struct {
+	doc    document
+	stage  string
+	index  string
+	status int
+	reason json.RawMessage
+	err    error
+}
+
+// partitionRejection rejection of document without daily index
+func partitionRejection(doc document, err error) rejection {
+	reason, _ := json.Marshal(err.Error())
+	return rejection{doc: doc, stage: StagePartition, reason: reason, err: fmt.Errorf("document %s: %w", doc.ID, err)}
+}
+
+// transformRejection rejection of document restore.transform failed on
+func transformRejection(doc document, err error) rejection {
+	reason, _ := json.Marshal(err.Error())
+	return rejection{doc: doc, stage: StageTransform, reason: reason, err: fmt.Errorf("document %s: %w", doc.ID, err)}
+}
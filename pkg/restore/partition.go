@@ -0,0 +1,202 @@
+package restore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	log "github.com/sirupsen/logrus"
+)
+
+// timestampField document field daily target indices are picked by, the
+// field backups are split into periods by
+const timestampField = "@timestamp"
+
+// timestampLayouts layouts of string timestamps besides RFC 3339; values
+// without zone are UTC
+var timestampLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05", "2006-01-02"}
+
+// Partition routes restored documents into one index per UTC day of their
+// @timestamp, named by a pattern with a Go layout in braces
+// ("logs-{2006.01.02}"); missing indices are created with the archived
+// mapping of the backup. Safe for concurrent restores of several days
+type Partition struct {
+	prefix, layout, suffix string
+
+	mu    sync.Mutex
+	ready map[string]bool // indices known to exist
+}
+
+// NewPartition partition of pattern, which holds exactly one {layout}
+func NewPartition(pattern string) (*Partition, error) {
+	start, end := strings.Index(pattern, "{"), strings.LastIndex(pattern, "}")
+	if start < 0 || end < start+2 || strings.Count(pattern, "{") != 1 || strings.Count(pattern, "}") != 1 {
+		return nil, fmt.Errorf("%w: partition %q needs one date layout in braces, e.g. \"logs-{2006.01.02}\"", errs.ErrInvalidConfig, pattern)
+	}
+	p := &Partition{
+		prefix: pattern[:start],
+		layout: pattern[start+1 : end],
+		suffix: pattern[end+1:],
+		ready:  make(map[string]bool),
+	}
+	day := time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC)
+	if p.Index(day) == p.Index(day.AddDate(0, 0, 1)) {
+		return nil, fmt.Errorf("%w: partition %q: layout %q does not tell days apart", errs.ErrInvalidConfig, pattern, p.layout)
+	}
+	return p, nil
+}
+
+// Index name of daily index of t
+func (p *Partition) Index(t time.Time) string {
+	return p.prefix + t.UTC().Format(p.layout) + p.suffix
+}
+
+// target daily index of document by its @timestamp
+func (p *Partition) target(doc document) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(doc.Source, &fields); err != nil {
+		return "", fmt.Errorf("invalid document: %w", err)
+	}
+	value, ok := fields[timestampField]
+	if !ok {
+		return "", fmt.Errorf("document has no %s", timestampField)
+	}
+	t, err := parseTimestamp(value)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", timestampField, err)
+	}
+	return p.Index(t), nil
+}
+
+// parseTimestamp date value as string in one of timestampLayouts or number
+// of epoch millis
+func parseTimestamp(value json.RawMessage) (time.Time, error) {
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		millis, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unsupported value %s", value)
+		}
+		return time.UnixMilli(millis), nil
+	}
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported date %q", text)
+}
+
+// ensure create index with mapping of source index from archived mapping
+// unless it exists; without archived mapping indices are left to auto-create
+// and index templates
+func (p *Partition) ensure(ctx context.Context, client opensearch.API, index, source string, archived *archivedMapping) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ready[index] {
+		return nil
+	}
+	mapping, err := archived.of(source)
+	if err != nil || mapping == nil {
+		return err
+	}
+
+	resp, err := client.GetClient().Indices.Exists(ctx, opensearchapi.IndicesExistsReq{Indices: []string{index}})
+	if resp == nil || (resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound) {
+		return fmt.Errorf("failed to check index %s: %w", index, err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		body, err := json.Marshal(map[string]json.RawMessage{"mappings": mapping})
+		if err != nil {
+			return err
+		}
+		if _, err := client.GetClient().Indices.Create(ctx, opensearchapi.IndicesCreateReq{
+			Index: index,
+			Body:  bytes.NewReader(body),
+		}); err != nil && opensearch.ResponseStatus(err) != http.StatusBadRequest {
+			// 400 resource_already_exists when a concurrent day created it meanwhile
+			return fmt.Errorf("failed to create index %s: %w", index, err)
+		}
+		log.Infof("Created index %s with archived mapping", index)
+	}
+	p.ready[index] = true
+	return nil
+}
+
+// archivedMapping _mapping response archived with a backup, read on first use
+type archivedMapping struct {
+	load func() ([]byte, error)
+
+	once     sync.Once
+	mappings map[string]struct {
+		Mappings json.RawMessage `json:"mappings"`
+	}
+	err error
+}
+
+// of mapping of source index, or of the last index by name when the backup
+// has none for it (documents without _index); nil without archived mapping
+func (a *archivedMapping) of(source string) (json.RawMessage, error) {
+	if a == nil {
+		return nil, nil
+	}
+	a.once.Do(func() {
+		var data []byte
+		if data, a.err = a.load(); a.err == nil {
+			a.err = json.Unmarshal(data, &a.mappings)
+		}
+		if a.err != nil {
+			a.err = fmt.Errorf("failed to read archived mapping: %w", a.err)
+		}
+	})
+	if a.err != nil {
+		return nil, a.err
+	}
+	if m, ok := a.mappings[source]; ok {
+		return m.Mappings, nil
+	}
+	names := make([]string, 0, len(a.mappings))
+	for name := range a.mappings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return a.mappings[names[len(names)-1]].Mappings, nil
+}
+
+// archivedMapping archived mapping of request, decrypted when it has the .age
+// suffix; nil when the request has none
+func (s *Service) archivedMapping(ctx context.Context, key string) *archivedMapping {
+	if key == "" {
+		return nil
+	}
+	return &archivedMapping{load: func() ([]byte, error) {
+		data, err := s.s3Client.Get(ctx, key)
+		if err != nil || !strings.HasSuffix(key, ".age") {
+			return data, err
+		}
+		identities, err := s.identities()
+		if err != nil {
+			return nil, err
+		}
+		decrypted, err := age.Decrypt(bytes.NewReader(data), identities...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
+		}
+		return io.ReadAll(decrypted)
+	}}
+}
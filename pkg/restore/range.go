@@ -0,0 +1,164 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+// RangeRequest days of index backups to restore
+type RangeRequest struct {
+	Index       string      // backed up index, as recorded in manifests
+	S3Path      string      // path of backup job in bucket
+	From, To    time.Time   // inclusive range of days
+	TargetIndex string      // default original _index of documents
+	Concurrency int         // days restored in parallel (default 1)
+	DeadLetter  *DeadLetter // optional, records rejected documents of all days
+	Partition   *Partition  // optional, daily target indices instead of TargetIndex
+}
+
+// RangeResult combined summary of restored days
+type RangeResult struct {
+	Days       int // days found in range
+	FailedDays int
+	Documents  int
+	Failed     int // rejected documents
+	// DeadLettered rejected documents recorded in dead-letter file
+	DeadLettered int
+}
+
+// RestoreRange locate daily backups in range by their manifests and restore
+// every artifact of each day, Concurrency days at a time; failed days do not
+// stop the others and are reported together at the end
+func (s *Service) RestoreRange(ctx context.Context, req RangeRequest) (RangeResult, error) {
+	ctx, runID := run.Ensure(ctx)
+	var result RangeResult
+
+	from := truncateDay(req.From)
+	to := truncateDay(req.To)
+	if to.Before(from) {
+		return result, fmt.Errorf("%w: restore range ends before it starts", errs.ErrInvalidConfig)
+	}
+
+	keys, err := manifest.LoadPublicKeys(s.config.Restore.VerifyKeys)
+	if err != nil {
+		return result, fmt.Errorf("restore.verify_keys: %w", err)
+	}
+	verifier := manifest.NewVerifier(keys, s.config.Restore.AllowUnsigned)
+
+	entries, err := manifest.List(ctx, s.s3Client, req.S3Path, req.Index)
+	if err != nil {
+		return result, fmt.Errorf("failed to list backups: %w", err)
+	}
+	var days []manifest.Entry
+	for _, entry := range entries {
+		if !entry.Date.Before(from) && !entry.Date.After(to) {
+			days = append(days, entry)
+		}
+	}
+	if len(days) == 0 {
+		return result, fmt.Errorf("no backups of %s between %s and %s", req.Index, from.Format(manifest.DateLayout), to.Format(manifest.DateLayout))
+	}
+
+	result.Days = len(days)
+	log.Infof("Restoring %d days of %s (%s - %s, run %s)", len(days), req.Index,
+		from.Format(manifest.DateLayout), to.Format(manifest.DateLayout), runID)
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		done     int
+		firstErr error
+		slots    = make(chan struct{}, concurrency)
+	)
+	for _, day := range days {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(day manifest.Entry) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			var restored Result
+			err := run.Protect(ctx, "restore of "+day.Manifest.Date, func() (err error) {
+				restored, err = s.restoreDay(ctx, verifier, day, req)
+				return err
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			result.Documents += restored.Documents
+			result.Failed += restored.Failed
+			result.DeadLettered += restored.DeadLettered
+			if err != nil {
+				result.FailedDays++
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", day.Manifest.Date, err)
+				}
+				log.Errorf("Restore of %s for %s failed: %v", req.Index, day.Manifest.Date, err)
+			}
+			log.WithFields(log.Fields{
+				"run_id":      runID,
+				"days_done":   done,
+				"days":        len(days),
+				"documents":   result.Documents,
+				"failed":      result.Failed,
+				"dead_letter": result.DeadLettered,
+			}).Infof("Restore progress: %d/%d days", done, len(days))
+		}(day)
+	}
+	wg.Wait()
+
+	log.Infof("Restore of %s completed: %d days, %d documents indexed, %d failed (%d dead-lettered), %d days failed",
+		req.Index, result.Days, result.Documents, result.Failed, result.DeadLettered, result.FailedDays)
+	if result.FailedDays > 0 {
+		return result, fmt.Errorf("%w: %d of %d days not fully restored, first: %w", errs.ErrPartialFailure, result.FailedDays, result.Days, firstErr)
+	}
+	return result, nil
+}
+
+// restoreDay restore all objects of day manifest in order, once verifier
+// accepts its signature, returns their combined summary
+func (s *Service) restoreDay(ctx context.Context, verifier *manifest.Verifier, day manifest.Entry, req RangeRequest) (Result, error) {
+	var summary Result
+	signed, err := verifier.Verify(ctx, s.s3Client, day)
+	if err != nil {
+		return summary, err
+	}
+	day.Manifest = signed
+	var firstErr error
+	for _, key := range day.Manifest.Objects {
+		result, err := s.Restore(ctx, Request{
+			Key:           key,
+			TargetIndex:   req.TargetIndex,
+			DeadLetter:    req.DeadLetter,
+			Format:        day.Manifest.Format,
+			FormatVersion: day.Manifest.Version(),
+			Dictionary:    day.Manifest.Dictionary,
+			Partition:     req.Partition,
+			Mapping:       day.Manifest.Mapping,
+		})
+		summary.Documents += result.Documents
+		summary.Failed += result.Failed
+		summary.DeadLettered += result.DeadLettered
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return summary, firstErr
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,193 @@
+package restore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+)
+
+// Artifact layouts restore can read
+const (
+	layoutJSON   = "json"   // format version 1: raw search responses or _source lines, told apart per line
+	layoutSearch = "search" // raw search response per line
+	layoutSource = "source" // document _source per line
+	layoutBulk   = "bulk"   // _bulk action and source line pairs
+)
+
+// document archived document with metadata recorded by export format
+type document struct {
+	Index   string
+	ID      string
+	Routing string
+	Source  json.RawMessage
+}
+
+// bulkMeta metadata of _bulk action line, as written by the bulk format
+type bulkMeta struct {
+	Index   string `json:"_index"`
+	ID      string `json:"_id"`
+	Routing string `json:"routing"`
+}
+
+// formatOf artifact layout by object name extension
+func formatOf(key string) string {
+	name := strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(key, ".age"), ".gz"), ".zst")
+	switch {
+	case strings.HasSuffix(name, ".ndjson"):
+		return layoutBulk
+	case strings.HasSuffix(name, ".json"):
+		return layoutJSON
+	}
+	return ""
+}
+
+// layoutOf reader layout of artifact written with format version and export
+// format; format may be empty for artifacts without manifest or metadata
+func layoutOf(key string, version int, format string) (string, error) {
+	if version > manifest.FormatVersion {
+		return "", fmt.Errorf("%w: %s has format version %d, this release reads up to %d",
+			errs.ErrInvalidConfig, key, version, manifest.FormatVersion)
+	}
+	switch format {
+	case "":
+		return formatOf(key), nil
+	case "search", "source":
+		// Version 1 did not guarantee a single line kind per artifact
+		if version <= 1 {
+			return layoutJSON, nil
+		}
+		return format, nil
+	case "bulk":
+		return layoutBulk, nil
+	}
+	return "", nil
+}
+
+// readDocuments decode artifact lines and pass every document to fn
+func readDocuments(r io.Reader, layout string, fn func(document) error) error {
+	if layout == "" {
+		return fmt.Errorf("%w: only json and bulk artifacts can be restored", errs.ErrInvalidConfig)
+	}
+
+	reader := bufio.NewReaderSize(r, 1<<20)
+	var action map[string]bulkMeta
+	for {
+		// Search response lines hold a whole page, so no line length limit
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if layout == layoutBulk && action == nil {
+				if perr := json.Unmarshal(line, &action); perr != nil {
+					return fmt.Errorf("invalid bulk action line: %w", perr)
+				}
+			} else {
+				var docs []document
+				var perr error
+				if layout == layoutBulk {
+					docs = bulkDocument(action, line)
+					action = nil
+				} else {
+					docs, perr = lineDocuments(layout, line)
+				}
+				if perr != nil {
+					return perr
+				}
+				for _, doc := range docs {
+					if ferr := fn(doc); ferr != nil {
+						return ferr
+					}
+				}
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read artifact: %w", err)
+		}
+	}
+}
+
+// bulkDocument document of bulk action and source line pair
+func bulkDocument(action map[string]bulkMeta, source []byte) []document {
+	var doc document
+	for _, meta := range action {
+		doc = document{Index: meta.Index, ID: meta.ID, Routing: meta.Routing}
+	}
+	doc.Source = append(json.RawMessage(nil), bytes.TrimSpace(source)...)
+	return []document{doc}
+}
+
+// lineDocuments documents of one line of json artifact
+func lineDocuments(layout string, line []byte) ([]document, error) {
+	switch layout {
+	case layoutSource:
+		return []document{sourceDocument(line)}, nil
+	case layoutSearch:
+		var page struct {
+			Hits json.RawMessage `json:"hits"`
+		}
+		if err := json.Unmarshal(line, &page); err != nil {
+			return nil, fmt.Errorf("invalid artifact line: %w", err)
+		}
+		if page.Hits == nil {
+			return nil, fmt.Errorf("line is not a search response")
+		}
+		return searchDocuments(page.Hits)
+	}
+	return jsonDocuments(line)
+}
+
+// jsonDocuments hits of search response line, or the line itself as _source
+func jsonDocuments(line []byte) ([]document, error) {
+	var page struct {
+		Took json.RawMessage `json:"took"`
+		Hits json.RawMessage `json:"hits"`
+	}
+	if err := json.Unmarshal(line, &page); err != nil {
+		return nil, fmt.Errorf("invalid artifact line: %w", err)
+	}
+
+	// Documents may have own hits field, responses always carry took as well
+	if page.Took == nil || page.Hits == nil {
+		return []document{sourceDocument(line)}, nil
+	}
+	return searchDocuments(page.Hits)
+}
+
+// sourceDocument line as document _source
+func sourceDocument(line []byte) document {
+	return document{Source: append(json.RawMessage(nil), bytes.TrimSpace(line)...)}
+}
+
+// searchDocuments documents of hits object of search response
+func searchDocuments(raw json.RawMessage) ([]document, error) {
+	var hits struct {
+		Hits []struct {
+			Index   string          `json:"_index"`
+			ID      string          `json:"_id"`
+			Routing string          `json:"_routing"`
+			Source  json.RawMessage `json:"_source"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(raw, &hits); err != nil {
+		return nil, fmt.Errorf("invalid search response line: %w", err)
+	}
+	docs := make([]document, 0, len(hits.Hits))
+	for _, hit := range hits.Hits {
+		if hit.Source == nil {
+			// Raw pages of docvalue/stored fields exports before _source was
+			// added to them; dropping them would restore a fraction silently
+			return nil, fmt.Errorf("hit %s of %s has no _source to restore", hit.ID, hit.Index)
+		}
+		docs = append(docs, document{Index: hit.Index, ID: hit.ID, Routing: hit.Routing, Source: hit.Source})
+	}
+	return docs, nil
+}
@@ -0,0 +1,122 @@
+package restore
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+)
+
+func TestReadDocuments(t *testing.T) {
+	const page = `{"took":2,"hits":{"hits":[` +
+		`{"_index":"logs-1","_id":"a","_routing":"r1","_source":{"msg":"one"}},` +
+		`{"_index":"logs-1","_id":"b","_source":{"msg":"two"}}]}}`
+	tests := []struct {
+		name     string
+		layout   string
+		artifact string
+		want     []document
+	}{
+		{"search", layoutSearch, page + "\n",
+			[]document{
+				{Index: "logs-1", ID: "a", Routing: "r1", Source: []byte(`{"msg":"one"}`)},
+				{Index: "logs-1", ID: "b", Source: []byte(`{"msg":"two"}`)},
+			}},
+		{"source", layoutSource, `{"msg":"one"}` + "\n\n" + `{"msg":"two","hits":3}`,
+			[]document{{Source: []byte(`{"msg":"one"}`)}, {Source: []byte(`{"msg":"two","hits":3}`)}}},
+		{"bulk", layoutBulk,
+			`{"index":{"_index":"logs-1","_id":"a","routing":"r1"}}` + "\n" + `{"msg":"one"}` + "\n" +
+				`{"index":{"_index":"logs-2","_id":"b"}}` + "\n" + `{"msg":"two"}` + "\n",
+			[]document{
+				{Index: "logs-1", ID: "a", Routing: "r1", Source: []byte(`{"msg":"one"}`)},
+				{Index: "logs-2", ID: "b", Source: []byte(`{"msg":"two"}`)},
+			}},
+		{"json mixes pages and sources", layoutJSON, page + "\n" + `{"msg":"three","hits":1}` + "\n",
+			[]document{
+				{Index: "logs-1", ID: "a", Routing: "r1", Source: []byte(`{"msg":"one"}`)},
+				{Index: "logs-1", ID: "b", Source: []byte(`{"msg":"two"}`)},
+				{Source: []byte(`{"msg":"three","hits":1}`)},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []document
+			err := readDocuments(strings.NewReader(tt.artifact), tt.layout, func(doc document) error {
+				got = append(got, doc)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("documents = %d, want %d", len(got), len(tt.want))
+			}
+			for i, doc := range got {
+				want := tt.want[i]
+				if doc.Index != want.Index || doc.ID != want.ID || doc.Routing != want.Routing || string(doc.Source) != string(want.Source) {
+					t.Errorf("document %d = %+v, want %+v", i, doc, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadDocumentsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		layout   string
+		artifact string
+	}{
+		{"unknown layout", "", `{}`},
+		{"search line without hits", layoutSearch, `{"msg":"one"}`},
+		{"hit without source", layoutSearch, `{"took":1,"hits":{"hits":[{"_index":"logs","_id":"a","fields":{"msg":["one"]}}]}}`},
+		{"invalid bulk action", layoutBulk, "not json\n{}\n"},
+		{"invalid json line", layoutJSON, "{\n"},
+	}
+	for _, tt := range tests {
+		err := readDocuments(strings.NewReader(tt.artifact), tt.layout, func(document) error { return nil })
+		if err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+
+	stop := errors.New("stop")
+	if err := readDocuments(strings.NewReader("{}\n{}\n"), layoutSource, func(document) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("callback error %v, want %v", err, stop)
+	}
+}
+
+func TestLayoutOf(t *testing.T) {
+	tests := []struct {
+		key     string
+		version int
+		format  string
+		want    string
+	}{
+		{"logs/2026-03-10.json.gz", 0, "", layoutJSON},
+		{"logs/2026-03-10.ndjson.zst.age", 0, "", layoutBulk},
+		{"logs/2026-03-10.avro", 0, "", ""},
+		{"logs/2026-03-10.json.gz", 1, "search", layoutJSON},
+		{"logs/2026-03-10.json.gz", 1, "source", layoutJSON},
+		{"logs/2026-03-10.json.gz", manifest.FormatVersion, "search", layoutSearch},
+		{"logs/2026-03-10.json.gz", manifest.FormatVersion, "source", layoutSource},
+		{"logs/2026-03-10.ndjson.gz", 1, "bulk", layoutBulk},
+		{"logs/2026-03-10.parquet", manifest.FormatVersion, "parquet", ""},
+	}
+	for _, tt := range tests {
+		got, err := layoutOf(tt.key, tt.version, tt.format)
+		if err != nil {
+			t.Errorf("layoutOf(%s, %d, %q): %v", tt.key, tt.version, tt.format, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("layoutOf(%s, %d, %q) = %q, want %q", tt.key, tt.version, tt.format, got, tt.want)
+		}
+	}
+
+	if _, err := layoutOf("logs/2026-03-10.json.gz", manifest.FormatVersion+1, "search"); !errors.Is(err, errs.ErrInvalidConfig) {
+		t.Errorf("newer format version: error %v, want invalid config", err)
+	}
+}
@@ -0,0 +1,377 @@
+// Package restore loads backup artifacts from S3 back into OpenSearch
+package restore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultBulkSize documents per _bulk request when not configured
+const defaultBulkSize = 1000
+
+// Service restores backup artifacts
+type Service struct {
+	client   opensearch.API
+	s3Client storage.Backend
+	config   *config.Config
+}
+
+// Options restore service dependencies for embedding into other programs
+type Options struct {
+	Client  opensearch.API
+	Storage storage.Backend
+	Config  *config.Config // optional, restore section gives bulk size, identities and transform
+}
+
+// New create restore service from options
+func New(opts Options) (*Service, error) {
+	if opts.Client == nil || opts.Storage == nil {
+		return nil, fmt.Errorf("%w: restore requires OpenSearch client and storage", errs.ErrInvalidConfig)
+	}
+	return NewService(opts.Client, opts.Storage, opts.Config), nil
+}
+
+// NewService create new restore service
+func NewService(client opensearch.API, s3Client storage.Backend, cfg *config.Config) *Service {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	return &Service{
+		client:   client,
+		s3Client: s3Client,
+		config:   cfg,
+	}
+}
+
+// Request single artifact to restore
+type Request struct {
+	Key         string      // S3 key of artifact or chunk
+	TargetIndex string      // index to write into, default original _index of documents
+	DeadLetter  *DeadLetter // optional, rejected documents are recorded instead of failing restore
+
+	// Format and FormatVersion recorded in manifest; without version both are
+	// taken from object metadata, objects without it are read as version 1
+	Format        string
+	FormatVersion int
+	// Dictionary S3 key of zstd dictionary recorded in manifest, taken from
+	// object metadata when empty
+	Dictionary string
+
+	// Partition routes documents into daily indices instead of TargetIndex,
+	// creating missing ones with the archived mapping at S3 key Mapping
+	Partition *Partition
+	Mapping   string
+}
+
+// Result restored artifact summary
+type Result struct {
+	Key       string
+	Documents int // indexed documents
+	Failed    int // documents rejected by transform or _bulk
+	// DeadLettered rejected documents recorded in dead-letter file
+	DeadLettered int
+}
+
+// Restore download artifact, decompress (and decrypt) it and bulk-index its
+// documents, transformed by restore config
+func (s *Service) Restore(ctx context.Context, req Request) (Result, error) {
+	ctx, runID := run.Ensure(ctx)
+	result := Result{Key: req.Key}
+	log.Infof("Starting restore of %s (run %s)", req.Key, runID)
+
+	if req.Partition != nil && req.TargetIndex != "" {
+		return result, fmt.Errorf("%w: restore into partition and target index at once", errs.ErrInvalidConfig)
+	}
+	layout, err := s.layout(ctx, req)
+	if err != nil {
+		return result, err
+	}
+
+	reader, err := s.open(ctx, req)
+	if err != nil {
+		return result, err
+	}
+	defer reader.Close()
+
+	transform := s.config.Restore.Transform
+	bulkSize := s.config.Restore.BulkSize
+	if bulkSize <= 0 {
+		bulkSize = defaultBulkSize
+	}
+
+	mapping := s.archivedMapping(ctx, req.Mapping)
+	batch := make([]document, 0, bulkSize)
+	var firstErr error
+	// reject count documents that were not indexed and record them in
+	// dead-letter file when restore has one
+	reject := func(rejected ...rejection) error {
+		if len(rejected) == 0 {
+			return nil
+		}
+		result.Failed += len(rejected)
+		if req.DeadLetter != nil {
+			if err := req.DeadLetter.write(ctx, req.Key, rejected); err != nil {
+				return err
+			}
+			result.DeadLettered += len(rejected)
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = rejected[0].err
+		}
+		return nil
+	}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		indexed, rejected, err := s.bulk(ctx, req.TargetIndex, batch)
+		batch = batch[:0]
+		if err != nil {
+			return err
+		}
+		result.Documents += indexed
+		return reject(rejected...)
+	}
+
+	err = readDocuments(reader, layout, func(doc document) error {
+		if !transform.Empty() {
+			if err := applyTransform(&doc, transform); err != nil {
+				return reject(transformRejection(doc, err))
+			}
+		}
+
+		if req.Partition != nil {
+			target, err := req.Partition.target(doc)
+			if err != nil {
+				return reject(partitionRejection(doc, err))
+			}
+			if err := req.Partition.ensure(ctx, s.client, target, doc.Index, mapping); err != nil {
+				return err
+			}
+			doc.Index = target
+		}
+
+		batch = append(batch, doc)
+		if len(batch) >= bulkSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to restore %s: %w", req.Key, err)
+	}
+
+	log.Infof("Restore of %s completed: %d documents indexed, %d failed", req.Key, result.Documents, result.Failed)
+	if result.DeadLettered > 0 {
+		log.Warnf("%d rejected documents of %s recorded in %s", result.DeadLettered, req.Key, req.DeadLetter.Path())
+	}
+	if result.Failed > result.DeadLettered {
+		return result, fmt.Errorf("%w: %d documents of %s not restored, first: %w", errs.ErrPartialFailure, result.Failed-result.DeadLettered, req.Key, firstErr)
+	}
+	return result, nil
+}
+
+// layout reader layout of requested artifact by its format version
+func (s *Service) layout(ctx context.Context, req Request) (string, error) {
+	version, format := req.FormatVersion, req.Format
+	if version == 0 {
+		info, err := s.s3Client.Stat(ctx, req.Key)
+		if err != nil {
+			return "", err
+		}
+		version, format = 1, info.Metadata[manifest.MetadataFormat]
+		if v, ok := info.Metadata[manifest.MetadataFormatVersion]; ok {
+			if version, err = strconv.Atoi(v); err != nil {
+				return "", fmt.Errorf("%w: %s has invalid format version %q", errs.ErrInvalidConfig, req.Key, v)
+			}
+		}
+	}
+	return layoutOf(req.Key, version, format)
+}
+
+// open artifact stream: decrypted when key has .age suffix, then gunzipped
+// (artifacts are multi-member gzip files) or, with .zst suffix, decoded as
+// zstd frames
+func (s *Service) open(ctx context.Context, req Request) (io.ReadCloser, error) {
+	key := req.Key
+	object, err := s.s3Client.Download(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed io.Reader = object
+	if strings.HasSuffix(key, ".age") {
+		identities, err := s.identities()
+		if err != nil {
+			object.Close()
+			return nil, err
+		}
+		if compressed, err = age.Decrypt(object, identities...); err != nil {
+			object.Close()
+			return nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
+		}
+	}
+
+	if strings.HasSuffix(strings.TrimSuffix(key, ".age"), ".zst") {
+		opts, err := s.zstdOptions(ctx, req)
+		if err != nil {
+			object.Close()
+			return nil, err
+		}
+		decoder, err := zstd.NewReader(compressed, opts...)
+		if err != nil {
+			object.Close()
+			return nil, fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		return readCloser{Reader: decoder, close: func() error {
+			decoder.Close()
+			return object.Close()
+		}}, nil
+	}
+
+	gzipReader, err := pgzip.NewReader(compressed)
+	if err != nil {
+		object.Close()
+		return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+	}
+	return readCloser{Reader: gzipReader, close: func() error {
+		gzipReader.Close()
+		return object.Close()
+	}}, nil
+}
+
+// zstdOptions decoder options of artifact, with the dictionary it was
+// compressed with
+func (s *Service) zstdOptions(ctx context.Context, req Request) ([]zstd.DOption, error) {
+	dictKey := req.Dictionary
+	if dictKey == "" {
+		info, err := s.s3Client.Stat(ctx, req.Key)
+		if err != nil {
+			return nil, err
+		}
+		dictKey = info.Metadata[manifest.MetadataDictionary]
+	}
+	if dictKey == "" {
+		return nil, nil
+	}
+	dict, err := s.s3Client.Get(ctx, dictKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zstd dictionary %s: %w", dictKey, err)
+	}
+	return []zstd.DOption{zstd.WithDecoderDicts(dict)}, nil
+}
+
+// identities age identities from restore identity file
+func (s *Service) identities() ([]age.Identity, error) {
+	path := s.config.Restore.IdentityFile
+	if path == "" {
+		return nil, fmt.Errorf("%w: encrypted artifact needs restore.identity_file", errs.ErrInvalidConfig)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: identity file: %w", errs.ErrInvalidConfig, err)
+	}
+	defer file.Close()
+
+	identities, err := age.ParseIdentities(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: identity file: %w", errs.ErrInvalidConfig, err)
+	}
+	return identities, nil
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error { return r.close() }
+
+// bulk index batch, returns number of indexed documents and rejected ones
+func (s *Service) bulk(ctx context.Context, targetIndex string, batch []document) (int, []rejection, error) {
+	type metadata struct {
+		Index   string `json:"_index"`
+		ID      string `json:"_id,omitempty"`
+		Routing string `json:"routing,omitempty"`
+	}
+
+	body := &bytes.Buffer{}
+	encoder := json.NewEncoder(body)
+	for _, doc := range batch {
+		index := targetIndex
+		if index == "" {
+			index = doc.Index
+		}
+		if index == "" {
+			return 0, nil, fmt.Errorf("%w: artifact does not record source index, target index is required", errs.ErrInvalidConfig)
+		}
+
+		action := map[string]metadata{"index": {Index: index, ID: doc.ID, Routing: doc.Routing}}
+		if err := encoder.Encode(action); err != nil {
+			return 0, nil, err
+		}
+		body.Write(doc.Source)
+		body.WriteByte('\n')
+	}
+
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			ID     string          `json:"_id"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := s.client.Do(ctx, "POST", "/_bulk", body, &resp); err != nil {
+		return 0, nil, fmt.Errorf("bulk request failed: %w", err)
+	}
+
+	if !resp.Errors {
+		return len(batch), nil, nil
+	}
+	// Items follow the order of request actions
+	var rejected []rejection
+	for i, item := range resp.Items {
+		for _, result := range item {
+			if result.Status < 300 {
+				continue
+			}
+			r := rejection{
+				stage:  StageBulk,
+				index:  targetIndex,
+				status: result.Status,
+				reason: result.Error,
+				err:    fmt.Errorf("document %s: status %d: %s", result.ID, result.Status, result.Error),
+			}
+			if i < len(batch) {
+				r.doc = batch[i]
+				if r.index == "" {
+					r.index = batch[i].Index
+				}
+			}
+			rejected = append(rejected, r)
+		}
+	}
+	return len(batch) - len(rejected), rejected, nil
+}
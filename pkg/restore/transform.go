@@ -0,0 +1,167 @@
+package restore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+)
+
+// Date formats of DateTransform besides Go layouts
+const (
+	epochMillis = "epoch_millis"
+	epochSecond = "epoch_second"
+)
+
+// applyTransform rename, drop and reformat fields of document source;
+// field paths are dotted and match both nested objects and flat dotted keys
+func applyTransform(doc *document, transform config.RestoreTransform) error {
+	decoder := json.NewDecoder(bytes.NewReader(doc.Source))
+	decoder.UseNumber()
+	var source map[string]interface{}
+	if err := decoder.Decode(&source); err != nil {
+		return fmt.Errorf("failed to decode source: %w", err)
+	}
+
+	for from, to := range transform.Rename {
+		if value, ok := takeField(source, from); ok {
+			setField(source, to, value)
+		}
+	}
+	for _, path := range transform.Drop {
+		takeField(source, path)
+	}
+	for _, date := range transform.Dates {
+		value, ok := takeField(source, date.Field)
+		if !ok {
+			continue
+		}
+		converted, err := convertDate(value, date.From, date.To)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", date.Field, err)
+		}
+		setField(source, date.Field, converted)
+	}
+
+	data, err := json.Marshal(source)
+	if err != nil {
+		return fmt.Errorf("failed to encode source: %w", err)
+	}
+	doc.Source = data
+	return nil
+}
+
+// takeField remove value at path from source
+func takeField(source map[string]interface{}, path string) (interface{}, bool) {
+	if value, ok := source[path]; ok {
+		delete(source, path)
+		return value, true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if nested, ok := source[path[:i]].(map[string]interface{}); ok {
+			if value, ok := takeField(nested, path[i+1:]); ok {
+				return value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// setField store value at path, creating nested objects
+func setField(source map[string]interface{}, path string, value interface{}) {
+	parts := strings.Split(path, ".")
+	node := source
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := node[part].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			node[part] = child
+		}
+		node = child
+	}
+	node[parts[len(parts)-1]] = value
+}
+
+// convertDate parse value in from format and render it in to format;
+// arrays are converted element-wise
+func convertDate(value interface{}, from, to string) (interface{}, error) {
+	if values, ok := value.([]interface{}); ok {
+		converted := make([]interface{}, len(values))
+		for i, v := range values {
+			c, err := convertDate(v, from, to)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = c
+		}
+		return converted, nil
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	t, err := parseDate(value, from)
+	if err != nil {
+		return nil, err
+	}
+
+	switch to {
+	case epochMillis:
+		return json.Number(strconv.FormatInt(t.UnixMilli(), 10)), nil
+	case epochSecond:
+		return json.Number(strconv.FormatInt(t.Unix(), 10)), nil
+	case "":
+		return t.Format(time.RFC3339Nano), nil
+	default:
+		return t.Format(to), nil
+	}
+}
+
+func parseDate(value interface{}, format string) (time.Time, error) {
+	switch format {
+	case epochMillis, epochSecond:
+		var n float64
+		switch v := value.(type) {
+		case json.Number:
+			f, err := v.Float64()
+			if err != nil {
+				return time.Time{}, err
+			}
+			n = f
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("%q is not %s", v, format)
+			}
+			n = f
+		default:
+			return time.Time{}, fmt.Errorf("%v is not %s", value, format)
+		}
+		if format == epochSecond {
+			n *= 1000
+		}
+		return time.UnixMilli(int64(math.Round(n))).UTC(), nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%v is not a date string", value)
+	}
+	layout := format
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q does not match %q", s, layout)
+	}
+	return t, nil
+}
@@ -0,0 +1,168 @@
+package rpc
+
+//go:generate protoc -I ../../api --go_out=../.. --go_opt=module=github.com/okto/opensearch-backup-manager --go-grpc_out=../.. --go-grpc_opt=module=github.com/okto/opensearch-backup-manager manager/v1/manager.proto
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/okto/opensearch-backup-manager/pkg/admin"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/events"
+	"github.com/okto/opensearch-backup-manager/pkg/rpc/managerv1"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// methodRoles role required by each method, unlisted methods are denied
+var methodRoles = map[string]string{
+	managerv1.JobService_ListJobs_FullMethodName:   admin.RoleRead,
+	managerv1.JobService_TriggerJob_FullMethodName: admin.RoleAdmin,
+	managerv1.JobService_WatchRuns_FullMethodName:  admin.RoleRead,
+}
+
+// Server gRPC JobService, authorized by the same tokens and roles as admin API
+type Server struct {
+	managerv1.UnimplementedJobServiceServer
+
+	tokens    admin.Tokens
+	scheduler admin.Scheduler
+	hub       *events.Hub
+}
+
+// New create gRPC server of scheduler jobs and hub run events
+func New(tokens admin.Tokens, scheduler admin.Scheduler, hub *events.Hub) *Server {
+	return &Server{tokens: tokens, scheduler: scheduler, hub: hub}
+}
+
+// Serve run gRPC API on listen until ctx is cancelled
+func (s *Server) Serve(ctx context.Context, listen string) {
+	lis, err := net.Listen("tcp", listen)
+	if err != nil {
+		log.Errorf("gRPC API failed: %v", err)
+		return
+	}
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := s.authorize(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := s.authorize(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	managerv1.RegisterJobServiceServer(server, s)
+	go func() {
+		<-ctx.Done()
+		server.Stop()
+	}()
+
+	log.Infof("Serving gRPC API on %s", listen)
+	if err := server.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		log.Errorf("gRPC API failed: %v", err)
+	}
+}
+
+// authorize check bearer token of call metadata against method role
+func (s *Server) authorize(ctx context.Context, method string) error {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get("authorization") {
+			if t, ok := strings.CutPrefix(value, "Bearer "); ok {
+				token = t
+			}
+		}
+	}
+
+	name, granted := s.tokens.Lookup(token)
+	if name == "" {
+		return status.Error(codes.Unauthenticated, "missing or invalid token")
+	}
+	required, ok := methodRoles[method]
+	if !ok || !admin.Allows(granted, required) {
+		log.Warnf("gRPC API: token %s (%s) denied %s", name, granted, method)
+		return status.Errorf(codes.PermissionDenied, "%s role required", required)
+	}
+	log.Debugf("gRPC API: %s by %s", method, name)
+	return nil
+}
+
+// ListJobs configured jobs
+func (s *Server) ListJobs(ctx context.Context, req *managerv1.ListJobsRequest) (*managerv1.ListJobsResponse, error) {
+	resp := &managerv1.ListJobsResponse{}
+	for _, job := range s.scheduler.Jobs() {
+		resp.Jobs = append(resp.Jobs, &managerv1.Job{
+			Type:     job.Type,
+			Index:    job.Index,
+			Schedule: job.Schedule,
+			Labels:   job.Labels,
+		})
+	}
+	return resp, nil
+}
+
+// TriggerJob start job now, with overrides of request
+func (s *Server) TriggerJob(ctx context.Context, req *managerv1.TriggerJobRequest) (*managerv1.TriggerJobResponse, error) {
+	overrides, err := run.ParseOverrides(req.GetDate(), req.GetFrom(), req.GetTo(), req.GetTargetIndex(), req.GetDryRun())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	runID, err := s.scheduler.Trigger(req.GetType(), req.GetIndex(), overrides)
+	switch {
+	case errors.Is(err, errs.ErrInvalidConfig):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, admin.ErrUnknownJob):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, admin.ErrBusy):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &managerv1.TriggerJobResponse{RunId: runID}, nil
+}
+
+// WatchRuns stream run events matching request filters
+func (s *Server) WatchRuns(req *managerv1.WatchRunsRequest, stream managerv1.JobService_WatchRunsServer) error {
+	ch, cancel := s.hub.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev := <-ch:
+			if (req.GetType() != "" && req.GetType() != ev.Type) || (req.GetIndex() != "" && req.GetIndex() != ev.Index) {
+				continue
+			}
+			err := stream.Send(&managerv1.RunEvent{
+				Kind:      ev.Kind,
+				Type:      ev.Type,
+				Index:     ev.Index,
+				RunId:     ev.RunID,
+				Time:      timestamppb.New(ev.Time),
+				Error:     ev.Error,
+				ErrorKind: ev.ErrorKind,
+				Message:   ev.Message,
+				Done:      int32(ev.Done),
+				Total:     int32(ev.Total),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/admin"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/rpc/managerv1"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// triggerScheduler records overrides of triggered runs; other Scheduler methods are not used
+type triggerScheduler struct {
+	admin.Scheduler
+	err       error
+	overrides run.Overrides
+}
+
+func (s *triggerScheduler) Trigger(jobType, index string, overrides run.Overrides) (string, error) {
+	s.overrides = overrides
+	return "run-1", s.err
+}
+
+func TestTriggerJob(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       *managerv1.TriggerJobRequest
+		err       error // of scheduler
+		wantCode  codes.Code
+		overrides run.Overrides
+	}{
+		{"no overrides", &managerv1.TriggerJobRequest{Type: "backup", Index: "logs"}, nil, codes.OK, run.Overrides{}},
+		{"date", &managerv1.TriggerJobRequest{Type: "backup", Index: "logs", Date: "2026-03-10", DryRun: true}, nil, codes.OK,
+			run.Overrides{Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), DryRun: true}},
+		{"range", &managerv1.TriggerJobRequest{Type: "backup", Index: "logs", From: "2026-03-10T06:00:00Z", To: "2026-03-10T12:00:00Z", TargetIndex: "logs-eu"}, nil, codes.OK,
+			run.Overrides{From: time.Date(2026, 3, 10, 6, 0, 0, 0, time.UTC), To: time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC), Index: "logs-eu"}},
+		{"invalid date", &managerv1.TriggerJobRequest{Type: "backup", Index: "logs", Date: "10.03.2026"}, nil, codes.InvalidArgument, run.Overrides{}},
+		{"from without to", &managerv1.TriggerJobRequest{Type: "backup", Index: "logs", From: "2026-03-10"}, nil, codes.InvalidArgument, run.Overrides{}},
+		{"rejected by job", &managerv1.TriggerJobRequest{Type: "cleanup", Index: "logs", Date: "2026-03-10"},
+			fmt.Errorf("%w: cleanup takes no date", errs.ErrInvalidConfig), codes.InvalidArgument, run.Overrides{Date: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)}},
+		{"unknown job", &managerv1.TriggerJobRequest{Type: "backup", Index: "metrics"}, admin.ErrUnknownJob, codes.NotFound, run.Overrides{}},
+		{"busy", &managerv1.TriggerJobRequest{Type: "backup", Index: "logs"}, admin.ErrBusy, codes.FailedPrecondition, run.Overrides{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduler := &triggerScheduler{err: tt.err}
+			resp, err := New(nil, scheduler, nil).TriggerJob(context.Background(), tt.req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %s (%v), want %s", code, err, tt.wantCode)
+			}
+			if scheduler.overrides != tt.overrides {
+				t.Errorf("overrides = %+v, want %+v", scheduler.overrides, tt.overrides)
+			}
+			if err == nil && resp.GetRunId() != "run-1" {
+				t.Errorf("run_id = %q", resp.GetRunId())
+			}
+		})
+	}
+}
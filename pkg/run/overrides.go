@@ -0,0 +1,83 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+)
+
+type overridesKey struct{}
+
+// Overrides one-off parameters of a manually triggered run, replacing job
+// configuration for that run only; zero fields keep configured behaviour
+type Overrides struct {
+	Date   time.Time // backup the window containing this day instead of the last complete one
+	From   time.Time // backup only documents from this time...
+	To     time.Time // ...until this time (exclusive)
+	Index  string    // index or pattern instead of the configured one
+	DryRun bool      // report what would be exported or deleted without changing anything
+}
+
+// Empty check if no field is overridden
+func (o Overrides) Empty() bool {
+	return o == Overrides{}
+}
+
+// Ranged check if run exports an explicit time range
+func (o Overrides) Ranged() bool {
+	return !o.From.IsZero()
+}
+
+// ParseOverrides build overrides from CLI flags or API fields: date as
+// YYYY-MM-DD, from/to as RFC 3339 time or YYYY-MM-DD (UTC midnight)
+func ParseOverrides(date, from, to, index string, dryRun bool) (Overrides, error) {
+	o := Overrides{Index: index, DryRun: dryRun}
+	var err error
+	if date != "" {
+		if o.Date, err = time.Parse("2006-01-02", date); err != nil {
+			return Overrides{}, fmt.Errorf("%w: date %q: expected YYYY-MM-DD", errs.ErrInvalidConfig, date)
+		}
+	}
+	if o.From, err = parseTime("from", from); err != nil {
+		return Overrides{}, err
+	}
+	if o.To, err = parseTime("to", to); err != nil {
+		return Overrides{}, err
+	}
+	switch {
+	case o.From.IsZero() != o.To.IsZero():
+		return Overrides{}, fmt.Errorf("%w: from and to must be set together", errs.ErrInvalidConfig)
+	case o.Ranged() && !o.From.Before(o.To):
+		return Overrides{}, fmt.Errorf("%w: from %s must be before to %s", errs.ErrInvalidConfig, from, to)
+	case o.Ranged() && !o.Date.IsZero():
+		return Overrides{}, fmt.Errorf("%w: date and from/to are mutually exclusive", errs.ErrInvalidConfig)
+	}
+	return o, nil
+}
+
+// parseTime parse RFC 3339 time or date, zero for empty value
+func parseTime(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: %s %q: expected RFC 3339 time or YYYY-MM-DD", errs.ErrInvalidConfig, name, value)
+}
+
+// WithOverrides attach run overrides to context
+func WithOverrides(ctx context.Context, o Overrides) context.Context {
+	return context.WithValue(ctx, overridesKey{}, o)
+}
+
+// OverridesOf overrides of run from context, empty when not set
+func OverridesOf(ctx context.Context) Overrides {
+	o, _ := ctx.Value(overridesKey{}).(Overrides)
+	return o
+}
@@ -0,0 +1,106 @@
+package run
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	log "github.com/sirupsen/logrus"
+)
+
+type contextKey struct{}
+
+// NewID generate unique job execution identifier, sortable by start time
+func NewID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// WithID attach run identifier to context
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// ID run identifier from context, empty when not set
+func ID(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Ensure return context carrying run identifier, generating one when missing
+func Ensure(ctx context.Context) (context.Context, string) {
+	if id := ID(ctx); id != "" {
+		return ctx, id
+	}
+	id := NewID()
+	return WithID(ctx, id), id
+}
+
+type budgetKey struct{}
+
+// Budget retries and backoff time shared by all OpenSearch and S3 operations
+// of one run, so a dead dependency fails the run fast instead of being retried
+// for hours. Nil budget is unlimited
+type Budget struct {
+	maxRetries int
+	maxWait    time.Duration
+
+	mu      sync.Mutex
+	retries int
+	waited  time.Duration
+}
+
+// NewBudget create budget of total retries and backoff time (zero means no
+// limit), nil when both are unlimited
+func NewBudget(maxRetries int, maxWait time.Duration) *Budget {
+	if maxRetries <= 0 && maxWait <= 0 {
+		return nil
+	}
+	return &Budget{maxRetries: maxRetries, maxWait: maxWait}
+}
+
+// WithBudget attach retry budget to context
+func WithBudget(ctx context.Context, b *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, b)
+}
+
+// Retry spend one retry waiting delay from run budget of context; error
+// wrapping errs.ErrRetryBudgetExhausted when it does not fit
+func Retry(ctx context.Context, delay time.Duration) error {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if (b.maxRetries > 0 && b.retries >= b.maxRetries) || (b.maxWait > 0 && b.waited+delay > b.maxWait) {
+		return fmt.Errorf("%w: %d retries and %v of backoff spent (limits: %d retries, %v)",
+			errs.ErrRetryBudgetExhausted, b.retries, b.waited, b.maxRetries, b.maxWait)
+	}
+	b.retries++
+	b.waited += delay
+	return nil
+}
+
+// Protect call fn, turning a panic into an errs.ErrPanic error after logging
+// its stack, so a bug in one job fails only that run instead of the process;
+// goroutines started by jobs need their own Protect
+func Protect(ctx context.Context, name string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.WithFields(log.Fields{
+				"run_id": ID(ctx),
+				"stack":  string(debug.Stack()),
+			}).Errorf("Panic in %s: %v", name, r)
+			err = fmt.Errorf("%w in %s: %v", errs.ErrPanic, name, r)
+		}
+	}()
+	return fn()
+}
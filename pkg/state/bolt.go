@@ -0,0 +1,122 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// locks bucket of lock leases, beside the namespaces
+const locks = "locks"
+
+// boltStore store in a bbolt file, one bucket per namespace
+type boltStore struct {
+	db *bbolt.DB
+}
+
+// openBolt open or create bbolt database at path; fails while another
+// process holds it
+func openBolt(path string) (*boltStore, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state database %s: %w", path, err)
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) Get(_ context.Context, namespace, key string) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		if bucket := tx.Bucket([]byte(namespace)); bucket != nil {
+			if v := bucket.Get([]byte(key)); v != nil {
+				value = bytes.Clone(v)
+			}
+		}
+		return nil
+	})
+	if err == nil && value == nil {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *boltStore) Put(_ context.Context, namespace, key string, value []byte) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(namespace))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), value)
+	})
+}
+
+func (s *boltStore) Delete(_ context.Context, namespace, key string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if bucket := tx.Bucket([]byte(namespace)); bucket != nil {
+			return bucket.Delete([]byte(key))
+		}
+		return nil
+	})
+}
+
+func (s *boltStore) List(_ context.Context, namespace, prefix string) (map[string][]byte, error) {
+	values := make(map[string][]byte)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(namespace))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for k, v := cursor.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = cursor.Next() {
+			values[string(k)] = bytes.Clone(v)
+		}
+		return nil
+	})
+	return values, err
+}
+
+func (s *boltStore) Lock(_ context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	acquired := false
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(locks))
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if v := bucket.Get([]byte(name)); v != nil {
+			var held lease
+			if err := json.Unmarshal(v, &held); err == nil && held.Owner != owner && now.Before(held.Expires) {
+				return nil
+			}
+		}
+		data, err := json.Marshal(lease{Owner: owner, Expires: now.Add(ttl)})
+		if err != nil {
+			return err
+		}
+		acquired = true
+		return bucket.Put([]byte(name), data)
+	})
+	return acquired && err == nil, err
+}
+
+func (s *boltStore) Unlock(_ context.Context, name, owner string) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(locks))
+		if bucket == nil {
+			return nil
+		}
+		var held lease
+		if v := bucket.Get([]byte(name)); v == nil || json.Unmarshal(v, &held) != nil || held.Owner != owner {
+			return nil
+		}
+		return bucket.Delete([]byte(name))
+	})
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,213 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultIndex default name of state index
+const DefaultIndex = "opensearch-backup-state"
+
+// listSize most records returned by List
+const listSize = 10000
+
+const indexMapping = `{
+	"mappings": {
+		"dynamic": false,
+		"properties": {
+			"namespace": {"type": "keyword"},
+			"key": {"type": "keyword"},
+			"value": {"type": "binary"},
+			"owner": {"type": "keyword"},
+			"expires": {"type": "date"},
+			"updated_at": {"type": "date"}
+		}
+	}
+}`
+
+// indexStore store in an OpenSearch index, one document per key; writes
+// wait for refresh so every instance reads them right away
+type indexStore struct {
+	client opensearch.API
+	index  string
+}
+
+// record document of key, lease fields are set on locks only
+type record struct {
+	Namespace string     `json:"namespace"`
+	Key       string     `json:"key"`
+	Value     []byte     `json:"value,omitempty"`
+	Owner     string     `json:"owner,omitempty"`
+	Expires   *time.Time `json:"expires,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// storedRecord record with its version, for conditional writes
+type storedRecord struct {
+	Source      record `json:"_source"`
+	SeqNo       int64  `json:"_seq_no"`
+	PrimaryTerm int64  `json:"_primary_term"`
+}
+
+// openIndex store in index, created with its mapping if it does not exist
+func openIndex(ctx context.Context, client opensearch.API, index string) (*indexStore, error) {
+	if index == "" {
+		index = DefaultIndex
+	}
+	s := &indexStore{client: client, index: index}
+
+	resp, err := client.GetClient().Indices.Exists(ctx, opensearchapi.IndicesExistsReq{Indices: []string{index}})
+	if resp != nil && resp.StatusCode == http.StatusOK {
+		return s, nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return nil, fmt.Errorf("failed to check state index %s: %w", index, err)
+	}
+	if _, err := client.GetClient().Indices.Create(ctx, opensearchapi.IndicesCreateReq{
+		Index: index,
+		Body:  strings.NewReader(indexMapping),
+	}); err != nil && opensearch.ResponseStatus(err) != http.StatusBadRequest {
+		// 400 resource_already_exists when another instance created it meanwhile
+		return nil, fmt.Errorf("failed to create state index %s: %w", index, err)
+	}
+	log.Infof("Using state index %s", index)
+	return s, nil
+}
+
+// docPath path of document of key in namespace; the id is base64url, keys
+// hold slashes that do not survive the path of the client
+func (s *indexStore) docPath(namespace, key string) string {
+	return "/" + s.index + "/_doc/" + base64.RawURLEncoding.EncodeToString([]byte(namespace+"/"+key))
+}
+
+// get document of key, nil when missing
+func (s *indexStore) get(ctx context.Context, namespace, key string) (*storedRecord, error) {
+	var doc storedRecord
+	err := s.client.Do(ctx, "GET", s.docPath(namespace, key), nil, &doc)
+	if opensearch.ResponseStatus(err) == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state %s/%s: %w", namespace, key, err)
+	}
+	return &doc, nil
+}
+
+// put write document, with params selecting create or version check; false
+// on version conflict
+func (s *indexStore) put(ctx context.Context, doc record, params url.Values) (bool, error) {
+	doc.UpdatedAt = time.Now().UTC()
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return false, err
+	}
+	params.Set("refresh", "wait_for")
+	err = s.client.Do(ctx, "PUT", s.docPath(doc.Namespace, doc.Key)+"?"+params.Encode(), bytes.NewReader(body), nil)
+	if opensearch.ResponseStatus(err) == http.StatusConflict {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to write state %s/%s: %w", doc.Namespace, doc.Key, err)
+	}
+	return true, nil
+}
+
+func (s *indexStore) Get(ctx context.Context, namespace, key string) ([]byte, error) {
+	doc, err := s.get(ctx, namespace, key)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, ErrNotFound
+	}
+	return doc.Source.Value, nil
+}
+
+func (s *indexStore) Put(ctx context.Context, namespace, key string, value []byte) error {
+	_, err := s.put(ctx, record{Namespace: namespace, Key: key, Value: value}, url.Values{})
+	return err
+}
+
+func (s *indexStore) Delete(ctx context.Context, namespace, key string) error {
+	err := s.client.Do(ctx, "DELETE", s.docPath(namespace, key)+"?refresh=wait_for", nil, nil)
+	if err != nil && opensearch.ResponseStatus(err) != http.StatusNotFound {
+		return fmt.Errorf("failed to delete state %s/%s: %w", namespace, key, err)
+	}
+	return nil
+}
+
+func (s *indexStore) List(ctx context.Context, namespace, prefix string) (map[string][]byte, error) {
+	filter := []interface{}{map[string]interface{}{"term": map[string]interface{}{"namespace": namespace}}}
+	if prefix != "" {
+		filter = append(filter, map[string]interface{}{"prefix": map[string]interface{}{"key": prefix}})
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"size":  listSize,
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filter}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Hits struct {
+			Hits []storedRecord `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := s.client.Do(ctx, "POST", "/"+s.index+"/_search", bytes.NewReader(body), &resp); err != nil {
+		return nil, fmt.Errorf("failed to list state %s: %w", namespace, err)
+	}
+	values := make(map[string][]byte, len(resp.Hits.Hits))
+	for _, hit := range resp.Hits.Hits {
+		values[hit.Source.Key] = hit.Source.Value
+	}
+	return values, nil
+}
+
+func (s *indexStore) Lock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	held, err := s.get(ctx, locks, name)
+	if err != nil {
+		return false, err
+	}
+	expires := time.Now().Add(ttl).UTC()
+	doc := record{Namespace: locks, Key: name, Owner: owner, Expires: &expires}
+	if held == nil {
+		// Of instances creating the lock at once only one succeeds
+		return s.put(ctx, doc, url.Values{"op_type": {"create"}})
+	}
+	if held.Source.Owner != owner && held.Source.Expires != nil && time.Now().Before(*held.Source.Expires) {
+		return false, nil
+	}
+	// Taken over or renewed only if nobody else did since it was read
+	return s.put(ctx, doc, url.Values{
+		"if_seq_no":       {fmt.Sprint(held.SeqNo)},
+		"if_primary_term": {fmt.Sprint(held.PrimaryTerm)},
+	})
+}
+
+func (s *indexStore) Unlock(ctx context.Context, name, owner string) error {
+	held, err := s.get(ctx, locks, name)
+	if err != nil || held == nil || held.Source.Owner != owner {
+		return err
+	}
+	path := fmt.Sprintf("%s?if_seq_no=%d&if_primary_term=%d&refresh=wait_for", s.docPath(locks, name), held.SeqNo, held.PrimaryTerm)
+	err = s.client.Do(ctx, "DELETE", path, nil, nil)
+	if status := opensearch.ResponseStatus(err); err != nil && status != http.StatusNotFound && status != http.StatusConflict {
+		return fmt.Errorf("failed to release lock %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *indexStore) Close() error {
+	return nil
+}
@@ -0,0 +1,101 @@
+// Package state store of manager state that must outlive a process: job
+// locks, run history, watermarks and backup checkpoints.
+// It is embedded in a bbolt file on a single VM, or kept in an OpenSearch
+// index where instances have no persistent disk (e.g. Kubernetes replicas)
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+)
+
+// Namespaces of stored records
+const (
+	Runs        = "runs"        // finished runs by "<type>/<index>/<run_id>"
+	Watermarks  = "watermarks"  // last successful run by "<type>/<index>"
+	Checkpoints = "checkpoints" // backup progress by checkpoint object key
+)
+
+// DefaultHistory finished runs kept per job
+const DefaultHistory = 100
+
+// ErrNotFound key does not exist in namespace
+var ErrNotFound = errors.New("state not found")
+
+// Store namespaced key-value records and leased locks; implementations are
+// safe for concurrent use
+type Store interface {
+	// Get value of key, ErrNotFound when missing
+	Get(ctx context.Context, namespace, key string) ([]byte, error)
+	Put(ctx context.Context, namespace, key string, value []byte) error
+	// Delete key, missing keys are not an error
+	Delete(ctx context.Context, namespace, key string) error
+	// List values of keys starting with prefix, by key
+	List(ctx context.Context, namespace, prefix string) (map[string][]byte, error)
+	// Lock take lock name for owner, or renew it, until ttl passes; false
+	// while it is held by another owner whose lease has not expired
+	Lock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+	// Unlock release lock name if owner holds it
+	Unlock(ctx context.Context, name, owner string) error
+	Close() error
+}
+
+// Open store of cfg, nil when state is disabled; client is used by the
+// opensearch type
+func Open(ctx context.Context, cfg config.StateConfig, client opensearch.API) (Store, error) {
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case "bolt":
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("%w: state type bolt needs path", errs.ErrInvalidConfig)
+		}
+		return openBolt(cfg.Path)
+	case "opensearch":
+		return openIndex(ctx, client, cfg.Index)
+	}
+	return nil, fmt.Errorf("%w: state type %q must be bolt or opensearch", errs.ErrInvalidConfig, cfg.Type)
+}
+
+// Owner lock owner of this process, host and pid
+func Owner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}
+
+// JobKey key of job in Runs and Watermarks
+func JobKey(jobType, index string) string {
+	return jobType + "/" + index
+}
+
+// Run finished run of job
+type Run struct {
+	RunID     string    `json:"run_id"`
+	Type      string    `json:"type"`
+	Index     string    `json:"index"`
+	Status    string    `json:"status"` // "success", "skipped" or "failure"
+	Error     string    `json:"error,omitempty"`
+	ErrorKind string    `json:"error_kind,omitempty"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+	Owner     string    `json:"owner"` // instance that ran it
+}
+
+// Watermark last successful run of job
+type Watermark struct {
+	RunID    string    `json:"run_id"`
+	Finished time.Time `json:"finished"`
+}
+
+// lease holder of lock until expires
+type lease struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Backend хранилище артефактов бэкапа, общее для backup и restore; реализуется *S3Client
+type Backend interface {
+	Upload(ctx context.Context, filePath, key string, documentCount int, metadata map[string]string) error
+	UploadStream(ctx context.Context, reader io.Reader, key string, partSize uint64, metadata map[string]string) (int64, error)
+	Put(ctx context.Context, key string, data []byte, metadata map[string]string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+	Download(ctx context.Context, key string) (io.ReadCloser, error)
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, keys ...string) error
+	Tag(ctx context.Context, key string, tags map[string]string) error
+	AbortIncompleteUploads(ctx context.Context, prefix string, before time.Time) (int, error)
+	SetLifecycleRules(ctx context.Context, idPrefix string, rules []LifecycleRule) error
+}
+
+// ObjectInfo размер, ETag и пользовательские метаданные объекта в хранилище
+type ObjectInfo struct {
+	Size     int64
+	ETag     string            // MD5 содержимого или "<md5>-<parts>" для multipart загрузок
+	Metadata map[string]string // пользовательские метаданные, ключи в нижнем регистре без x-amz-meta-
+}
+
+// LifecycleRule правило lifecycle бакета для объектов под префиксом
+type LifecycleRule struct {
+	ID           string
+	Prefix       string
+	ExpireDays   int    // 0 без удаления
+	TransitDays  int    // перевод в StorageClass через N дней
+	StorageClass string // пусто без перевода
+}
+
+var _ Backend = (*S3Client)(nil)
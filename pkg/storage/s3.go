@@ -0,0 +1,534 @@
+// Package storage S3/MinIO клиент для загрузки бэкапов
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	"github.com/minio/minio-go/v7/pkg/tags"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/errs"
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+	"github.com/okto/opensearch-backup-manager/pkg/run"
+	log "github.com/sirupsen/logrus"
+)
+
+// S3Client клиент для работы с S3/MinIO
+type S3Client struct {
+	client  *minio.Client
+	bucket  string
+	headers config.ObjectHeaders
+	verify  bool   // HEAD после каждой загрузки
+	prefix  string // s3.key_prefix, добавляется ко всем ключам
+}
+
+// NewS3Client создает новый S3/MinIO клиент
+func NewS3Client(cfg config.S3Config) (*S3Client, error) {
+	// Определяем endpoint (по умолчанию s3.amazonaws.com)
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "s3.amazonaws.com"
+	}
+
+	log.WithFields(log.Fields{
+		"endpoint": endpoint,
+		"bucket":   cfg.Bucket,
+		"region":   cfg.Region,
+		"use_ssl":  cfg.UseSSL,
+		"ca_cert":  cfg.CACert,
+		"prefix":   cfg.KeyPrefix,
+	}).Info("Initializing S3 client")
+
+	transport, err := minio.DefaultTransport(cfg.UseSSL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 transport: %w", err)
+	}
+	if err := configureTLS(transport, cfg); err != nil {
+		return nil, err
+	}
+
+	// Создаем MinIO клиент
+	minioClient, err := minio.New(endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
+		Secure:    cfg.UseSSL,
+		Region:    cfg.Region,
+		Transport: instrumentedTransport{next: transport, bucket: cfg.Bucket},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MinIO client: %w", err)
+	}
+	if cfg.Accelerate {
+		// Загрузки идут через ближайшую edge-точку AWS, бакет должен иметь включенное ускорение
+		minioClient.SetS3TransferAccelerate(accelerateEndpoint)
+	}
+	if cfg.Trace {
+		// Полные HTTP запросы и ответы (подпись скрыта) в debug лог
+		minioClient.TraceOn(log.StandardLogger().WriterLevel(log.DebugLevel))
+	}
+
+	// Проверяем доступ к бакету
+	ctx := context.Background()
+	exists, err := minioClient.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		log.Warnf("Failed to check bucket existence: %v", err)
+	} else if !exists {
+		log.Warnf("Bucket %s does not exist or no access", cfg.Bucket)
+	} else {
+		log.Infof("Successfully connected to bucket: %s", cfg.Bucket)
+	}
+
+	return &S3Client{
+		client:  minioClient,
+		bucket:  cfg.Bucket,
+		headers: cfg.Objects,
+		verify:  !cfg.SkipUploadVerify,
+		prefix:  config.NormalizePrefix(cfg.KeyPrefix),
+	}, nil
+}
+
+// objectKey ключ в бакете с учетом key_prefix; вызывающие работают с ключами
+// без префикса, так что несколько инстансов делят бакет без коллизий
+func (c *S3Client) objectKey(key string) string {
+	return c.prefix + key
+}
+
+// accelerateEndpoint endpoint S3 Transfer Acceleration
+const accelerateEndpoint = "s3-accelerate.amazonaws.com"
+
+// tlsVersions допустимые значения tls_min_version
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// configureTLS добавляет CA bundle к системным корневым сертификатам (например,
+// для TLS-перехватывающего прокси) и задает минимальную версию TLS
+func configureTLS(transport *http.Transport, cfg config.S3Config) error {
+	if cfg.CACert == "" && cfg.TLSMinVersion == "" {
+		return nil
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+
+	if cfg.CACert != "" {
+		bundle, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return fmt.Errorf("failed to read S3 CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return fmt.Errorf("%w: no certificates in S3 CA bundle %s", errs.ErrInvalidConfig, cfg.CACert)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	if cfg.TLSMinVersion != "" {
+		version, ok := tlsVersions[cfg.TLSMinVersion]
+		if !ok {
+			return fmt.Errorf("%w: tls_min_version %q must be 1.0, 1.1, 1.2 or 1.3", errs.ErrInvalidConfig, cfg.TLSMinVersion)
+		}
+		transport.TLSClientConfig.MinVersion = version
+	}
+	return nil
+}
+
+// Upload загружает файл в S3/MinIO с retry механизмом, metadata сохраняется
+// как пользовательские метаданные объекта
+func (c *S3Client) Upload(ctx context.Context, filePath, key string, documentCount int, metadata map[string]string) error {
+	key = c.objectKey(key)
+	const maxRetries = 3
+	const baseDelay = 2 * time.Second
+
+	// Получаем информацию о файле
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	log.Infof("Uploading %s (%d documents) to s3://%s/%s", filePath, documentCount, c.bucket, key)
+
+	var lastErr error
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		start := time.Now()
+		// Открываем файл для каждой попытки
+		file, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+
+		// Загружаем файл
+		info, err := c.client.PutObject(
+			ctx,
+			c.bucket,
+			key,
+			file,
+			fileInfo.Size(),
+			c.putOptions(key, metadata),
+		)
+		file.Close()
+
+		if err == nil {
+			err = c.verifyUpload(ctx, key, info, fileInfo.Size())
+		}
+		if err == nil {
+			parts, _, _, _ := minio.OptimalPartInfo(fileInfo.Size(), 0)
+			observeUpload("file", fileInfo.Size(), max(parts, 1), time.Since(start))
+			log.Infof("Successfully uploaded %d documents to %s/%s (etag: %s)",
+				documentCount, c.bucket, key, info.ETag)
+			return nil
+		}
+
+		lastErr = err
+		log.WithFields(log.Fields{
+			"attempt":      attempt,
+			"max_attempts": maxRetries,
+			"error":        err.Error(),
+		}).Errorf("Upload attempt %d failed", attempt)
+
+		// Если это не последняя попытка, ждем перед повтором
+		if attempt < maxRetries {
+			delay := baseDelay * time.Duration(attempt)
+			// Бюджет повторов запуска исчерпан — не ждем, сразу возвращаем ошибку
+			if budgetErr := run.Retry(ctx, delay); budgetErr != nil {
+				return &errs.UploadError{Key: key, Retriable: retriable(err), Err: fmt.Errorf("%w: %w", budgetErr, err)}
+			}
+			metrics.UploadRetries.Inc()
+			log.Infof("Retrying in %v...", delay)
+			time.Sleep(delay)
+		}
+	}
+
+	return &errs.UploadError{
+		Key:       key,
+		Retriable: retriable(lastErr),
+		Err:       fmt.Errorf("failed after %d attempts: %w", maxRetries, lastErr),
+	}
+}
+
+// observeUpload метрики завершенной загрузки: скорость и число частей
+func observeUpload(mode string, size int64, parts int, elapsed time.Duration) {
+	if elapsed > 0 {
+		metrics.UploadThroughput.WithLabelValues(mode).Observe(float64(size) / elapsed.Seconds())
+	}
+	metrics.UploadParts.Observe(float64(max(parts, 1)))
+	log.WithFields(log.Fields{
+		"mode":        mode,
+		"bytes":       size,
+		"parts":       parts,
+		"duration_ms": elapsed.Milliseconds(),
+	}).Debug("Upload finished")
+}
+
+// verifyAttempts попытки HEAD запроса, пока объект не станет виден в хранилищах
+// с eventual consistency
+const verifyAttempts = 3
+
+// verifyUpload HEAD запросом проверяет, что загруженный объект виден с ожидаемым
+// размером, ETag и контрольной суммой; молча обрезанная загрузка или объект,
+// который еще не виден, считаются ошибкой загрузки
+func (c *S3Client) verifyUpload(ctx context.Context, key string, uploaded minio.UploadInfo, size int64) error {
+	if !c.verify {
+		return nil
+	}
+
+	var stat minio.ObjectInfo
+	var err error
+	for attempt := 1; attempt <= verifyAttempts; attempt++ {
+		stat, err = c.client.StatObject(ctx, c.bucket, key, minio.StatObjectOptions{Checksum: true})
+		if err == nil || minio.ToErrorResponse(err).Code != "NoSuchKey" || attempt == verifyAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * time.Second):
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("upload verification of %s failed: %w", key, err)
+	}
+
+	if stat.Size != size {
+		return fmt.Errorf("upload verification of %s failed: size %d, want %d", key, stat.Size, size)
+	}
+	if uploaded.ETag != "" && strings.Trim(stat.ETag, `"`) != strings.Trim(uploaded.ETag, `"`) {
+		return fmt.Errorf("upload verification of %s failed: etag %s, want %s", key, stat.ETag, uploaded.ETag)
+	}
+	if uploaded.ChecksumCRC32C != "" && stat.ChecksumCRC32C != "" && stat.ChecksumCRC32C != uploaded.ChecksumCRC32C {
+		return fmt.Errorf("upload verification of %s failed: crc32c %s, want %s", key, stat.ChecksumCRC32C, uploaded.ChecksumCRC32C)
+	}
+	log.Debugf("Verified upload of %s (%d bytes, etag: %s)", key, stat.Size, stat.ETag)
+	return nil
+}
+
+// minStreamPartSize минимальный размер части multipart загрузки в S3
+const minStreamPartSize = 5 << 20
+
+// UploadStream загружает поток неизвестной длины multipart загрузкой, держа в памяти
+// одну часть размером partSize. Поток нельзя перемотать, поэтому повторов нет:
+// при ошибке чтения или записи незавершенная загрузка отменяется
+func (c *S3Client) UploadStream(ctx context.Context, reader io.Reader, key string, partSize uint64, metadata map[string]string) (int64, error) {
+	key = c.objectKey(key)
+	if partSize < minStreamPartSize {
+		partSize = minStreamPartSize
+	}
+
+	log.Infof("Streaming upload to s3://%s/%s (part size: %d bytes)", c.bucket, key, partSize)
+	start := time.Now()
+
+	opts := c.putOptions(key, metadata)
+	opts.PartSize = partSize
+	opts.NumThreads = 1
+	info, err := c.client.PutObject(ctx, c.bucket, key, reader, -1, opts)
+	if err == nil {
+		err = c.verifyUpload(ctx, key, info, info.Size)
+	}
+	if err != nil {
+		return 0, &errs.UploadError{Key: key, Retriable: retriable(err), Err: err}
+	}
+	observeUpload("stream", info.Size, int((uint64(info.Size)+partSize-1)/partSize), time.Since(start))
+
+	log.Infof("Successfully streamed %d bytes to %s/%s (etag: %s)", info.Size, c.bucket, key, info.ETag)
+	return info.Size, nil
+}
+
+// Put загружает небольшой объект из памяти (манифесты и служебные файлы)
+func (c *S3Client) Put(ctx context.Context, key string, data []byte, metadata map[string]string) error {
+	key = c.objectKey(key)
+	start := time.Now()
+	info, err := c.client.PutObject(ctx, c.bucket, key, bytes.NewReader(data), int64(len(data)), c.putOptions(key, metadata))
+	if err == nil {
+		err = c.verifyUpload(ctx, key, info, int64(len(data)))
+	}
+	if err != nil {
+		return &errs.UploadError{Key: key, Retriable: retriable(err), Err: err}
+	}
+	observeUpload("put", int64(len(data)), 1, time.Since(start))
+	return nil
+}
+
+// Exists проверяет наличие объекта по ключу
+func (c *S3Client) Exists(ctx context.Context, key string) (bool, error) {
+	key = c.objectKey(key)
+	_, err := c.client.StatObject(ctx, c.bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return true, nil
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to stat object: %w", err)
+}
+
+// Stat возвращает размер и ETag объекта
+func (c *S3Client) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	key = c.objectKey(key)
+	info, err := c.client.StatObject(ctx, c.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("failed to stat object %s: %w", key, err)
+	}
+	metadata := make(map[string]string, len(info.UserMetadata))
+	for k, v := range info.UserMetadata {
+		metadata[strings.ToLower(k)] = v
+	}
+	return ObjectInfo{Size: info.Size, ETag: strings.Trim(info.ETag, `"`), Metadata: metadata}, nil
+}
+
+// Get читает небольшой объект целиком в память
+func (c *S3Client) Get(ctx context.Context, key string) ([]byte, error) {
+	key = c.objectKey(key)
+	object, err := c.client.GetObject(ctx, c.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	defer object.Close()
+
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// Download открывает объект на чтение потоком; закрыть должен вызывающий
+func (c *S3Client) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	key = c.objectKey(key)
+	object, err := c.client.GetObject(ctx, c.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	// GetObject ленивый, ошибку отсутствия объекта возвращает Stat
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	return object, nil
+}
+
+// List возвращает ключи объектов с префиксом, рекурсивно
+func (c *S3Client) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for object := range c.client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{Prefix: c.objectKey(prefix), Recursive: true}) {
+		if object.Err != nil {
+			return keys, fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		keys = append(keys, strings.TrimPrefix(object.Key, c.prefix))
+	}
+	return keys, nil
+}
+
+// Delete удаляет объекты; отсутствующие ключи не считаются ошибкой
+func (c *S3Client) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		key = c.objectKey(key)
+		if err := c.client.RemoveObject(ctx, c.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("failed to delete object %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// Tag заменяет теги объекта, например для lifecycle правил бакета
+func (c *S3Client) Tag(ctx context.Context, key string, values map[string]string) error {
+	key = c.objectKey(key)
+	objectTags, err := tags.NewTags(values, true)
+	if err != nil {
+		return fmt.Errorf("invalid tags: %w", err)
+	}
+	if err := c.client.PutObjectTagging(ctx, c.bucket, key, objectTags, minio.PutObjectTaggingOptions{}); err != nil {
+		return fmt.Errorf("failed to tag object %s: %w", key, err)
+	}
+	return nil
+}
+
+// AbortIncompleteUploads отменяет незавершенные multipart загрузки под префиксом,
+// начатые раньше before (оставшиеся от упавших попыток), возвращает число отмененных
+func (c *S3Client) AbortIncompleteUploads(ctx context.Context, prefix string, before time.Time) (int, error) {
+	core := minio.Core{Client: c.client}
+	aborted := 0
+	for upload := range c.client.ListIncompleteUploads(ctx, c.bucket, c.objectKey(prefix), true) {
+		if upload.Err != nil {
+			return aborted, fmt.Errorf("failed to list incomplete uploads: %w", upload.Err)
+		}
+		if !upload.Initiated.Before(before) {
+			// Возможно, загрузка еще идет
+			continue
+		}
+		if err := core.AbortMultipartUpload(ctx, c.bucket, upload.Key, upload.UploadID); err != nil {
+			return aborted, fmt.Errorf("failed to abort upload of %s: %w", upload.Key, err)
+		}
+		log.Infof("Aborted incomplete upload of s3://%s/%s started %s (%d bytes)",
+			c.bucket, upload.Key, upload.Initiated.Format(time.RFC3339), upload.Size)
+		aborted++
+	}
+	return aborted, nil
+}
+
+// SetLifecycleRules заменяет правила lifecycle бакета с ID, начинающимся с idPrefix,
+// на rules; остальные правила бакета не меняются
+func (c *S3Client) SetLifecycleRules(ctx context.Context, idPrefix string, rules []LifecycleRule) error {
+	current, err := c.client.GetBucketLifecycle(ctx, c.bucket)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchLifecycleConfiguration" {
+			return fmt.Errorf("failed to read bucket lifecycle: %w", err)
+		}
+		current = lifecycle.NewConfiguration()
+	}
+
+	updated := lifecycle.NewConfiguration()
+	for _, rule := range current.Rules {
+		if !strings.HasPrefix(rule.ID, idPrefix) {
+			updated.Rules = append(updated.Rules, rule)
+		}
+	}
+	for _, rule := range rules {
+		lc := lifecycle.Rule{
+			ID:         rule.ID,
+			Status:     "Enabled",
+			RuleFilter: lifecycle.Filter{Prefix: c.objectKey(rule.Prefix)},
+		}
+		if rule.ExpireDays > 0 {
+			lc.Expiration = lifecycle.Expiration{Days: lifecycle.ExpirationDays(rule.ExpireDays)}
+		}
+		if rule.StorageClass != "" {
+			lc.Transition = lifecycle.Transition{Days: lifecycle.ExpirationDays(rule.TransitDays), StorageClass: rule.StorageClass}
+		}
+		updated.Rules = append(updated.Rules, lc)
+	}
+
+	if err := c.client.SetBucketLifecycle(ctx, c.bucket, updated); err != nil {
+		return fmt.Errorf("failed to update bucket lifecycle: %w", err)
+	}
+	return nil
+}
+
+// retriable ошибка загрузки временная: сеть, throttling или 5xx
+func retriable(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	resp := minio.ToErrorResponse(err)
+	if resp.StatusCode == 0 {
+		// Ответа нет — сетевая ошибка
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError ||
+		resp.Code == "SlowDown" || resp.Code == "RequestTimeout"
+}
+
+// contentTypes заголовки Content-Type по последнему расширению ключа;
+// неизвестные расширения загружаются как gzip
+var contentTypes = map[string]string{
+	".gz":      "application/gzip",
+	".zst":     "application/zstd",
+	".json":    "application/json",
+	".ndjson":  "application/x-ndjson",
+	".csv":     "text/csv",
+	".avro":    "application/avro",
+	".parquet": "application/vnd.apache.parquet",
+	".tar":     "application/x-tar",
+	".age":     "application/octet-stream", // зашифрованный артефакт
+	".zdict":   "application/octet-stream", // словарь zstd
+}
+
+// contentTypeOf Content-Type объекта: сначала s3.objects.content_types, затем встроенные
+func (c *S3Client) contentTypeOf(key string) string {
+	ext := path.Ext(key)
+	if contentType, ok := c.headers.ContentTypes[ext]; ok {
+		return contentType
+	}
+	if contentType, ok := contentTypes[ext]; ok {
+		return contentType
+	}
+	return "application/gzip"
+}
+
+// putOptions заголовки и метаданные загружаемого объекта
+func (c *S3Client) putOptions(key string, metadata map[string]string) minio.PutObjectOptions {
+	return minio.PutObjectOptions{
+		ContentType:     c.contentTypeOf(key),
+		ContentEncoding: c.headers.ContentEncodings[path.Ext(key)],
+		CacheControl:    c.headers.CacheControl,
+		UserMetadata:    metadata,
+	}
+}
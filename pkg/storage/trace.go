@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/okto/opensearch-backup-manager/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// instrumentedTransport замеряет каждый HTTP запрос к S3: задержку по операции,
+// переданные байты; при уровне debug запрос пишется в лог
+type instrumentedTransport struct {
+	next   http.RoundTripper
+	bucket string
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	operation := operationOf(req, t.isObject(req.URL))
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.StorageRequestDuration.WithLabelValues(operation, code).Observe(elapsed.Seconds())
+	if req.ContentLength > 0 {
+		metrics.StorageBytes.WithLabelValues("sent").Add(float64(req.ContentLength))
+	}
+	if err == nil && resp.Body != nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body}
+	}
+
+	if log.IsLevelEnabled(log.DebugLevel) {
+		log.WithFields(log.Fields{
+			"operation":   operation,
+			"method":      req.Method,
+			"path":        req.URL.Path,
+			"code":        code,
+			"duration_ms": elapsed.Milliseconds(),
+			"bytes_sent":  req.ContentLength,
+		}).Debug("S3 request")
+	}
+	return resp, err
+}
+
+// countingBody учитывает прочитанные байты ответа
+type countingBody struct {
+	io.ReadCloser
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		metrics.StorageBytes.WithLabelValues("received").Add(float64(n))
+	}
+	return n, err
+}
+
+// operationOf имя S3 операции по методу и параметрам запроса
+func operationOf(req *http.Request, objectPath bool) string {
+	query := req.URL.Query()
+	has := func(key string) bool {
+		_, ok := query[key]
+		return ok
+	}
+
+	switch req.Method {
+	case http.MethodPut:
+		switch {
+		case has("partNumber"):
+			return "upload_part"
+		case has("tagging"):
+			return "put_tagging"
+		case objectPath:
+			return "put_object"
+		}
+		return "put_bucket"
+	case http.MethodPost:
+		switch {
+		case has("uploads"):
+			return "create_multipart"
+		case has("uploadId"):
+			return "complete_multipart"
+		case has("delete"):
+			return "delete_objects"
+		}
+		return "post"
+	case http.MethodHead:
+		if objectPath {
+			return "head_object"
+		}
+		return "head_bucket"
+	case http.MethodGet:
+		switch {
+		case has("location"):
+			return "get_bucket_location"
+		case objectPath:
+			return "get_object"
+		}
+		return "list_objects"
+	case http.MethodDelete:
+		if has("uploadId") {
+			return "abort_multipart"
+		}
+		return "delete_object"
+	}
+	return req.Method
+}
+
+// isObject запрос к объекту, а не к бакету: в path-style URL ключ идет после
+// /bucket/, в virtual-host (бакет в имени хоста) path содержит только ключ
+func (t instrumentedTransport) isObject(u *url.URL) bool {
+	path := strings.TrimPrefix(u.Path, "/")
+	if !strings.HasPrefix(u.Host, t.bucket+".") {
+		path = strings.TrimPrefix(strings.TrimPrefix(path, t.bucket), "/")
+	}
+	return path != ""
+}
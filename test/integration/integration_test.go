@@ -0,0 +1,422 @@
+//go:build integration
+
+// Package integration runs backup and cleanup flows against real OpenSearch and
+// MinIO started from docker-compose.yml in this directory
+package integration
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/okto/opensearch-backup-manager/pkg/backup"
+	"github.com/okto/opensearch-backup-manager/pkg/cleanup"
+	"github.com/okto/opensearch-backup-manager/pkg/clock"
+	"github.com/okto/opensearch-backup-manager/pkg/config"
+	"github.com/okto/opensearch-backup-manager/pkg/firedrill"
+	"github.com/okto/opensearch-backup-manager/pkg/manifest"
+	"github.com/okto/opensearch-backup-manager/pkg/opensearch"
+	"github.com/okto/opensearch-backup-manager/pkg/restore"
+	"github.com/okto/opensearch-backup-manager/pkg/state"
+	"github.com/okto/opensearch-backup-manager/pkg/storage"
+	opensearchapi "github.com/opensearch-project/opensearch-go/v4/opensearchapi"
+)
+
+const bucket = "integration"
+
+type env struct {
+	client  *opensearch.Client
+	storage *storage.S3Client
+	minio   *minio.Client
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func setup(t *testing.T) *env {
+	t.Helper()
+	ctx := context.Background()
+
+	client, err := opensearch.NewClient(config.OpenSearchConfig{
+		Addresses: []string{getEnv("IT_OPENSEARCH_URL", "http://localhost:9200")},
+	})
+	if err != nil {
+		t.Fatalf("create OpenSearch client: %v", err)
+	}
+
+	s3 := config.S3Config{
+		Endpoint:        getEnv("IT_S3_ENDPOINT", "localhost:9000"),
+		AccessKeyID:     getEnv("IT_S3_ACCESS_KEY", "minioadmin"),
+		SecretAccessKey: getEnv("IT_S3_SECRET_KEY", "minioadmin"),
+		Bucket:          bucket,
+	}
+	minioClient, err := minio.New(s3.Endpoint, &minio.Options{
+		Creds: credentials.NewStaticV4(s3.AccessKeyID, s3.SecretAccessKey, ""),
+	})
+	if err != nil {
+		t.Fatalf("create MinIO client: %v", err)
+	}
+	if exists, err := minioClient.BucketExists(ctx, bucket); err != nil {
+		t.Fatalf("check bucket: %v", err)
+	} else if !exists {
+		if err := minioClient.MakeBucket(ctx, bucket, minio.MakeBucketOptions{}); err != nil {
+			t.Fatalf("create bucket: %v", err)
+		}
+	}
+
+	s3Client, err := storage.NewS3Client(s3)
+	if err != nil {
+		t.Fatalf("create S3 client: %v", err)
+	}
+
+	return &env{client: client, storage: s3Client, minio: minioClient}
+}
+
+// seed index documents at given timestamps and refresh
+func (e *env) seed(t *testing.T, index string, timestamps []time.Time) {
+	t.Helper()
+
+	var body bytes.Buffer
+	for i, ts := range timestamps {
+		fmt.Fprintf(&body, `{"index":{"_index":%q}}`+"\n", index)
+		fmt.Fprintf(&body, `{"@timestamp":%q,"n":%d}`+"\n", ts.UTC().Format(time.RFC3339Nano), i)
+	}
+
+	var resp struct {
+		Errors bool `json:"errors"`
+	}
+	if err := e.client.Do(context.Background(), "POST", "/_bulk?refresh=true", &body, &resp); err != nil {
+		t.Fatalf("bulk index: %v", err)
+	}
+	if resp.Errors {
+		t.Fatalf("bulk index %s reported errors", index)
+	}
+
+	t.Cleanup(func() {
+		e.client.Do(context.Background(), "DELETE", "/"+index, nil, nil)
+	})
+}
+
+func (e *env) count(t *testing.T, index string) int {
+	t.Helper()
+
+	var resp struct {
+		Count int `json:"count"`
+	}
+	if err := e.client.Do(context.Background(), "GET", "/"+index+"/_count", nil, &resp); err != nil {
+		t.Fatalf("count %s: %v", index, err)
+	}
+	return resp.Count
+}
+
+// archivedDocs download all objects under prefix and count archived documents
+func (e *env) archivedDocs(t *testing.T, prefix string, rawSource bool) int {
+	t.Helper()
+	ctx := context.Background()
+
+	docs := 0
+	for object := range e.minio.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			t.Fatalf("list objects: %v", object.Err)
+		}
+		if strings.HasSuffix(object.Key, ".manifest.json") || strings.HasSuffix(object.Key, ".index.json") {
+			continue
+		}
+
+		reader, err := e.minio.GetObject(ctx, bucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			t.Fatalf("get %s: %v", object.Key, err)
+		}
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			t.Fatalf("gunzip %s: %v", object.Key, err)
+		}
+
+		scanner := bufio.NewScanner(gz)
+		scanner.Buffer(make([]byte, 1<<20), 64<<20)
+		for scanner.Scan() {
+			if rawSource {
+				docs++
+				continue
+			}
+			var page struct {
+				Hits struct {
+					Hits []json.RawMessage `json:"hits"`
+				} `json:"hits"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &page); err != nil {
+				t.Fatalf("decode page of %s: %v", object.Key, err)
+			}
+			docs += len(page.Hits.Hits)
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("read %s: %v", object.Key, err)
+		}
+		reader.Close()
+	}
+	return docs
+}
+
+// yesterday timestamps spread over the previous UTC day
+func yesterday(now time.Time, count int) []time.Time {
+	day := now.UTC().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+	step := 24 * time.Hour / time.Duration(count)
+
+	timestamps := make([]time.Time, count)
+	for i := range timestamps {
+		timestamps[i] = day.Add(time.Duration(i) * step)
+	}
+	return timestamps
+}
+
+func TestBackupVerify(t *testing.T) {
+	e := setup(t)
+	now := time.Now()
+
+	cases := []struct {
+		name string
+		job  config.BackupJob
+	}{
+		{name: "artifact", job: config.BackupJob{IntervalHours: 6, PageSize: 7, MaxDocsPerFile: 20}},
+		{name: "chunked", job: config.BackupJob{IntervalHours: 6, PageSize: 7, Chunked: true}},
+		{name: "checkpoint", job: config.BackupJob{IntervalHours: 6, PageSize: 7, Chunked: true, Checkpoint: true}},
+		{name: "sliced", job: config.BackupJob{IntervalHours: 6, PageSize: 7, Chunked: true, MaxDocsPerFile: 5, Slices: 3}},
+		{name: "stream", job: config.BackupJob{IntervalHours: 24, PageSize: 7, Stream: true}},
+		{name: "raw_source", job: config.BackupJob{IntervalHours: 4, RawSource: true}},
+		{name: "spot_check", job: config.BackupJob{IntervalHours: 6, Format: "bulk", SpotCheck: config.SpotCheck{Enabled: true, Documents: 10}}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			index := fmt.Sprintf("it-backup-%s-%d", strings.ReplaceAll(tc.name, "_", "-"), now.UnixNano())
+			e.seed(t, index, yesterday(now, 50))
+
+			workDir := t.TempDir()
+			svc, err := backup.New(backup.Options{
+				Client:  e.client,
+				Storage: e.storage,
+				Clock:   clock.Fixed(now),
+				WorkDir: workDir,
+			})
+			if err != nil {
+				t.Fatalf("create backup service: %v", err)
+			}
+
+			job := tc.job
+			job.IndexName = index
+			job.S3Path = path.Join("backups", index)
+			if err := svc.Backup(context.Background(), job); err != nil {
+				t.Fatalf("backup: %v", err)
+			}
+
+			if got := e.archivedDocs(t, job.S3Path+"/", job.RawSource); got != 50 {
+				t.Errorf("archived %d documents, want 50", got)
+			}
+			entries, err := manifest.List(context.Background(), e.storage, job.S3Path, index)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("list manifests: %d, %v", len(entries), err)
+			}
+			day := entries[0].Date
+			if got, ok := entries[0].Manifest.HourCount(day, day.Add(24*time.Hour)); !ok || got != 50 {
+				t.Errorf("hour histogram holds %d documents (recorded %v), want 50", got, ok)
+			}
+			if got, _ := entries[0].Manifest.HourCount(day, day.Add(6*time.Hour)); got != 13 {
+				t.Errorf("hour histogram holds %d documents of first 6 hours, want 13", got)
+			}
+			if left, _ := os.ReadDir(workDir); len(left) > 0 {
+				t.Errorf("%d entries left in work dir", len(left))
+			}
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	e := setup(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	index := fmt.Sprintf("it-restore-%d", now.UnixNano())
+	e.seed(t, index, yesterday(now, 30))
+
+	svc, err := backup.New(backup.Options{Client: e.client, Storage: e.storage, Clock: clock.Fixed(now), WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("create backup service: %v", err)
+	}
+	job := config.BackupJob{IndexName: index, IntervalHours: 6, PageSize: 7, S3Path: path.Join("backups", index)}
+	if err := svc.Backup(ctx, job); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+
+	target := index + "-restored"
+	t.Cleanup(func() {
+		e.client.Do(context.Background(), "DELETE", "/"+target, nil, nil)
+	})
+
+	cfg := &config.Config{Restore: config.RestoreConfig{
+		BulkSize:  8,
+		Transform: config.RestoreTransform{Rename: map[string]string{"n": "seq"}},
+	}}
+	restorer, err := restore.New(restore.Options{Client: e.client, Storage: e.storage, Config: cfg})
+	if err != nil {
+		t.Fatalf("create restore service: %v", err)
+	}
+
+	// Located through manifest of the backup
+	day := now.AddDate(0, 0, -1)
+	result, err := restorer.RestoreRange(ctx, restore.RangeRequest{
+		Index:       index,
+		S3Path:      job.S3Path,
+		From:        day,
+		To:          day,
+		TargetIndex: target,
+	})
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if result.Days != 1 || result.Documents != 30 {
+		t.Errorf("restored %d documents from %d days, want 30 from 1", result.Documents, result.Days)
+	}
+
+	e.client.Do(ctx, "POST", "/"+target+"/_refresh", nil, nil)
+	var resp struct {
+		Count int `json:"count"`
+	}
+	query := strings.NewReader(`{"query":{"exists":{"field":"seq"}}}`)
+	if err := e.client.Do(ctx, "POST", "/"+target+"/_count", query, &resp); err != nil {
+		t.Fatalf("count renamed: %v", err)
+	}
+	if resp.Count != 30 {
+		t.Errorf("%d documents with renamed field, want 30", resp.Count)
+	}
+}
+
+func TestFireDrill(t *testing.T) {
+	e := setup(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	index := fmt.Sprintf("it-drill-%d", now.UnixNano())
+	e.seed(t, index, yesterday(now, 25))
+
+	svc, err := backup.New(backup.Options{Client: e.client, Storage: e.storage, Clock: clock.Fixed(now), WorkDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("create backup service: %v", err)
+	}
+	job := config.BackupJob{IndexName: index, IntervalHours: 6, PageSize: 10, S3Path: path.Join("backups", index), RawSource: true}
+	if err := svc.Backup(ctx, job); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+
+	drill := config.FireDrillJob{IndexName: index, S3Path: job.S3Path, MaxAge: "2d"}
+	result, err := firedrill.NewService(e.client, e.storage, nil).Drill(ctx, drill)
+	if err != nil {
+		t.Fatalf("fire drill: %v", err)
+	}
+	if result.Expected != 25 || result.Restored != 25 || result.Counted != 25 {
+		t.Errorf("drill expected %d, restored %d, counted %d documents, want 25", result.Expected, result.Restored, result.Counted)
+	}
+
+	resp, _ := e.client.GetClient().Indices.Exists(ctx, opensearchapi.IndicesExistsReq{Indices: []string{result.ScratchIndex}})
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("scratch index %s was not deleted", result.ScratchIndex)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	e := setup(t)
+	now := time.Now()
+	index := fmt.Sprintf("it-cleanup-%d", now.UnixNano())
+
+	var timestamps []time.Time
+	for i := 0; i < 30; i++ {
+		timestamps = append(timestamps, now.AddDate(0, 0, -40).Add(time.Duration(i)*time.Minute))
+	}
+	for i := 0; i < 20; i++ {
+		timestamps = append(timestamps, now.Add(-time.Duration(i)*time.Hour))
+	}
+	e.seed(t, index, timestamps)
+
+	svc, err := cleanup.New(cleanup.Options{Client: e.client, Clock: clock.Fixed(now)})
+	if err != nil {
+		t.Fatalf("create cleanup service: %v", err)
+	}
+
+	t.Run("safety guard", func(t *testing.T) {
+		job := config.CleanupJob{IndexName: index, RetentionDays: 30, MaxDeleteRatio: 0.1}
+		if err := svc.Cleanup(context.Background(), job); err == nil {
+			t.Fatal("cleanup exceeding max_delete_ratio succeeded")
+		}
+		if got := e.count(t, index); got != 50 {
+			t.Fatalf("guarded cleanup left %d documents, want 50", got)
+		}
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		job := config.CleanupJob{IndexName: index, RetentionDays: 30, Refresh: true}
+		if err := svc.Cleanup(context.Background(), job); err != nil {
+			t.Fatalf("cleanup: %v", err)
+		}
+		if got := e.count(t, index); got != 20 {
+			t.Fatalf("cleanup left %d documents, want 20", got)
+		}
+	})
+}
+
+func TestStateStore(t *testing.T) {
+	e := setup(t)
+	ctx := context.Background()
+	index := fmt.Sprintf("it-state-%d", time.Now().UnixNano())
+
+	store, err := state.Open(ctx, config.StateConfig{Type: "opensearch", Index: index}, e.client)
+	if err != nil {
+		t.Fatalf("open state store: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := store.Get(ctx, state.Runs, "backup/logs/1"); !errors.Is(err, state.ErrNotFound) {
+		t.Fatalf("get of missing key: %v, want ErrNotFound", err)
+	}
+	for _, key := range []string{"backup/logs/1", "backup/logs/2", "backup/audit/1"} {
+		if err := store.Put(ctx, state.Runs, key, []byte(`{"run_id":"`+key+`"}`)); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+	if runs, err := store.List(ctx, state.Runs, "backup/logs/"); err != nil || len(runs) != 2 {
+		t.Fatalf("list backup/logs/: %d runs, %v, want 2", len(runs), err)
+	}
+	if err := store.Delete(ctx, state.Runs, "backup/logs/1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, state.Runs, "backup/logs/1"); !errors.Is(err, state.ErrNotFound) {
+		t.Fatalf("get of deleted key: %v, want ErrNotFound", err)
+	}
+
+	if ok, err := store.Lock(ctx, "backup/logs", "a", time.Minute); err != nil || !ok {
+		t.Fatalf("first lock: %t, %v", ok, err)
+	}
+	if ok, err := store.Lock(ctx, "backup/logs", "b", time.Minute); err != nil || ok {
+		t.Fatalf("lock held by another owner taken: %t, %v", ok, err)
+	}
+	if err := store.Unlock(ctx, "backup/logs", "a"); err != nil {
+		t.Fatalf("unlock: %v", err)
+	}
+	if ok, err := store.Lock(ctx, "backup/logs", "b", time.Minute); err != nil || !ok {
+		t.Fatalf("lock after unlock: %t, %v", ok, err)
+	}
+}